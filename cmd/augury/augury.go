@@ -1,53 +1,120 @@
 package augury
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
+	"github.com/emiliopalmerini/grimorio/internal/chat"
+	"github.com/emiliopalmerini/grimorio/internal/claude"
 	"github.com/emiliopalmerini/grimorio/internal/clipboard"
 	"github.com/emiliopalmerini/grimorio/internal/metrics"
+	"github.com/emiliopalmerini/grimorio/internal/prompt"
 	"github.com/emiliopalmerini/grimorio/internal/spell/augury"
 	"github.com/spf13/cobra"
 )
 
+var (
+	model      string
+	chatMode   bool
+	fix        bool
+	iterations int
+	yes        bool
+	undo       bool
+	noAI       bool
+	pty        bool
+	timeout    time.Duration
+)
+
 var Cmd = &cobra.Command{
 	Use:   "augury [command]",
 	Short: "[Spell] Run a command and analyze errors",
 	Long: `Augury runs a command, captures its output, and analyzes any errors using Claude.
 
+With --fix, Claude writes a patch instead. It is shown, applied once you
+confirm, and the command is run again, until it succeeds or the iterations
+run out. Applied patches are logged in .git/grimorio/fixes; --undo reverts
+the latest one.
+
+With --no-ai, the errors found in the output (file, line, column, message
+and failing test for go, cargo, tsc, eslint, pytest, dotnet and nix) are
+printed as JSON and Claude is not called.
+
+The command's output is shown as it runs. Tools that only color or stream
+their output on a terminal can be run in a pseudo-terminal with --pty;
+escape codes are stripped before anything is sent to Claude. Ctrl+C is
+passed on to the command, and --timeout stops it after a while and analyzes
+what it printed so far.
+
 Examples:
   grimorio augury "go build"
   grimorio augury "npm test"
   grimorio augury "dotnet build"
-  grimorio augury "cargo check"`,
-	Args: cobra.MinimumNArgs(1),
+  grimorio augury "cargo check"
+  grimorio augury --chat "go test ./..."
+  grimorio augury --fix "go test ./..."
+  grimorio augury --undo
+  grimorio augury --pty --timeout 10m "cargo test"
+  grimorio augury --no-ai "cargo check" | jq '.diagnostics[].file'`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if undo {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
 	RunE: runAugury,
 }
 
+func init() {
+	Cmd.Flags().StringVar(&model, "model", string(augury.DefaultModel), "Claude model to use")
+	Cmd.Flags().BoolVar(&chatMode, "chat", false, "Ask follow-up questions after the analysis")
+	Cmd.Flags().BoolVar(&fix, "fix", false, "Apply suggested patches and re-run until the command succeeds")
+	Cmd.Flags().IntVar(&iterations, "iterations", augury.DefaultIterations, "Maximum number of patches with --fix")
+	Cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Apply patches without asking")
+	Cmd.Flags().BoolVar(&undo, "undo", false, "Revert the last patch applied by --fix")
+	Cmd.Flags().BoolVar(&noAI, "no-ai", false, "Print the errors found in the output as JSON, without calling Claude")
+	Cmd.Flags().BoolVar(&pty, "pty", false, "Run the command in a pseudo-terminal")
+	Cmd.Flags().DurationVar(&timeout, "timeout", 0, "Stop the command after this long (e.g. 90s, 10m)")
+	Cmd.MarkFlagsMutuallyExclusive("chat", "fix", "undo", "no-ai")
+}
+
 func runAugury(cmd *cobra.Command, args []string) error {
-	return metrics.Track("augury", metrics.Spell, "", func() error {
+	flags, _ := json.Marshal(map[string]any{"model": model, "chat": chatMode, "fix": fix, "iterations": iterations, "undo": undo, "no_ai": noAI, "pty": pty, "timeout": timeout.String()})
+	return metrics.Track("augury", metrics.Spell, string(flags), func() error {
+		if undo {
+			return runUndo()
+		}
+
 		command := strings.Join(args, " ")
+		if noAI {
+			return runNoAI(command)
+		}
+		if fix {
+			return runFix(command)
+		}
 
-		fmt.Printf("Running: %s\n\n", command)
-		result, err := augury.RunCommand(command)
+		result, err := run(command)
 		if err != nil {
 			return err
 		}
 
-		if result.Stdout != "" {
-			fmt.Println(result.Stdout)
-		}
-		if result.Stderr != "" {
-			fmt.Println(result.Stderr)
-		}
-
 		if result.ExitCode == 0 && result.Stderr == "" {
 			fmt.Println("Command succeeded.")
 			return nil
 		}
 
 		fmt.Println("\nReading the augury...")
-		analysis, err := augury.Analyze(result)
+		if chatMode {
+			text, err := augury.Prompt(claude.Model(model), result)
+			if err != nil {
+				return err
+			}
+			return chat.Start(claude.NewSession(claude.Model(model), "augury", prompt.Transcript), text, os.Stdin, os.Stdout)
+		}
+
+		analysis, err := augury.Analyze(claude.Model(model), result)
 		if err != nil {
 			return err
 		}
@@ -60,3 +127,53 @@ func runAugury(cmd *cobra.Command, args []string) error {
 		return nil
 	})
 }
+
+// run runs command, showing its output as it goes. A command stopped with
+// Ctrl+C is not analyzed.
+func run(command string) (*augury.Result, error) {
+	fmt.Printf("Running: %s\n\n", command)
+	result, err := augury.RunCommand(command, augury.RunOptions{
+		Stdout:  os.Stdout,
+		Stderr:  os.Stderr,
+		PTY:     pty,
+		Timeout: timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Interrupted {
+		return nil, fmt.Errorf("%s was interrupted", command)
+	}
+	if result.TimedOut {
+		fmt.Printf("\nStopped after %s.\n", timeout)
+	}
+	return result, nil
+}
+
+// runNoAI prints the errors parsed from the output of command as JSON. The
+// command's own output is not echoed, so stdout stays valid JSON.
+func runNoAI(command string) error {
+	result, err := augury.RunCommand(command, augury.RunOptions{PTY: pty, Timeout: timeout})
+	if err != nil {
+		return err
+	}
+	if result.Interrupted {
+		return fmt.Errorf("%s was interrupted", command)
+	}
+
+	diagnostics := augury.ParseDiagnostics(result.Stderr + result.Stdout)
+	if diagnostics == nil {
+		diagnostics = []augury.Diagnostic{}
+	}
+	out, err := json.MarshalIndent(struct {
+		Command     string              `json:"command"`
+		ExitCode    int                 `json:"exit_code"`
+		Diagnostics []augury.Diagnostic `json:"diagnostics"`
+	}{result.Command, result.ExitCode, diagnostics}, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
@@ -0,0 +1,100 @@
+package augury
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/emiliopalmerini/grimorio/internal/claude"
+	"github.com/emiliopalmerini/grimorio/internal/spell/augury"
+)
+
+// runFix asks for a patch, applies it on confirmation and runs command
+// again, until it succeeds or the iterations run out. A patch that does not
+// apply, or does not fix the command, is fed back into the next request.
+func runFix(command string) error {
+	result, err := run(command)
+	if err != nil {
+		return err
+	}
+
+	var feedback string
+	applied := 0
+	for i := 1; ; i++ {
+		if result.ExitCode == 0 {
+			if applied > 0 {
+				fmt.Printf("Command succeeded after %d patch(es). Undo with: grimorio augury --undo\n", applied)
+			} else {
+				fmt.Println("Command succeeded.")
+			}
+			return nil
+		}
+		if i > iterations {
+			return fmt.Errorf("command still fails after %d fix attempt(s)", iterations)
+		}
+
+		fmt.Printf("\nDivining a fix (%d/%d)...\n", i, iterations)
+		patch, err := augury.SuggestFix(claude.Model(model), result, feedback)
+		if errors.Is(err, augury.ErrNoFix) {
+			return fmt.Errorf("%w: the failure does not look fixable in the code", err)
+		}
+		if err != nil {
+			if patch == "" {
+				return err
+			}
+			fmt.Printf("Rejected the suggested patch: %v\n", err)
+			feedback = fmt.Sprintf("It was rejected: %v\n\n%s", err, patch)
+			continue
+		}
+
+		fmt.Println("\n" + patch)
+		if !yes {
+			apply, err := confirm("Apply this patch? [y/n] ")
+			if err != nil {
+				return err
+			}
+			if !apply {
+				fmt.Println("Patch not applied.")
+				return nil
+			}
+		}
+
+		path, err := augury.ApplyFix(patch)
+		if err != nil {
+			return err
+		}
+		applied++
+		fmt.Printf("Applied %s\n\n", filepath.Base(path))
+
+		feedback = "It applied, but the command still fails.\n\n" + patch
+		if result, err = run(command); err != nil {
+			return err
+		}
+	}
+}
+
+// runUndo reverts the last patch applied by --fix.
+func runUndo() error {
+	path, err := augury.UndoFix()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Reverted %s\n", filepath.Base(path))
+	return nil
+}
+
+func confirm(question string) (bool, error) {
+	fmt.Print(question)
+
+	reader := bufio.NewReader(os.Stdin)
+	input, err := reader.ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+
+	input = strings.TrimSpace(strings.ToLower(input))
+	return input == "y" || input == "yes", nil
+}
@@ -0,0 +1,73 @@
+package breaking
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/emiliopalmerini/grimorio/internal/cantrip/breaking"
+	"github.com/emiliopalmerini/grimorio/internal/git"
+	"github.com/emiliopalmerini/grimorio/internal/metrics"
+	"github.com/spf13/cobra"
+)
+
+var (
+	baseBranch      string
+	includeInternal bool
+	checkOnly       bool
+)
+
+var Cmd = &cobra.Command{
+	Use:   "breaking",
+	Short: "[Cantrip] Detect API-breaking changes in Go packages",
+	Long: `Breaking compares the exported API of every changed Go package between the
+point where the working tree forked from a base revision and the working
+tree, and suggests a semver bump. Packages that fail to load are listed
+as not compared.
+
+Removed, renamed and signature-changed exported identifiers are breaking.
+Internal and main packages are skipped unless --internal is set.
+
+Examples:
+  grimorio breaking
+  grimorio breaking --base main
+  grimorio breaking --base v1.4.0 --check`,
+	RunE: runBreaking,
+}
+
+func init() {
+	Cmd.Flags().StringVarP(&baseBranch, "base", "b", "", "Base revision to compare against (default: auto-detect main/master)")
+	Cmd.Flags().BoolVarP(&includeInternal, "internal", "i", false, "Include internal and main packages")
+	Cmd.Flags().BoolVarP(&checkOnly, "check", "c", false, "Exit 1 if breaking changes are found")
+}
+
+func runBreaking(cmd *cobra.Command, args []string) error {
+	flags, _ := json.Marshal(map[string]any{"base": baseBranch, "internal": includeInternal, "check": checkOnly})
+	return metrics.Track("breaking", metrics.Cantrip, string(flags), func() error {
+		base := baseBranch
+		if base == "" {
+			var err error
+			base, err = git.GetBaseBranch()
+			if err != nil {
+				return err
+			}
+		}
+
+		report, err := breaking.Detect(breaking.Options{
+			Base:            base,
+			IncludeInternal: includeInternal,
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(report.Format())
+
+		if checkOnly && report.HasBreaking() {
+			return fmt.Errorf("breaking changes detected")
+		}
+		if checkOnly && len(report.Errors) > 0 {
+			return fmt.Errorf("%d package(s) could not be compared", len(report.Errors))
+		}
+		return nil
+	})
+}
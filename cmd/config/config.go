@@ -0,0 +1,244 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/emiliopalmerini/grimorio/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var repoScope bool
+
+var Cmd = &cobra.Command{
+	Use:   "config",
+	Short: "[Cantrip] Show and change per-command defaults",
+	Long: `Config shows and changes the defaults of command flags.
+
+Values are layered, each overriding the previous one:
+  1. built-in default
+  2. user config (~/.config/grimorio/config.toml)
+  3. repository config (.grimorio.toml at the repo root)
+  4. environment (GRIMORIO_<COMMAND>_<FLAG>, e.g. GRIMORIO_SENDING_BASE)
+  5. command-line flags
+
+Keys are the command and flag joined by dots, e.g. sending.base,
+conjure.transport or scrying.model.
+
+Examples:
+  grimorio config list
+  grimorio config list sending
+  grimorio config get scrying.model
+  grimorio config set sending.base develop
+  grimorio config set conjure.transport http,grpc --repo`,
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list [command]",
+	Short: "List effective values and where they come from",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runList,
+}
+
+var getCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Show the effective value of a key",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runGet,
+}
+
+var setCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set a default in the user or repository config",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runSet,
+}
+
+func init() {
+	setCmd.Flags().BoolVar(&repoScope, "repo", false, "Write to the repository config instead of the user config")
+
+	Cmd.AddCommand(listCmd)
+	Cmd.AddCommand(getCmd)
+	Cmd.AddCommand(setCmd)
+}
+
+// Key returns the settings key of a flag defined on cmd.
+func Key(cmd *cobra.Command, name string) string {
+	var path []string
+	for c := cmd; c.HasParent(); c = c.Parent() {
+		path = append([]string{c.Name()}, path...)
+	}
+	return strings.Join(append(path, name), ".")
+}
+
+// Flags returns the flags cmd accepts by settings key, including the
+// persistent flags it inherits.
+func Flags(cmd *cobra.Command) map[string]*pflag.Flag {
+	flags := make(map[string]*pflag.Flag)
+	for c := cmd; c != nil; c = c.Parent() {
+		fs := c.PersistentFlags()
+		if c == cmd {
+			fs = c.LocalFlags()
+		}
+		fs.VisitAll(func(f *pflag.Flag) {
+			if f.Name != "help" {
+				flags[Key(c, f.Name)] = f
+			}
+		})
+	}
+	return flags
+}
+
+// ApplyDefaults sets the flags of cmd that were not passed on the command
+// line from the settings.
+func ApplyDefaults(cmd *cobra.Command, settings *config.Settings) error {
+	for key, f := range Flags(cmd) {
+		if f.Changed {
+			continue
+		}
+		s, ok := settings.Lookup(key)
+		if !ok {
+			continue
+		}
+		if err := f.Value.Set(s.Value); err != nil {
+			return fmt.Errorf("invalid %s %q from %s: %w", key, s.Value, s.Origin, err)
+		}
+	}
+	return nil
+}
+
+// allFlags returns the flags defined by every command in the tree.
+func allFlags(root *cobra.Command) map[string]*pflag.Flag {
+	flags := make(map[string]*pflag.Flag)
+	var walk func(c *cobra.Command)
+	walk = func(c *cobra.Command) {
+		c.LocalFlags().VisitAll(func(f *pflag.Flag) {
+			if f.Name != "help" {
+				flags[Key(c, f.Name)] = f
+			}
+		})
+		for _, sub := range c.Commands() {
+			if sub.Name() != "help" && sub.Name() != "completion" && sub != Cmd {
+				walk(sub)
+			}
+		}
+	}
+	walk(root)
+	return flags
+}
+
+// effective resolves a key through the settings, falling back to the
+// flag's built-in default.
+func effective(settings *config.Settings, key string, f *pflag.Flag) config.Setting {
+	if s, ok := settings.Lookup(key); ok {
+		return s
+	}
+	value := f.DefValue
+	if t := f.Value.Type(); t == "stringSlice" || t == "stringArray" {
+		value = strings.Trim(value, "[]")
+	}
+	return config.Setting{Value: value, Source: config.SourceDefault}
+}
+
+func printSetting(key string, s config.Setting) {
+	source := string(s.Source)
+	if s.Origin != "" {
+		source += ": " + s.Origin
+	}
+	fmt.Printf("%s = %s  (%s)\n", key, s.Value, source)
+}
+
+func runList(cmd *cobra.Command, args []string) error {
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return err
+	}
+
+	flags := allFlags(cmd.Root())
+	keys := make([]string, 0, len(flags))
+	for key := range flags {
+		if len(args) == 0 || strings.HasPrefix(key, args[0]+".") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		printSetting(key, effective(settings, key, flags[key]))
+	}
+
+	for _, key := range settings.Keys() {
+		if _, ok := flags[key]; !ok {
+			s, _ := settings.Lookup(key)
+			fmt.Printf("warning: %s in %s is not a known flag\n", key, s.Origin)
+		}
+	}
+	return nil
+}
+
+func runGet(cmd *cobra.Command, args []string) error {
+	key := args[0]
+	f, ok := allFlags(cmd.Root())[key]
+	if !ok {
+		return fmt.Errorf("unknown key %q, see grimorio config list", key)
+	}
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return err
+	}
+	printSetting(key, effective(settings, key, f))
+	return nil
+}
+
+func runSet(cmd *cobra.Command, args []string) error {
+	key, raw := args[0], args[1]
+	f, ok := allFlags(cmd.Root())[key]
+	if !ok {
+		return fmt.Errorf("unknown key %q, see grimorio config list", key)
+	}
+
+	value, err := parseValue(f, raw)
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+
+	path, err := config.UserPath()
+	if repoScope {
+		path, err = config.RepoPath()
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := config.SetValue(path, key, value); err != nil {
+		return err
+	}
+	fmt.Printf("Set %s = %s in %s\n", key, raw, path)
+	return nil
+}
+
+// parseValue converts raw to the TOML type matching the flag.
+func parseValue(f *pflag.Flag, raw string) (any, error) {
+	switch f.Value.Type() {
+	case "bool":
+		return strconv.ParseBool(raw)
+	case "int", "int64", "int32", "count":
+		return strconv.ParseInt(raw, 10, 64)
+	case "float64", "float32":
+		return strconv.ParseFloat(raw, 64)
+	case "stringSlice", "stringArray":
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items, nil
+	default:
+		return raw, nil
+	}
+}
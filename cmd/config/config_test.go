@@ -0,0 +1,123 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/emiliopalmerini/grimorio/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func testTree() (root, sub, leaf *cobra.Command) {
+	root = &cobra.Command{Use: "grimorio"}
+	root.PersistentFlags().Bool("no-cache", false, "")
+
+	sub = &cobra.Command{Use: "diff"}
+	leaf = &cobra.Command{Use: "explain", Run: func(*cobra.Command, []string) {}}
+	leaf.Flags().StringP("command", "c", "modify-memory", "")
+	leaf.Flags().StringSlice("transport", []string{"http"}, "")
+	leaf.Flags().String("base", "", "")
+
+	sub.AddCommand(leaf)
+	root.AddCommand(sub)
+	return root, sub, leaf
+}
+
+func TestKeyAndFlags(t *testing.T) {
+	root, _, leaf := testTree()
+	if err := leaf.ParseFlags(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := Key(leaf, "command"); got != "diff.explain.command" {
+		t.Errorf("Key() = %q, want diff.explain.command", got)
+	}
+	if got := Key(root, "no-cache"); got != "no-cache" {
+		t.Errorf("Key(root) = %q, want no-cache", got)
+	}
+
+	flags := Flags(leaf)
+	for _, key := range []string{"no-cache", "diff.explain.command", "diff.explain.transport", "diff.explain.base"} {
+		if _, ok := flags[key]; !ok {
+			t.Errorf("Flags() missing %q, got %v", key, flags)
+		}
+	}
+	if _, ok := flags["diff.explain.help"]; ok {
+		t.Error("Flags() should skip help")
+	}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	content := `[commands]
+no-cache = true
+
+[commands.diff.explain]
+command = "scrying"
+transport = ["http", "grpc"]
+base = "develop"
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	settings, err := config.NewSettings(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GRIMORIO_DIFF_EXPLAIN_BASE", "trunk")
+
+	root, _, leaf := testTree()
+	root.SetArgs([]string{"diff", "explain", "-c", "sending"})
+	root.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		return ApplyDefaults(cmd, settings)
+	}
+	if err := root.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	get := func(name string) string {
+		t.Helper()
+		f := leaf.Flags().Lookup(name)
+		if f == nil {
+			t.Fatalf("flag %s not found", name)
+		}
+		return f.Value.String()
+	}
+
+	if got := get("command"); got != "sending" {
+		t.Errorf("command = %q, want the flag value sending", got)
+	}
+	if got := get("transport"); got != "[http,grpc]" {
+		t.Errorf("transport = %q, want [http,grpc]", got)
+	}
+	if got := get("base"); got != "trunk" {
+		t.Errorf("base = %q, want the env value trunk", got)
+	}
+	if got := get("no-cache"); got != "true" {
+		t.Errorf("no-cache = %q, want true", got)
+	}
+}
+
+func TestApplyDefaultsInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("[commands]\nno-cache = \"sometimes\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	settings, err := config.NewSettings(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root, _, _ := testTree()
+	root.SetArgs([]string{"diff", "explain"})
+	root.SilenceErrors = true
+	root.SilenceUsage = true
+	root.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		return ApplyDefaults(cmd, settings)
+	}
+	if err := root.Execute(); err == nil {
+		t.Error("Execute() expected error for invalid bool")
+	}
+}
@@ -0,0 +1,194 @@
+package custom
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/emiliopalmerini/grimorio/internal/claude"
+	"github.com/emiliopalmerini/grimorio/internal/clipboard"
+	"github.com/emiliopalmerini/grimorio/internal/editor"
+	"github.com/emiliopalmerini/grimorio/internal/metrics"
+	"github.com/emiliopalmerini/grimorio/internal/prepare"
+	"github.com/emiliopalmerini/grimorio/internal/spell/custom"
+	"github.com/spf13/cobra"
+)
+
+// Register adds a subcommand to root for every spell defined in the
+// repository. Invalid definitions and names taken by built-in commands are
+// reported on stderr and skipped, so one bad file does not break grimorio.
+func Register(root *cobra.Command) {
+	defs, err := custom.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: skipping invalid spells:\n%v\n", err)
+	}
+
+	for _, def := range defs {
+		if builtin(root, def.Name) {
+			fmt.Fprintf(os.Stderr, "warning: spell %q in %s is shadowed by a built-in command\n", def.Name, def.Path)
+			continue
+		}
+		root.AddCommand(Command(def))
+	}
+}
+
+func builtin(root *cobra.Command, name string) bool {
+	for _, c := range root.Commands() {
+		if c.Name() == name || c.HasAlias(name) {
+			return true
+		}
+	}
+	return name == "help" || name == "completion"
+}
+
+// Command builds the cobra command casting def.
+func Command(def *custom.Definition) *cobra.Command {
+	var (
+		model       string
+		all         bool
+		base        string
+		description string
+	)
+
+	short := def.Description
+	if short == "" {
+		short = "User-defined spell"
+	}
+
+	cmd := &cobra.Command{
+		Use:   use(def),
+		Short: "[Spell] " + short,
+		Long: fmt.Sprintf(`%s
+
+Defined in %s.`, short, def.Path),
+		Args: args(def),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags, _ := json.Marshal(map[string]any{"model": model, "all": all, "base": base, "description": description})
+			return metrics.Track(def.Name, metrics.Spell, string(flags), func() error {
+				fmt.Fprintf(os.Stderr, "Casting %s...\n", def.Name)
+				response, err := def.Cast(custom.Options{
+					Model:       claude.Model(model),
+					All:         all,
+					Base:        base,
+					Description: description,
+					Args:        args,
+					Stdin:       stdin(),
+				})
+				if err != nil {
+					return err
+				}
+				return deliver(def, response)
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&model, "model", def.Model, "Claude model to use")
+	cmd.Flags().StringVarP(&description, "description", "m", "", "Additional context for the prompt")
+	if def.Has(custom.StagedDiff) {
+		cmd.Flags().BoolVarP(&all, "all", "a", false, "Include all changes, not just staged")
+	}
+	if def.Has(custom.BranchDiff) {
+		cmd.Flags().StringVarP(&base, "base", "b", "", "Base branch to compare against (default: auto-detect main/master)")
+	}
+	if def.Has(custom.CommandOutput) {
+		// Flags after the command belong to it, not to grimorio
+		cmd.Flags().SetInterspersed(false)
+	}
+	return cmd
+}
+
+// stdin returns standard input when it is piped, nil on a terminal.
+func stdin() io.Reader {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice != 0 {
+		return nil
+	}
+	return os.Stdin
+}
+
+// Skill describes def for prepare, which installs it as a Claude Code skill.
+func Skill(def *custom.Definition) prepare.Command {
+	short := def.Description
+	if short == "" {
+		short = "Run the " + def.Name + " spell"
+	}
+
+	reads := "no input"
+	if len(def.Inputs) > 0 {
+		parts := make([]string, len(def.Inputs))
+		for i, in := range def.Inputs {
+			parts[i] = string(in)
+		}
+		reads = strings.Join(parts, ", ")
+	}
+
+	return prepare.Command{
+		Name:        def.Name,
+		Type:        prepare.Spell,
+		Short:       short,
+		Description: fmt.Sprintf("%s is a project spell defined in %s. It reads %s and asks Claude (%s) to respond.", def.Name, def.Path, reads, def.Model),
+		Usage:       "grimorio " + use(def),
+	}
+}
+
+func use(def *custom.Definition) string {
+	switch {
+	case def.Has(custom.File):
+		return def.Name + " <file>"
+	case def.Has(custom.CommandOutput):
+		return def.Name + " <command>"
+	default:
+		return def.Name
+	}
+}
+
+func args(def *custom.Definition) cobra.PositionalArgs {
+	switch {
+	case def.Has(custom.File):
+		return cobra.ExactArgs(1)
+	case def.Has(custom.CommandOutput):
+		return cobra.MinimumNArgs(1)
+	default:
+		return cobra.NoArgs
+	}
+}
+
+// deliver sends the response to the outputs of def, editing it first when
+// the editor output is set.
+func deliver(def *custom.Definition, response string) error {
+	if def.Writes(custom.Editor) {
+		edited, err := editor.Edit(response, def.Name+"-*.md")
+		if err != nil {
+			return err
+		}
+		response = edited
+	}
+
+	if def.Writes(custom.Print) {
+		fmt.Println(response)
+	}
+
+	if def.Writes(custom.WriteFile) {
+		path, err := def.OutputPath()
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(strings.TrimRight(response, "\n")+"\n"), 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s\n", path)
+	}
+
+	if def.Writes(custom.Clipboard) {
+		if err := clipboard.Copy(response); err == nil {
+			fmt.Println("(Copied to clipboard)")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/emiliopalmerini/grimorio/internal/claude"
+	"github.com/emiliopalmerini/grimorio/internal/config"
+	"github.com/emiliopalmerini/grimorio/internal/diff"
+	"github.com/emiliopalmerini/grimorio/internal/git"
+	"github.com/emiliopalmerini/grimorio/internal/metrics"
+	"github.com/emiliopalmerini/grimorio/internal/prompt"
+	"github.com/emiliopalmerini/grimorio/internal/spell/memory"
+	"github.com/emiliopalmerini/grimorio/internal/spell/scrying"
+	"github.com/emiliopalmerini/grimorio/internal/spell/sending"
+	"github.com/spf13/cobra"
+)
+
+var (
+	allChanges bool
+	baseBranch string
+	command    string
+)
+
+// commandModels maps the spells that prioritize diffs to their default
+// model, which decides the diff token budget.
+var commandModels = map[string]claude.Model{
+	"modify-memory": memory.DefaultModel,
+	"sending":       sending.DefaultModel,
+	"scrying":       scrying.DefaultModel,
+}
+
+var Cmd = &cobra.Command{
+	Use:   "diff",
+	Short: "[Cantrip] Inspect how diffs are prioritized for spells",
+	Long: `Diff inspects the prioritization applied to diffs before they are sent to Claude.
+
+Scoring is tuned with the [scoring] section of .grimorio.toml at the repository root:
+
+  [scoring.paths]
+  "migrations/**" = "high"       # always keep in full
+  "*.pb.go" = "generated"        # treat as a category
+  "docs/**" = "ignore"           # only mention in the summary
+
+  [scoring.multipliers]
+  test = 0.8
+
+  [scoring.weights]
+  function = 120
+  exported = 40
+
+  [scoring.budgets]
+  scrying = 250`,
+}
+
+var explainCmd = &cobra.Command{
+	Use:   "explain",
+	Short: "Print each hunk's score breakdown",
+	Long: `Explain scores the staged diff (or a branch diff with --base) and prints every hunk
+in priority order with the breakdown of its score.
+
+Examples:
+  grimorio diff explain
+  grimorio diff explain -a
+  grimorio diff explain --base main --command sending`,
+	RunE: runExplain,
+}
+
+func init() {
+	explainCmd.Flags().BoolVarP(&allChanges, "all", "a", false, "Include all changes, not just staged")
+	explainCmd.Flags().StringVarP(&baseBranch, "base", "b", "", "Explain the branch diff against this base instead")
+	explainCmd.Flags().StringVarP(&command, "command", "c", "modify-memory", "Command whose budget to apply")
+	Cmd.AddCommand(explainCmd)
+}
+
+func runExplain(cmd *cobra.Command, args []string) error {
+	flags, _ := json.Marshal(map[string]any{"all": allChanges, "base": baseBranch, "command": command})
+	return metrics.Track("diff-explain", metrics.Cantrip, string(flags), func() error {
+		var rawDiff string
+		var err error
+		if baseBranch != "" {
+			rawDiff, err = git.GetBranchDiff(baseBranch, 0)
+		} else {
+			rawDiff, err = git.GetDiff(git.DiffOptions{All: allChanges})
+		}
+		if err != nil {
+			return err
+		}
+
+		profile, err := diff.LoadProfile()
+		if err != nil {
+			return err
+		}
+
+		model, ok := commandModels[command]
+		if !ok {
+			return fmt.Errorf("unknown command %q (expected modify-memory, sending or scrying)", command)
+		}
+		settings, err := config.LoadSettings()
+		if err != nil {
+			return err
+		}
+		if s, ok := settings.Lookup(command + ".model"); ok {
+			model = claude.Model(s.Value)
+		}
+
+		opts := diff.DefaultOptions()
+		opts.Profile = profile
+		opts.MaxHighPriorityLines = profile.Budget(command, 0)
+		if opts.MaxHighPriorityTokens, err = prompt.DiffBudget(model); err != nil {
+			return err
+		}
+		if baseBranch != "" {
+			fork, err := git.MergeBase(baseBranch, "HEAD")
+			if err != nil {
+				return err
+			}
+			opts.BaseRev = fork
+			opts.NewRev = "HEAD"
+		} else if !allChanges {
+			opts.NewRev = diff.IndexRev
+		}
+
+		budget := fmt.Sprintf("%d tokens", opts.MaxHighPriorityTokens)
+		if opts.MaxHighPriorityLines > 0 {
+			budget += fmt.Sprintf(", %d lines", opts.MaxHighPriorityLines)
+		}
+		fmt.Printf("Budget: %s (%s on %s), %d path rule(s)\n\n", budget, command, model, len(profile.Rules))
+
+		for _, e := range diff.Explain(rawDiff, opts) {
+			printExplanation(e)
+		}
+		return nil
+	})
+}
+
+func printExplanation(e diff.HunkExplanation) {
+	h := e.Hunk
+	b := h.Breakdown
+
+	priority := "low "
+	if e.HighPriority {
+		priority = "HIGH"
+	}
+	header := strings.SplitN(h.Content, "\n", 2)[0]
+	fmt.Printf("%s %7.1f  %s %s\n", priority, h.Score, h.FilePath, header)
+
+	symbol := "no symbol"
+	if b.Symbol != "" {
+		symbol = fmt.Sprintf("%s [%s]", b.Symbol, b.SymbolKind)
+	}
+	fmt.Printf("             %s: weight %.0f + exported %.0f + size %.1f + signature %.0f, x%.2f (%s)\n",
+		symbol, b.SymbolWeight, b.ExportedBonus, b.SizeBonus, b.SignatureBonus, b.Multiplier, b.Category)
+
+	var notes []string
+	if b.Rule != "" {
+		notes = append(notes, "rule "+b.Rule)
+	}
+	if b.Pinned {
+		notes = append(notes, "pinned")
+	}
+	if b.Ignored {
+		notes = append(notes, "ignored")
+	}
+	if h.Breaking {
+		notes = append(notes, "breaking")
+	}
+	if len(notes) > 0 {
+		fmt.Printf("             %s\n", strings.Join(notes, ", "))
+	}
+}
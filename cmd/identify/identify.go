@@ -3,36 +3,61 @@ package identify
 import (
 	"encoding/json"
 	"fmt"
+	"os"
 
+	"github.com/emiliopalmerini/grimorio/internal/chat"
+	"github.com/emiliopalmerini/grimorio/internal/claude"
 	"github.com/emiliopalmerini/grimorio/internal/clipboard"
 	"github.com/emiliopalmerini/grimorio/internal/metrics"
+	"github.com/emiliopalmerini/grimorio/internal/prompt"
 	"github.com/emiliopalmerini/grimorio/internal/spell/identify"
 	"github.com/spf13/cobra"
 )
 
-var symbol string
+var (
+	symbol   string
+	model    string
+	chatMode bool
+)
 
 var Cmd = &cobra.Command{
-	Use:   "identify [file]",
+	Use:   "identify [file|directory]",
 	Short: "[Spell] Explain code in plain language",
 	Long: `Identify reads a file and explains its code using Claude.
 
+With --symbol, the language server resolves the symbol in the file or
+the workspace, and only its source is sent, with its type information, the
+types it uses and a sample of its call sites.
+
+Given a directory, it explains the package as a whole: its purpose, API
+and architecture, then each file. Files are ranked by their exported API
+and how widely their types are used, according to the language server when
+one is installed, and the most important are sent in full.
+
 Examples:
   grimorio identify main.go
+  grimorio identify ./internal/diff
   grimorio identify internal/auth/auth.go
-  grimorio identify handler.go --symbol HandleLogin`,
+  grimorio identify handler.go --symbol HandleLogin
+  grimorio identify client.go --symbol Client.Close
+  grimorio identify parser.go --chat`,
 	Args: cobra.ExactArgs(1),
 	RunE: runIdentify,
 }
 
 func init() {
-	Cmd.Flags().StringVarP(&symbol, "symbol", "s", "", "Focus on a specific function/type")
+	Cmd.Flags().StringVarP(&symbol, "symbol", "s", "", "Explain a specific function/type, sending only its source and context")
+	Cmd.Flags().StringVar(&model, "model", string(identify.DefaultModel), "Claude model to use")
+	Cmd.Flags().BoolVar(&chatMode, "chat", false, "Ask follow-up questions after the explanation")
 }
 
 func runIdentify(cmd *cobra.Command, args []string) error {
-	flags, _ := json.Marshal(map[string]any{"symbol": symbol})
+	flags, _ := json.Marshal(map[string]any{"symbol": symbol, "model": model, "chat": chatMode})
 	return metrics.Track("identify", metrics.Spell, string(flags), func() error {
 		path := args[0]
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			return identifyPackage(path)
+		}
 
 		content, err := identify.ReadFile(path)
 		if err != nil {
@@ -40,8 +65,29 @@ func runIdentify(cmd *cobra.Command, args []string) error {
 		}
 
 		fmt.Println("Identifying the code...")
-		lspContext := identify.GetLSPContext(path, content)
-		explanation, err := identify.Explain(content, symbol, lspContext)
+		var lspContext string
+		if symbol != "" {
+			// Send only the symbol and what it touches, if the language
+			// server can find it
+			sym, err := identify.ResolveSymbol(path, content, symbol)
+			if err == nil {
+				content, lspContext = sym.Source, sym.Context()
+			} else {
+				fmt.Printf("Could not resolve %s (%v), sending the whole file.\n", symbol, err)
+			}
+		}
+		if lspContext == "" {
+			lspContext = identify.GetLSPContext(path, content)
+		}
+		if chatMode {
+			text, err := identify.Prompt(claude.Model(model), content, symbol, lspContext)
+			if err != nil {
+				return err
+			}
+			return chat.Start(claude.NewSession(claude.Model(model), "identify", prompt.Transcript), text, os.Stdin, os.Stdout)
+		}
+
+		explanation, err := identify.Explain(claude.Model(model), content, symbol, lspContext)
 		if err != nil {
 			return err
 		}
@@ -54,3 +100,32 @@ func runIdentify(cmd *cobra.Command, args []string) error {
 		return nil
 	})
 }
+
+// identifyPackage explains the package in dir.
+func identifyPackage(dir string) error {
+	fmt.Println("Identifying the package...")
+	pkg, err := identify.ReadPackage(dir)
+	if err != nil {
+		return err
+	}
+
+	if chatMode {
+		text, err := identify.PackagePrompt(claude.Model(model), pkg, symbol)
+		if err != nil {
+			return err
+		}
+		return chat.Start(claude.NewSession(claude.Model(model), "identify", prompt.Transcript), text, os.Stdin, os.Stdout)
+	}
+
+	explanation, err := identify.ExplainPackage(claude.Model(model), pkg, symbol)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(explanation)
+
+	if err := clipboard.Copy(explanation); err == nil {
+		fmt.Println("\n(Copied to clipboard)")
+	}
+	return nil
+}
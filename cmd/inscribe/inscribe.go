@@ -0,0 +1,153 @@
+package inscribe
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/emiliopalmerini/grimorio/internal/cantrip/mending"
+	"github.com/emiliopalmerini/grimorio/internal/claude"
+	"github.com/emiliopalmerini/grimorio/internal/metrics"
+	"github.com/emiliopalmerini/grimorio/internal/spell/inscribe"
+	"github.com/emiliopalmerini/grimorio/internal/textutil"
+	"github.com/spf13/cobra"
+)
+
+var (
+	model     string
+	checkOnly bool
+	dryRun    bool
+	yes       bool
+)
+
+// stdin is shared by the confirmations, so piped answers are not lost to
+// a reader's buffer between files.
+var stdin = bufio.NewReader(os.Stdin)
+
+var Cmd = &cobra.Command{
+	Use:   "inscribe [files...]",
+	Short: "[Spell] Write doc comments for undocumented exported symbols",
+	Long: `Inscribe finds exported symbols without doc comments and writes them using Claude.
+
+Go files are parsed directly; Python, Rust, C# and TypeScript files need
+their language server to list symbols. Comments follow the conventions of
+each language and are inserted into the files. Each file's changes are
+shown as a diff and written once you confirm. Test files are skipped.
+
+With --check, inscribe only lists the symbols missing doc comments and
+exits 1 if there are any, without calling Claude.
+
+Examples:
+  grimorio inscribe ./internal/...
+  grimorio inscribe handler.go --dry-run
+  grimorio inscribe --check ./...
+  grimorio inscribe --yes ./internal/diff`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runInscribe,
+}
+
+func init() {
+	Cmd.Flags().StringVar(&model, "model", string(inscribe.DefaultModel), "Claude model to use")
+	Cmd.Flags().BoolVarP(&checkOnly, "check", "c", false, "List symbols missing doc comments (exit 1 if any)")
+	Cmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Show the diff without writing files")
+	Cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Write comments without asking")
+	Cmd.MarkFlagsMutuallyExclusive("check", "dry-run", "yes")
+}
+
+func runInscribe(cmd *cobra.Command, args []string) error {
+	flags, _ := json.Marshal(map[string]any{"model": model, "check": checkOnly, "dry_run": dryRun, "yes": yes})
+	return metrics.Track("inscribe", metrics.Spell, string(flags), func() error {
+		paths, err := mending.ExpandPaths(args)
+		if err != nil {
+			return err
+		}
+
+		var files []*inscribe.File
+		var hasErrors bool
+		for _, path := range paths {
+			if !inscribe.Supported(path) {
+				continue
+			}
+			file, err := inscribe.Find(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+				hasErrors = true
+				continue
+			}
+			if len(file.Targets) > 0 {
+				files = append(files, file)
+			}
+		}
+
+		if checkOnly {
+			missing := 0
+			for _, file := range files {
+				for _, t := range file.Targets {
+					fmt.Printf("%s:%d: %s has no doc comment\n", file.Path, t.Line+1, t.Name)
+					missing++
+				}
+			}
+			if missing > 0 {
+				return fmt.Errorf("%d exported symbol(s) without doc comments", missing)
+			}
+			if hasErrors {
+				return fmt.Errorf("some files could not be read")
+			}
+			fmt.Println("All exported symbols are documented.")
+			return nil
+		}
+
+		if len(files) == 0 {
+			fmt.Println("All exported symbols are documented.")
+			return nil
+		}
+
+		for _, file := range files {
+			fmt.Printf("Inscribing %s (%d symbol(s))...\n", file.Path, len(file.Targets))
+			updated, n, err := inscribe.Inscribe(claude.Model(model), file)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error inscribing %s: %v\n", file.Path, err)
+				hasErrors = true
+				continue
+			}
+
+			fmt.Println(textutil.UnifiedDiff(file.Path, file.Content, updated, 3))
+			if dryRun {
+				continue
+			}
+			if !yes {
+				write, err := confirm(fmt.Sprintf("Write %d comment(s) to %s? [y/n] ", n, file.Path))
+				if err != nil {
+					return err
+				}
+				if !write {
+					continue
+				}
+			}
+			if err := os.WriteFile(file.Path, []byte(updated), 0644); err != nil {
+				return fmt.Errorf("failed to write file: %w", err)
+			}
+			fmt.Printf("Inscribed: %s\n", file.Path)
+		}
+
+		if hasErrors {
+			return errors.New("some files could not be inscribed")
+		}
+		return nil
+	})
+}
+
+func confirm(question string) (bool, error) {
+	fmt.Print(question)
+
+	input, err := stdin.ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+
+	input = strings.TrimSpace(strings.ToLower(input))
+	return input == "y" || input == "yes", nil
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/emiliopalmerini/grimorio/internal/claude"
 	"github.com/emiliopalmerini/grimorio/internal/metrics"
 	"github.com/emiliopalmerini/grimorio/internal/spell/memory"
 	"github.com/spf13/cobra"
@@ -13,6 +14,7 @@ var (
 	allChanges bool
 	dryRun     bool
 	motivation string
+	model      string
 )
 
 var Cmd = &cobra.Command{
@@ -34,12 +36,13 @@ func init() {
 	Cmd.Flags().BoolVarP(&allChanges, "all", "a", false, "Include all changes, not just staged")
 	Cmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Just output the message, don't prompt for commit")
 	Cmd.Flags().StringVarP(&motivation, "motivation", "m", "", "Motivation/context for the commit")
+	Cmd.Flags().StringVar(&model, "model", string(memory.DefaultModel), "Claude model to use")
 }
 
 func runModifyMemory(cmd *cobra.Command, args []string) error {
-	flags, _ := json.Marshal(map[string]any{"all": allChanges, "dry-run": dryRun, "motivation": motivation})
+	flags, _ := json.Marshal(map[string]any{"all": allChanges, "dry-run": dryRun, "motivation": motivation, "model": model})
 	return metrics.Track("modify-memory", metrics.Spell, string(flags), func() error {
-		diff, err := memory.GetDiff(allChanges)
+		diff, err := memory.GetDiff(allChanges, claude.Model(model))
 		if err != nil {
 			return err
 		}
@@ -47,7 +50,7 @@ func runModifyMemory(cmd *cobra.Command, args []string) error {
 		history, _ := memory.GetRecentCommits(5)
 
 		fmt.Println("Generating commit message...")
-		message, err := memory.GenerateMessage(diff, history, motivation)
+		message, err := memory.GenerateMessage(claude.Model(model), diff, history, motivation)
 		if err != nil {
 			return err
 		}
@@ -15,6 +15,9 @@ var (
 	fromFormat string
 	toFormat   string
 	outputFile string
+	query      string
+	inferTypes bool
+	noStream   bool
 )
 
 var Cmd = &cobra.Command{
@@ -22,10 +25,41 @@ var Cmd = &cobra.Command{
 	Short: "[Cantrip] Transform data between formats",
 	Long: `Polymorph transforms data between different formats.
 
-Supported formats: json, yaml, toml, xml, csv, markdown (md), html
+Supported formats: json, jsonl (ndjson), yaml, toml, xml, csv, tsv, markdown (md),
+html, ini, env (dotenv), hcl (tf), textproto (pbtxt), msgpack, cbor, xlsx
 
 The input format is auto-detected from file extension, or specify with --from.
 Arrays of objects render as tables in markdown/html, single objects as key-value pairs.
+Keys keep the order of the input, and YAML and TOML comments are carried
+between the two.
+
+INI and .env values are read as strings. HCL expressions that are not plain
+values are kept as "${...}" strings and written back as they were. XLSX
+sheets read as arrays of objects keyed by the first row. The binary formats
+(msgpack, cbor, xlsx) must be written to a file with -o.
+
+--query runs a jq-like expression on the data between reading and writing
+it: paths (.a.b, .[0], .[2:5], .[], ..), pipes, commas, [...] and {...}
+construction, arithmetic, comparisons, and/or/not, //, if-then-else, and
+functions such as select, map, sort_by, group_by, unique_by, flatten,
+keys, keys_unsorted, has, length, add, min, max, first, last, to_entries, join, split and
+test. A query that yields several values is written as an array.
+
+Nested objects and arrays are flattened into dotted CSV/TSV columns such
+as address.city and tags.0. --infer-types reads CSV, TSV, XML and markdown
+values as numbers, booleans, nulls, dates and JSON where they look like
+them, and rebuilds nested objects from dotted columns, so a CSV round trip
+keeps its types. TOML local dates and times are written as they were read.
+
+Conversions between record formats (csv, tsv, jsonl) are streamed row by
+row with constant memory, so inputs of any size work, and nested values
+are flattened into dotted columns as without streaming. A jsonl file is read twice, so that the
+CSV/TSV header has the fields of every record. From stdin the header
+comes from the first record, and a later record with another field is an
+error; use --no-stream to load the whole input instead.
+
+The validate and infer-schema subcommands check files against a JSON
+Schema and generate one from samples.
 
 Examples:
   grimorio polymorph data.json --to yaml
@@ -33,6 +67,14 @@ Examples:
   grimorio polymorph config.xml --to json
   grimorio polymorph users.json --to xml
   grimorio polymorph data.json --to csv -o output.csv
+  grimorio polymorph events.jsonl --to csv -o events.csv
+  grimorio polymorph main.tf --to json
+  grimorio polymorph .env --to yaml
+  grimorio polymorph report.xlsx --to csv
+  grimorio polymorph users.csv --infer-types --to toml
+  grimorio polymorph users.yaml --query '.users[] | select(.active) | {name,email}' --to csv
+  grimorio polymorph orders.json -q 'group_by(.customer) | map({customer: .[0].customer, total: map(.amount) | add})' --to yaml
+  grimorio polymorph validate config.yaml --schema config.schema.json
   cat data.json | grimorio polymorph --from json --to yaml`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runPolymorph,
@@ -42,12 +84,25 @@ func init() {
 	Cmd.Flags().StringVarP(&fromFormat, "from", "f", "", "Input format (auto-detected from extension if not specified)")
 	Cmd.Flags().StringVarP(&toFormat, "to", "t", "", "Output format (required)")
 	Cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output file (default: stdout)")
+	Cmd.Flags().StringVarP(&query, "query", "q", "", "jq-like expression to select and transform the data")
+	Cmd.Flags().BoolVar(&inferTypes, "infer-types", false, "Read csv, tsv, xml and markdown values as numbers, booleans, nulls, dates and nested objects")
+	Cmd.Flags().BoolVar(&noStream, "no-stream", false, "Load record formats whole instead of streaming them, so the CSV/TSV header has every field")
 	Cmd.MarkFlagRequired("to")
 }
 
 func runPolymorph(cmd *cobra.Command, args []string) error {
-	flags, _ := json.Marshal(map[string]any{"from": fromFormat, "to": toFormat})
+	flags, _ := json.Marshal(map[string]any{"from": fromFormat, "to": toFormat, "query": query != "", "infer_types": inferTypes, "no_stream": noStream})
 	return metrics.Track("polymorph", metrics.Cantrip, string(flags), func() error {
+		if srcFormat := sourceFormat(args); query == "" && !noStream && polymorph.Streamable(srcFormat, toFormat) {
+			return runStream(args, srcFormat)
+		}
+
+		if outputFile == "" && polymorph.IsBinary(toFormat) {
+			if stat, _ := os.Stdout.Stat(); (stat.Mode() & os.ModeCharDevice) != 0 {
+				return fmt.Errorf("%s output is binary, use -o to write it to a file", toFormat)
+			}
+		}
+
 		var input []byte
 		var err error
 		var inputPath string
@@ -79,7 +134,7 @@ func runPolymorph(cmd *cobra.Command, args []string) error {
 			}
 		}
 
-		result, err := polymorph.Convert(input, srcFormat, toFormat)
+		result, err := polymorph.ConvertWith(input, srcFormat, toFormat, polymorph.Options{Query: query, InferTypes: inferTypes})
 		if err != nil {
 			return fmt.Errorf("polymorph failed: %w", err)
 		}
@@ -96,3 +151,49 @@ func runPolymorph(cmd *cobra.Command, args []string) error {
 		return nil
 	})
 }
+
+// sourceFormat returns --from, or the format of the input file's extension.
+func sourceFormat(args []string) string {
+	if fromFormat == "" && len(args) == 1 {
+		return polymorph.DetectFormat(args[0])
+	}
+	return fromFormat
+}
+
+// runStream converts record formats row by row, never holding the whole
+// input in memory.
+func runStream(args []string, srcFormat string) error {
+	input := os.Stdin
+	if len(args) == 1 {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to read input file: %w", err)
+		}
+		defer f.Close()
+		input = f
+	} else if stat, _ := os.Stdin.Stat(); (stat.Mode() & os.ModeCharDevice) != 0 {
+		return fmt.Errorf("no input file provided and stdin is empty")
+	}
+
+	output := os.Stdout
+	if outputFile != "" {
+		f, err := os.Create(outputFile)
+		if err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
+		}
+		defer f.Close()
+		output = f
+	}
+
+	n, err := polymorph.ConvertStream(input, output, srcFormat, toFormat, polymorph.Options{InferTypes: inferTypes})
+	if err != nil {
+		return fmt.Errorf("polymorph failed: %w", err)
+	}
+	if outputFile != "" {
+		if err := output.Close(); err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
+		}
+		fmt.Printf("Polymorphed %s → %s: %s (%d records)\n", srcFormat, toFormat, outputFile, n)
+	}
+	return nil
+}
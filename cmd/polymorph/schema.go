@@ -0,0 +1,185 @@
+package polymorph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/emiliopalmerini/grimorio/internal/cantrip/polymorph"
+	"github.com/emiliopalmerini/grimorio/internal/metrics"
+	"github.com/spf13/cobra"
+)
+
+var (
+	schemaFile   string
+	schemaFormat string
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate [file...]",
+	Short: "Check files in any format against a JSON Schema",
+	Long: `Validate checks files in any supported format against a JSON Schema
+(draft 2020-12), printing each value that does not match with its JSON
+Pointer path, and exits with an error if any file does not match.
+
+The schema itself can be written in any format, such as JSON or YAML.
+References must point inside the schema. "format" is an annotation and
+is not checked, and patterns use Go's regular expression syntax.
+
+Examples:
+  grimorio polymorph validate config.yaml --schema config.schema.json
+  grimorio polymorph validate deploy/*.toml -s schema.yaml
+  grimorio polymorph validate users.csv --infer-types -s users.schema.json
+  cat data.json | grimorio polymorph validate --from json -s schema.json`,
+	RunE: runValidate,
+}
+
+var inferSchemaCmd = &cobra.Command{
+	Use:   "infer-schema [file...]",
+	Short: "Generate a JSON Schema from sample files",
+	Long: `Infer-schema writes a JSON Schema (draft 2020-12) that all the given
+files match, each read as a sample of the same document. Properties
+present in every object at the same place are required, and strings that
+are all dates, date-times, emails or UUIDs get a format.
+
+Examples:
+  grimorio polymorph infer-schema data.json
+  grimorio polymorph infer-schema staging.yaml production.yaml -o config.schema.json
+  grimorio polymorph infer-schema users.csv --infer-types --to yaml`,
+	RunE: runInferSchema,
+}
+
+func init() {
+	validateCmd.Flags().StringVarP(&schemaFile, "schema", "s", "", "JSON Schema file (required)")
+	validateCmd.Flags().StringVarP(&fromFormat, "from", "f", "", "Input format (auto-detected from extension if not specified)")
+	validateCmd.Flags().BoolVar(&inferTypes, "infer-types", false, "Read csv, tsv, xml and markdown values as numbers, booleans, nulls, dates and nested objects")
+	validateCmd.MarkFlagRequired("schema")
+
+	inferSchemaCmd.Flags().StringVarP(&fromFormat, "from", "f", "", "Input format (auto-detected from extension if not specified)")
+	inferSchemaCmd.Flags().StringVarP(&schemaFormat, "to", "t", "json", "Output format of the schema")
+	inferSchemaCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output file (default: stdout)")
+	inferSchemaCmd.Flags().BoolVar(&inferTypes, "infer-types", false, "Read csv, tsv, xml and markdown values as numbers, booleans, nulls, dates and nested objects")
+
+	Cmd.AddCommand(validateCmd)
+	Cmd.AddCommand(inferSchemaCmd)
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+	flags, _ := json.Marshal(map[string]any{"from": fromFormat, "files": len(args), "infer_types": inferTypes})
+	return metrics.Track("polymorph-validate", metrics.Cantrip, string(flags), func() error {
+		// A file that does not match is a result, not a usage mistake.
+		cmd.SilenceUsage = true
+
+		format := polymorph.DetectFormat(schemaFile)
+		if format == "" {
+			format = "json"
+		}
+		input, err := os.ReadFile(schemaFile)
+		if err != nil {
+			return fmt.Errorf("failed to read schema: %w", err)
+		}
+		data, err := polymorph.Parse(input, format, polymorph.Options{})
+		if err != nil {
+			return fmt.Errorf("invalid schema %s: %w", schemaFile, err)
+		}
+		schema, err := polymorph.CompileSchema(data)
+		if err != nil {
+			return fmt.Errorf("invalid schema %s: %w", schemaFile, err)
+		}
+
+		paths := inputPaths(args)
+		var invalid []string
+		for _, path := range paths {
+			data, name, err := readSample(path)
+			if err != nil {
+				return err
+			}
+			errs := schema.Validate(data)
+			if len(errs) == 0 {
+				fmt.Printf("%s: valid\n", name)
+				continue
+			}
+			invalid = append(invalid, name)
+			for _, e := range errs {
+				fmt.Printf("%s: %s\n", name, e)
+			}
+		}
+		switch {
+		case len(paths) == 1 && len(invalid) == 1:
+			return fmt.Errorf("%s does not match %s", invalid[0], schemaFile)
+		case len(invalid) > 0:
+			return fmt.Errorf("%d of %d files do not match %s", len(invalid), len(paths), schemaFile)
+		}
+		return nil
+	})
+}
+
+func runInferSchema(cmd *cobra.Command, args []string) error {
+	flags, _ := json.Marshal(map[string]any{"from": fromFormat, "to": schemaFormat, "files": len(args), "infer_types": inferTypes})
+	return metrics.Track("polymorph-infer-schema", metrics.Cantrip, string(flags), func() error {
+		var samples []any
+		for _, path := range inputPaths(args) {
+			data, _, err := readSample(path)
+			if err != nil {
+				return err
+			}
+			samples = append(samples, data)
+		}
+
+		result, err := polymorph.Render(polymorph.InferSchema(samples...), schemaFormat)
+		if err != nil {
+			return fmt.Errorf("polymorph failed: %w", err)
+		}
+		if outputFile != "" {
+			if err := os.WriteFile(outputFile, result, 0644); err != nil {
+				return fmt.Errorf("failed to write output file: %w", err)
+			}
+			fmt.Printf("Inferred schema from %d samples: %s\n", len(samples), outputFile)
+		} else {
+			fmt.Print(string(result))
+		}
+		return nil
+	})
+}
+
+// inputPaths returns the files to read, "-" standing for stdin when none
+// is given.
+func inputPaths(args []string) []string {
+	if len(args) == 0 {
+		return []string{"-"}
+	}
+	return args
+}
+
+// readSample reads and parses a file, or stdin for "-", returning its data
+// and the name to report it by.
+func readSample(path string) (any, string, error) {
+	var input []byte
+	var err error
+	name := path
+	if path == "-" {
+		name = "stdin"
+		if stat, _ := os.Stdin.Stat(); (stat.Mode() & os.ModeCharDevice) != 0 {
+			return nil, "", fmt.Errorf("no input file provided and stdin is empty")
+		}
+		if input, err = io.ReadAll(os.Stdin); err != nil {
+			return nil, "", fmt.Errorf("failed to read stdin: %w", err)
+		}
+	} else if input, err = os.ReadFile(path); err != nil {
+		return nil, "", fmt.Errorf("failed to read input file: %w", err)
+	}
+
+	format := fromFormat
+	if format == "" && path != "-" {
+		format = polymorph.DetectFormat(path)
+	}
+	if format == "" {
+		return nil, "", fmt.Errorf("cannot detect the format of %s, use --from to specify", name)
+	}
+	data, err := polymorph.Parse(input, format, polymorph.Options{InferTypes: inferTypes})
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", name, err)
+	}
+	return data, name, nil
+}
@@ -3,7 +3,9 @@ package prepare
 import (
 	"fmt"
 
+	customcmd "github.com/emiliopalmerini/grimorio/cmd/custom"
 	"github.com/emiliopalmerini/grimorio/internal/prepare"
+	"github.com/emiliopalmerini/grimorio/internal/spell/custom"
 	"github.com/spf13/cobra"
 )
 
@@ -46,6 +48,12 @@ func runPrepare(cmd *cobra.Command, args []string) error {
 	}
 
 	if project {
+		// Spells defined in the repository only make sense in its skills
+		defs, _ := custom.Load()
+		for _, def := range defs {
+			commands = append(commands, customcmd.Skill(def))
+		}
+
 		fmt.Println("Installing skills to project directory...")
 		if err := prepare.Install(prepare.Project, commands); err != nil {
 			return err
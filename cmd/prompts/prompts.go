@@ -0,0 +1,88 @@
+package prompts
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/emiliopalmerini/grimorio/internal/prompt"
+	"github.com/spf13/cobra"
+)
+
+var builtinOnly bool
+
+var Cmd = &cobra.Command{
+	Use:   "prompts",
+	Short: "[Cantrip] Inspect the prompt templates used by spells",
+	Long: `Prompts shows the templates spells fill before calling Claude.
+
+Built-in templates can be overridden per repository by a text/template file
+at .grimorio/prompts/<spell>.tmpl. Overrides are validated when loaded.
+
+Variables: {{.Diff}}, {{.Commits}}, {{.History}}, {{.Description}},
+{{.LSPContext}}, {{.APIChanges}}, {{.Code}}, {{.Path}}, {{.Symbol}},
+{{.Command}}, {{.ExitCode}}, {{.Stdout}}, {{.Stderr}}, {{.Stdin}},
+{{.Errors}}, {{.Docs}}, {{.Language}}, {{.Symbols}}, {{.Tests}},
+{{.TestPath}}. Large variables are trimmed to the model's token budget
+before rendering.
+
+Examples:
+  grimorio prompts list
+  grimorio prompts show scrying
+  grimorio prompts show sending --builtin > .grimorio/prompts/sending.tmpl`,
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List spells and where their template comes from",
+	Args:  cobra.NoArgs,
+	RunE:  runList,
+}
+
+var showCmd = &cobra.Command{
+	Use:   "show <spell>",
+	Short: "Print the effective template of a spell",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runShow,
+}
+
+func init() {
+	showCmd.Flags().BoolVarP(&builtinOnly, "builtin", "b", false, "Print the built-in template, ignoring overrides")
+
+	Cmd.AddCommand(listCmd)
+	Cmd.AddCommand(showCmd)
+}
+
+func runList(cmd *cobra.Command, args []string) error {
+	width := 0
+	for _, spell := range prompt.Spells {
+		width = max(width, len(spell))
+	}
+	for _, spell := range prompt.Spells {
+		tmpl, err := prompt.Load(spell)
+		if err != nil {
+			fmt.Printf("%-*s  %v\n", width, spell, err)
+			continue
+		}
+		fmt.Printf("%-*s  %s\n", width, spell, tmpl.Source)
+	}
+	return nil
+}
+
+func runShow(cmd *cobra.Command, args []string) error {
+	load := prompt.Load
+	if builtinOnly {
+		load = prompt.Builtin
+	}
+	tmpl, err := load(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "# %s (%s)\n", tmpl.Spell, tmpl.Source)
+	fmt.Print(tmpl.Text)
+	if !strings.HasSuffix(tmpl.Text, "\n") {
+		fmt.Println()
+	}
+	return nil
+}
@@ -2,28 +2,70 @@ package cmd
 
 import (
 	"os"
+	"time"
 
 	"github.com/emiliopalmerini/grimorio/cmd/augury"
+	"github.com/emiliopalmerini/grimorio/cmd/breaking"
+	configcmd "github.com/emiliopalmerini/grimorio/cmd/config"
 	"github.com/emiliopalmerini/grimorio/cmd/conjure"
+	"github.com/emiliopalmerini/grimorio/cmd/custom"
 	"github.com/emiliopalmerini/grimorio/cmd/dashboard"
+	"github.com/emiliopalmerini/grimorio/cmd/diff"
 	"github.com/emiliopalmerini/grimorio/cmd/identify"
+	"github.com/emiliopalmerini/grimorio/cmd/inscribe"
 	"github.com/emiliopalmerini/grimorio/cmd/mending"
 	modifymemory "github.com/emiliopalmerini/grimorio/cmd/modify-memory"
 	"github.com/emiliopalmerini/grimorio/cmd/polymorph"
 	"github.com/emiliopalmerini/grimorio/cmd/prepare"
+	"github.com/emiliopalmerini/grimorio/cmd/prompts"
 	"github.com/emiliopalmerini/grimorio/cmd/scrying"
 	"github.com/emiliopalmerini/grimorio/cmd/sending"
 	"github.com/emiliopalmerini/grimorio/cmd/stats"
 	"github.com/emiliopalmerini/grimorio/cmd/summon"
+	"github.com/emiliopalmerini/grimorio/cmd/transmute"
+	"github.com/emiliopalmerini/grimorio/internal/cache"
+	"github.com/emiliopalmerini/grimorio/internal/config"
 	"github.com/emiliopalmerini/grimorio/internal/metrics"
 	"github.com/emiliopalmerini/grimorio/internal/metrics/turso"
 	"github.com/spf13/cobra"
 )
 
+var noCache bool
+
 var rootCmd = &cobra.Command{
 	Use:   "grimorio",
 	Short: "A spellbook of developer incantations",
 	Long:  `Grimorio is a CLI spellbook containing cantrips and spells for scaffolding, automation, and productivity.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		settings, err := config.LoadSettings()
+		if err != nil {
+			return err
+		}
+		if err := configcmd.ApplyDefaults(cmd, settings); err != nil {
+			return err
+		}
+		setupCache()
+		return nil
+	},
+}
+
+// setupCache enables the spell response cache unless --no-cache is set.
+func setupCache() {
+	if noCache {
+		return
+	}
+	dbPath, err := cache.DefaultDBPath()
+	if err != nil {
+		return
+	}
+
+	ttl := cache.DefaultTTL
+	if cfg, err := config.LoadRepo(); err == nil && cfg.Cache.TTL != "" {
+		if d, err := time.ParseDuration(cfg.Cache.TTL); err == nil {
+			ttl = d
+		}
+	}
+	cache.Default = cache.NewSQLiteStore(dbPath, ttl)
 }
 
 func Execute() {
@@ -39,22 +81,34 @@ func Execute() {
 		}
 	}
 
-	if err := rootCmd.Execute(); err != nil {
+	custom.Register(rootCmd)
+
+	err = rootCmd.Execute()
+	cache.Default.Close()
+	if err != nil {
 		os.Exit(1)
 	}
 }
 
 func init() {
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "Always call Claude instead of reusing cached responses")
+
 	rootCmd.AddCommand(augury.Cmd)
+	rootCmd.AddCommand(breaking.Cmd)
+	rootCmd.AddCommand(configcmd.Cmd)
 	rootCmd.AddCommand(conjure.Cmd)
 	rootCmd.AddCommand(dashboard.Cmd)
+	rootCmd.AddCommand(diff.Cmd)
 	rootCmd.AddCommand(identify.Cmd)
+	rootCmd.AddCommand(inscribe.Cmd)
 	rootCmd.AddCommand(mending.Cmd)
 	rootCmd.AddCommand(modifymemory.Cmd)
 	rootCmd.AddCommand(polymorph.Cmd)
 	rootCmd.AddCommand(prepare.Cmd)
+	rootCmd.AddCommand(prompts.Cmd)
 	rootCmd.AddCommand(scrying.Cmd)
 	rootCmd.AddCommand(sending.Cmd)
 	rootCmd.AddCommand(stats.Cmd)
 	rootCmd.AddCommand(summon.Cmd)
+	rootCmd.AddCommand(transmute.Cmd)
 }
@@ -3,14 +3,22 @@ package scrying
 import (
 	"encoding/json"
 	"fmt"
+	"os"
 
+	"github.com/emiliopalmerini/grimorio/internal/chat"
+	"github.com/emiliopalmerini/grimorio/internal/claude"
 	"github.com/emiliopalmerini/grimorio/internal/clipboard"
 	"github.com/emiliopalmerini/grimorio/internal/metrics"
+	"github.com/emiliopalmerini/grimorio/internal/prompt"
 	"github.com/emiliopalmerini/grimorio/internal/spell/scrying"
 	"github.com/spf13/cobra"
 )
 
-var allChanges bool
+var (
+	allChanges bool
+	model      string
+	chatMode   bool
+)
 
 var Cmd = &cobra.Command{
 	Use:   "scrying",
@@ -19,24 +27,35 @@ var Cmd = &cobra.Command{
 
 Examples:
   grimorio scrying
-  grimorio scrying -a`,
+  grimorio scrying -a
+  grimorio scrying --chat`,
 	RunE: runScrying,
 }
 
 func init() {
 	Cmd.Flags().BoolVarP(&allChanges, "all", "a", false, "Include all changes, not just staged")
+	Cmd.Flags().StringVar(&model, "model", string(scrying.DefaultModel), "Claude model to use")
+	Cmd.Flags().BoolVar(&chatMode, "chat", false, "Ask follow-up questions after the review")
 }
 
 func runScrying(cmd *cobra.Command, args []string) error {
-	flags, _ := json.Marshal(map[string]any{"all": allChanges})
+	flags, _ := json.Marshal(map[string]any{"all": allChanges, "model": model, "chat": chatMode})
 	return metrics.Track("scrying", metrics.Spell, string(flags), func() error {
-		diff, err := scrying.GetDiff(allChanges)
+		diff, err := scrying.GetDiff(allChanges, claude.Model(model))
 		if err != nil {
 			return err
 		}
 
 		fmt.Println("Scrying the changes...")
-		review, err := scrying.Review(diff)
+		if chatMode {
+			text, err := scrying.Prompt(claude.Model(model), diff)
+			if err != nil {
+				return err
+			}
+			return chat.Start(claude.NewSession(claude.Model(model), "scrying", prompt.Transcript), text, os.Stdin, os.Stdout)
+		}
+
+		review, err := scrying.Review(claude.Model(model), diff)
 		if err != nil {
 			return err
 		}
@@ -8,6 +8,7 @@ import (
 	"os/exec"
 	"strings"
 
+	"github.com/emiliopalmerini/grimorio/internal/claude"
 	"github.com/emiliopalmerini/grimorio/internal/clipboard"
 	"github.com/emiliopalmerini/grimorio/internal/editor"
 	"github.com/emiliopalmerini/grimorio/internal/metrics"
@@ -19,6 +20,7 @@ var (
 	dryRun      bool
 	description string
 	baseBranch  string
+	model       string
 )
 
 var Cmd = &cobra.Command{
@@ -40,10 +42,11 @@ func init() {
 	Cmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Output description without creating PR")
 	Cmd.Flags().StringVarP(&description, "description", "m", "", "Additional context for the PR")
 	Cmd.Flags().StringVarP(&baseBranch, "base", "b", "", "Base branch to compare against (default: auto-detect main/master)")
+	Cmd.Flags().StringVar(&model, "model", string(sending.DefaultModel), "Claude model to use")
 }
 
 func runSending(cmd *cobra.Command, args []string) error {
-	flags, _ := json.Marshal(map[string]any{"dry-run": dryRun, "description": description, "base": baseBranch})
+	flags, _ := json.Marshal(map[string]any{"dry-run": dryRun, "description": description, "base": baseBranch, "model": model})
 	return metrics.Track("sending", metrics.Spell, string(flags), func() error {
 		current, base, err := sending.GetBranchInfo()
 		if err != nil {
@@ -56,7 +59,21 @@ func runSending(cmd *cobra.Command, args []string) error {
 
 		fmt.Printf("Comparing %s against %s...\n", current, base)
 
-		diff, err := sending.GetBranchDiff(base)
+		report, err := sending.GetAPIChanges(base)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: skipping API change detection: %v\n", err)
+		}
+		if report != nil {
+			for _, err := range report.Errors {
+				fmt.Fprintf(os.Stderr, "warning: API changes not compared: %v\n", err)
+			}
+		}
+		apiChanges := ""
+		if report != nil && len(report.Changes) > 0 {
+			apiChanges = report.Format()
+		}
+
+		diff, err := sending.GetBranchDiff(base, report, claude.Model(model))
 		if err != nil {
 			return err
 		}
@@ -64,7 +81,7 @@ func runSending(cmd *cobra.Command, args []string) error {
 		commits, _ := sending.GetBranchCommits(base)
 
 		fmt.Println("Preparing to send PR...")
-		content, err := sending.GeneratePRDescription(diff, commits, description)
+		content, err := sending.GeneratePRDescription(claude.Model(model), diff, commits, description, apiChanges)
 		if err != nil {
 			return err
 		}
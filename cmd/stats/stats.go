@@ -76,6 +76,15 @@ func runLocalStats(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Prompt tokens:     %d\n", summary.TotalPromptTokens)
 		fmt.Printf("Response tokens:   %d\n", summary.TotalResponseTokens)
 		fmt.Printf("Avg latency:       %.0fms\n", summary.AvgLatencyMs)
+		fmt.Printf("Redactions:        %d\n", summary.TotalRedactions)
+		fmt.Println()
+	}
+
+	if summary.CacheHits > 0 {
+		fmt.Println("Cache")
+		fmt.Println("-----")
+		fmt.Printf("Hits:              %d\n", summary.CacheHits)
+		fmt.Printf("Tokens saved:      %d\n", summary.SavedPromptTokens+summary.SavedResponseTokens)
 		fmt.Println()
 	}
 
@@ -0,0 +1,254 @@
+package transmute
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/emiliopalmerini/grimorio/internal/claude"
+	"github.com/emiliopalmerini/grimorio/internal/diff"
+	"github.com/emiliopalmerini/grimorio/internal/git"
+	"github.com/emiliopalmerini/grimorio/internal/metrics"
+	"github.com/emiliopalmerini/grimorio/internal/spell/augury"
+	"github.com/emiliopalmerini/grimorio/internal/spell/transmute"
+	"github.com/spf13/cobra"
+)
+
+var (
+	model       string
+	allChanges  bool
+	branch      bool
+	baseBranch  string
+	attempts    int
+	testCommand string
+	timeout     time.Duration
+)
+
+var Cmd = &cobra.Command{
+	Use:   "transmute",
+	Short: "[Spell] Write tests for changed functions",
+	Long: `Transmute writes table-driven tests for the functions changed in the
+staged diff, or the branch diff with --branch, using Claude.
+
+Changed hunks are mapped to the functions they touch. Go files are parsed
+directly; Python and TypeScript files need their language server. Tests go
+in the matching _test.go, test_*.py or *.test.ts file and are run right
+away. When they fail, the output is sent back and the tests rewritten, up
+to --attempts times. Whatever still fails is then removed, so only tests
+that compile and pass are left in the working tree for you to review.
+
+Examples:
+  grimorio transmute
+  grimorio transmute -a
+  grimorio transmute --branch --base develop
+  grimorio transmute --attempts 5 --run "npx vitest run {}"`,
+	Args: cobra.NoArgs,
+	RunE: runTransmute,
+}
+
+func init() {
+	Cmd.Flags().StringVar(&model, "model", string(transmute.DefaultModel), "Claude model to use")
+	Cmd.Flags().BoolVarP(&allChanges, "all", "a", false, "Include all changes, not just staged")
+	Cmd.Flags().BoolVar(&branch, "branch", false, "Use the changes of the branch instead of the staged ones")
+	Cmd.Flags().StringVarP(&baseBranch, "base", "b", "", "Base branch to compare against (implies --branch; default: auto-detect main/master)")
+	Cmd.Flags().IntVar(&attempts, "attempts", transmute.DefaultAttempts, "Maximum number of attempts per file")
+	Cmd.Flags().StringVar(&testCommand, "run", "", "Command that runs the tests, {} standing for the test file (default: per language)")
+	Cmd.Flags().DurationVar(&timeout, "timeout", 10*time.Minute, "Stop a test run after this long")
+	Cmd.MarkFlagsMutuallyExclusive("all", "branch")
+	Cmd.MarkFlagsMutuallyExclusive("all", "base")
+}
+
+func runTransmute(cmd *cobra.Command, args []string) error {
+	flags, _ := json.Marshal(map[string]any{"model": model, "all": allChanges, "branch": branch, "base": baseBranch, "attempts": attempts, "run": testCommand, "timeout": timeout.String()})
+	return metrics.Track("transmute", metrics.Spell, string(flags), func() error {
+		rawDiff, newRev, err := getDiff()
+		if err != nil {
+			return err
+		}
+		root, err := git.GetRootDir()
+		if err != nil {
+			return err
+		}
+
+		var targets []*transmute.Target
+		for _, fd := range diff.Parse(rawDiff) {
+			if fd.IsDelete || fd.IsBinary || !transmute.Supported(fd.NewPath) {
+				continue
+			}
+			t, err := transmute.Find(fd, root, newRev)
+			if errors.Is(err, transmute.ErrNoFunctions) {
+				continue
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Skipping %s: %v\n", fd.NewPath, err)
+				continue
+			}
+			if testCommand != "" {
+				t.Command = strings.ReplaceAll(testCommand, "{}", t.TestPath)
+			}
+			targets = append(targets, t)
+		}
+		if len(targets) == 0 {
+			fmt.Println("No changed functions to test.")
+			return nil
+		}
+
+		var failed int
+		for _, t := range targets {
+			if err := transmuteTarget(t); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", t.Path, err)
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("no tests written for %d of %d file(s)", failed, len(targets))
+		}
+		return nil
+	})
+}
+
+// getDiff returns the diff to write tests for and the revision holding its
+// new side.
+func getDiff() (string, string, error) {
+	if branch || baseBranch != "" {
+		base := baseBranch
+		if base == "" {
+			var err error
+			if base, err = git.GetBaseBranch(); err != nil {
+				return "", "", err
+			}
+		}
+		raw, err := git.GetBranchDiff(base, 0)
+		return raw, "HEAD", err
+	}
+	raw, err := git.GetDiff(git.DiffOptions{All: allChanges})
+	if allChanges {
+		return raw, "", err
+	}
+	return raw, diff.IndexRev, err
+}
+
+// transmuteTarget writes and runs the tests of t, sending the failures back
+// until they pass or the attempts run out. Tests that still fail are then
+// removed; if that does not work either, the test file is put back as it
+// was.
+func transmuteTarget(t *transmute.Target) error {
+	names := make([]string, len(t.Functions))
+	for i, f := range t.Functions {
+		names[i] = f.Name
+	}
+	fmt.Printf("\n%s: %s\n", t.Path, strings.Join(names, ", "))
+
+	_, statErr := os.Stat(t.TestPath)
+	existed := statErr == nil
+	restore := func() {
+		if existed {
+			os.WriteFile(t.TestPath, []byte(t.Tests), 0o644)
+		} else {
+			os.Remove(t.TestPath)
+		}
+	}
+
+	current := t.Tests
+	var failure *augury.Result
+	var feedback string
+	for i := 1; i <= attempts; i++ {
+		fmt.Printf("Transmuting tests into %s (%d/%d)...\n", t.TestPath, i, attempts)
+		tests, err := transmute.Generate(claude.Model(model), t, current, failure, feedback)
+		if err != nil {
+			if tests == "" {
+				restore()
+				return err
+			}
+			fmt.Printf("Rejected the tests: %v\n", err)
+			feedback = fmt.Sprintf("They were rejected: %v", err)
+			continue
+		}
+
+		result, err := runTests(t, tests)
+		if err != nil {
+			restore()
+			return err
+		}
+		if result.ExitCode == 0 {
+			fmt.Printf("Tests pass: %s\n", t.TestPath)
+			return nil
+		}
+		current, failure = tests, result
+		feedback = "They fail when run. Fix the tests, or drop the cases whose expected results you cannot be sure of; do not change the code under test."
+	}
+
+	if failure == nil {
+		restore()
+		return fmt.Errorf("no usable tests after %d attempt(s)", attempts)
+	}
+	if err := prune(t, current, failure); err != nil {
+		restore()
+		return fmt.Errorf("tests still fail after %d attempt(s), %s left as it was: %w", attempts, t.TestPath, err)
+	}
+	return nil
+}
+
+// prune removes the new tests that failed in the last run, and checks that
+// the rest pass.
+func prune(t *transmute.Target, tests string, failure *augury.Result) error {
+	failed, ok := transmute.FailedTests(t.Language, failure)
+	if !ok {
+		return errors.New("the failures cannot be narrowed to single tests")
+	}
+	for _, name := range transmute.TestNames(t.Language, t.Tests) {
+		for _, f := range failed {
+			if f == name {
+				return fmt.Errorf("existing test %s fails", name)
+			}
+		}
+	}
+
+	pruned, err := transmute.Prune(t.Language, tests, failed)
+	if err != nil {
+		return err
+	}
+	if len(transmute.TestNames(t.Language, pruned)) == len(transmute.TestNames(t.Language, t.Tests)) {
+		return errors.New("none of the new tests pass")
+	}
+
+	fmt.Printf("Removing %d failing test(s): %s\n", len(failed), strings.Join(failed, ", "))
+	result, err := runTests(t, pruned)
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return errors.New("the remaining tests fail too")
+	}
+	fmt.Printf("Tests pass: %s\n", t.TestPath)
+	return nil
+}
+
+// runTests writes tests to the test file of t and runs them. A run stopped
+// with Ctrl+C is an error.
+func runTests(t *transmute.Target, tests string) (*augury.Result, error) {
+	if err := os.MkdirAll(filepath.Dir(t.TestPath), 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(t.TestPath, []byte(tests), 0o644); err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Running: %s\n", t.Command)
+	result, err := augury.RunCommand(t.Command, augury.RunOptions{Timeout: timeout})
+	if err != nil {
+		return nil, err
+	}
+	if result.Interrupted {
+		return nil, fmt.Errorf("%s was interrupted", t.Command)
+	}
+	if result.ExitCode != 0 {
+		diags := augury.ParseDiagnostics(result.Stderr + result.Stdout)
+		fmt.Printf("Tests fail (exit code %d, %d error(s) found)\n", result.ExitCode, len(diags))
+	}
+	return result, nil
+}
@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/emiliopalmerini/grimorio/internal/config"
+	_ "modernc.org/sqlite"
+)
+
+// DefaultTTL is how long responses stay valid unless configured otherwise.
+const DefaultTTL = 24 * time.Hour
+
+const schema = `CREATE TABLE IF NOT EXISTS responses (
+    key TEXT PRIMARY KEY,
+    model TEXT NOT NULL,
+    command TEXT NOT NULL,
+    response TEXT NOT NULL,
+    created_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_responses_created ON responses(created_at);`
+
+// Store caches model responses by model, command and prompt.
+type Store interface {
+	Get(ctx context.Context, model, command, prompt string) (string, bool, error)
+	Put(ctx context.Context, model, command, prompt, response string) error
+	Close() error
+}
+
+var Default Store = NoopStore{}
+
+// NoopStore never hits. It is used when caching is disabled.
+type NoopStore struct{}
+
+func (NoopStore) Get(context.Context, string, string, string) (string, bool, error) {
+	return "", false, nil
+}
+
+func (NoopStore) Put(context.Context, string, string, string, string) error {
+	return nil
+}
+
+func (NoopStore) Close() error {
+	return nil
+}
+
+// cached lists the commands whose answer only depends on the prompt, such
+// as an explanation of a file. Commands that write something new, such as
+// a commit message, are left out so running them again asks for a
+// different answer.
+var (
+	cachedMu sync.Mutex
+	cached   = map[string]bool{
+		"identify": true,
+		"scrying":  true,
+	}
+)
+
+// Allow opts a command into caching, for custom spells that ask for it.
+func Allow(command string) {
+	cachedMu.Lock()
+	defer cachedMu.Unlock()
+	cached[command] = true
+}
+
+// Cacheable reports whether the responses of a command are cached.
+func Cacheable(command string) bool {
+	cachedMu.Lock()
+	defer cachedMu.Unlock()
+	return cached[command]
+}
+
+// Key returns the content address of a prompt for a model and command.
+func Key(model, command, prompt string) string {
+	sum := sha256.Sum256([]byte(model + "\x00" + command + "\x00" + prompt))
+	return hex.EncodeToString(sum[:])
+}
+
+func DefaultDBPath() (string, error) {
+	return config.DataPath("cache.db")
+}
+
+// SQLiteStore is a Store backed by a SQLite database, opened lazily.
+type SQLiteStore struct {
+	dbPath string
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu    sync.Mutex
+	sqlDB *sql.DB
+}
+
+func NewSQLiteStore(dbPath string, ttl time.Duration) *SQLiteStore {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &SQLiteStore{dbPath: dbPath, ttl: ttl, now: time.Now}
+}
+
+func (s *SQLiteStore) ensureInit(ctx context.Context) (*sql.DB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sqlDB != nil {
+		return s.sqlDB, nil
+	}
+
+	sqlDB, err := sql.Open("sqlite", s.dbPath+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("open cache database: %w", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	if _, err := sqlDB.ExecContext(ctx, schema); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("create cache schema: %w", err)
+	}
+
+	s.sqlDB = sqlDB
+	return sqlDB, nil
+}
+
+// Get returns the cached response for a prompt, if one younger than the
+// TTL exists.
+func (s *SQLiteStore) Get(ctx context.Context, model, command, prompt string) (string, bool, error) {
+	sqlDB, err := s.ensureInit(ctx)
+	if err != nil {
+		return "", false, err
+	}
+
+	var response string
+	err = sqlDB.QueryRowContext(ctx,
+		`SELECT response FROM responses WHERE key = ? AND created_at >= ?`,
+		Key(model, command, prompt), s.now().Add(-s.ttl).Unix(),
+	).Scan(&response)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("read cache: %w", err)
+	}
+	return response, true, nil
+}
+
+// Put stores a response and prunes expired entries.
+func (s *SQLiteStore) Put(ctx context.Context, model, command, prompt, response string) error {
+	sqlDB, err := s.ensureInit(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	if _, err := sqlDB.ExecContext(ctx,
+		`INSERT OR REPLACE INTO responses (key, model, command, response, created_at) VALUES (?, ?, ?, ?, ?)`,
+		Key(model, command, prompt), model, command, response, now.Unix(),
+	); err != nil {
+		return fmt.Errorf("write cache: %w", err)
+	}
+
+	if _, err := sqlDB.ExecContext(ctx,
+		`DELETE FROM responses WHERE created_at < ?`, now.Add(-s.ttl).Unix(),
+	); err != nil {
+		return fmt.Errorf("prune cache: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sqlDB != nil {
+		return s.sqlDB.Close()
+	}
+	return nil
+}
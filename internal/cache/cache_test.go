@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	store := NewSQLiteStore(filepath.Join(t.TempDir(), "cache.db"), time.Hour)
+	defer store.Close()
+
+	ctx := context.Background()
+
+	if _, ok, err := store.Get(ctx, "haiku", "scrying", "prompt"); err != nil || ok {
+		t.Fatalf("Get() on empty cache = %v, %v, want miss", ok, err)
+	}
+
+	if err := store.Put(ctx, "haiku", "scrying", "prompt", "looks good"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	got, ok, err := store.Get(ctx, "haiku", "scrying", "prompt")
+	if err != nil || !ok || got != "looks good" {
+		t.Errorf("Get() = %q, %v, %v, want hit", got, ok, err)
+	}
+
+	for _, key := range [][3]string{
+		{"opus", "scrying", "prompt"},
+		{"haiku", "identify", "prompt"},
+		{"haiku", "scrying", "other prompt"},
+	} {
+		if _, ok, _ := store.Get(ctx, key[0], key[1], key[2]); ok {
+			t.Errorf("Get(%v) hit, want miss", key)
+		}
+	}
+}
+
+func TestSQLiteStoreTTL(t *testing.T) {
+	store := NewSQLiteStore(filepath.Join(t.TempDir(), "cache.db"), time.Hour)
+	defer store.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	if err := store.Put(ctx, "haiku", "identify", "prompt", "answer"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	store.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if _, ok, _ := store.Get(ctx, "haiku", "identify", "prompt"); ok {
+		t.Error("Get() hit after TTL, want miss")
+	}
+}
+
+func TestNoopStore(t *testing.T) {
+	store := NoopStore{}
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "haiku", "scrying", "prompt", "answer"); err != nil {
+		t.Errorf("Put: %v", err)
+	}
+	if _, ok, _ := store.Get(ctx, "haiku", "scrying", "prompt"); ok {
+		t.Error("Get() hit on NoopStore")
+	}
+}
+
+func TestCacheable(t *testing.T) {
+	for command, want := range map[string]bool{"identify": true, "scrying": true, "sending": false, "transmute": false, "changelog": false} {
+		if got := Cacheable(command); got != want {
+			t.Errorf("Cacheable(%q) = %v, want %v", command, got, want)
+		}
+	}
+	Allow("changelog")
+	t.Cleanup(func() { delete(cached, "changelog") })
+	if !Cacheable("changelog") {
+		t.Error("Cacheable(changelog) = false after Allow")
+	}
+}
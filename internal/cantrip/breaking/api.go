@@ -0,0 +1,111 @@
+package breaking
+
+import (
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// entry is one exported identifier of a package's API surface.
+type entry struct {
+	kind string // func, method, type, field, var, const
+	sig  string // signature without the identifier's own name
+	pos  token.Position
+}
+
+// surface collects the exported API of a package, keyed by name.
+// Methods and fields are keyed as "Type.Member".
+func surface(pkg *types.Package, fset *token.FileSet) map[string]entry {
+	api := make(map[string]entry)
+	if pkg == nil {
+		return api
+	}
+
+	qualifier := func(p *types.Package) string {
+		if p.Path() == pkg.Path() {
+			return ""
+		}
+		return p.Name()
+	}
+	typeString := func(t types.Type) string {
+		return types.TypeString(t, qualifier)
+	}
+
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() {
+			continue
+		}
+		pos := fset.Position(obj.Pos())
+
+		switch o := obj.(type) {
+		case *types.Func:
+			api[name] = entry{kind: "func", sig: typeString(o.Type()), pos: pos}
+		case *types.Var:
+			api[name] = entry{kind: "var", sig: typeString(o.Type()), pos: pos}
+		case *types.Const:
+			api[name] = entry{kind: "const", sig: typeString(o.Type()), pos: pos}
+		case *types.TypeName:
+			api[name] = entry{kind: "type", sig: typeSurface(o, typeString), pos: pos}
+			addMembers(api, name, o, fset, typeString)
+		}
+	}
+
+	return api
+}
+
+// typeSurface describes a type declaration without its unexported details.
+// Struct fields and methods are tracked as separate entries.
+func typeSurface(obj *types.TypeName, typeString func(types.Type) string) string {
+	if obj.IsAlias() {
+		return "= " + typeString(obj.Type())
+	}
+
+	var sb strings.Builder
+	if named, ok := obj.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+		var params []string
+		for i := 0; i < named.TypeParams().Len(); i++ {
+			tp := named.TypeParams().At(i)
+			params = append(params, tp.Obj().Name()+" "+typeString(tp.Constraint()))
+		}
+		sb.WriteString("[" + strings.Join(params, ", ") + "] ")
+	}
+
+	if _, ok := obj.Type().Underlying().(*types.Struct); ok {
+		sb.WriteString("struct")
+	} else {
+		sb.WriteString(typeString(obj.Type().Underlying()))
+	}
+	return sb.String()
+}
+
+func addMembers(api map[string]entry, typeName string, obj *types.TypeName, fset *token.FileSet, typeString func(types.Type) string) {
+	named, ok := obj.Type().(*types.Named)
+	if !ok || obj.IsAlias() {
+		return
+	}
+
+	if st, ok := named.Underlying().(*types.Struct); ok {
+		for i := 0; i < st.NumFields(); i++ {
+			f := st.Field(i)
+			if !f.Exported() {
+				continue
+			}
+			api[typeName+"."+f.Name()] = entry{kind: "field", sig: typeString(f.Type()), pos: fset.Position(f.Pos())}
+		}
+	}
+
+	if _, isInterface := named.Underlying().(*types.Interface); isInterface {
+		return
+	}
+
+	mset := types.NewMethodSet(types.NewPointer(named))
+	for i := 0; i < mset.Len(); i++ {
+		m := mset.At(i).Obj()
+		if !m.Exported() || len(mset.At(i).Index()) > 1 {
+			continue
+		}
+		api[typeName+"."+m.Name()] = entry{kind: "method", sig: typeString(m.Type()), pos: fset.Position(m.Pos())}
+	}
+}
@@ -0,0 +1,385 @@
+package breaking
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/emiliopalmerini/grimorio/internal/diff"
+	"github.com/emiliopalmerini/grimorio/internal/git"
+)
+
+// ChangeKind classifies a change to an exported identifier.
+type ChangeKind string
+
+const (
+	Removed ChangeKind = "removed"
+	Renamed ChangeKind = "renamed"
+	Changed ChangeKind = "changed"
+	Added   ChangeKind = "added"
+)
+
+// Semver bump levels.
+const (
+	Major = "major"
+	Minor = "minor"
+	Patch = "patch"
+)
+
+// Options configures breaking change detection.
+type Options struct {
+	Base            string // Base revision to compare against
+	Head            string // Head revision (empty = working tree)
+	IncludeInternal bool   // Also report internal and main packages
+}
+
+// Position is a file location relative to the repository root.
+type Position struct {
+	Path string
+	Line int
+}
+
+// Change describes one difference in a package's exported API.
+type Change struct {
+	Package string
+	Kind    ChangeKind
+	Object  string // func, method, type, field, var, const
+	Name    string
+	NewName string // Set for renames
+	OldSig  string
+	NewSig  string
+	OldPos  Position
+	NewPos  Position
+}
+
+// Breaking reports whether the change breaks existing callers.
+func (c Change) Breaking() bool {
+	return c.Kind != Added
+}
+
+// Report is the result of comparing the API surface of two revisions.
+type Report struct {
+	Base    string
+	Changes []Change
+	// Errors are the packages that could not be loaded, and so were not
+	// compared.
+	Errors []error
+}
+
+// Bump returns the suggested semver bump for the report.
+func (r *Report) Bump() string {
+	bump := Patch
+	for _, c := range r.Changes {
+		if c.Breaking() {
+			return Major
+		}
+		bump = Minor
+	}
+	return bump
+}
+
+// HasBreaking reports whether any change breaks existing callers.
+func (r *Report) HasBreaking() bool {
+	return r.Bump() == Major
+}
+
+// Locations returns the diff locations of breaking changes, for scoring.
+func (r *Report) Locations() []diff.Location {
+	var locs []diff.Location
+	for _, c := range r.Changes {
+		if !c.Breaking() {
+			continue
+		}
+		if c.OldPos.Line > 0 {
+			locs = append(locs, diff.Location{Path: c.OldPos.Path, Line: c.OldPos.Line, Old: true})
+		}
+		if c.NewPos.Line > 0 {
+			locs = append(locs, diff.Location{Path: c.NewPos.Path, Line: c.NewPos.Line})
+		}
+	}
+	return locs
+}
+
+// Format renders the report as plain text, grouped by package.
+func (r *Report) Format() string {
+	var sb strings.Builder
+	if len(r.Changes) == 0 {
+		sb.WriteString(fmt.Sprintf("No exported API changes against %s (suggested bump: %s)\n", r.Base, Patch))
+	} else {
+		sb.WriteString(fmt.Sprintf("API changes against %s (suggested bump: %s)\n", r.Base, r.Bump()))
+	}
+
+	currentPkg := ""
+	for _, c := range r.Changes {
+		if c.Package != currentPkg {
+			currentPkg = c.Package
+			sb.WriteString("\n" + currentPkg + "\n")
+		}
+		switch c.Kind {
+		case Removed:
+			sb.WriteString(fmt.Sprintf("  - removed  %s %s\n", c.Object, c.Name))
+		case Renamed:
+			sb.WriteString(fmt.Sprintf("  > renamed  %s %s -> %s\n", c.Object, c.Name, c.NewName))
+		case Changed:
+			sb.WriteString(fmt.Sprintf("  ~ changed  %s %s: %s -> %s\n", c.Object, c.Name, c.OldSig, c.NewSig))
+		case Added:
+			sb.WriteString(fmt.Sprintf("  + added    %s %s\n", c.Object, c.Name))
+		}
+	}
+	if len(r.Errors) > 0 {
+		sb.WriteString("\nNot compared:\n")
+		for _, err := range r.Errors {
+			sb.WriteString(fmt.Sprintf("  ! %v\n", err))
+		}
+	}
+	return sb.String()
+}
+
+// Detect compares the exported API of every Go package changed between
+// the point where opts.Head forked from opts.Base and opts.Head, as
+// git diff base...head does, so that changes made on the base since are
+// not reported. Of each revision, only the module files and the packages
+// type-checking needs are exported. Packages that fail to load are listed
+// in the report's Errors and not compared.
+func Detect(opts Options) (*Report, error) {
+	root, err := git.GetRootDir()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(root, "go.mod")); err != nil {
+		return nil, fmt.Errorf("go.mod not found at repository root")
+	}
+
+	head := opts.Head
+	if head == "" {
+		head = "HEAD"
+	}
+	fork, err := git.MergeBase(opts.Base, head)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := git.GetChangedFiles(fork, opts.Head)
+	if err != nil {
+		return nil, err
+	}
+	dirs := changedPackageDirs(files)
+
+	report := &Report{Base: opts.Base}
+	if len(dirs) == 0 {
+		return report, nil
+	}
+
+	fset := token.NewFileSet()
+	ext := newSourceImporter(fset)
+
+	baseRoot, err := os.MkdirTemp("", "grimorio-breaking-base-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(baseRoot)
+	baseLoader, err := newRevLoader(fset, ext, baseRoot, fork)
+	if err != nil {
+		return nil, err
+	}
+
+	var headLoader *loader
+	if opts.Head == "" {
+		headLoader, err = newLoader(fset, ext, root)
+	} else {
+		var headRoot string
+		headRoot, err = os.MkdirTemp("", "grimorio-breaking-head-*")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temp dir: %w", err)
+		}
+		defer os.RemoveAll(headRoot)
+		headLoader, err = newRevLoader(fset, ext, headRoot, opts.Head)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dir := range dirs {
+		basePkg, baseErr := baseLoader.load(dir)
+		headPkg, headErr := headLoader.load(dir)
+		if baseErr != nil || headErr != nil {
+			report.Errors = append(report.Errors, errors.Join(baseErr, headErr))
+			continue
+		}
+		if basePkg == nil && headPkg == nil {
+			continue
+		}
+
+		pkgPath := headLoader.importPath(dir)
+		if !opts.IncludeInternal && !isPublicPackage(pkgPath, basePkg, headPkg) {
+			continue
+		}
+
+		changes := compare(surface(basePkg, fset), surface(headPkg, fset))
+		for i := range changes {
+			changes[i].Package = pkgPath
+			changes[i].OldPos.Path = baseLoader.relPath(changes[i].OldPos.Path)
+			changes[i].NewPos.Path = headLoader.relPath(changes[i].NewPos.Path)
+		}
+		report.Changes = append(report.Changes, changes...)
+	}
+
+	return report, nil
+}
+
+// changedPackageDirs returns the directories holding changed non-test Go files.
+func changedPackageDirs(files []string) []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, f := range files {
+		if !strings.HasSuffix(f, ".go") || strings.HasSuffix(f, "_test.go") {
+			continue
+		}
+		dir := path.Dir(f)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// isPublicPackage reports whether a package is importable by other modules.
+func isPublicPackage(pkgPath string, pkgs ...*types.Package) bool {
+	for _, pkg := range pkgs {
+		if pkg != nil && pkg.Name() == "main" {
+			return false
+		}
+	}
+	for _, elem := range strings.Split(pkgPath, "/") {
+		if elem == "internal" {
+			return false
+		}
+	}
+	return true
+}
+
+// compare diffs two API surfaces. Members of removed, renamed or added
+// types are folded into the type-level change.
+func compare(base, head map[string]entry) []Change {
+	var removed, added []string
+	var changes []Change
+
+	for name, old := range base {
+		cur, ok := head[name]
+		switch {
+		case !ok:
+			removed = append(removed, name)
+		case cur.kind != old.kind || cur.sig != old.sig:
+			changes = append(changes, Change{
+				Kind: Changed, Object: cur.kind, Name: name,
+				OldSig: old.sig, NewSig: cur.sig,
+				OldPos: position(old.pos), NewPos: position(cur.pos),
+			})
+		}
+	}
+	for name := range head {
+		if _, ok := base[name]; !ok {
+			added = append(added, name)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+
+	// Pair removals with additions of identical shape as renames.
+	renamedTo := make(map[string]string)
+	usedAdded := make(map[string]bool)
+	for _, r := range removed {
+		old := base[r]
+		for _, a := range added {
+			cur := head[a]
+			if usedAdded[a] || cur.kind != old.kind || cur.sig != old.sig || parentOf(a) != parentOf(r) {
+				continue
+			}
+			renamedTo[r] = a
+			usedAdded[a] = true
+			break
+		}
+	}
+
+	gone := make(map[string]bool)
+	for _, r := range removed {
+		if base[r].kind == "type" {
+			gone[r] = true
+		}
+	}
+	for _, a := range added {
+		if head[a].kind == "type" {
+			gone[a] = true
+		}
+	}
+
+	for _, r := range removed {
+		if gone[parentOf(r)] {
+			continue
+		}
+		old := base[r]
+		if to, ok := renamedTo[r]; ok {
+			changes = append(changes, Change{
+				Kind: Renamed, Object: old.kind, Name: r, NewName: to,
+				OldSig: old.sig, NewSig: head[to].sig,
+				OldPos: position(old.pos), NewPos: position(head[to].pos),
+			})
+			continue
+		}
+		changes = append(changes, Change{
+			Kind: Removed, Object: old.kind, Name: r,
+			OldSig: old.sig, OldPos: position(old.pos),
+		})
+	}
+	for _, a := range added {
+		if usedAdded[a] || gone[parentOf(a)] {
+			continue
+		}
+		cur := head[a]
+		changes = append(changes, Change{
+			Kind: Added, Object: cur.kind, Name: a,
+			NewSig: cur.sig, NewPos: position(cur.pos),
+		})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Kind != changes[j].Kind {
+			return kindOrder(changes[i].Kind) < kindOrder(changes[j].Kind)
+		}
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+// parentOf returns the type name of a "Type.Member" key, or "".
+func parentOf(name string) string {
+	if i := strings.Index(name, "."); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+func position(p token.Position) Position {
+	return Position{Path: p.Filename, Line: p.Line}
+}
+
+func kindOrder(k ChangeKind) int {
+	switch k {
+	case Removed:
+		return 0
+	case Renamed:
+		return 1
+	case Changed:
+		return 2
+	default:
+		return 3
+	}
+}
@@ -0,0 +1,244 @@
+package breaking
+
+import (
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func at(line int) token.Position {
+	return token.Position{Filename: "api.go", Line: line}
+}
+
+func TestCompare(t *testing.T) {
+	base := map[string]entry{
+		"Old":       {kind: "func", sig: "func()", pos: at(1)},
+		"Gone":      {kind: "func", sig: "func(x int)", pos: at(2)},
+		"Client":    {kind: "type", sig: "struct", pos: at(3)},
+		"Client.Do": {kind: "method", sig: "func() error", pos: at(4)},
+		"Legacy":    {kind: "type", sig: "struct", pos: at(5)},
+		"Legacy.Go": {kind: "method", sig: "func()", pos: at(6)},
+	}
+	head := map[string]entry{
+		"New":       {kind: "func", sig: "func()", pos: at(1)},
+		"Client":    {kind: "type", sig: "struct", pos: at(3)},
+		"Client.Do": {kind: "method", sig: "func(n int) error", pos: at(4)},
+		"Extra":     {kind: "const", sig: "untyped int", pos: at(7)},
+	}
+
+	changes := compare(base, head)
+
+	got := make(map[string]ChangeKind)
+	for _, c := range changes {
+		got[c.Name] = c.Kind
+	}
+
+	want := map[string]ChangeKind{
+		"Gone":      Removed,
+		"Legacy":    Removed,
+		"Old":       Renamed,
+		"Client.Do": Changed,
+		"Extra":     Added,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("compare() = %v, want %v", got, want)
+	}
+	for name, kind := range want {
+		if got[name] != kind {
+			t.Errorf("change %s = %q, want %q", name, got[name], kind)
+		}
+	}
+
+	for _, c := range changes {
+		if c.Kind == Renamed && c.NewName != "New" {
+			t.Errorf("renamed to %q, want New", c.NewName)
+		}
+	}
+}
+
+func TestReportBump(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes []Change
+		want    string
+	}{
+		{"no changes", nil, Patch},
+		{"additions only", []Change{{Kind: Added}}, Minor},
+		{"removal", []Change{{Kind: Added}, {Kind: Removed}}, Major},
+		{"signature change", []Change{{Kind: Changed}}, Major},
+		{"rename", []Change{{Kind: Renamed}}, Major},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Report{Changes: tt.changes}
+			if got := r.Bump(); got != tt.want {
+				t.Errorf("Bump() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReportLocations(t *testing.T) {
+	r := &Report{Changes: []Change{
+		{Kind: Removed, OldPos: Position{Path: "a.go", Line: 3}},
+		{Kind: Changed, OldPos: Position{Path: "a.go", Line: 5}, NewPos: Position{Path: "a.go", Line: 6}},
+		{Kind: Added, NewPos: Position{Path: "a.go", Line: 9}},
+	}}
+
+	locs := r.Locations()
+	if len(locs) != 3 {
+		t.Fatalf("Locations() returned %d locations, want 3", len(locs))
+	}
+	if !locs[0].Old || locs[0].Line != 3 {
+		t.Errorf("first location = %+v, want old side line 3", locs[0])
+	}
+}
+
+func TestReportFormat(t *testing.T) {
+	r := &Report{Base: "main", Changes: []Change{
+		{Package: "example.com/m/api", Kind: Removed, Object: "func", Name: "Gone"},
+	}}
+
+	out := r.Format()
+	if !strings.Contains(out, "suggested bump: major") {
+		t.Errorf("Format() missing bump, got: %s", out)
+	}
+	if !strings.Contains(out, "removed  func Gone") {
+		t.Errorf("Format() missing change, got: %s", out)
+	}
+}
+
+func TestIsPublicPackage(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"example.com/m/api", true},
+		{"example.com/m/internal/api", false},
+		{"example.com/m/internal", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := isPublicPackage(tt.path); got != tt.want {
+				t.Errorf("isPublicPackage(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+// gitRepo creates a repository in a new working directory and returns a
+// function running git in it.
+func gitRepo(t *testing.T) func(args ...string) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	run := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	run("init", "-q", "-b", "main")
+	run("config", "user.email", "dev@example.com")
+	run("config", "user.name", "dev")
+	return run
+}
+
+func write(name, content string) {
+	os.MkdirAll(filepath.Dir(name), 0o755)
+	os.WriteFile(name, []byte(content), 0o644)
+}
+
+func TestDetect(t *testing.T) {
+	run := gitRepo(t)
+
+	write("go.mod", "module example.com/m\n\ngo 1.22\n")
+	write("model/model.go", "package model\n\ntype User struct{ Name string }\n")
+	write("api/api.go", "package api\n\nimport \"example.com/m/model\"\n\nfunc Find(name string) model.User { return model.User{Name: name} }\n")
+	write("unrelated/unrelated.go", "package unrelated\n\nfunc Same() {}\n")
+	run("add", "-A")
+	run("commit", "-q", "-m", "base")
+
+	write("api/api.go", "package api\n\nimport \"example.com/m/model\"\n\nfunc Find(id int) *model.User { return nil }\n")
+	run("commit", "-q", "-am", "head")
+
+	report, err := Detect(Options{Base: "HEAD~1", Head: "HEAD"})
+	if err != nil {
+		t.Fatalf("Detect() error = %v", err)
+	}
+	if len(report.Changes) != 1 {
+		t.Fatalf("Detect() changes = %+v, want one", report.Changes)
+	}
+	c := report.Changes[0]
+	if c.Package != "example.com/m/api" || c.Kind != Changed || c.Name != "Find" {
+		t.Errorf("change = %+v, want api.Find changed", c)
+	}
+	if c.OldSig != "func(name string) model.User" || c.NewSig != "func(id int) *model.User" {
+		t.Errorf("signatures = %q -> %q, want the imported model.User resolved", c.OldSig, c.NewSig)
+	}
+
+	write("README.md", "docs\n")
+	run("add", "-A")
+	run("commit", "-q", "-m", "docs")
+	if report, err := Detect(Options{Base: "HEAD~1", Head: "HEAD"}); err != nil || len(report.Changes) != 0 {
+		t.Errorf("Detect() without Go changes = %+v, %v, want none", report, err)
+	}
+}
+
+func TestDetectDivergedBase(t *testing.T) {
+	run := gitRepo(t)
+	write("go.mod", "module example.com/m\n\ngo 1.22\n")
+	write("api/api.go", "package api\n\nfunc Find() {}\n")
+	run("add", "-A")
+	run("commit", "-q", "-m", "base")
+
+	run("checkout", "-q", "-b", "feature")
+	write("api/api.go", "package api\n\nfunc Find() {}\n\nfunc List() {}\n")
+	run("commit", "-q", "-am", "add List")
+
+	// main moves ahead after the fork
+	run("checkout", "-q", "main")
+	write("api/api.go", "package api\n\nfunc Find() {}\n\nfunc Upstream() {}\n")
+	run("commit", "-q", "-am", "add Upstream")
+	run("checkout", "-q", "feature")
+
+	report, err := Detect(Options{Base: "main", Head: "HEAD"})
+	if err != nil {
+		t.Fatalf("Detect() error = %v", err)
+	}
+	if len(report.Changes) != 1 || report.Changes[0].Kind != Added || report.Changes[0].Name != "List" {
+		t.Errorf("Detect() changes = %+v, want only List added", report.Changes)
+	}
+	if report.Bump() != Minor {
+		t.Errorf("Bump() = %s, want minor", report.Bump())
+	}
+}
+
+func TestDetectLoadError(t *testing.T) {
+	run := gitRepo(t)
+	write("go.mod", "module example.com/m\n\ngo 1.22\n")
+	write("api/api.go", "package api\n\nfunc Find() {}\n")
+	run("add", "-A")
+	run("commit", "-q", "-m", "base")
+
+	write("api/api.go", "package api\n\nfunc Find( {}\n")
+	run("commit", "-q", "-am", "broken")
+
+	report, err := Detect(Options{Base: "HEAD~1", Head: "HEAD"})
+	if err != nil {
+		t.Fatalf("Detect() error = %v", err)
+	}
+	if len(report.Changes) != 0 {
+		t.Errorf("Detect() changes = %+v, want none for a package that does not parse", report.Changes)
+	}
+	if len(report.Errors) != 1 || !strings.Contains(report.Errors[0].Error(), "example.com/m/api") {
+		t.Errorf("Detect() errors = %v, want the api package", report.Errors)
+	}
+	if !strings.Contains(report.Format(), "Not compared") {
+		t.Errorf("Format() does not list the package that failed:\n%s", report.Format())
+	}
+}
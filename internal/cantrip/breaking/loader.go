@@ -0,0 +1,181 @@
+package breaking
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/emiliopalmerini/grimorio/internal/git"
+)
+
+// loader type-checks the packages of a single module tree from source.
+// Packages inside the module are loaded from root; everything else is
+// delegated to the shared source importer.
+type loader struct {
+	fset   *token.FileSet
+	root   string
+	module string
+	ext    types.ImporterFrom
+	pkgs   map[string]*types.Package
+
+	// rev, when set, is the revision root is exported from. Each package
+	// directory is written on first use, so only the changed packages and
+	// their imports inside the module are exported.
+	rev string
+}
+
+// newRevLoader exports the module files of rev into root, then returns a
+// loader that exports the packages it loads as they are needed.
+func newRevLoader(fset *token.FileSet, ext types.ImporterFrom, root, rev string) (*loader, error) {
+	if err := git.ExportTree(rev, root, ":(glob)go.*"); err != nil {
+		return nil, err
+	}
+	// The source importer reads vendored dependencies, when there are any
+	git.ExportTree(rev, root, "vendor")
+
+	l, err := newLoader(fset, ext, root)
+	if err != nil {
+		return nil, err
+	}
+	l.rev = rev
+	return l, nil
+}
+
+func newLoader(fset *token.FileSet, ext types.ImporterFrom, root string) (*loader, error) {
+	module, err := readModulePath(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return nil, err
+	}
+	return &loader{
+		fset:   fset,
+		root:   root,
+		module: module,
+		ext:    ext,
+		pkgs:   make(map[string]*types.Package),
+	}, nil
+}
+
+func newSourceImporter(fset *token.FileSet) types.ImporterFrom {
+	return importer.ForCompiler(fset, "source", nil).(types.ImporterFrom)
+}
+
+func (l *loader) Import(path string) (*types.Package, error) {
+	return l.ImportFrom(path, l.root, 0)
+}
+
+func (l *loader) ImportFrom(path, dir string, mode types.ImportMode) (*types.Package, error) {
+	if path == l.module || strings.HasPrefix(path, l.module+"/") {
+		pkg, err := l.load(strings.TrimPrefix(strings.TrimPrefix(path, l.module), "/"))
+		if pkg == nil && err == nil {
+			err = fmt.Errorf("package %s not found", path)
+		}
+		return pkg, err
+	}
+	return l.ext.ImportFrom(path, l.root, mode)
+}
+
+// load type-checks the package in the given directory, relative to the
+// module root. Type errors are tolerated so that a partially broken tree
+// still yields its API surface, but a package that cannot be read or
+// parsed is an error. A package absent from the tree is nil.
+func (l *loader) load(rel string) (*types.Package, error) {
+	path := l.importPath(rel)
+	if pkg, ok := l.pkgs[path]; ok {
+		if pkg == nil {
+			return nil, fmt.Errorf("import cycle through %s", path)
+		}
+		return pkg, nil
+	}
+	l.pkgs[path] = nil
+
+	if l.rev != "" {
+		// A package missing at rev fails ImportDir below
+		git.ExportTree(l.rev, l.root, ":(glob)"+filepath.ToSlash(filepath.Join(rel, "*.go")))
+	}
+	dir := filepath.Join(l.root, filepath.FromSlash(rel))
+	bp, err := build.Default.ImportDir(dir, 0)
+	if err != nil {
+		delete(l.pkgs, path)
+		if absent(dir, bp, err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s at %s: %w", path, l.revName(), err)
+	}
+
+	var files []*ast.File
+	for _, name := range append(bp.GoFiles, bp.CgoFiles...) {
+		f, err := parser.ParseFile(l.fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			delete(l.pkgs, path)
+			return nil, fmt.Errorf("%s at %s: %w", path, l.revName(), err)
+		}
+		files = append(files, f)
+	}
+
+	conf := types.Config{
+		Importer:    l,
+		FakeImportC: true,
+		Error:       func(error) {},
+	}
+	pkg, _ := conf.Check(path, l.fset, files, nil)
+	l.pkgs[path] = pkg
+	return pkg, nil
+}
+
+// absent reports whether the failure to import dir means the tree has no
+// such package: the directory is missing, or holds no Go files beyond
+// tests. Files all excluded by build constraints are not absent.
+func absent(dir string, bp *build.Package, err error) bool {
+	if _, statErr := os.Stat(dir); errors.Is(statErr, fs.ErrNotExist) {
+		return true
+	}
+	var noGo *build.NoGoError
+	return errors.As(err, &noGo) && bp != nil && len(bp.IgnoredGoFiles) == 0 && len(bp.InvalidGoFiles) == 0
+}
+
+// revName names the tree the loader reads, for errors.
+func (l *loader) revName() string {
+	if l.rev == "" {
+		return "the working tree"
+	}
+	return l.rev
+}
+
+func (l *loader) importPath(rel string) string {
+	if rel == "" || rel == "." {
+		return l.module
+	}
+	return l.module + "/" + filepath.ToSlash(rel)
+}
+
+// relPath returns a position's file path relative to the tree root.
+func (l *loader) relPath(filename string) string {
+	rel, err := filepath.Rel(l.root, filename)
+	if err != nil {
+		return filename
+	}
+	return filepath.ToSlash(rel)
+}
+
+func readModulePath(goMod string) (string, error) {
+	content, err := os.ReadFile(goMod)
+	if err != nil {
+		return "", fmt.Errorf("failed to read go.mod: %w", err)
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`), nil
+		}
+	}
+	return "", fmt.Errorf("no module directive in %s", goMod)
+}
@@ -0,0 +1,306 @@
+package polymorph
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// CBOR major types.
+const (
+	cborUint byte = iota << 5
+	cborNegative
+	cborBytes
+	cborText
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+// cborBreak ends an item of indefinite length.
+const cborBreak = 0xff
+
+// parseCBOR decodes a single CBOR value. Byte strings become strings,
+// base64 encoded unless they are valid UTF-8; epoch time tags become
+// times and other tags are dropped, keeping the value they wrap.
+func parseCBOR(input []byte) (any, error) {
+	d := &binaryDecoder{data: input}
+	v, err := d.cbor()
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("%d trailing bytes after the CBOR value", len(d.data)-d.pos)
+	}
+	return v, nil
+}
+
+// cborHead reads the major type of the next item and its argument. The
+// argument is -1 for an indefinite length.
+func (d *binaryDecoder) cborHead() (byte, int64, uint64, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info := b[0]&0xe0, b[0]&0x1f
+	switch {
+	case info < 24:
+		return major, int64(info), uint64(info), nil
+	case info <= 27:
+		n, err := d.uint(1 << (info - 24))
+		return major, int64(info), n, err
+	case info == 31:
+		return major, -1, 0, nil
+	}
+	return 0, 0, 0, fmt.Errorf("invalid CBOR byte 0x%02x at byte %d", b[0], d.pos-1)
+}
+
+func (d *binaryDecoder) cbor() (any, error) {
+	major, info, arg, err := d.cborHead()
+	if err != nil {
+		return nil, err
+	}
+	indefinite := info < 0
+
+	switch major {
+	case cborUint:
+		if arg > math.MaxInt64 {
+			return arg, nil
+		}
+		return int64(arg), nil
+	case cborNegative:
+		if arg > math.MaxInt64 {
+			return -1 - float64(arg), nil
+		}
+		return -1 - int64(arg), nil
+	case cborBytes, cborText:
+		b, err := d.cborString(major, indefinite, arg)
+		if err != nil {
+			return nil, err
+		}
+		if major == cborText {
+			return string(b), nil
+		}
+		return binaryValue(b), nil
+	case cborArray:
+		result := []any{}
+		for i := uint64(0); indefinite || i < arg; i++ {
+			if indefinite && d.cborAtBreak() {
+				break
+			}
+			v, err := d.cbor()
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, v)
+		}
+		return result, nil
+	case cborMap:
+		result := NewObject()
+		for i := uint64(0); indefinite || i < arg; i++ {
+			if indefinite && d.cborAtBreak() {
+				break
+			}
+			k, err := d.cbor()
+			if err != nil {
+				return nil, err
+			}
+			v, err := d.cbor()
+			if err != nil {
+				return nil, err
+			}
+			key, ok := formatScalar(k)
+			if !ok {
+				return nil, fmt.Errorf("unsupported map key %v", k)
+			}
+			result.Set(key, v)
+		}
+		return result, nil
+	case cborTag:
+		v, err := d.cbor()
+		if err != nil {
+			return nil, err
+		}
+		if arg == 1 {
+			switch n := v.(type) {
+			case int64:
+				return time.Unix(n, 0).UTC(), nil
+			case float64:
+				sec, frac := math.Modf(n)
+				return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+			}
+		}
+		return v, nil
+	}
+
+	switch {
+	case info == 20 || info == 21:
+		return info == 21, nil
+	case info == 22 || info == 23:
+		return nil, nil
+	case info == 25:
+		return halfFloat(uint16(arg)), nil
+	case info == 26:
+		return float64(math.Float32frombits(uint32(arg))), nil
+	case info == 27:
+		return math.Float64frombits(arg), nil
+	}
+	return nil, fmt.Errorf("unsupported CBOR simple value %d at byte %d", arg, d.pos)
+}
+
+// cborString reads a byte or text string, joining the chunks of one of
+// indefinite length.
+func (d *binaryDecoder) cborString(major byte, indefinite bool, n uint64) ([]byte, error) {
+	if !indefinite {
+		if n > uint64(len(d.data)-d.pos) {
+			return nil, fmt.Errorf("length %d at byte %d is past the end of input", n, d.pos)
+		}
+		return d.next(int(n))
+	}
+	var result []byte
+	for !d.cborAtBreak() {
+		chunkMajor, info, n, err := d.cborHead()
+		if err != nil {
+			return nil, err
+		}
+		if chunkMajor != major || info < 0 {
+			return nil, fmt.Errorf("invalid chunk in string at byte %d", d.pos)
+		}
+		chunk, err := d.cborString(major, false, n)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, chunk...)
+	}
+	return result, nil
+}
+
+// cborAtBreak reports whether the next byte ends an indefinite length
+// item, and moves past it if so.
+func (d *binaryDecoder) cborAtBreak() bool {
+	if d.pos < len(d.data) && d.data[d.pos] == cborBreak {
+		d.pos++
+		return true
+	}
+	return false
+}
+
+// halfFloat converts an IEEE 754 half-precision float.
+func halfFloat(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -f
+	}
+	return f
+}
+
+// renderCBOR encodes data as CBOR, with map keys in order so the output is
+// the same for the same data. Times are written as RFC 3339 strings with
+// the standard date/time tag.
+func renderCBOR(data any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeCBOR(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCBOR(buf *bytes.Buffer, v any) error {
+	if n, ok := toNumber(v); ok {
+		switch n := n.(type) {
+		case int64:
+			if n >= 0 {
+				writeCBORHead(buf, cborUint, uint64(n))
+			} else {
+				writeCBORHead(buf, cborNegative, uint64(-1-n))
+			}
+		case uint64:
+			writeCBORHead(buf, cborUint, n)
+		case float64:
+			buf.WriteByte(cborSimple | 27)
+			buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(n)))
+		}
+		return nil
+	}
+
+	switch x := v.(type) {
+	case nil:
+		buf.WriteByte(cborSimple | 22)
+		return nil
+	case bool:
+		if x {
+			buf.WriteByte(cborSimple | 21)
+		} else {
+			buf.WriteByte(cborSimple | 20)
+		}
+		return nil
+	case string:
+		writeCBORHead(buf, cborText, uint64(len(x)))
+		buf.WriteString(x)
+		return nil
+	case []byte:
+		writeCBORHead(buf, cborBytes, uint64(len(x)))
+		buf.Write(x)
+		return nil
+	case time.Time:
+		writeCBORHead(buf, cborTag, 0)
+		return writeCBOR(buf, x.Format(time.RFC3339Nano))
+	}
+
+	if m, ok := toObject(v); ok {
+		writeCBORHead(buf, cborMap, uint64(m.Len()))
+		for _, k := range m.Keys() {
+			writeCBOR(buf, k)
+			if err := writeCBOR(buf, m.values[k]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if items, ok := toSlice(v); ok {
+		writeCBORHead(buf, cborArray, uint64(len(items)))
+		for _, item := range items {
+			if err := writeCBOR(buf, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("CBOR cannot encode %T", v)
+}
+
+// writeCBORHead writes a major type with its argument in the fewest bytes.
+func writeCBORHead(buf *bytes.Buffer, major byte, n uint64) {
+	switch {
+	case n < 24:
+		buf.WriteByte(major | byte(n))
+	case n <= math.MaxUint8:
+		buf.Write([]byte{major | 24, byte(n)})
+	case n <= math.MaxUint16:
+		buf.WriteByte(major | 25)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	case n <= math.MaxUint32:
+		buf.WriteByte(major | 26)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	default:
+		buf.WriteByte(major | 27)
+		buf.Write(binary.BigEndian.AppendUint64(nil, n))
+	}
+}
@@ -7,33 +7,83 @@ import (
 )
 
 var formatExtensions = map[string]string{
-	".json": "json",
-	".yaml": "yaml",
-	".yml":  "yaml",
-	".toml": "toml",
-	".csv":  "csv",
-	".md":   "markdown",
-	".html": "html",
-	".xml":  "xml",
+	".json":      "json",
+	".jsonl":     "jsonl",
+	".ndjson":    "jsonl",
+	".yaml":      "yaml",
+	".yml":       "yaml",
+	".toml":      "toml",
+	".csv":       "csv",
+	".tsv":       "tsv",
+	".md":        "markdown",
+	".html":      "html",
+	".xml":       "xml",
+	".ini":       "ini",
+	".cfg":       "ini",
+	".env":       "env",
+	".hcl":       "hcl",
+	".tf":        "hcl",
+	".tfvars":    "hcl",
+	".textproto": "textproto",
+	".pbtxt":     "textproto",
+	".txtpb":     "textproto",
+	".msgpack":   "msgpack",
+	".mpk":       "msgpack",
+	".cbor":      "cbor",
+	".xlsx":      "xlsx",
 }
 
 var formatAliases = map[string]string{
-	"md":       "markdown",
-	"yml":      "yaml",
-	"markdown": "markdown",
-	"json":     "json",
-	"yaml":     "yaml",
-	"toml":     "toml",
-	"csv":      "csv",
-	"html":     "html",
-	"xml":      "xml",
+	"md":          "markdown",
+	"yml":         "yaml",
+	"markdown":    "markdown",
+	"json":        "json",
+	"jsonl":       "jsonl",
+	"ndjson":      "jsonl",
+	"yaml":        "yaml",
+	"toml":        "toml",
+	"csv":         "csv",
+	"tsv":         "tsv",
+	"html":        "html",
+	"xml":         "xml",
+	"ini":         "ini",
+	"env":         "env",
+	"dotenv":      "env",
+	"hcl":         "hcl",
+	"tf":          "hcl",
+	"terraform":   "hcl",
+	"textproto":   "textproto",
+	"prototext":   "textproto",
+	"pbtxt":       "textproto",
+	"msgpack":     "msgpack",
+	"messagepack": "msgpack",
+	"cbor":        "cbor",
+	"xlsx":        "xlsx",
+	"excel":       "xlsx",
+}
+
+// binaryFormats are the formats whose output is not text.
+var binaryFormats = map[string]bool{
+	"msgpack": true,
+	"cbor":    true,
+	"xlsx":    true,
 }
 
 func DetectFormat(filename string) string {
+	base := strings.ToLower(filepath.Base(filename))
+	if base == ".env" || strings.HasPrefix(base, ".env.") {
+		return "env"
+	}
 	ext := strings.ToLower(filepath.Ext(filename))
 	return formatExtensions[ext]
 }
 
+// IsBinary reports whether format is written as binary data rather than
+// text.
+func IsBinary(format string) bool {
+	return binaryFormats[normalizeFormat(format)]
+}
+
 func normalizeFormat(format string) string {
 	f := strings.ToLower(strings.TrimSpace(format))
 	if alias, ok := formatAliases[f]; ok {
@@ -43,18 +93,62 @@ func normalizeFormat(format string) string {
 }
 
 func Convert(input []byte, from, to string) ([]byte, error) {
-	from = normalizeFormat(from)
-	to = normalizeFormat(to)
+	return ConvertWith(input, from, to, Options{})
+}
+
+// Options change how ConvertWith reads and transforms the data.
+type Options struct {
+	// Query is a jq-like expression run on the parsed data before it is
+	// rendered. Empty keeps the data as it is.
+	Query string
+	// InferTypes reads CSV, TSV, XML and Markdown values as the numbers,
+	// booleans, nulls, dates and JSON they look like instead of strings,
+	// and rebuilds nested objects from dotted CSV and TSV headers.
+	InferTypes bool
+}
 
-	data, err := parse(input, from)
+// ConvertWith converts input like Convert, with the given options.
+func ConvertWith(input []byte, from, to string, opts Options) ([]byte, error) {
+	data, err := Parse(input, from, opts)
+	if err != nil {
+		return nil, err
+	}
+	// A query that selects a single record still makes a table.
+	if _, ok := recordFormats[normalizeFormat(to)]; ok && strings.TrimSpace(opts.Query) != "" && isObject(data) {
+		data = []any{data}
+	}
+	return Render(data, to)
+}
+
+// Parse reads input of format and runs opts.Query on it. Objects are
+// returned as *Object, arrays as []any.
+func Parse(input []byte, format string, opts Options) (any, error) {
+	data, err := parse(input, normalizeFormat(format), opts.InferTypes)
 	if err != nil {
 		return nil, fmt.Errorf("parse error: %w", err)
 	}
+	if strings.TrimSpace(opts.Query) != "" {
+		if data, err = Query(data, opts.Query); err != nil {
+			return nil, fmt.Errorf("query error: %w", err)
+		}
+	}
+	return data, nil
+}
 
-	return render(data, to)
+// Render writes data, as returned by Parse, in format.
+func Render(data any, format string) ([]byte, error) {
+	format = normalizeFormat(format)
+	// Only TOML has local dates and times; elsewhere they are kept as
+	// text rather than becoming midnight UTC.
+	if format != "toml" {
+		data = localTimesToStrings(data)
+	}
+	return render(data, format)
 }
 
-func parse(input []byte, format string) (any, error) {
+// parse reads input of format. With infer, values in formats that only
+// hold text are read with inferValue.
+func parse(input []byte, format string, infer bool) (any, error) {
 	switch format {
 	case "json":
 		return parseJSON(input)
@@ -63,13 +157,29 @@ func parse(input []byte, format string) (any, error) {
 	case "toml":
 		return parseTOML(input)
 	case "csv":
-		return parseCSV(input)
+		return parseCSV(input, infer)
+	case "tsv", "jsonl":
+		return parseRecords(input, format, infer)
 	case "xml":
-		return parseXML(input)
+		return parseXML(input, infer)
 	case "html":
 		return parseHTML(input)
 	case "markdown":
-		return parseMarkdown(input)
+		return parseMarkdown(input, infer)
+	case "ini":
+		return parseINI(input)
+	case "env":
+		return parseDotenv(input)
+	case "hcl":
+		return parseHCL(input)
+	case "textproto":
+		return parseTextproto(input)
+	case "msgpack":
+		return parseMessagePack(input)
+	case "cbor":
+		return parseCBOR(input)
+	case "xlsx":
+		return parseXLSX(input)
 	default:
 		return nil, fmt.Errorf("unsupported input format: %s", format)
 	}
@@ -85,12 +195,30 @@ func render(data any, format string) ([]byte, error) {
 		return renderTOML(data)
 	case "csv":
 		return renderCSV(data)
+	case "tsv":
+		return renderDelimited(data, '\t', "TSV")
+	case "jsonl":
+		return renderJSONLines(data)
 	case "markdown":
 		return renderMarkdown(data)
 	case "html":
 		return renderHTML(data)
 	case "xml":
 		return renderXML(data)
+	case "ini":
+		return renderINI(data)
+	case "env":
+		return renderDotenv(data)
+	case "hcl":
+		return renderHCL(data)
+	case "textproto":
+		return renderTextproto(data)
+	case "msgpack":
+		return renderMessagePack(data)
+	case "cbor":
+		return renderCBOR(data)
+	case "xlsx":
+		return renderXLSX(data)
 	default:
 		return nil, fmt.Errorf("unsupported output format: %s", format)
 	}
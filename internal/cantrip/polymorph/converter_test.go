@@ -1,7 +1,9 @@
 package polymorph
 
 import (
+	"bytes"
 	"encoding/json"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -19,6 +21,17 @@ func TestDetectFormat(t *testing.T) {
 		{"readme.md", "markdown"},
 		{"index.html", "html"},
 		{"data.xml", "xml"},
+		{"events.ndjson", "jsonl"},
+		{"export.tsv", "tsv"},
+		{"settings.ini", "ini"},
+		{".env", "env"},
+		{"app/.env.local", "env"},
+		{"main.tf", "hcl"},
+		{"prod.tfvars", "hcl"},
+		{"config.pbtxt", "textproto"},
+		{"data.msgpack", "msgpack"},
+		{"data.cbor", "cbor"},
+		{"report.xlsx", "xlsx"},
 		{"unknown.xyz", ""},
 		{"DATA.JSON", "json"},
 	}
@@ -43,6 +56,11 @@ func TestNormalizeFormat(t *testing.T) {
 		{"md", "markdown"},
 		{"yml", "yaml"},
 		{"  yaml  ", "yaml"},
+		{"ndjson", "jsonl"},
+		{"dotenv", "env"},
+		{"terraform", "hcl"},
+		{"prototext", "textproto"},
+		{"MessagePack", "msgpack"},
 		{"unknown", "unknown"},
 	}
 
@@ -174,6 +192,29 @@ func TestConvert_JSONToXML(t *testing.T) {
 	}
 }
 
+func TestConvert_NestedValuesAsJSON(t *testing.T) {
+	tests := []struct {
+		input string
+		to    string
+		want  string
+	}{
+		{`[{"a": {"x": 1}, "b": [1, 2]}]`, "html", "<td>{&quot;x&quot;:1}</td>"},
+		{`[{"x": 1}, "y"]`, "html", "<td></td>"},
+		{`["y", {"x": 1}]`, "html", "<li>{&quot;x&quot;:1}</li>"},
+		{`{"r": {"@a": {"x": 1}, "b": 2}}`, "xml", `<r a="{&quot;x&quot;:1}">`},
+		{`["y", {"x": 1}]`, "markdown", "- {\"x\":1}\n"},
+	}
+	for _, tt := range tests {
+		output, err := Convert([]byte(tt.input), "json", tt.to)
+		if err != nil {
+			t.Fatalf("Convert(%s, %s) error = %v", tt.input, tt.to, err)
+		}
+		if !strings.Contains(string(output), tt.want) {
+			t.Errorf("Convert(%s, %s) =\n%s\nwant it to contain %s", tt.input, tt.to, output, tt.want)
+		}
+	}
+}
+
 func TestConvert_UnsupportedFormat(t *testing.T) {
 	input := []byte(`{"test": true}`)
 
@@ -194,3 +235,367 @@ func TestConvert_InvalidInput(t *testing.T) {
 		t.Error("Expected error for invalid JSON input")
 	}
 }
+
+func TestConvert_RoundTrip(t *testing.T) {
+	tests := []struct {
+		format string
+		input  string
+	}{
+		{"jsonl", `[{"id": 1, "tags": ["a", "b"]}, {"id": 2, "user": {"name": "Bob"}}]`},
+		{"tsv", `[{"name": "Alice", "note": "tab\there"}, {"name": "Bob", "note": ""}]`},
+		{"ini", `{"name": "app", "server": {"host": "localhost", "port": "8080", "paths": ["/a", "/b"]}, "db": {"url": " spaced ; value "}}`},
+		{"env", `{"API_KEY": "secret", "GREETING": "hello world", "MULTI": "line1\nline2", "QUOTE": "say \"hi\"", "EMPTY": ""}`},
+		{"hcl", `{"region": "eu-west-1", "count": 3, "enabled": true, "tags": {"env": "prod", "team name": "core"}, "resource": {"aws_s3_bucket": {"logs": {"bucket": "logs", "versioning": {"enabled": true}}, "data": {"bucket": "data"}}}, "rule": [{"port": 80}, {"port": 443}], "zones": ["a", "b"], "nothing": null}`},
+		{"textproto", `{"name": "server", "port": 8080, "ratio": 0.5, "debug": false, "hosts": ["a", "b"], "tls": {"cert": "c.pem"}, "routes": [{"path": "/"}, {"path": "/api"}]}`},
+		{"msgpack", `{"name": "Alice", "age": 30, "balance": -12.75, "big": 4294967296, "negative": -200, "active": true, "none": null, "scores": [1, 2, 3], "nested": {"long": "` + strings.Repeat("x", 300) + `"}}`},
+		{"cbor", `{"name": "Alice", "age": 30, "balance": -12.75, "big": 4294967296, "negative": -200, "active": true, "none": null, "scores": [1, 2, 3], "nested": {"long": "` + strings.Repeat("x", 300) + `"}}`},
+		{"xlsx", `[{"name": "Alice", "age": 30, "admin": true}, {"name": "Bob <b>", "age": 25.5}]`},
+		{"xlsx", `{"people": [{"name": "Alice"}], "pets": [{"name": "Rex", "kind": "dog"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			encoded, err := Convert([]byte(tt.input), "json", tt.format)
+			if err != nil {
+				t.Fatalf("Convert(json, %s) failed: %v", tt.format, err)
+			}
+			decoded, err := Convert(encoded, tt.format, "json")
+			if err != nil {
+				t.Fatalf("Convert(%s, json) failed: %v\n%s", tt.format, err, encoded)
+			}
+
+			var want, got any
+			json.Unmarshal([]byte(tt.input), &want)
+			if err := json.Unmarshal(decoded, &got); err != nil {
+				t.Fatalf("Failed to parse JSON output: %v", err)
+			}
+			if tt.format == "textproto" {
+				delete(want.(map[string]any), "nothing")
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("round trip through %s:\ngot  %s\nwant %s\nencoded:\n%s", tt.format, decoded, tt.input, encoded)
+			}
+		})
+	}
+}
+
+func TestConvert_HCLToJSON(t *testing.T) {
+	input := []byte(`# Terraform
+terraform {
+  required_version = ">= 1.5"
+}
+
+variable "region" {
+  type    = string
+  default = "eu-west-1" // overridden in prod
+}
+
+resource "aws_instance" "web" {
+  ami           = data.aws_ami.ubuntu.id
+  instance_type = "t3.micro"
+  count         = 2
+  tags = {
+    Name = "web-${var.env}"
+  }
+
+  ebs_block_device {
+    device_name = "/dev/sdb"
+  }
+  ebs_block_device {
+    device_name = "/dev/sdc"
+  }
+
+  user_data = <<-EOT
+    #!/bin/bash
+    echo hello
+  EOT
+}
+
+/* disabled
+resource "aws_eip" "ip" {}
+*/
+locals {
+  zones = [for z in var.zones : upper(z)]
+  price = 0.25
+}
+`)
+	output, err := Convert(input, "hcl", "json")
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	var result map[string]any
+	if err := json.Unmarshal(output, &result); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	web := result["resource"].(map[string]any)["aws_instance"].(map[string]any)["web"].(map[string]any)
+	checks := map[string]any{
+		"ami":       "${data.aws_ami.ubuntu.id}",
+		"count":     float64(2),
+		"user_data": "#!/bin/bash\necho hello\n",
+	}
+	for k, want := range checks {
+		if web[k] != want {
+			t.Errorf("web.%s = %#v, want %#v", k, web[k], want)
+		}
+	}
+	if name := web["tags"].(map[string]any)["Name"]; name != "web-${var.env}" {
+		t.Errorf("web.tags.Name = %v", name)
+	}
+	if devices := web["ebs_block_device"].([]any); len(devices) != 2 {
+		t.Errorf("Expected 2 ebs_block_device blocks, got %d", len(devices))
+	}
+	if _, ok := result["resource"].(map[string]any)["aws_eip"]; ok {
+		t.Error("Expected the commented out block to be skipped")
+	}
+	locals := result["locals"].(map[string]any)
+	if locals["zones"] != "${[for z in var.zones : upper(z)]}" || locals["price"] != 0.25 {
+		t.Errorf("locals = %v", locals)
+	}
+
+	rendered, err := Convert(output, "json", "hcl")
+	if err != nil {
+		t.Fatalf("Convert back failed: %v", err)
+	}
+	for _, want := range []string{
+		`resource "aws_instance" "web" {`,
+		`  ami = data.aws_ami.ubuntu.id`,
+		`  ebs_block_device {`,
+		`variable "region" {`,
+		`  zones = [for z in var.zones : upper(z)]`,
+	} {
+		if !strings.Contains(string(rendered), want) {
+			t.Errorf("Expected HCL output to contain %q, got:\n%s", want, rendered)
+		}
+	}
+}
+
+func TestConvert_INIToJSON(t *testing.T) {
+	input := []byte(`; global settings
+name = demo
+
+[database]
+host: db.local
+port = 5432
+password = "p;ss # word"
+
+# comment
+[paths]
+include[] = /usr/lib
+include[] = /opt/lib
+`)
+	output, err := Convert(input, "ini", "json")
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	var result map[string]any
+	if err := json.Unmarshal(output, &result); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	want := map[string]any{
+		"name":     "demo",
+		"database": map[string]any{"host": "db.local", "port": "5432", "password": "p;ss # word"},
+		"paths":    map[string]any{"include": []any{"/usr/lib", "/opt/lib"}},
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("got %v, want %v", result, want)
+	}
+}
+
+func TestConvert_DotenvToJSON(t *testing.T) {
+	input := []byte(`# app config
+export APP_NAME=grimorio
+PORT=8080 # inline comment
+LITERAL='no $expansion \n here'
+CERT="-----BEGIN-----
+abc
+-----END-----"
+ESCAPED="tab\there"
+`)
+	output, err := Convert(input, "dotenv", "json")
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	var result map[string]any
+	if err := json.Unmarshal(output, &result); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	want := map[string]any{
+		"APP_NAME": "grimorio",
+		"PORT":     "8080",
+		"LITERAL":  `no $expansion \n here`,
+		"CERT":     "-----BEGIN-----\nabc\n-----END-----",
+		"ESCAPED":  "tab\there",
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("got %v, want %v", result, want)
+	}
+
+	nested, err := Convert([]byte(`{"db": {"host": "x", "ports": [1, 2]}, "app-name": "y"}`), "json", "env")
+	if err != nil {
+		t.Fatalf("Convert to env failed: %v", err)
+	}
+	if got, want := string(nested), "db_host=x\ndb_ports_0=1\ndb_ports_1=2\napp_name=y\n"; got != want {
+		t.Errorf("env output = %q, want %q", got, want)
+	}
+}
+
+func TestConvert_TextprotoToJSON(t *testing.T) {
+	input := []byte(`# proto-file: config.proto
+name: "serv" 'ice'
+replicas: 3
+mode: FAST
+ports: 80
+ports: 443
+limits < cpu: 1.5f memory: 0x100 >
+backend {
+  host: "a"
+}
+backend {
+  host: "b"
+}
+`)
+	output, err := Convert(input, "textproto", "json")
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	var result map[string]any
+	if err := json.Unmarshal(output, &result); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	want := map[string]any{
+		"name":     "service",
+		"replicas": float64(3),
+		"mode":     "FAST",
+		"ports":    []any{float64(80), float64(443)},
+		"limits":   map[string]any{"cpu": 1.5, "memory": float64(256)},
+		"backend":  []any{map[string]any{"host": "a"}, map[string]any{"host": "b"}},
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("got %v, want %v", result, want)
+	}
+}
+
+func TestConvert_MessagePackEncoding(t *testing.T) {
+	output, err := Convert([]byte(`{"a": 1, "b": [true, null, -1, 1.5]}`), "json", "msgpack")
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	want := []byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x94, 0xc3, 0xc0, 0xff, 0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}
+	if !bytes.Equal(output, want) {
+		t.Errorf("got % x, want % x", output, want)
+	}
+
+	// A timestamp extension and binary data that is not UTF-8.
+	input := []byte{0x82, 0xa1, 't', 0xd6, 0xff, 0x00, 0x00, 0x00, 0x3c, 0xa1, 'b', 0xc4, 0x02, 0xff, 0xfe}
+	decoded, err := Convert(input, "msgpack", "json")
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if got := string(decoded); !strings.Contains(got, `"t": "1970-01-01T00:01:00Z"`) || !strings.Contains(got, `"b": "//4="`) {
+		t.Errorf("got %s", got)
+	}
+
+	if _, err := Convert([]byte{0x92, 0x01}, "msgpack", "json"); err == nil {
+		t.Error("Expected error for truncated MessagePack input")
+	}
+}
+
+func TestConvert_CBOREncoding(t *testing.T) {
+	output, err := Convert([]byte(`{"a": 1, "b": [true, null, -1, 1.5]}`), "json", "cbor")
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	want := []byte{0xa2, 0x61, 'a', 0x01, 0x61, 'b', 0x84, 0xf5, 0xf6, 0x20, 0xfb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}
+	if !bytes.Equal(output, want) {
+		t.Errorf("got % x, want % x", output, want)
+	}
+
+	// Indefinite-length map and text, a half float and an epoch tag.
+	input := []byte{0xbf, 0x61, 'h', 0xf9, 0x3e, 0x00, 0x61, 's', 0x7f, 0x62, 'a', 'b', 0x61, 'c', 0xff, 0x61, 't', 0xc1, 0x18, 0x3c, 0xff}
+	decoded, err := Convert(input, "cbor", "json")
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	var result map[string]any
+	if err := json.Unmarshal(decoded, &result); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	if result["h"] != 1.5 || result["s"] != "abc" || result["t"] != "1970-01-01T00:01:00Z" {
+		t.Errorf("got %v", result)
+	}
+
+	if _, err := Convert([]byte{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, "cbor", "json"); err == nil {
+		t.Error("Expected error for a CBOR length past the end of input")
+	}
+}
+
+func TestConvert_XLSXSheetNames(t *testing.T) {
+	used := make(map[string]bool)
+	tests := []struct{ name, want string }{
+		{"Q1/Q2: sales", "Q1_Q2_ sales"},
+		{"q1/q2: SALES", "q1_q2_ SALES (2)"},
+		{strings.Repeat("a", 40), strings.Repeat("a", 31)},
+		{"", "Sheet"},
+	}
+	for _, tt := range tests {
+		if got := xlsxSheetName(tt.name, used); got != tt.want {
+			t.Errorf("xlsxSheetName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := Convert([]byte(`{"a": 1}`), "json", "xlsx"); err == nil {
+		t.Error("Expected error for XLSX output of a plain object")
+	}
+}
+
+func TestConvert_KeepsKeyOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		from, to string
+		want     string
+	}{
+		{"json", `{"zeta": 1, "alpha": {"b": 2, "a": 3}}`, "json", "json", "{\n  \"zeta\": 1,\n  \"alpha\": {\n    \"b\": 2,\n    \"a\": 3\n  }\n}"},
+		{"yaml to json", "zeta: 1\nalpha: 2\n", "yaml", "json", "{\n  \"zeta\": 1,\n  \"alpha\": 2\n}"},
+		{"toml", "zeta = 1\nalpha = 2\n\n[z]\nb = 1\na = 2\n\n[a]\nx = 1\n", "toml", "yaml", "zeta: 1\nalpha: 2\nz:\n    b: 1\n    a: 2\na:\n    x: 1\n"},
+		{"xml", `<r z="1" a="2"><y>3</y><b>4</b></r>`, "xml", "json", "{\n  \"r\": {\n    \"@z\": \"1\",\n    \"@a\": \"2\",\n    \"y\": \"3\",\n    \"b\": \"4\"\n  }\n}"},
+		{"csv header", `[{"name": "Ann", "age": 31}, {"name": "Bob", "city": "Rome"}]`, "json", "csv", "name,age,city\nAnn,31,\nBob,,Rome\n"},
+		{"csv to yaml", "name,age\nAnn,31\n", "csv", "yaml", "- name: Ann\n  age: \"31\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := Convert([]byte(tt.input), tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			if string(output) != tt.want {
+				t.Errorf("Convert() = %q, want %q", output, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvert_YAMLTOMLComments(t *testing.T) {
+	yamlInput := "# Service config\n\nname: api # the service name\nserver:\n    # where to listen\n    port: 8080\n    host: localhost\n"
+	tomlWant := "# Service config\n\nname = \"api\" # the service name\n\n[server]\n  # where to listen\n  port = 8080\n  host = \"localhost\"\n"
+
+	tomlOut, err := Convert([]byte(yamlInput), "yaml", "toml")
+	if err != nil {
+		t.Fatalf("yaml to toml failed: %v", err)
+	}
+	if string(tomlOut) != tomlWant {
+		t.Errorf("yaml to toml = %q, want %q", tomlOut, tomlWant)
+	}
+
+	yamlOut, err := Convert(tomlOut, "toml", "yaml")
+	if err != nil {
+		t.Fatalf("toml to yaml failed: %v", err)
+	}
+	if string(yamlOut) != yamlInput {
+		t.Errorf("toml to yaml = %q, want %q", yamlOut, yamlInput)
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"fmt"
 )
 
-func parseCSV(input []byte) (any, error) {
+// parseCSV reads rows into objects keyed by the header row, inferring the
+// type of each column with infer.
+func parseCSV(input []byte, infer bool) (any, error) {
 	reader := csv.NewReader(bytes.NewReader(input))
 	records, err := reader.ReadAll()
 	if err != nil {
@@ -19,40 +21,66 @@ func parseCSV(input []byte) (any, error) {
 	headers := records[0]
 	var result []any
 	for _, row := range records[1:] {
-		obj := make(map[string]any)
+		obj := NewObject()
 		for i, val := range row {
 			if i < len(headers) {
-				obj[headers[i]] = val
+				obj.Set(headers[i], val)
 			}
 		}
 		result = append(result, obj)
 	}
+	if infer {
+		return inferRecords(result), nil
+	}
 	return result, nil
 }
 
 func renderCSV(data any) ([]byte, error) {
-	rows, ok := toSlice(data)
-	if !ok || len(rows) == 0 {
-		return nil, fmt.Errorf("CSV output requires an array of objects")
+	return renderDelimited(data, ',', "CSV")
+}
+
+// renderDelimited writes an array of objects as CSV or TSV, depending on
+// comma. name is the format as shown in errors. Nested objects and arrays
+// are flattened into dotted columns such as "address.city".
+func renderDelimited(data any, comma rune, name string) ([]byte, error) {
+	items, ok := toSlice(data)
+	if !ok || len(items) == 0 {
+		return nil, fmt.Errorf("%s output requires an array of objects", name)
+	}
+
+	rows := make([]any, 0, len(items))
+	for _, item := range items {
+		obj, ok := toObject(item)
+		if !ok {
+			continue
+		}
+		flat := NewObject()
+		for _, k := range obj.Keys() {
+			flattenDotted(k, obj.values[k], flat)
+		}
+		if _, ordered := item.(*Object); ordered {
+			rows = append(rows, flat)
+		} else {
+			rows = append(rows, flat.values)
+		}
 	}
 
 	headers := extractHeaders(rows)
 	if len(headers) == 0 {
-		return nil, fmt.Errorf("CSV output requires objects with keys")
+		return nil, fmt.Errorf("%s output requires objects with keys", name)
 	}
 
 	var buf bytes.Buffer
 	writer := csv.NewWriter(&buf)
+	writer.Comma = comma
 
 	writer.Write(headers)
 	for _, row := range rows {
-		obj, ok := toMap(row)
-		if !ok {
-			continue
-		}
+		obj, _ := toObject(row)
 		record := make([]string, len(headers))
 		for i, h := range headers {
-			record[i] = fmt.Sprintf("%v", obj[h])
+			v, _ := obj.Get(h)
+			record[i] = cell(v)
 		}
 		writer.Write(record)
 	}
@@ -0,0 +1,154 @@
+package polymorph
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// parseDotenv reads KEY=value lines into a flat object of strings. Lines
+// may start with "export"; single-quoted values are literal, double-quoted
+// ones may span lines and use backslash escapes, and unquoted ones end at
+// a " #" comment.
+func parseDotenv(input []byte) (any, error) {
+	result := NewObject()
+	rest := string(input)
+	n := 0
+	for rest != "" {
+		var line string
+		line, rest, _ = strings.Cut(rest, "\n")
+		n++
+		line = strings.TrimSpace(strings.TrimSuffix(line, "\r"))
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+		key, value, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=value", n)
+		}
+		value = strings.TrimLeft(value, " \t")
+
+		switch {
+		case strings.HasPrefix(value, "'"):
+			end := strings.IndexByte(value[1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated single quote", n)
+			}
+			value = value[1 : end+1]
+		case strings.HasPrefix(value, `"`):
+			s, remaining, lines, err := readDoubleQuoted(value[1:], rest)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			value, rest = s, remaining
+			n += lines
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = value[:i]
+			}
+			value = strings.TrimSpace(value)
+		}
+		result.Set(key, value)
+	}
+	return result, nil
+}
+
+// readDoubleQuoted reads a double-quoted value that starts in line and may
+// continue into rest. It returns the unescaped value, what is left of the
+// input after its line, and the number of extra lines it took.
+func readDoubleQuoted(line, rest string) (string, string, int, error) {
+	var b strings.Builder
+	lines := 0
+	for {
+		for i := 0; i < len(line); i++ {
+			c := line[i]
+			switch {
+			case c == '"':
+				return b.String(), rest, lines, nil
+			case c == '\\' && i+1 < len(line):
+				i++
+				switch line[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 'r':
+					b.WriteByte('\r')
+				case 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(line[i])
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+		if rest == "" {
+			return "", "", lines, fmt.Errorf("unterminated double quote")
+		}
+		b.WriteByte('\n')
+		line, rest, _ = strings.Cut(rest, "\n")
+		line = strings.TrimSuffix(line, "\r")
+		lines++
+	}
+}
+
+// renderDotenv writes an object as KEY=value lines. Nested objects are
+// flattened with "_" between the keys and arrays get an index suffix.
+func renderDotenv(data any) ([]byte, error) {
+	obj, ok := toObject(data)
+	if !ok {
+		return nil, fmt.Errorf(".env output requires an object")
+	}
+
+	flat := NewObject()
+	flattenEnv("", obj, flat)
+
+	var buf bytes.Buffer
+	for _, k := range flat.Keys() {
+		s, _ := formatScalar(flat.values[k])
+		fmt.Fprintf(&buf, "%s=%s\n", k, dotenvValue(s))
+	}
+	return buf.Bytes(), nil
+}
+
+func flattenEnv(prefix string, v any, flat *Object) {
+	if obj, ok := toObject(v); ok {
+		for _, k := range obj.Keys() {
+			flattenEnv(joinEnvKey(prefix, k), obj.values[k], flat)
+		}
+		return
+	}
+	if list, ok := toSlice(v); ok {
+		for i, val := range list {
+			flattenEnv(joinEnvKey(prefix, fmt.Sprint(i)), val, flat)
+		}
+		return
+	}
+	flat.Set(prefix, v)
+}
+
+// joinEnvKey appends key to prefix, replacing the characters a variable
+// name cannot hold with "_".
+func joinEnvKey(prefix, key string) string {
+	key = strings.Map(func(r rune) rune {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, key)
+	if prefix == "" {
+		return key
+	}
+	return prefix + "_" + key
+}
+
+// dotenvValue double-quotes values that would not read back as they are.
+func dotenvValue(s string) string {
+	if s != "" && s == strings.TrimSpace(s) && !strings.ContainsAny(s, "\"'#\\\n\r\t $`") {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
@@ -0,0 +1,676 @@
+package polymorph
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	hclIdentPattern  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+	hclNumberPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+)
+
+// parseHCL reads an HCL file such as a Terraform configuration. Attributes
+// become keys and blocks nested objects, one level per label, so
+// `resource "aws_s3_bucket" "logs" {}` ends up under
+// resource.aws_s3_bucket.logs; a block repeated at the same path becomes an
+// array. Expressions other than literals are kept as "${expression}"
+// strings.
+func parseHCL(input []byte) (any, error) {
+	p := &hclParser{src: string(input)}
+	body, err := p.body(0)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", p.line(), err)
+	}
+	return body, nil
+}
+
+type hclParser struct {
+	src string
+	pos int
+}
+
+func (p *hclParser) line() int {
+	return strings.Count(p.src[:p.pos], "\n") + 1
+}
+
+func (p *hclParser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+// skip moves past blanks and comments, and past newlines too when
+// newlines is set.
+func (p *hclParser) skip(newlines bool) {
+	for p.pos < len(p.src) {
+		rest := p.src[p.pos:]
+		switch {
+		case rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\r':
+			p.pos++
+		case rest[0] == '\n' && newlines:
+			p.pos++
+		case rest[0] == '#' || strings.HasPrefix(rest, "//"):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			p.pos += end
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest[2:], "*/")
+			if end < 0 {
+				p.pos = len(p.src)
+				return
+			}
+			p.pos += end + 4
+		default:
+			return
+		}
+	}
+}
+
+func (p *hclParser) ident() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '_' || c == '-' && p.pos > start || isAlnum(c) {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.src[start:p.pos]
+}
+
+// body reads attributes and blocks up to end, or to the end of the input
+// when end is 0.
+func (p *hclParser) body(end byte) (*Object, error) {
+	result := NewObject()
+	for {
+		p.skip(true)
+		if p.pos >= len(p.src) {
+			if end != 0 {
+				return nil, fmt.Errorf("missing closing %q", end)
+			}
+			return result, nil
+		}
+		if p.peek() == end {
+			p.pos++
+			return result, nil
+		}
+
+		name := p.ident()
+		if name == "" {
+			return nil, fmt.Errorf("unexpected %q", p.peek())
+		}
+		p.skip(false)
+
+		if p.peek() == '=' {
+			p.pos++
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			result.Set(name, value)
+			p.skip(false)
+			if c := p.peek(); c != '\n' && c != 0 && c != end {
+				return nil, fmt.Errorf("unexpected %q after %s", c, name)
+			}
+			continue
+		}
+
+		path := []string{name}
+		for p.peek() != '{' {
+			switch {
+			case p.peek() == '"':
+				label, err := p.quoted()
+				if err != nil {
+					return nil, err
+				}
+				path = append(path, label)
+			case isAlnum(p.peek()) || p.peek() == '_':
+				path = append(path, p.ident())
+			default:
+				return nil, fmt.Errorf("expected = or { after %s", name)
+			}
+			p.skip(false)
+		}
+		p.pos++
+		block, err := p.body('}')
+		if err != nil {
+			return nil, err
+		}
+		if err := mergeHCLBlock(result, path, block); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// mergeHCLBlock stores block under path, turning a repeated block into an
+// array.
+func mergeHCLBlock(obj *Object, path []string, block *Object) error {
+	for _, key := range path[:len(path)-1] {
+		next, ok := obj.values[key].(*Object)
+		if !ok {
+			if obj.has(key) {
+				return fmt.Errorf("block %s conflicts with an attribute", strings.Join(path, "."))
+			}
+			next = NewObject()
+			obj.Set(key, next)
+		}
+		obj = next
+	}
+
+	last := path[len(path)-1]
+	switch existing := obj.values[last].(type) {
+	case nil:
+		obj.Set(last, block)
+	case *Object:
+		obj.Set(last, []any{existing, block})
+	case []any:
+		obj.Set(last, append(existing, block))
+	default:
+		return fmt.Errorf("block %s conflicts with an attribute", strings.Join(path, "."))
+	}
+	return nil
+}
+
+// value reads an expression. Literals are decoded; anything else is kept
+// as written, wrapped in "${}".
+func (p *hclParser) value() (any, error) {
+	p.skip(false)
+	start := p.pos
+
+	var v any
+	var err error
+	switch c := p.peek(); {
+	case c == '"':
+		v, err = p.quoted()
+	case strings.HasPrefix(p.src[p.pos:], "<<"):
+		return p.heredoc()
+	case (c == '[' || c == '{') && strings.HasPrefix(strings.TrimLeft(p.src[p.pos+1:], " \t\r\n"), "for "):
+		return p.expression(start)
+	case c == '[':
+		v, err = p.tuple()
+	case c == '{':
+		v, err = p.object()
+	default:
+		return p.expression(start)
+	}
+
+	p.skip(false)
+	if err != nil || !p.atValueEnd() {
+		return p.expression(start)
+	}
+	return v, nil
+}
+
+// atValueEnd reports whether the parser is where a value may end.
+func (p *hclParser) atValueEnd() bool {
+	switch p.peek() {
+	case 0, '\n', ',', ']', '}', ')':
+		return true
+	}
+	return false
+}
+
+// expression reads from start to the end of the value, keeping brackets
+// and strings whole, and decodes it when it is a number, bool or null.
+func (p *hclParser) expression(start int) (any, error) {
+	p.pos = start
+	depth := 0
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if depth == 0 && (c == '\n' || c == ',' || c == ']' || c == '}' || c == ')') {
+			break
+		}
+		if depth == 0 && (c == '#' || strings.HasPrefix(p.src[p.pos:], "//") || strings.HasPrefix(p.src[p.pos:], "/*")) {
+			break
+		}
+		switch c {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case '"':
+			if _, err := p.quoted(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		p.pos++
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced brackets in expression")
+	}
+
+	expr := strings.TrimSpace(p.src[start:p.pos])
+	switch {
+	case expr == "":
+		return nil, fmt.Errorf("missing value")
+	case expr == "true" || expr == "false":
+		return expr == "true", nil
+	case expr == "null":
+		return nil, nil
+	case hclNumberPattern.MatchString(expr):
+		if n, err := strconv.ParseInt(expr, 10, 64); err == nil {
+			return n, nil
+		}
+		return strconv.ParseFloat(expr, 64)
+	}
+	return "${" + expr + "}", nil
+}
+
+// quoted reads a string literal. Escapes are decoded; ${} and %{} template
+// sequences are kept as written.
+func (p *hclParser) quoted() (string, error) {
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == '"':
+			p.pos++
+			return b.String(), nil
+		case c == '\n':
+			return "", fmt.Errorf("unterminated string")
+		case c == '\\' && p.pos+1 < len(p.src):
+			p.pos += 2
+			switch e := p.src[p.pos-1]; e {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u', 'U':
+				size := 4
+				if e == 'U' {
+					size = 8
+				}
+				if p.pos+size > len(p.src) {
+					return "", fmt.Errorf("invalid escape")
+				}
+				r, err := strconv.ParseUint(p.src[p.pos:p.pos+size], 16, 32)
+				if err != nil {
+					return "", fmt.Errorf("invalid escape: %w", err)
+				}
+				b.WriteRune(rune(r))
+				p.pos += size
+			default:
+				b.WriteByte(e)
+			}
+		case (c == '$' || c == '%') && strings.HasPrefix(p.src[p.pos+1:], "{"):
+			start := p.pos
+			p.pos += 2
+			depth := 1
+			for depth > 0 && p.pos < len(p.src) {
+				switch p.src[p.pos] {
+				case '{':
+					depth++
+				case '}':
+					depth--
+				case '"':
+					if _, err := p.quoted(); err != nil {
+						return "", err
+					}
+					continue
+				}
+				p.pos++
+			}
+			b.WriteString(p.src[start:p.pos])
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+// heredoc reads a <<EOF or <<-EOF string; the indented form drops the
+// indentation the lines have in common.
+func (p *hclParser) heredoc() (string, error) {
+	p.pos += 2
+	indented := p.peek() == '-'
+	if indented {
+		p.pos++
+	}
+	marker := p.ident()
+	if marker == "" {
+		return "", fmt.Errorf("missing heredoc marker")
+	}
+	nl := strings.IndexByte(p.src[p.pos:], '\n')
+	if nl < 0 {
+		return "", fmt.Errorf("unterminated heredoc")
+	}
+	p.pos += nl + 1
+
+	var lines []string
+	for {
+		if p.pos >= len(p.src) {
+			return "", fmt.Errorf("unterminated heredoc %s", marker)
+		}
+		line, _, _ := strings.Cut(p.src[p.pos:], "\n")
+		p.pos += len(line)
+		if strings.TrimSpace(line) == marker {
+			break
+		}
+		p.pos++
+		lines = append(lines, strings.TrimSuffix(line, "\r"))
+	}
+
+	if indented {
+		indent := -1
+		for _, l := range lines {
+			if strings.TrimSpace(l) == "" {
+				continue
+			}
+			n := len(l) - len(strings.TrimLeft(l, " \t"))
+			if indent < 0 || n < indent {
+				indent = n
+			}
+		}
+		for i, l := range lines {
+			if len(l) >= indent && indent > 0 {
+				lines[i] = l[indent:]
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+func (p *hclParser) tuple() ([]any, error) {
+	p.pos++
+	result := []any{}
+	for {
+		p.skip(true)
+		if p.peek() == ']' {
+			p.pos++
+			return result, nil
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v)
+		p.skip(true)
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			return nil, fmt.Errorf("expected , or ] in list")
+		}
+	}
+}
+
+func (p *hclParser) object() (*Object, error) {
+	p.pos++
+	result := NewObject()
+	for {
+		p.skip(true)
+		if p.peek() == '}' {
+			p.pos++
+			return result, nil
+		}
+
+		var key string
+		if p.peek() == '"' {
+			k, err := p.quoted()
+			if err != nil {
+				return nil, err
+			}
+			key = k
+		} else if key = p.ident(); key == "" {
+			return nil, fmt.Errorf("expected an object key")
+		}
+		p.skip(false)
+		if c := p.peek(); c != '=' && c != ':' {
+			return nil, fmt.Errorf("expected = after %s", key)
+		}
+		p.pos++
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		result.Set(key, v)
+
+		p.skip(false)
+		switch p.peek() {
+		case ',', '\n':
+			p.pos++
+		case '}':
+		default:
+			return nil, fmt.Errorf("expected , or } in object")
+		}
+	}
+}
+
+func isAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// renderHCL writes an object as HCL. Objects holding other objects become
+// blocks and arrays of two or more objects repeated blocks; at the top
+// level, an object made only of objects is written as one block per key,
+// with the keys as labels, so resource.aws_s3_bucket.logs comes back as
+// `resource "aws_s3_bucket" "logs" {}`. Everything else is an attribute,
+// and "${expression}" strings are written as bare expressions.
+func renderHCL(data any) ([]byte, error) {
+	obj, ok := toObject(data)
+	if !ok {
+		return nil, fmt.Errorf("HCL output requires an object")
+	}
+	var buf bytes.Buffer
+	if err := writeHCLBody(&buf, obj, "", true); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeHCLBody(buf *bytes.Buffer, obj *Object, indent string, top bool) error {
+	var attrs, blocks []string
+	for _, k := range obj.Keys() {
+		if isHCLBlock(obj.values[k]) {
+			blocks = append(blocks, k)
+		} else {
+			attrs = append(attrs, k)
+		}
+	}
+
+	for _, k := range attrs {
+		if !hclIdentPattern.MatchString(k) {
+			return fmt.Errorf("HCL attribute name %q is not an identifier", k)
+		}
+		fmt.Fprintf(buf, "%s%s = %s\n", indent, k, hclExpr(obj.values[k], indent))
+	}
+
+	for _, k := range blocks {
+		if !hclIdentPattern.MatchString(k) {
+			return fmt.Errorf("HCL block name %q is not an identifier", k)
+		}
+		if m, ok := toObject(obj.values[k]); ok {
+			if err := writeHCLBlock(buf, k, nil, m, indent, top); err != nil {
+				return err
+			}
+			continue
+		}
+		items, _ := toSlice(obj.values[k])
+		for _, item := range items {
+			m, _ := toObject(item)
+			if err := writeHCLBlock(buf, k, nil, m, indent, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeHCLBlock(buf *bytes.Buffer, name string, labels []string, obj *Object, indent string, top bool) error {
+	if top && obj.Len() > 0 && allMaps(obj) {
+		for _, k := range obj.Keys() {
+			child, _ := toObject(obj.values[k])
+			if err := writeHCLBlock(buf, name, append(labels, k), child, indent, top); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("{\n")) {
+		buf.WriteString("\n")
+	}
+	buf.WriteString(indent + name)
+	for _, l := range labels {
+		buf.WriteString(" " + quoteHCL(l))
+	}
+	buf.WriteString(" {\n")
+	if err := writeHCLBody(buf, obj, indent+"  ", false); err != nil {
+		return err
+	}
+	buf.WriteString(indent + "}\n")
+	return nil
+}
+
+// isHCLBlock reports whether v is written as a block rather than as an
+// attribute.
+func isHCLBlock(v any) bool {
+	if m, ok := toObject(v); ok {
+		return !isFlat(m)
+	}
+	items, ok := toSlice(v)
+	if !ok || len(items) < 2 {
+		return false
+	}
+	for _, item := range items {
+		if _, ok := toObject(item); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// isFlat reports whether m holds no objects, directly or in arrays.
+func isFlat(m *Object) bool {
+	for _, v := range m.values {
+		if _, ok := toObject(v); ok {
+			return false
+		}
+		if items, ok := toSlice(v); ok {
+			for _, item := range items {
+				if _, ok := toObject(item); ok {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func allMaps(m *Object) bool {
+	for _, v := range m.values {
+		if _, ok := toObject(v); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func hclExpr(v any, indent string) string {
+	if s, ok := v.(string); ok {
+		if expr, ok := hclExpression(s); ok {
+			return expr
+		}
+		return quoteHCL(s)
+	}
+	if m, ok := toObject(v); ok {
+		if m.Len() == 0 {
+			return "{}"
+		}
+		var b strings.Builder
+		b.WriteString("{\n")
+		for _, k := range m.Keys() {
+			key := k
+			if !hclIdentPattern.MatchString(k) {
+				key = quoteHCL(k)
+			}
+			fmt.Fprintf(&b, "%s  %s = %s\n", indent, key, hclExpr(m.values[k], indent+"  "))
+		}
+		b.WriteString(indent + "}")
+		return b.String()
+	}
+	if items, ok := toSlice(v); ok {
+		parts := make([]string, len(items))
+		for i, item := range items {
+			parts[i] = hclExpr(item, indent)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	}
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return fmt.Sprint(v)
+	}
+	s, _ := formatScalar(v)
+	if hclNumberPattern.MatchString(s) {
+		return s
+	}
+	return quoteHCL(s)
+}
+
+// hclExpression returns the expression of a string that is a single
+// "${expression}" template.
+func hclExpression(s string) (string, bool) {
+	if !strings.HasPrefix(s, "${") || !strings.HasSuffix(s, "}") || len(s) < 4 {
+		return "", false
+	}
+	depth := 0
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 && i != len(s)-1 {
+				return "", false
+			}
+		}
+	}
+	return strings.TrimSpace(s[2 : len(s)-1]), depth == 0
+}
+
+func quoteHCL(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == utf8.RuneError {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
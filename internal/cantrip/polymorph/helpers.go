@@ -1,8 +1,14 @@
 package polymorph
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"math"
 	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
 )
 
 func toSlice(v any) ([]any, bool) {
@@ -19,37 +25,32 @@ func toSlice(v any) ([]any, bool) {
 	return nil, false
 }
 
-func toMap(v any) (map[string]any, bool) {
-	switch m := v.(type) {
-	case map[string]any:
-		return m, true
-	case map[any]any:
-		result := make(map[string]any)
-		for k, val := range m {
-			result[fmt.Sprintf("%v", k)] = val
-		}
-		return result, true
-	}
-	return nil, false
-}
-
+// extractHeaders returns the keys of the objects in rows in the order they
+// are first seen. Rows that are all plain maps, which have no order, give
+// sorted keys.
 func extractHeaders(rows []any) []string {
 	seen := make(map[string]bool)
 	var headers []string
+	ordered := false
 
 	for _, row := range rows {
-		obj, ok := toMap(row)
+		obj, ok := toObject(row)
 		if !ok {
 			continue
 		}
-		for k := range obj {
+		if _, ok := row.(*Object); ok {
+			ordered = true
+		}
+		for _, k := range obj.Keys() {
 			if !seen[k] {
 				seen[k] = true
 				headers = append(headers, k)
 			}
 		}
 	}
-	sort.Strings(headers)
+	if !ordered {
+		sort.Strings(headers)
+	}
 	return headers
 }
 
@@ -61,3 +62,96 @@ func sortedKeys(m map[string]any) []string {
 	sort.Strings(keys)
 	return keys
 }
+
+// formatScalar renders a string, number, bool, time or nil as text, for
+// formats whose values are all strings. It reports false for maps and
+// slices.
+func formatScalar(v any) (string, bool) {
+	switch x := v.(type) {
+	case nil:
+		return "", true
+	case string:
+		return x, true
+	case bool:
+		return strconv.FormatBool(x), true
+	case float64:
+		return formatFloat(x), true
+	case float32:
+		return formatFloat(float64(x)), true
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, json.Number:
+		return fmt.Sprintf("%v", x), true
+	case time.Time:
+		if s, ok := localTimeString(x); ok {
+			return s, true
+		}
+		return x.Format(time.RFC3339Nano), true
+	}
+	if _, ok := toObject(v); ok {
+		return "", false
+	}
+	if _, ok := toSlice(v); ok {
+		return "", false
+	}
+	return fmt.Sprintf("%v", v), true
+}
+
+// formatFloat renders whole numbers without a decimal point or exponent.
+func formatFloat(f float64) string {
+	if f == math.Trunc(f) && math.Abs(f) < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// toNumber returns a number as int64, uint64 or float64, whole floats
+// becoming int64 so that binary formats store them as integers.
+func toNumber(v any) (any, bool) {
+	switch x := v.(type) {
+	case int:
+		return int64(x), true
+	case int8:
+		return int64(x), true
+	case int16:
+		return int64(x), true
+	case int32:
+		return int64(x), true
+	case int64:
+		return x, true
+	case uint:
+		return uint64(x), true
+	case uint8:
+		return uint64(x), true
+	case uint16:
+		return uint64(x), true
+	case uint32:
+		return uint64(x), true
+	case uint64:
+		return x, true
+	case float32:
+		return toNumber(float64(x))
+	case float64:
+		if x == math.Trunc(x) && x >= math.MinInt64 && x < math.MaxInt64 {
+			return int64(x), true
+		}
+		return x, true
+	case json.Number:
+		if n, err := x.Int64(); err == nil {
+			return n, true
+		}
+		if n, err := strconv.ParseUint(x.String(), 10, 64); err == nil {
+			return n, true
+		}
+		f, err := x.Float64()
+		return f, err == nil
+	}
+	return nil, false
+}
+
+// binaryValue turns bytes read from a binary format into a string, base64
+// encoded unless they are valid UTF-8.
+func binaryValue(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
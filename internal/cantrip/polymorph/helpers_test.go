@@ -58,54 +58,61 @@ func TestToSlice(t *testing.T) {
 	}
 }
 
-func TestToMap(t *testing.T) {
+func TestToObject(t *testing.T) {
+	ordered := NewObject()
+	ordered.Set("zebra", 1)
+	ordered.Set("apple", 2)
+
 	tests := []struct {
-		name   string
-		input  any
-		want   map[string]any
-		wantOk bool
+		name     string
+		input    any
+		wantKeys []string
+		wantOk   bool
 	}{
 		{
-			name:   "map[string]any",
-			input:  map[string]any{"key": "value"},
-			want:   map[string]any{"key": "value"},
-			wantOk: true,
+			name:     "*Object keeps its order",
+			input:    ordered,
+			wantKeys: []string{"zebra", "apple"},
+			wantOk:   true,
 		},
 		{
-			name:   "map[any]any",
-			input:  map[any]any{"key": "value", 123: "num"},
-			want:   map[string]any{"key": "value", "123": "num"},
-			wantOk: true,
+			name:     "map[string]any",
+			input:    map[string]any{"key": "value", "a": 1},
+			wantKeys: []string{"a", "key"},
+			wantOk:   true,
+		},
+		{
+			name:     "map[any]any",
+			input:    map[any]any{"key": "value", 123: "num"},
+			wantKeys: []string{"123", "key"},
+			wantOk:   true,
 		},
 		{
 			name:   "string - not a map",
 			input:  "not a map",
-			want:   nil,
 			wantOk: false,
 		},
 		{
 			name:   "slice - not a map",
 			input:  []any{"a", "b"},
-			want:   nil,
 			wantOk: false,
 		},
 		{
 			name:   "nil",
 			input:  nil,
-			want:   nil,
 			wantOk: false,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, ok := toMap(tt.input)
+			got, ok := toObject(tt.input)
 			if ok != tt.wantOk {
-				t.Errorf("toMap() ok = %v, wantOk %v", ok, tt.wantOk)
+				t.Errorf("toObject() ok = %v, wantOk %v", ok, tt.wantOk)
 				return
 			}
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("toMap() = %v, want %v", got, tt.want)
+			if ok && !reflect.DeepEqual(got.Keys(), tt.wantKeys) {
+				t.Errorf("toObject() keys = %v, want %v", got.Keys(), tt.wantKeys)
 			}
 		})
 	}
@@ -125,6 +132,21 @@ func TestExtractHeaders(t *testing.T) {
 	}
 }
 
+func TestExtractHeaders_Ordered(t *testing.T) {
+	first, second := NewObject(), NewObject()
+	first.Set("name", "Alice")
+	first.Set("age", 30)
+	second.Set("name", "Bob")
+	second.Set("city", "NYC")
+
+	headers := extractHeaders([]any{first, second})
+
+	expected := []string{"name", "age", "city"}
+	if !reflect.DeepEqual(headers, expected) {
+		t.Errorf("extractHeaders() = %v, want %v", headers, expected)
+	}
+}
+
 func TestExtractHeaders_EmptyRows(t *testing.T) {
 	headers := extractHeaders([]any{})
 	if len(headers) != 0 {
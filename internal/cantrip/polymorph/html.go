@@ -25,10 +25,12 @@ func parseHTML(input []byte) (any, error) {
 	text := stripHTMLTags(content)
 	text = strings.TrimSpace(text)
 	if text != "" {
-		return map[string]any{"content": text}, nil
+		obj := NewObject()
+		obj.Set("content", text)
+		return obj, nil
 	}
 
-	return map[string]any{}, nil
+	return NewObject(), nil
 }
 
 func parseHTMLTable(content string) []any {
@@ -78,13 +80,13 @@ func parseHTMLTable(content string) []any {
 		if len(cells) == 0 {
 			continue
 		}
-		obj := make(map[string]any)
+		obj := NewObject()
 		for j, cell := range cells {
 			if j < len(headers) {
-				obj[headers[j]] = strings.TrimSpace(stripHTMLTags(cell[1]))
+				obj.Set(headers[j], strings.TrimSpace(stripHTMLTags(cell[1])))
 			}
 		}
-		if len(obj) > 0 {
+		if obj.Len() > 0 {
 			result = append(result, obj)
 		}
 	}
@@ -112,21 +114,21 @@ func parseHTMLList(content string) []any {
 	return result
 }
 
-func parseHTMLDefinitionList(content string) map[string]any {
+func parseHTMLDefinitionList(content string) *Object {
 	dlRe := regexp.MustCompile(`(?is)<dl[^>]*>(.*?)</dl>`)
 	dlMatch := dlRe.FindStringSubmatch(content)
 	if dlMatch == nil {
 		return nil
 	}
 
-	result := make(map[string]any)
+	result := NewObject()
 	dtRe := regexp.MustCompile(`(?is)<dt[^>]*>(.*?)</dt>\s*<dd[^>]*>(.*?)</dd>`)
 	pairs := dtRe.FindAllStringSubmatch(dlMatch[1], -1)
 	for _, pair := range pairs {
 		key := strings.TrimSpace(stripHTMLTags(pair[1]))
 		value := strings.TrimSpace(stripHTMLTags(pair[2]))
 		if key != "" {
-			result[key] = value
+			result.Set(key, value)
 		}
 	}
 
@@ -149,15 +151,15 @@ func renderHTML(data any) ([]byte, error) {
 	var buf bytes.Buffer
 
 	if rows, ok := toSlice(data); ok && len(rows) > 0 {
-		if _, isMap := toMap(rows[0]); isMap {
+		if _, isMap := toObject(rows[0]); isMap {
 			renderHTMLTable(&buf, rows)
 		} else {
 			renderHTMLList(&buf, rows)
 		}
-	} else if obj, ok := toMap(data); ok {
+	} else if obj, ok := toObject(data); ok {
 		renderHTMLKeyValue(&buf, obj)
 	} else {
-		buf.WriteString(fmt.Sprintf("<p>%v</p>\n", escapeHTML(fmt.Sprintf("%v", data))))
+		buf.WriteString(fmt.Sprintf("<p>%s</p>\n", escapeHTML(cell(data))))
 	}
 
 	return buf.Bytes(), nil
@@ -176,10 +178,11 @@ func renderHTMLTable(buf *bytes.Buffer, rows []any) {
 	buf.WriteString("</tr>\n</thead>\n<tbody>\n")
 
 	for _, row := range rows {
-		obj, _ := toMap(row)
+		obj, _ := toObject(row)
 		buf.WriteString("<tr>\n")
 		for _, h := range headers {
-			buf.WriteString(fmt.Sprintf("  <td>%s</td>\n", escapeHTML(fmt.Sprintf("%v", obj[h]))))
+			v, _ := obj.Get(h)
+			buf.WriteString(fmt.Sprintf("  <td>%s</td>\n", escapeHTML(cell(v))))
 		}
 		buf.WriteString("</tr>\n")
 	}
@@ -189,25 +192,24 @@ func renderHTMLTable(buf *bytes.Buffer, rows []any) {
 func renderHTMLList(buf *bytes.Buffer, items []any) {
 	buf.WriteString("<ul>\n")
 	for _, item := range items {
-		buf.WriteString(fmt.Sprintf("  <li>%s</li>\n", escapeHTML(fmt.Sprintf("%v", item))))
+		buf.WriteString(fmt.Sprintf("  <li>%s</li>\n", escapeHTML(cell(item))))
 	}
 	buf.WriteString("</ul>\n")
 }
 
-func renderHTMLKeyValue(buf *bytes.Buffer, obj map[string]any) {
+func renderHTMLKeyValue(buf *bytes.Buffer, obj *Object) {
 	buf.WriteString("<dl>\n")
-	keys := sortedKeys(obj)
-	for _, k := range keys {
-		v := obj[k]
+	for _, k := range obj.Keys() {
+		v := obj.values[k]
 		buf.WriteString(fmt.Sprintf("  <dt>%s</dt>\n", escapeHTML(k)))
-		if nested, ok := toMap(v); ok {
+		if nested, ok := toObject(v); ok {
 			buf.WriteString("  <dd>\n")
 			renderHTMLKeyValue(buf, nested)
 			buf.WriteString("  </dd>\n")
 		} else if arr, ok := toSlice(v); ok {
 			buf.WriteString("  <dd>\n")
 			if len(arr) > 0 {
-				if _, isMap := toMap(arr[0]); isMap {
+				if _, isMap := toObject(arr[0]); isMap {
 					renderHTMLTable(buf, arr)
 				} else {
 					renderHTMLList(buf, arr)
@@ -215,7 +217,7 @@ func renderHTMLKeyValue(buf *bytes.Buffer, obj map[string]any) {
 			}
 			buf.WriteString("  </dd>\n")
 		} else {
-			buf.WriteString(fmt.Sprintf("  <dd>%s</dd>\n", escapeHTML(fmt.Sprintf("%v", v))))
+			buf.WriteString(fmt.Sprintf("  <dd>%s</dd>\n", escapeHTML(cell(v))))
 		}
 	}
 	buf.WriteString("</dl>\n")
@@ -0,0 +1,304 @@
+package polymorph
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+var (
+	intPattern   = regexp.MustCompile(`^-?(0|[1-9][0-9]*)$`)
+	floatPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+)
+
+// The locations the TOML decoder gives local date-times, dates and times,
+// which have no offset. Times in them are written back in the same form.
+var localDatetime, localDate, localTime = tomlLocations()
+
+func tomlLocations() (*time.Location, *time.Location, *time.Location) {
+	var v map[string]any
+	toml.Decode("a = 2006-01-02T15:04:05\nb = 2006-01-02\nc = 15:04:05", &v)
+	loc := func(key string) *time.Location { return v[key].(time.Time).Location() }
+	return loc("a"), loc("b"), loc("c")
+}
+
+// localTimeLayouts are the forms of local times, in the order they are
+// tried.
+var localTimeLayouts = []struct {
+	layout string
+	loc    **time.Location
+}{
+	{"2006-01-02T15:04:05.999999999", &localDatetime},
+	{"2006-01-02", &localDate},
+	{"15:04:05.999999999", &localTime},
+}
+
+// inferValue reads a text value as the type it looks like: empty text and
+// null become nil, then booleans, numbers, dates and times, and JSON arrays
+// and objects. Integers with leading zeros, such as postcodes, and integers
+// too large for int64 stay strings, as does anything else.
+func inferValue(s string) any {
+	switch s {
+	case "", "null":
+		return nil
+	}
+	if b, err := strconv.ParseBool(s); err == nil && len(s) > 1 {
+		return b
+	}
+	if intPattern.MatchString(s) {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+		return s
+	}
+	if floatPattern.MatchString(s) {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+		return s
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t
+	}
+	for _, l := range localTimeLayouts {
+		if t, err := time.ParseInLocation(l.layout, s, *l.loc); err == nil {
+			return t
+		}
+	}
+	if s[0] == '[' || s[0] == '{' {
+		if v, err := parseJSON([]byte(s)); err == nil {
+			return v
+		}
+	}
+	return s
+}
+
+// inferRecords types the string values of rows read from a table one
+// column at a time: a column whose values all infer to the same kind, such
+// as numbers, gets the inferred values, and any other keeps its text, so
+// postcodes stay text even if only some start with 0. Empty cells become
+// nil either way, and dotted columns are nested with nestDotted.
+func inferRecords(rows []any) []any {
+	kinds := make(map[string]string)
+	for _, row := range rows {
+		obj := row.(*Object)
+		for _, k := range obj.Keys() {
+			s, _ := obj.values[k].(string)
+			kind := valueKind(inferValue(s))
+			switch prev := kinds[k]; {
+			case kind == "" || kind == prev:
+			case prev == "":
+				kinds[k] = kind
+			default:
+				kinds[k] = "string"
+			}
+		}
+	}
+
+	result := make([]any, len(rows))
+	for i, row := range rows {
+		obj := NewObject()
+		for _, k := range row.(*Object).Keys() {
+			s, _ := row.(*Object).values[k].(string)
+			switch {
+			case s == "":
+				obj.Set(k, nil)
+			case kinds[k] == "string":
+				obj.Set(k, s)
+			default:
+				obj.Set(k, inferValue(s))
+			}
+		}
+		result[i] = nestDotted(obj)
+	}
+	return result
+}
+
+// valueKind names the kind of an inferred value, or "" for nil.
+func valueKind(v any) string {
+	switch v.(type) {
+	case nil:
+		return ""
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case int64, float64:
+		return "number"
+	case time.Time:
+		return "time"
+	}
+	return "json"
+}
+
+// localTimeString renders a TOML local date-time, date or time the way
+// TOML writes it. It reports false for other times.
+func localTimeString(t time.Time) (string, bool) {
+	for _, l := range localTimeLayouts {
+		if t.Location() == *l.loc {
+			return t.Format(l.layout), true
+		}
+	}
+	return "", false
+}
+
+// localTimesToStrings replaces TOML local dates and times in data with
+// their text, for formats that would otherwise write them as an instant in
+// UTC.
+func localTimesToStrings(data any) any {
+	switch v := data.(type) {
+	case time.Time:
+		if s, ok := localTimeString(v); ok {
+			return s
+		}
+	case *Object:
+		for _, k := range v.keys {
+			v.values[k] = localTimesToStrings(v.values[k])
+		}
+	case map[string]any:
+		for k, val := range v {
+			v[k] = localTimesToStrings(val)
+		}
+	case []any:
+		for i, val := range v {
+			v[i] = localTimesToStrings(val)
+		}
+	}
+	return data
+}
+
+// flattenDotted adds v to flat under prefix, nested objects and arrays
+// becoming one entry per leaf with dotted keys such as "address.city" and
+// "tags.0". Empty objects and arrays are kept as they are.
+func flattenDotted(prefix string, v any, flat *Object) {
+	if obj, ok := toObject(v); ok && obj.Len() > 0 {
+		for _, k := range obj.Keys() {
+			flattenDotted(joinDotted(prefix, k), obj.values[k], flat)
+		}
+		return
+	}
+	if list, ok := toSlice(v); ok && len(list) > 0 {
+		for i, val := range list {
+			flattenDotted(joinDotted(prefix, strconv.Itoa(i)), val, flat)
+		}
+		return
+	}
+	flat.Set(prefix, v)
+}
+
+func joinDotted(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// nestDotted rebuilds the objects and arrays that flattenDotted wrote, each
+// in the place of its first column. Empty values under a dotted key are
+// left out, so shorter arrays and missing objects in some rows come back as
+// they were; a dotted key that clashes with a plain value is kept as it is.
+func nestDotted(obj *Object) *Object {
+	result := NewObject()
+	var dotted []string
+	for _, k := range obj.Keys() {
+		v := obj.values[k]
+		if !isDotted(k) {
+			result.Set(k, v)
+			continue
+		}
+		if v == nil {
+			continue
+		}
+		dotted = append(dotted, k)
+		// Hold the place of the object until the plain keys are all set.
+		if first, _, _ := strings.Cut(k, "."); !result.has(first) {
+			result.Set(first, nil)
+		}
+	}
+
+	for _, k := range dotted {
+		v := obj.values[k]
+		if !setDotted(result, strings.Split(k, "."), v) {
+			result.Set(k, v)
+		}
+	}
+	for _, k := range result.Keys() {
+		if _, ok := result.values[k].(*Object); ok {
+			result.values[k] = indexedToSlices(result.values[k])
+		}
+	}
+	return result
+}
+
+// isDotted reports whether key is a path of non-empty names joined by
+// dots.
+func isDotted(key string) bool {
+	return strings.Contains(key, ".") && !strings.HasPrefix(key, ".") &&
+		!strings.HasSuffix(key, ".") && !strings.Contains(key, "..")
+}
+
+// setDotted sets the value at path in obj, creating the objects on the
+// way. It reports false if a value other than an object or nil is in the
+// way.
+func setDotted(obj *Object, path []string, v any) bool {
+	for _, name := range path[:len(path)-1] {
+		next, ok := obj.values[name].(*Object)
+		if !ok {
+			if obj.values[name] != nil {
+				return false
+			}
+			next = NewObject()
+			obj.Set(name, next)
+		}
+		obj = next
+	}
+	last := path[len(path)-1]
+	if obj.has(last) {
+		return false
+	}
+	obj.Set(last, v)
+	return true
+}
+
+// indexedToSlices turns objects whose keys are all array indexes into
+// arrays, filling the gaps left by empty cells with nil. Objects with
+// indexes far apart stay objects.
+func indexedToSlices(v any) any {
+	obj, ok := v.(*Object)
+	if !ok || obj.Len() == 0 {
+		return v
+	}
+	for _, k := range obj.Keys() {
+		obj.values[k] = indexedToSlices(obj.values[k])
+	}
+
+	size := 0
+	for _, k := range obj.Keys() {
+		if !intPattern.MatchString(k) || strings.HasPrefix(k, "-") {
+			return obj
+		}
+		i, err := strconv.Atoi(k)
+		if err != nil || i >= 2*obj.Len() {
+			return obj
+		}
+		size = max(size, i+1)
+	}
+	list := make([]any, size)
+	for _, k := range obj.Keys() {
+		i, _ := strconv.Atoi(k)
+		list[i] = obj.values[k]
+	}
+	return list
+}
+
+// textValue returns inferValue with infer, and otherwise a function that
+// keeps text as it is.
+func textValue(infer bool) func(string) any {
+	if infer {
+		return inferValue
+	}
+	return func(s string) any { return s }
+}
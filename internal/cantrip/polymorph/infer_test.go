@@ -0,0 +1,237 @@
+package polymorph
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInferValue(t *testing.T) {
+	tests := []struct {
+		input string
+		want  any
+	}{
+		{"", nil},
+		{"null", nil},
+		{"true", true},
+		{"FALSE", false},
+		{"t", "t"},
+		{"42", int64(42)},
+		{"-7", int64(-7)},
+		{"01234", "01234"},
+		{"99999999999999999999", "99999999999999999999"},
+		{"1.5", 1.5},
+		{"-2e3", -2000.0},
+		{"1.", "1."},
+		{"Rome", "Rome"},
+		{"2024-05-01T10:00:00Z", time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)},
+		{"{nope", "{nope"},
+	}
+
+	for _, tt := range tests {
+		got := inferValue(tt.input)
+		if want, ok := tt.want.(time.Time); ok {
+			if got, ok := got.(time.Time); !ok || !got.Equal(want) {
+				t.Errorf("inferValue(%q) = %v, want %v", tt.input, got, want)
+			}
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("inferValue(%q) = %#v, want %#v", tt.input, got, tt.want)
+		}
+	}
+
+	for _, s := range []string{"2024-05-01T07:30:00", "2024-05-01", "07:30:00.5"} {
+		v, ok := inferValue(s).(time.Time)
+		if !ok {
+			t.Errorf("inferValue(%q) is not a time", s)
+			continue
+		}
+		if got, _ := localTimeString(v); got != s {
+			t.Errorf("localTimeString(inferValue(%q)) = %q", s, got)
+		}
+	}
+
+	if got, _ := json.Marshal(inferValue(`{"a": [1, 2.5]}`)); string(got) != `{"a":[1,2.5]}` {
+		t.Errorf("inferValue(JSON object) = %s", got)
+	}
+}
+
+func TestConvert_InferTypes(t *testing.T) {
+	input := "name,age,zip,active,joined,address.city,tags.0,tags.1,note\n" +
+		"Ann,31,01234,true,2024-05-01,Rome,a,b,\n" +
+		"Bob,25.5,99999,false,2024-05-02,,x,,\"{\"\"k\"\":1}\"\n"
+
+	output, err := ConvertWith([]byte(input), "csv", "json", Options{InferTypes: true})
+	if err != nil {
+		t.Fatalf("ConvertWith failed: %v", err)
+	}
+	var got, want any
+	json.Unmarshal(output, &got)
+	json.Unmarshal([]byte(`[
+		{"name": "Ann", "age": 31, "zip": "01234", "active": true, "joined": "2024-05-01", "address": {"city": "Rome"}, "tags": ["a", "b"], "note": null},
+		{"name": "Bob", "age": 25.5, "zip": "99999", "active": false, "joined": "2024-05-02", "tags": ["x"], "note": {"k": 1}}
+	]`), &want)
+	if compare(got, want) != 0 {
+		t.Errorf("csv to json =\n%s", output)
+	}
+
+	output, err = Convert([]byte(input), "csv", "json")
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if !bytes.Contains(output, []byte(`"age": "31"`)) || !bytes.Contains(output, []byte(`"address.city": "Rome"`)) {
+		t.Errorf("csv to json without inference =\n%s", output)
+	}
+}
+
+func TestConvert_NestedCSVRoundTrip(t *testing.T) {
+	input := `[{"id": 1, "user": {"name": "Ann", "roles": ["admin", "dev"], "prefs": {}}, "score": 9.5, "ok": true, "none": null},
+		{"id": 2, "user": {"name": "Bob", "roles": ["dev"], "prefs": {"theme": "dark"}}, "score": 7, "ok": false, "none": null}]`
+
+	csvOut, err := Convert([]byte(input), "json", "csv")
+	if err != nil {
+		t.Fatalf("json to csv failed: %v", err)
+	}
+	header := strings.SplitN(string(csvOut), "\n", 2)[0]
+	if want := "id,user.name,user.roles.0,user.roles.1,user.prefs,score,ok,none,user.prefs.theme"; header != want {
+		t.Errorf("csv header = %q, want %q", header, want)
+	}
+
+	jsonOut, err := ConvertWith(csvOut, "csv", "json", Options{InferTypes: true})
+	if err != nil {
+		t.Fatalf("csv to json failed: %v", err)
+	}
+	var got, want any
+	json.Unmarshal(jsonOut, &got)
+	json.Unmarshal([]byte(input), &want)
+	if compare(got, want) != 0 {
+		t.Errorf("round trip =\n%s\nwant\n%s", jsonOut, input)
+	}
+}
+
+func TestConvert_TOMLDates(t *testing.T) {
+	input := "day = 2024-05-01\nat = 07:30:00\nlocal = 2024-05-01T07:30:00\nzoned = 2024-05-01T07:30:00+02:00\n"
+
+	jsonOut, err := Convert([]byte(input), "toml", "json")
+	if err != nil {
+		t.Fatalf("toml to json failed: %v", err)
+	}
+	for _, want := range []string{`"day": "2024-05-01"`, `"at": "07:30:00"`, `"local": "2024-05-01T07:30:00"`, `"zoned": "2024-05-01T07:30:00+02:00"`} {
+		if !bytes.Contains(jsonOut, []byte(want)) {
+			t.Errorf("toml to json = %s, want it to contain %s", jsonOut, want)
+		}
+	}
+
+	tomlOut, err := Convert([]byte(input), "toml", "toml")
+	if err != nil {
+		t.Fatalf("toml to toml failed: %v", err)
+	}
+	for _, want := range []string{"day = 2024-05-01\n", "at = 07:30:00\n", "local = 2024-05-01T07:30:00\n"} {
+		if !bytes.Contains(tomlOut, []byte(want)) {
+			t.Errorf("toml to toml = %s, want it to contain %q", tomlOut, want)
+		}
+	}
+
+	csvOut, err := ConvertWith([]byte("day,n\n2024-05-01,1\n"), "csv", "toml", Options{InferTypes: true, Query: ".[0]"})
+	if err != nil {
+		t.Fatalf("csv to toml failed: %v", err)
+	}
+	if got, want := string(csvOut), "day = 2024-05-01\nn = 1\n"; got != want {
+		t.Errorf("csv to toml = %q, want %q", got, want)
+	}
+}
+
+func TestConvert_InferTypesXMLAndMarkdown(t *testing.T) {
+	xmlOut, err := ConvertWith([]byte(`<r id="7"><n>1.5</n><ok>true</ok><e/></r>`), "xml", "json", Options{InferTypes: true})
+	if err != nil {
+		t.Fatalf("xml to json failed: %v", err)
+	}
+	var got, want any
+	json.Unmarshal(xmlOut, &got)
+	json.Unmarshal([]byte(`{"r": {"@id": 7, "n": 1.5, "ok": true, "e": null}}`), &want)
+	if compare(got, want) != 0 {
+		t.Errorf("xml to json = %s", xmlOut)
+	}
+
+	mdOut, err := ConvertWith([]byte("| a | b |\n|---|---|\n| 1 | 007 |\n| 2 | 8 |\n"), "markdown", "json", Options{InferTypes: true})
+	if err != nil {
+		t.Fatalf("markdown to json failed: %v", err)
+	}
+	json.Unmarshal(mdOut, &got)
+	json.Unmarshal([]byte(`[{"a": 1, "b": "007"}, {"a": 2, "b": "8"}]`), &want)
+	if compare(got, want) != 0 {
+		t.Errorf("markdown to json = %s", mdOut)
+	}
+}
+
+func TestConvertStream_InferTypes(t *testing.T) {
+	var out bytes.Buffer
+	input := "id,address.city,address.zip,ok\n1,Rome,00100,true\n2,,,\n"
+	if _, err := ConvertStream(strings.NewReader(input), &out, "csv", "jsonl", Options{InferTypes: true}); err != nil {
+		t.Fatalf("ConvertStream() error = %v", err)
+	}
+	want := `{"id":1,"address":{"city":"Rome","zip":"00100"},"ok":true}` + "\n" + `{"id":2,"ok":null}` + "\n"
+	if out.String() != want {
+		t.Errorf("ConvertStream() = %q, want %q", out.String(), want)
+	}
+}
+
+func TestConvert_TOMLDatesThroughXMLAndHTML(t *testing.T) {
+	input := "[r]\nx = 1979-05-27T07:32:00Z\nday = 1979-05-27\nn = 1\n"
+
+	xmlOut, err := Convert([]byte(input), "toml", "xml")
+	if err != nil {
+		t.Fatalf("toml to xml failed: %v", err)
+	}
+	if !bytes.Contains(xmlOut, []byte("<x>1979-05-27T07:32:00Z</x>")) {
+		t.Errorf("toml to xml = %s", xmlOut)
+	}
+	tomlOut, err := ConvertWith(xmlOut, "xml", "toml", Options{InferTypes: true})
+	if err != nil {
+		t.Fatalf("xml to toml failed: %v", err)
+	}
+	if got := string(tomlOut); !strings.Contains(got, "x = 1979-05-27T07:32:00Z\n") || !strings.Contains(got, "day = 1979-05-27\n") || !strings.Contains(got, "n = 1\n") {
+		t.Errorf("toml to xml to toml = %s", got)
+	}
+
+	htmlOut, err := Convert([]byte(input), "toml", "html")
+	if err != nil {
+		t.Fatalf("toml to html failed: %v", err)
+	}
+	if !bytes.Contains(htmlOut, []byte("<dd>1979-05-27T07:32:00Z</dd>")) {
+		t.Errorf("toml to html = %s", htmlOut)
+	}
+}
+
+func TestConvert_NullsThroughXMLAndHTML(t *testing.T) {
+	input := `{"r": {"a": null, "b": 1}}`
+
+	xmlOut, err := Convert([]byte(input), "json", "xml")
+	if err != nil {
+		t.Fatalf("json to xml failed: %v", err)
+	}
+	if bytes.Contains(xmlOut, []byte("nil")) {
+		t.Errorf("json to xml = %s", xmlOut)
+	}
+	jsonOut, err := ConvertWith(xmlOut, "xml", "json", Options{InferTypes: true})
+	if err != nil {
+		t.Fatalf("xml to json failed: %v", err)
+	}
+	var got, want any
+	json.Unmarshal(jsonOut, &got)
+	json.Unmarshal([]byte(input), &want)
+	if compare(got, want) != 0 {
+		t.Errorf("json to xml to json = %s", jsonOut)
+	}
+
+	htmlOut, err := Convert([]byte(`[{"a": null, "b": 1}]`), "json", "html")
+	if err != nil {
+		t.Fatalf("json to html failed: %v", err)
+	}
+	if bytes.Contains(htmlOut, []byte("nil")) || !bytes.Contains(htmlOut, []byte("<td></td>")) {
+		t.Errorf("json to html = %s", htmlOut)
+	}
+}
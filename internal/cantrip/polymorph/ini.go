@@ -0,0 +1,136 @@
+package polymorph
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseINI reads sections into nested objects, keys before the first
+// section at the top level. Values are strings; "key[] = value" lines
+// collect into an array.
+func parseINI(input []byte) (any, error) {
+	result := NewObject()
+	section := result
+
+	scanner := bufio.NewScanner(bytes.NewReader(input))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unterminated section header", n)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			existing, ok := result.values[name].(*Object)
+			if !ok {
+				existing = NewObject()
+				result.Set(name, existing)
+			}
+			section = existing
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if i := strings.IndexByte(line, ':'); i >= 0 && (!found || i < len(key)) {
+			key, value, found = line[:i], line[i+1:], true
+		}
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("line %d: missing key", n)
+		}
+		value = strings.TrimSpace(value)
+		if strings.HasPrefix(value, `"`) {
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid quoted value: %w", n, err)
+			}
+			value = unquoted
+		}
+
+		if name, ok := strings.CutSuffix(key, "[]"); ok {
+			list, _ := section.values[name].([]any)
+			section.Set(name, append(list, value))
+			continue
+		}
+		section.Set(key, value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// renderINI writes top-level scalars first, then each object as a section.
+// Nested objects become sections named by their dotted path.
+func renderINI(data any) ([]byte, error) {
+	obj, ok := toObject(data)
+	if !ok {
+		return nil, fmt.Errorf("INI output requires an object")
+	}
+
+	var buf bytes.Buffer
+	if err := writeINISection(&buf, "", obj); err != nil {
+		return nil, err
+	}
+	return bytes.TrimLeft(buf.Bytes(), "\n"), nil
+}
+
+func writeINISection(buf *bytes.Buffer, name string, obj *Object) error {
+	var sections []string
+	wroteHeader := name == ""
+	for _, k := range obj.Keys() {
+		v := obj.values[k]
+		if _, ok := toObject(v); ok {
+			sections = append(sections, k)
+			continue
+		}
+		if !wroteHeader {
+			fmt.Fprintf(buf, "\n[%s]\n", name)
+			wroteHeader = true
+		}
+
+		if list, ok := toSlice(v); ok {
+			for _, item := range list {
+				s, ok := formatScalar(item)
+				if !ok {
+					return fmt.Errorf("INI cannot represent nested arrays or objects in arrays (%s)", k)
+				}
+				fmt.Fprintf(buf, "%s[] = %s\n", k, iniValue(s))
+			}
+			continue
+		}
+		s, _ := formatScalar(v)
+		fmt.Fprintf(buf, "%s = %s\n", k, iniValue(s))
+	}
+
+	for _, k := range sections {
+		child, _ := toObject(obj.values[k])
+		path := k
+		if name != "" {
+			path = name + "." + k
+		}
+		if child.Len() == 0 {
+			fmt.Fprintf(buf, "\n[%s]\n", path)
+			continue
+		}
+		if err := writeINISection(buf, path, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// iniValue quotes values that would not read back as they are.
+func iniValue(s string) string {
+	if s != strings.TrimSpace(s) || strings.ContainsAny(s, "\"\n\r;#") || strings.HasPrefix(s, "[") {
+		return strconv.Quote(s)
+	}
+	return s
+}
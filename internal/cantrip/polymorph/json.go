@@ -1,15 +1,100 @@
 package polymorph
 
-import "encoding/json"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
 
+// parseJSON reads a JSON document, keeping the order of object keys and
+// integers as int64 so that formats with an integer type, such as TOML,
+// write them without a decimal point.
 func parseJSON(input []byte) (any, error) {
-	var data any
-	if err := json.Unmarshal(input, &data); err != nil {
+	dec := json.NewDecoder(bytes.NewReader(input))
+	dec.UseNumber()
+	data, err := decodeJSON(dec, false)
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if err != nil {
 		return nil, err
 	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the JSON value at byte %d", dec.InputOffset())
+	}
 	return data, nil
 }
 
+// decodeJSON reads the next value from dec, which must use numbers.
+// Objects become Objects. With exactNumbers, numbers stay json.Numbers;
+// otherwise they become int64, or float64 for numbers with a fraction or
+// exponent and integers too large for int64.
+func decodeJSON(dec *json.Decoder, exactNumbers bool) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '[' {
+			list := []any{}
+			for dec.More() {
+				v, err := decodeJSON(dec, exactNumbers)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			_, err := dec.Token()
+			return list, err
+		}
+		obj := NewObject()
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeJSON(dec, exactNumbers)
+			if err != nil {
+				return nil, err
+			}
+			obj.Set(key.(string), v)
+		}
+		_, err := dec.Token()
+		return obj, err
+	case json.Number:
+		if exactNumbers {
+			return t, nil
+		}
+		if n, err := t.Int64(); err == nil {
+			return n, nil
+		}
+		f, _ := t.Float64()
+		return f, nil
+	}
+	return tok, nil
+}
+
 func renderJSON(data any) ([]byte, error) {
 	return json.MarshalIndent(data, "", "  ")
 }
+
+// renderJSONLines writes an array as one compact JSON value per line.
+func renderJSONLines(data any) ([]byte, error) {
+	rows, ok := toSlice(data)
+	if !ok {
+		return nil, fmt.Errorf("JSON Lines output requires an array")
+	}
+
+	var buf bytes.Buffer
+	for _, row := range rows {
+		line, err := json.Marshal(row)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
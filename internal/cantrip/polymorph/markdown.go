@@ -7,27 +7,33 @@ import (
 	"strings"
 )
 
-func parseMarkdown(input []byte) (any, error) {
+func parseMarkdown(input []byte, infer bool) (any, error) {
 	content := string(input)
+	value := textValue(infer)
 
 	if table := parseMarkdownTable(content); table != nil {
+		if infer {
+			return inferRecords(table), nil
+		}
 		return table, nil
 	}
 
-	if list := parseMarkdownList(content); list != nil {
+	if list := parseMarkdownList(content, value); list != nil {
 		return list, nil
 	}
 
-	if kv := parseMarkdownKeyValue(content); kv != nil {
+	if kv := parseMarkdownKeyValue(content, value); kv != nil {
 		return kv, nil
 	}
 
 	text := strings.TrimSpace(content)
 	if text != "" {
-		return map[string]any{"content": text}, nil
+		obj := NewObject()
+		obj.Set("content", text)
+		return obj, nil
 	}
 
-	return map[string]any{}, nil
+	return NewObject(), nil
 }
 
 func parseMarkdownTable(content string) []any {
@@ -66,13 +72,13 @@ func parseMarkdownTable(content string) []any {
 	var result []any
 	for i := startIdx; i < len(tableLines); i++ {
 		cells := parseMarkdownTableRow(tableLines[i])
-		obj := make(map[string]any)
+		obj := NewObject()
 		for j, cell := range cells {
 			if j < len(headers) {
-				obj[headers[j]] = cell
+				obj.Set(headers[j], cell)
 			}
 		}
-		if len(obj) > 0 {
+		if obj.Len() > 0 {
 			result = append(result, obj)
 		}
 	}
@@ -90,7 +96,7 @@ func parseMarkdownTableRow(line string) []string {
 	return result
 }
 
-func parseMarkdownList(content string) []any {
+func parseMarkdownList(content string, value func(string) any) []any {
 	lines := strings.Split(content, "\n")
 	var result []any
 	listRe := regexp.MustCompile(`^\s*[-*+]\s+(.+)$`)
@@ -98,9 +104,9 @@ func parseMarkdownList(content string) []any {
 
 	for _, line := range lines {
 		if m := listRe.FindStringSubmatch(line); m != nil {
-			result = append(result, strings.TrimSpace(m[1]))
+			result = append(result, value(strings.TrimSpace(m[1])))
 		} else if m := numListRe.FindStringSubmatch(line); m != nil {
-			result = append(result, strings.TrimSpace(m[1]))
+			result = append(result, value(strings.TrimSpace(m[1])))
 		}
 	}
 
@@ -110,25 +116,25 @@ func parseMarkdownList(content string) []any {
 	return result
 }
 
-func parseMarkdownKeyValue(content string) map[string]any {
+func parseMarkdownKeyValue(content string, value func(string) any) *Object {
 	lines := strings.Split(content, "\n")
-	result := make(map[string]any)
+	result := NewObject()
 	kvRe := regexp.MustCompile(`^\*\*(.+?)\*\*:\s*(.+)$`)
 	colonRe := regexp.MustCompile(`^([^:]+):\s+(.+)$`)
 
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
 		if m := kvRe.FindStringSubmatch(line); m != nil {
-			result[strings.TrimSpace(m[1])] = strings.TrimSpace(m[2])
+			result.Set(strings.TrimSpace(m[1]), value(strings.TrimSpace(m[2])))
 		} else if m := colonRe.FindStringSubmatch(line); m != nil {
 			key := strings.TrimSpace(m[1])
 			if !strings.HasPrefix(key, "#") && !strings.HasPrefix(key, "-") {
-				result[key] = strings.TrimSpace(m[2])
+				result.Set(key, value(strings.TrimSpace(m[2])))
 			}
 		}
 	}
 
-	if len(result) == 0 {
+	if result.Len() == 0 {
 		return nil
 	}
 	return result
@@ -138,15 +144,15 @@ func renderMarkdown(data any) ([]byte, error) {
 	var buf bytes.Buffer
 
 	if rows, ok := toSlice(data); ok && len(rows) > 0 {
-		if _, isMap := toMap(rows[0]); isMap {
+		if _, isMap := toObject(rows[0]); isMap {
 			renderMarkdownTable(&buf, rows)
 		} else {
 			renderMarkdownList(&buf, rows)
 		}
-	} else if obj, ok := toMap(data); ok {
+	} else if obj, ok := toObject(data); ok {
 		renderMarkdownKeyValue(&buf, obj)
 	} else {
-		buf.WriteString(fmt.Sprintf("%v\n", data))
+		buf.WriteString(cell(data) + "\n")
 	}
 
 	return buf.Bytes(), nil
@@ -162,10 +168,11 @@ func renderMarkdownTable(buf *bytes.Buffer, rows []any) {
 	buf.WriteString("|" + strings.Repeat(" --- |", len(headers)) + "\n")
 
 	for _, row := range rows {
-		obj, _ := toMap(row)
+		obj, _ := toObject(row)
 		vals := make([]string, len(headers))
 		for i, h := range headers {
-			vals[i] = escapeMarkdown(fmt.Sprintf("%v", obj[h]))
+			v, _ := obj.Get(h)
+			vals[i] = escapeMarkdown(cell(v))
 		}
 		buf.WriteString("| " + strings.Join(vals, " | ") + " |\n")
 	}
@@ -173,22 +180,21 @@ func renderMarkdownTable(buf *bytes.Buffer, rows []any) {
 
 func renderMarkdownList(buf *bytes.Buffer, items []any) {
 	for _, item := range items {
-		buf.WriteString(fmt.Sprintf("- %v\n", item))
+		buf.WriteString("- " + cell(item) + "\n")
 	}
 }
 
-func renderMarkdownKeyValue(buf *bytes.Buffer, obj map[string]any) {
-	keys := sortedKeys(obj)
-	for _, k := range keys {
-		v := obj[k]
-		if nested, ok := toMap(v); ok {
+func renderMarkdownKeyValue(buf *bytes.Buffer, obj *Object) {
+	for _, k := range obj.Keys() {
+		v := obj.values[k]
+		if nested, ok := toObject(v); ok {
 			buf.WriteString(fmt.Sprintf("## %s\n\n", k))
 			renderMarkdownKeyValue(buf, nested)
 			buf.WriteString("\n")
 		} else if arr, ok := toSlice(v); ok {
 			buf.WriteString(fmt.Sprintf("## %s\n\n", k))
 			if len(arr) > 0 {
-				if _, isMap := toMap(arr[0]); isMap {
+				if _, isMap := toObject(arr[0]); isMap {
 					renderMarkdownTable(buf, arr)
 				} else {
 					renderMarkdownList(buf, arr)
@@ -196,7 +202,7 @@ func renderMarkdownKeyValue(buf *bytes.Buffer, obj map[string]any) {
 			}
 			buf.WriteString("\n")
 		} else {
-			buf.WriteString(fmt.Sprintf("**%s**: %v\n\n", k, v))
+			buf.WriteString(fmt.Sprintf("**%s**: %s\n\n", k, cell(v)))
 		}
 	}
 }
@@ -0,0 +1,344 @@
+package polymorph
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// msgpackTimestamp is the extension type MessagePack reserves for
+// timestamps.
+const msgpackTimestamp = -1
+
+// parseMessagePack decodes a single MessagePack value. Binary data becomes
+// a string, base64 encoded unless it is valid UTF-8, and timestamps become
+// times; other extension types are base64 encoded too.
+func parseMessagePack(input []byte) (any, error) {
+	d := &binaryDecoder{data: input}
+	v, err := d.msgpack()
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("%d trailing bytes after the MessagePack value", len(d.data)-d.pos)
+	}
+	return v, nil
+}
+
+// binaryDecoder reads the values of a binary format, checking every length
+// against the input left.
+type binaryDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *binaryDecoder) next(n int) ([]byte, error) {
+	if n < 0 || n > len(d.data)-d.pos {
+		return nil, fmt.Errorf("unexpected end of input at byte %d", d.pos)
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// uint reads a big-endian unsigned integer of size bytes.
+func (d *binaryDecoder) uint(size int) (uint64, error) {
+	b, err := d.next(size)
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n, nil
+}
+
+// count reads a length of size bytes, which must not exceed the input
+// left, as each item takes at least one byte.
+func (d *binaryDecoder) count(size int) (int, error) {
+	n, err := d.uint(size)
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(len(d.data)-d.pos) {
+		return 0, fmt.Errorf("length %d at byte %d is past the end of input", n, d.pos)
+	}
+	return int(n), nil
+}
+
+func (d *binaryDecoder) msgpack() (any, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c >= 0x80 && c <= 0x8f:
+		return d.msgpackMap(int(c & 0x0f))
+	case c >= 0x90 && c <= 0x9f:
+		return d.msgpackArray(int(c & 0x0f))
+	case c >= 0xa0 && c <= 0xbf:
+		s, err := d.next(int(c & 0x1f))
+		return string(s), err
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2, 0xc3:
+		return c == 0xc3, nil
+	case 0xc4, 0xc5, 0xc6, 0xd9, 0xda, 0xdb:
+		size := map[byte]int{0xc4: 1, 0xc5: 2, 0xc6: 4, 0xd9: 1, 0xda: 2, 0xdb: 4}[c]
+		n, err := d.count(size)
+		if err != nil {
+			return nil, err
+		}
+		s, err := d.next(n)
+		if err != nil {
+			return nil, err
+		}
+		if c >= 0xd9 {
+			return string(s), nil
+		}
+		return binaryValue(s), nil
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.count(map[byte]int{0xc7: 1, 0xc8: 2, 0xc9: 4}[c])
+		if err != nil {
+			return nil, err
+		}
+		return d.msgpackExt(n)
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.msgpackExt(1 << (c - 0xd4))
+	case 0xca:
+		n, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := d.uint(8)
+		return math.Float64frombits(n), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := d.uint(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if n > math.MaxInt64 {
+			return n, nil
+		}
+		return int64(n), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		n, err := d.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		shift := 64 - 8*size
+		return int64(n<<shift) >> shift, nil
+	case 0xdc, 0xdd:
+		n, err := d.count(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.msgpackArray(n)
+	case 0xde, 0xdf:
+		n, err := d.count(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.msgpackMap(n)
+	}
+	return nil, fmt.Errorf("invalid MessagePack byte 0x%02x at byte %d", c, d.pos-1)
+}
+
+func (d *binaryDecoder) msgpackArray(n int) ([]any, error) {
+	result := make([]any, 0, n)
+	for range n {
+		v, err := d.msgpack()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v)
+	}
+	return result, nil
+}
+
+func (d *binaryDecoder) msgpackMap(n int) (*Object, error) {
+	result := NewObject()
+	for range n {
+		k, err := d.msgpack()
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.msgpack()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := formatScalar(k)
+		if !ok {
+			return nil, fmt.Errorf("unsupported map key %v", k)
+		}
+		result.Set(key, v)
+	}
+	return result, nil
+}
+
+func (d *binaryDecoder) msgpackExt(n int) (any, error) {
+	t, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+	if int8(t[0]) != msgpackTimestamp {
+		return base64.StdEncoding.EncodeToString(data), nil
+	}
+
+	switch n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0).UTC(), nil
+	case 8:
+		v := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(v&(1<<34-1)), int64(v>>34)).UTC(), nil
+	case 12:
+		nsec := binary.BigEndian.Uint32(data)
+		sec := int64(binary.BigEndian.Uint64(data[4:]))
+		return time.Unix(sec, int64(nsec)).UTC(), nil
+	}
+	return nil, fmt.Errorf("invalid MessagePack timestamp of %d bytes", n)
+}
+
+// renderMessagePack encodes data as MessagePack, with map keys in order so
+// the output is the same for the same data.
+func renderMessagePack(data any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeMessagePack(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeMessagePack(buf *bytes.Buffer, v any) error {
+	if n, ok := toNumber(v); ok {
+		switch n := n.(type) {
+		case int64:
+			writeMessagePackInt(buf, n)
+		case uint64:
+			writeMessagePackUint(buf, n)
+		case float64:
+			buf.WriteByte(0xcb)
+			buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(n)))
+		}
+		return nil
+	}
+
+	switch x := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+		return nil
+	case bool:
+		if x {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+		return nil
+	case string:
+		writeMessagePackHeader(buf, len(x), 0xa0, 32, [3]byte{0xd9, 0xda, 0xdb})
+		buf.WriteString(x)
+		return nil
+	case []byte:
+		writeMessagePackHeader(buf, len(x), 0, 0, [3]byte{0xc4, 0xc5, 0xc6})
+		buf.Write(x)
+		return nil
+	case time.Time:
+		buf.Write([]byte{0xc7, 12, 0xff})
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(x.Nanosecond())))
+		buf.Write(binary.BigEndian.AppendUint64(nil, uint64(x.Unix())))
+		return nil
+	}
+
+	if m, ok := toObject(v); ok {
+		writeMessagePackHeader(buf, m.Len(), 0x80, 16, [3]byte{0, 0xde, 0xdf})
+		for _, k := range m.Keys() {
+			writeMessagePack(buf, k)
+			if err := writeMessagePack(buf, m.values[k]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if items, ok := toSlice(v); ok {
+		writeMessagePackHeader(buf, len(items), 0x90, 16, [3]byte{0, 0xdc, 0xdd})
+		for _, item := range items {
+			if err := writeMessagePack(buf, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("MessagePack cannot encode %T", v)
+}
+
+// writeMessagePackHeader writes the type and length of a string, binary,
+// array or map: in the fixed byte when n is under fixLimit, otherwise with
+// the type byte of the 8-, 16- or 32-bit form, 0 if there is none.
+func writeMessagePackHeader(buf *bytes.Buffer, n int, fixed byte, fixLimit int, types [3]byte) {
+	switch {
+	case n < fixLimit:
+		buf.WriteByte(fixed | byte(n))
+	case n <= math.MaxUint8 && types[0] != 0:
+		buf.Write([]byte{types[0], byte(n)})
+	case n <= math.MaxUint16:
+		buf.WriteByte(types[1])
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		buf.WriteByte(types[2])
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+}
+
+func writeMessagePackInt(buf *bytes.Buffer, n int64) {
+	switch {
+	case n >= 0:
+		writeMessagePackUint(buf, uint64(n))
+	case n >= -32:
+		buf.WriteByte(byte(int8(n)))
+	case n >= math.MinInt8:
+		buf.Write([]byte{0xd0, byte(int8(n))})
+	case n >= math.MinInt16:
+		buf.WriteByte(0xd1)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	case n >= math.MinInt32:
+		buf.WriteByte(0xd2)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	default:
+		buf.WriteByte(0xd3)
+		buf.Write(binary.BigEndian.AppendUint64(nil, uint64(n)))
+	}
+}
+
+func writeMessagePackUint(buf *bytes.Buffer, n uint64) {
+	switch {
+	case n <= 0x7f:
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint8:
+		buf.Write([]byte{0xcc, byte(n)})
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	case n <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	default:
+		buf.WriteByte(0xcf)
+		buf.Write(binary.BigEndian.AppendUint64(nil, n))
+	}
+}
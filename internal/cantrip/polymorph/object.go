@@ -0,0 +1,160 @@
+package polymorph
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Object is an object that keeps its keys in the order they were read or
+// set, so that a converted document keeps the layout of its source. It
+// also carries the comments of formats that have them.
+type Object struct {
+	keys     []string
+	values   map[string]any
+	comments map[string]comment
+}
+
+// comment holds the comment lines written before a key and the comment
+// after its value on the same line, without their "#". The comment at the
+// top of a document is kept under the empty key.
+type comment struct {
+	head string
+	line string
+}
+
+// NewObject returns an empty Object.
+func NewObject() *Object {
+	return &Object{values: make(map[string]any)}
+}
+
+// Len returns the number of keys.
+func (o *Object) Len() int {
+	return len(o.keys)
+}
+
+// Keys returns the keys in order. The slice must not be modified.
+func (o *Object) Keys() []string {
+	return o.keys
+}
+
+// Get returns the value of key and whether it is set. A nil Object has
+// no keys.
+func (o *Object) Get(key string) (any, bool) {
+	if o == nil {
+		return nil, false
+	}
+	v, ok := o.values[key]
+	return v, ok
+}
+
+func (o *Object) has(key string) bool {
+	_, ok := o.values[key]
+	return ok
+}
+
+// Set sets the value of key, adding it after the others if it is new.
+func (o *Object) Set(key string, v any) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = v
+}
+
+// Delete removes key.
+func (o *Object) Delete(key string) {
+	if _, ok := o.values[key]; !ok {
+		return
+	}
+	delete(o.values, key)
+	delete(o.comments, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i:i], o.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// clone returns a copy of the object that can be changed without changing
+// o. Values are not copied.
+func (o *Object) clone() *Object {
+	c := &Object{keys: slices.Clone(o.keys), values: maps.Clone(o.values), comments: maps.Clone(o.comments)}
+	if c.values == nil {
+		c.values = make(map[string]any)
+	}
+	return c
+}
+
+func (o *Object) setComment(key string, c comment) {
+	if c.head == "" && c.line == "" {
+		return
+	}
+	if o.comments == nil {
+		o.comments = make(map[string]comment)
+	}
+	o.comments[key] = c
+}
+
+// MarshalJSON writes the object with its keys in order.
+func (o *Object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(k)
+		value, err := json.Marshal(o.values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// MarshalYAML returns the object as a mapping with its keys in order and
+// its comments.
+func (o *Object) MarshalYAML() (any, error) {
+	return yamlNode(o)
+}
+
+// commentText strips the "#" from each line of a comment read from a
+// document.
+func commentText(s string) string {
+	if s == "" {
+		return ""
+	}
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		line = strings.TrimPrefix(strings.TrimSpace(line), "#")
+		lines[i] = strings.TrimPrefix(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// toObject returns v as an Object. Plain maps, which have no order, get
+// their keys sorted.
+func toObject(v any) (*Object, bool) {
+	switch m := v.(type) {
+	case *Object:
+		return m, true
+	case map[string]any:
+		return &Object{keys: sortedKeys(m), values: m}, true
+	case map[any]any:
+		obj := &Object{values: make(map[string]any, len(m))}
+		for k, val := range m {
+			obj.values[fmt.Sprintf("%v", k)] = val
+		}
+		obj.keys = sortedKeys(obj.values)
+		return obj, true
+	}
+	return nil, false
+}
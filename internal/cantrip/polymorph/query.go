@@ -0,0 +1,518 @@
+package polymorph
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A query is a jq-like filter applied to the parsed document before it is
+// rendered. It supports paths (.a.b, .[0], .[1:3], .[], ..), pipes and
+// commas, array and object construction, arithmetic, comparisons, and/or,
+// the // alternative operator, if-then-else and the builtins in
+// queryBuiltins.
+type queryNode interface {
+	eval(in any) ([]any, error)
+}
+
+// Query runs the jq-like expr on data. A query that yields a single value
+// returns it; any other number of results is returned as an array.
+func Query(data any, expr string) (any, error) {
+	node, err := parseQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	results, err := node.eval(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 1 {
+		return results[0], nil
+	}
+	if results == nil {
+		results = []any{}
+	}
+	return results, nil
+}
+
+type queryTokenKind int
+
+const (
+	tokEOF queryTokenKind = iota
+	tokField
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+)
+
+type queryToken struct {
+	kind queryTokenKind
+	text string
+	pos  int
+}
+
+func (t queryToken) String() string {
+	if t.kind == tokEOF {
+		return "end of query"
+	}
+	return strconv.Quote(t.text)
+}
+
+var queryOps = []string{"==", "!=", "<=", ">=", "//", "..", ".", "[", "]", "{", "}", "(", ")", "|", ",", ":", ";", "?", "<", ">", "+", "-", "*", "/", "%"}
+
+func lexQuery(expr string) ([]queryToken, error) {
+	var tokens []queryToken
+	i := 0
+	for i < len(expr) {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#':
+			for i < len(expr) && expr[i] != '\n' {
+				i++
+			}
+		case c == '"':
+			s, n, err := lexQueryString(expr[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%w at position %d", err, i+1)
+			}
+			tokens = append(tokens, queryToken{tokString, s, i + 1})
+			i += n
+		case c == '.' && i+1 < len(expr) && isQueryIdentStart(expr[i+1]):
+			j := i + 1
+			for j < len(expr) && isQueryIdent(expr[j]) {
+				j++
+			}
+			tokens = append(tokens, queryToken{tokField, expr[i+1 : j], i + 1})
+			i = j
+		case c == '.' && i+1 < len(expr) && expr[i+1] == '"':
+			s, n, err := lexQueryString(expr[i+1:])
+			if err != nil {
+				return nil, fmt.Errorf("%w at position %d", err, i+2)
+			}
+			tokens = append(tokens, queryToken{tokField, s, i + 1})
+			i += n + 1
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(expr) && (expr[j] >= '0' && expr[j] <= '9' || expr[j] == '.' ||
+				expr[j] == 'e' || expr[j] == 'E' || (expr[j] == '-' || expr[j] == '+') && (expr[j-1] == 'e' || expr[j-1] == 'E')) {
+				j++
+			}
+			if _, err := strconv.ParseFloat(expr[i:j], 64); err != nil {
+				return nil, fmt.Errorf("invalid number %q at position %d", expr[i:j], i+1)
+			}
+			tokens = append(tokens, queryToken{tokNumber, expr[i:j], i + 1})
+			i = j
+		case isQueryIdentStart(c):
+			j := i
+			for j < len(expr) && isQueryIdent(expr[j]) {
+				j++
+			}
+			tokens = append(tokens, queryToken{tokIdent, expr[i:j], i + 1})
+			i = j
+		case c == '$':
+			j := i + 1
+			for j < len(expr) && isQueryIdent(expr[j]) {
+				j++
+			}
+			tokens = append(tokens, queryToken{tokIdent, expr[i:j], i + 1})
+			i = j
+		default:
+			op := ""
+			for _, o := range queryOps {
+				if strings.HasPrefix(expr[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q at position %d", c, i+1)
+			}
+			tokens = append(tokens, queryToken{tokOp, op, i + 1})
+			i += len(op)
+		}
+	}
+	return append(tokens, queryToken{kind: tokEOF, pos: len(expr) + 1}), nil
+}
+
+// lexQueryString reads the string literal at the start of s and returns it
+// with the number of bytes it took.
+func lexQueryString(s string) (string, int, error) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) && s[i+1] == '(' {
+				return "", 0, fmt.Errorf("string interpolation is not supported")
+			}
+			i++
+		case '"':
+			v, err := strconv.Unquote(s[:i+1])
+			if err != nil {
+				return "", 0, fmt.Errorf("invalid string %s", s[:i+1])
+			}
+			return v, i + 1, nil
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+func isQueryIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isQueryIdent(c byte) bool {
+	return isQueryIdentStart(c) || c >= '0' && c <= '9'
+}
+
+type queryParser struct {
+	tokens []queryToken
+	i      int
+}
+
+func parseQuery(expr string) (queryNode, error) {
+	tokens, err := lexQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return identityNode{}, nil
+	}
+	node, err := p.pipe(false)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.unexpected(t)
+	}
+	return node, nil
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.i]
+}
+
+func (p *queryParser) next() queryToken {
+	t := p.tokens[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// accept moves past the next token if it is the operator or keyword op.
+func (p *queryParser) accept(op string) bool {
+	if t := p.peek(); (t.kind == tokOp || t.kind == tokIdent) && t.text == op {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) expect(op string) error {
+	if !p.accept(op) {
+		t := p.peek()
+		return fmt.Errorf("expected %q but found %s at position %d", op, t, t.pos)
+	}
+	return nil
+}
+
+func (p *queryParser) unexpected(t queryToken) error {
+	if t.kind == tokIdent && t.text == "as" {
+		return fmt.Errorf("variables are not supported (position %d)", t.pos)
+	}
+	return fmt.Errorf("unexpected %s at position %d", t, t.pos)
+}
+
+// pipe parses the lowest precedence level; with noComma, a comma ends the
+// expression, as in object values.
+func (p *queryParser) pipe(noComma bool) (queryNode, error) {
+	left, err := p.comma(noComma)
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("|") {
+		right, err := p.comma(noComma)
+		if err != nil {
+			return nil, err
+		}
+		left = pipeNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) comma(noComma bool) (queryNode, error) {
+	left, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	for !noComma && p.accept(",") {
+		right, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		left = commaNode{left, right}
+	}
+	return left, nil
+}
+
+// queryPrecedence lists the binary operators from the loosest binding.
+var queryPrecedence = [][]string{
+	{"//"},
+	{"or"},
+	{"and"},
+	{"==", "!=", "<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *queryParser) binary(level int) (queryNode, error) {
+	if level == len(queryPrecedence) {
+		return p.unary()
+	}
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		op := ""
+		for _, o := range queryPrecedence[level] {
+			if (t.kind == tokOp || t.kind == tokIdent) && t.text == o {
+				op = o
+			}
+		}
+		if op == "" {
+			return left, nil
+		}
+		p.next()
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op, left, right}
+	}
+}
+
+func (p *queryParser) unary() (queryNode, error) {
+	if p.accept("-") {
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return binaryNode{"-", literalNode{0.0}, operand}, nil
+	}
+	return p.postfix()
+}
+
+func (p *queryParser) postfix() (queryNode, error) {
+	node, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokField:
+			p.next()
+			node = indexNode{node, literalNode{t.text}}
+		case t.kind == tokOp && t.text == "." && p.tokens[p.i+1].text == "[":
+			p.next()
+		case t.kind == tokOp && t.text == "[":
+			p.next()
+			if node, err = p.bracket(node); err != nil {
+				return nil, err
+			}
+		case t.kind == tokOp && t.text == "?":
+			p.next()
+			node = tryNode{node}
+		default:
+			return node, nil
+		}
+	}
+}
+
+// bracket parses what follows "[" after target: an iteration, an index
+// or a slice.
+func (p *queryParser) bracket(target queryNode) (queryNode, error) {
+	if p.accept("]") {
+		return iterateNode{target}, nil
+	}
+	var from, to queryNode
+	var err error
+	if !p.accept(":") {
+		if from, err = p.pipe(false); err != nil {
+			return nil, err
+		}
+		if p.accept("]") {
+			return indexNode{target, from}, nil
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek().text != "]" {
+		if to, err = p.pipe(false); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	return sliceNode{target, from, to}, nil
+}
+
+func (p *queryParser) primary() (queryNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokField:
+		return indexNode{identityNode{}, literalNode{t.text}}, nil
+	case tokString:
+		return literalNode{t.text}, nil
+	case tokNumber:
+		f, _ := strconv.ParseFloat(t.text, 64)
+		return literalNode{f}, nil
+	case tokIdent:
+		return p.word(t)
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of query at position %d", t.pos)
+	}
+
+	switch t.text {
+	case ".":
+		return identityNode{}, nil
+	case "..":
+		return recurseNode{}, nil
+	case "(":
+		node, err := p.pipe(false)
+		if err != nil {
+			return nil, err
+		}
+		return node, p.expect(")")
+	case "[":
+		if p.accept("]") {
+			return arrayNode{}, nil
+		}
+		node, err := p.pipe(false)
+		if err != nil {
+			return nil, err
+		}
+		return arrayNode{node}, p.expect("]")
+	case "{":
+		return p.object()
+	}
+	return nil, p.unexpected(t)
+}
+
+// word parses a literal, an if expression or a function call.
+func (p *queryParser) word(t queryToken) (queryNode, error) {
+	switch t.text {
+	case "true", "false":
+		return literalNode{t.text == "true"}, nil
+	case "null":
+		return literalNode{nil}, nil
+	case "if":
+		return p.ifThen()
+	case "def", "reduce", "foreach", "try", "label", "import", "include":
+		return nil, fmt.Errorf("%q is not supported (position %d)", t.text, t.pos)
+	}
+	if strings.HasPrefix(t.text, "$") {
+		return nil, fmt.Errorf("variables are not supported (position %d)", t.pos)
+	}
+
+	call := callNode{name: t.text}
+	if p.accept("(") {
+		for {
+			arg, err := p.pipe(false)
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if !p.accept(";") {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := queryBuiltins[call.signature()]; !ok {
+		return nil, fmt.Errorf("unknown function %s at position %d", call.signature(), t.pos)
+	}
+	return call, nil
+}
+
+func (p *queryParser) ifThen() (queryNode, error) {
+	cond, err := p.pipe(false)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("then"); err != nil {
+		return nil, err
+	}
+	then, err := p.pipe(false)
+	if err != nil {
+		return nil, err
+	}
+	node := ifNode{cond: cond, then: then, otherwise: identityNode{}}
+	switch {
+	case p.accept("elif"):
+		if node.otherwise, err = p.ifThen(); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case p.accept("else"):
+		if node.otherwise, err = p.pipe(false); err != nil {
+			return nil, err
+		}
+	}
+	return node, p.expect("end")
+}
+
+func (p *queryParser) object() (queryNode, error) {
+	var node objectNode
+	for !p.accept("}") {
+		if len(node.keys) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+
+		var key queryNode
+		var name string
+		switch t := p.next(); {
+		case t.kind == tokIdent || t.kind == tokString:
+			name = t.text
+			key = literalNode{name}
+		case t.kind == tokOp && t.text == "(":
+			k, err := p.pipe(false)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			key = k
+		default:
+			return nil, p.unexpected(t)
+		}
+
+		var value queryNode
+		if p.accept(":") {
+			v, err := p.pipe(true)
+			if err != nil {
+				return nil, err
+			}
+			value = v
+		} else if name != "" {
+			value = indexNode{identityNode{}, literalNode{name}}
+		} else {
+			return nil, fmt.Errorf("expected %q after a computed object key at position %d", ":", p.peek().pos)
+		}
+		node.keys = append(node.keys, key)
+		node.values = append(node.values, value)
+	}
+	return node, nil
+}
@@ -0,0 +1,609 @@
+package polymorph
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type callNode struct {
+	name string
+	args []queryNode
+}
+
+func (n callNode) signature() string {
+	return fmt.Sprintf("%s/%d", n.name, len(n.args))
+}
+
+func (n callNode) eval(in any) ([]any, error) {
+	result, err := queryBuiltins[n.signature()](in, n.args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", n.name, err)
+	}
+	return result, nil
+}
+
+// queryBuiltins are the functions a query can call, by name and number of
+// arguments.
+var queryBuiltins map[string]func(in any, args []queryNode) ([]any, error)
+
+func init() {
+	one := func(fn func(in any) (any, error)) func(any, []queryNode) ([]any, error) {
+		return func(in any, _ []queryNode) ([]any, error) {
+			v, err := fn(in)
+			if err != nil {
+				return nil, err
+			}
+			return []any{v}, nil
+		}
+	}
+	// withArg calls fn with each result of the single argument.
+	withArg := func(fn func(in, arg any) (any, error)) func(any, []queryNode) ([]any, error) {
+		return func(in any, args []queryNode) ([]any, error) {
+			values, err := args[0].eval(in)
+			if err != nil {
+				return nil, err
+			}
+			var result []any
+			for _, a := range values {
+				v, err := fn(in, a)
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, v)
+			}
+			return result, nil
+		}
+	}
+	// byKey calls fn with the elements of the input array and their keys,
+	// the results of the argument for each.
+	byKey := func(fn func(items, keys []any) any) func(any, []queryNode) ([]any, error) {
+		return func(in any, args []queryNode) ([]any, error) {
+			items, ok := toSlice(in)
+			if !ok {
+				return nil, fmt.Errorf("%s is not an array", describe(in))
+			}
+			keys := make([]any, len(items))
+			for i, item := range items {
+				k, err := args[0].eval(item)
+				if err != nil {
+					return nil, err
+				}
+				keys[i] = k
+				if len(k) == 1 {
+					keys[i] = k[0]
+				}
+			}
+			return []any{fn(items, keys)}, nil
+		}
+	}
+
+	queryBuiltins = map[string]func(any, []queryNode) ([]any, error){
+		"empty/0": func(any, []queryNode) ([]any, error) { return nil, nil },
+		"not/0":   one(func(in any) (any, error) { return !truthy(in), nil }),
+		"type/0":  one(func(in any) (any, error) { return queryType(in), nil }),
+		"length/0": one(func(in any) (any, error) {
+			if s, ok := queryString(in); ok {
+				return float64(utf8.RuneCountInString(s)), nil
+			}
+			if f, ok := queryNumber(in); ok {
+				return math.Abs(f), nil
+			}
+			switch {
+			case in == nil:
+				return 0.0, nil
+			case isArray(in):
+				items, _ := toSlice(in)
+				return float64(len(items)), nil
+			case isObject(in):
+				obj, _ := toObject(in)
+				return float64(obj.Len()), nil
+			}
+			return nil, fmt.Errorf("%s has no length", describe(in))
+		}),
+		"keys/0": one(func(in any) (any, error) {
+			if obj, ok := toObject(in); ok {
+				return toAnySlice(sortedKeys(obj.values)), nil
+			}
+			if items, ok := toSlice(in); ok {
+				keys := make([]any, len(items))
+				for i := range items {
+					keys[i] = float64(i)
+				}
+				return keys, nil
+			}
+			return nil, fmt.Errorf("%s has no keys", describe(in))
+		}),
+		"keys_unsorted/0": one(func(in any) (any, error) {
+			if obj, ok := toObject(in); ok {
+				return toAnySlice(obj.Keys()), nil
+			}
+			return nil, fmt.Errorf("%s has no keys", describe(in))
+		}),
+		"has/1": withArg(func(in, key any) (any, error) {
+			if obj, ok := toObject(in); ok {
+				k, ok := key.(string)
+				if !ok {
+					return nil, fmt.Errorf("cannot check whether object has a key of type %s", queryType(key))
+				}
+				return obj.has(k), nil
+			}
+			if items, ok := toSlice(in); ok {
+				i, ok := queryNumber(key)
+				if !ok {
+					return nil, fmt.Errorf("cannot check whether array has a key of type %s", queryType(key))
+				}
+				return i >= 0 && int(i) < len(items), nil
+			}
+			return nil, fmt.Errorf("cannot check whether %s has a key", queryType(in))
+		}),
+		"select/1": func(in any, args []queryNode) ([]any, error) {
+			conds, err := args[0].eval(in)
+			if err != nil {
+				return nil, err
+			}
+			var result []any
+			for _, c := range conds {
+				if truthy(c) {
+					result = append(result, in)
+				}
+			}
+			return result, nil
+		},
+		"map/1": func(in any, args []queryNode) ([]any, error) {
+			out, err := pipeNode{iterateNode{identityNode{}}, args[0]}.eval(in)
+			if err != nil {
+				return nil, err
+			}
+			if out == nil {
+				out = []any{}
+			}
+			return []any{out}, nil
+		},
+		"map_values/1": func(in any, args []queryNode) ([]any, error) {
+			first := func(v any) (any, bool, error) {
+				out, err := args[0].eval(v)
+				if err != nil || len(out) == 0 {
+					return nil, false, err
+				}
+				return out[0], true, nil
+			}
+			if obj, ok := toObject(in); ok {
+				result := NewObject()
+				for _, k := range obj.Keys() {
+					mapped, ok, err := first(obj.values[k])
+					if err != nil {
+						return nil, err
+					}
+					if ok {
+						result.Set(k, mapped)
+					}
+				}
+				return []any{result}, nil
+			}
+			items, err := iterate(in)
+			if err != nil {
+				return nil, err
+			}
+			result := []any{}
+			for _, v := range items {
+				mapped, ok, err := first(v)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					result = append(result, mapped)
+				}
+			}
+			return []any{result}, nil
+		},
+		"to_entries/0": one(func(in any) (any, error) {
+			obj, ok := toObject(in)
+			if !ok {
+				return nil, fmt.Errorf("%s is not an object", describe(in))
+			}
+			entries := []any{}
+			for _, k := range obj.Keys() {
+				entry := NewObject()
+				entry.Set("key", k)
+				entry.Set("value", obj.values[k])
+				entries = append(entries, entry)
+			}
+			return entries, nil
+		}),
+		"from_entries/0": one(fromEntries),
+		"with_entries/1": func(in any, args []queryNode) ([]any, error) {
+			node := pipeNode{callNode{name: "to_entries"}, pipeNode{callNode{"map", args}, callNode{name: "from_entries"}}}
+			return node.eval(in)
+		},
+		"add/0": one(func(in any) (any, error) {
+			items, err := iterate(in)
+			if err != nil {
+				return nil, err
+			}
+			var sum any
+			for _, item := range items {
+				if sum, err = arithmetic("+", sum, item); err != nil {
+					return nil, err
+				}
+			}
+			return sum, nil
+		}),
+		"flatten/0": one(func(in any) (any, error) { return flatten(in, math.MaxInt) }),
+		"flatten/1": withArg(func(in, depth any) (any, error) {
+			d, ok := queryNumber(depth)
+			if !ok || d < 0 {
+				return nil, fmt.Errorf("depth must be a number not below 0, not %s", describe(depth))
+			}
+			return flatten(in, int(d))
+		}),
+		"sort/0": one(func(in any) (any, error) {
+			items, ok := toSlice(in)
+			if !ok {
+				return nil, fmt.Errorf("%s cannot be sorted, as it is not an array", describe(in))
+			}
+			sorted := append([]any{}, items...)
+			sort.SliceStable(sorted, func(i, j int) bool { return compare(sorted[i], sorted[j]) < 0 })
+			return sorted, nil
+		}),
+		"sort_by/1": byKey(func(items, keys []any) any {
+			order := sortedOrder(keys)
+			sorted := make([]any, len(items))
+			for i, idx := range order {
+				sorted[i] = items[idx]
+			}
+			return sorted
+		}),
+		"group_by/1": byKey(func(items, keys []any) any {
+			groups := []any{}
+			var group []any
+			var last any
+			for i, idx := range sortedOrder(keys) {
+				if i > 0 && compare(keys[idx], last) != 0 {
+					groups = append(groups, group)
+					group = nil
+				}
+				group = append(group, items[idx])
+				last = keys[idx]
+			}
+			if group != nil {
+				groups = append(groups, group)
+			}
+			return groups
+		}),
+		"unique_by/1": byKey(func(items, keys []any) any {
+			unique := []any{}
+			var last any
+			for i, idx := range sortedOrder(keys) {
+				if i == 0 || compare(keys[idx], last) != 0 {
+					unique = append(unique, items[idx])
+				}
+				last = keys[idx]
+			}
+			return unique
+		}),
+		"min_by/1": byKey(func(items, keys []any) any {
+			if order := sortedOrder(keys); len(order) > 0 {
+				return items[order[0]]
+			}
+			return nil
+		}),
+		"max_by/1": byKey(func(items, keys []any) any {
+			if order := sortedOrder(keys); len(order) > 0 {
+				return items[order[len(order)-1]]
+			}
+			return nil
+		}),
+		"reverse/0": one(func(in any) (any, error) {
+			if s, ok := in.(string); ok {
+				r := []rune(s)
+				for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+					r[i], r[j] = r[j], r[i]
+				}
+				return string(r), nil
+			}
+			if in == nil {
+				return []any{}, nil
+			}
+			items, ok := toSlice(in)
+			if !ok {
+				return nil, fmt.Errorf("%s cannot be reversed", describe(in))
+			}
+			reversed := make([]any, len(items))
+			for i, item := range items {
+				reversed[len(items)-1-i] = item
+			}
+			return reversed, nil
+		}),
+		"first/0": one(func(in any) (any, error) { return index(in, 0.0) }),
+		"last/0":  one(func(in any) (any, error) { return index(in, -1.0) }),
+		"first/1": func(in any, args []queryNode) ([]any, error) {
+			out, err := args[0].eval(in)
+			if err != nil || len(out) == 0 {
+				return nil, err
+			}
+			return out[:1], nil
+		},
+		"limit/2": func(in any, args []queryNode) ([]any, error) {
+			limits, err := args[0].eval(in)
+			if err != nil {
+				return nil, err
+			}
+			out, err := args[1].eval(in)
+			if err != nil {
+				return nil, err
+			}
+			var result []any
+			for _, l := range limits {
+				n, ok := queryNumber(l)
+				if !ok {
+					return nil, fmt.Errorf("the limit must be a number, not %s", describe(l))
+				}
+				result = append(result, out[:max(0, min(int(n), len(out)))]...)
+			}
+			return result, nil
+		},
+		"min/0": one(func(in any) (any, error) { return extreme(in, -1) }),
+		"max/0": one(func(in any) (any, error) { return extreme(in, 1) }),
+		"unique/0": one(func(in any) (any, error) {
+			items, ok := toSlice(in)
+			if !ok {
+				return nil, fmt.Errorf("%s is not an array", describe(in))
+			}
+			order := sortedOrder(items)
+			unique := []any{}
+			for i, idx := range order {
+				if i == 0 || compare(items[idx], items[order[i-1]]) != 0 {
+					unique = append(unique, items[idx])
+				}
+			}
+			return unique, nil
+		}),
+		"any/0": one(func(in any) (any, error) {
+			items, err := iterate(in)
+			return slices.ContainsFunc(items, truthy), err
+		}),
+		"all/0": one(func(in any) (any, error) {
+			items, err := iterate(in)
+			return !slices.ContainsFunc(items, func(v any) bool { return !truthy(v) }), err
+		}),
+		"contains/1": withArg(func(in, b any) (any, error) {
+			if queryType(in) != queryType(b) {
+				return nil, fmt.Errorf("%s and %s cannot have their containment checked", describe(in), describe(b))
+			}
+			return contains(in, b), nil
+		}),
+		"tostring/0": one(func(in any) (any, error) {
+			if s, ok := queryString(in); ok {
+				return s, nil
+			}
+			b, err := json.Marshal(in)
+			return string(b), err
+		}),
+		"tojson/0": one(func(in any) (any, error) {
+			b, err := json.Marshal(in)
+			return string(b), err
+		}),
+		"fromjson/0": one(func(in any) (any, error) {
+			s, ok := in.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s cannot be parsed as JSON, as it is not a string", describe(in))
+			}
+			var v any
+			if err := json.Unmarshal([]byte(s), &v); err != nil {
+				return nil, fmt.Errorf("%s is not valid JSON: %w", describe(in), err)
+			}
+			return v, nil
+		}),
+		"tonumber/0": one(func(in any) (any, error) {
+			if f, ok := queryNumber(in); ok {
+				return f, nil
+			}
+			if s, ok := in.(string); ok {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+					return f, nil
+				}
+			}
+			return nil, fmt.Errorf("%s cannot be parsed as a number", describe(in))
+		}),
+		"ascii_downcase/0": stringFunc(func(s string) any { return strings.ToLower(s) }),
+		"ascii_upcase/0":   stringFunc(func(s string) any { return strings.ToUpper(s) }),
+		"startswith/1":     stringArg(func(s, arg string) (any, error) { return strings.HasPrefix(s, arg), nil }),
+		"endswith/1":       stringArg(func(s, arg string) (any, error) { return strings.HasSuffix(s, arg), nil }),
+		"ltrimstr/1":       stringArg(func(s, arg string) (any, error) { return strings.TrimPrefix(s, arg), nil }),
+		"rtrimstr/1":       stringArg(func(s, arg string) (any, error) { return strings.TrimSuffix(s, arg), nil }),
+		"split/1":          stringArg(func(s, sep string) (any, error) { return splitString(s, sep), nil }),
+		"test/1": stringArg(func(s, pattern string) (any, error) {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression %q: %w", pattern, err)
+			}
+			return re.MatchString(s), nil
+		}),
+		"join/1": withArg(func(in, sep any) (any, error) {
+			separator, ok := sep.(string)
+			if !ok {
+				return nil, fmt.Errorf("the separator must be a string, not %s", describe(sep))
+			}
+			items, err := iterate(in)
+			if err != nil {
+				return nil, err
+			}
+			parts := make([]string, len(items))
+			for i, item := range items {
+				switch {
+				case item == nil:
+				case isArray(item) || isObject(item):
+					return nil, fmt.Errorf("cannot join %s", describe(item))
+				default:
+					parts[i], _ = formatScalar(item)
+				}
+			}
+			return strings.Join(parts, separator), nil
+		}),
+	}
+}
+
+// stringFunc makes a builtin of a function of the input string.
+func stringFunc(fn func(s string) any) func(any, []queryNode) ([]any, error) {
+	return func(in any, _ []queryNode) ([]any, error) {
+		s, ok := queryString(in)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a string", describe(in))
+		}
+		return []any{fn(s)}, nil
+	}
+}
+
+// stringArg makes a builtin of a function of the input string and each
+// result of the argument, both of which must be strings.
+func stringArg(fn func(s, arg string) (any, error)) func(any, []queryNode) ([]any, error) {
+	return func(in any, args []queryNode) ([]any, error) {
+		s, ok := queryString(in)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a string", describe(in))
+		}
+		values, err := args[0].eval(in)
+		if err != nil {
+			return nil, err
+		}
+		var result []any
+		for _, v := range values {
+			arg, ok := queryString(v)
+			if !ok {
+				return nil, fmt.Errorf("the argument must be a string, not %s", describe(v))
+			}
+			out, err := fn(s, arg)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, out)
+		}
+		return result, nil
+	}
+}
+
+func fromEntries(in any) (any, error) {
+	items, ok := toSlice(in)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an array", describe(in))
+	}
+	result := NewObject()
+	for _, item := range items {
+		entry, ok := toObject(item)
+		if !ok {
+			return nil, fmt.Errorf("entry %s is not an object", describe(item))
+		}
+		var key any
+		for _, name := range []string{"key", "k", "name", "Name", "Key", "K"} {
+			if k, ok := entry.Get(name); ok && k != nil {
+				key = k
+				break
+			}
+		}
+		var value any
+		for _, name := range []string{"value", "v", "Value", "V"} {
+			if v, ok := entry.Get(name); ok {
+				value = v
+				break
+			}
+		}
+		k, ok := formatScalar(key)
+		if key == nil || !ok {
+			return nil, fmt.Errorf("entry %s has no usable key", describe(item))
+		}
+		result.Set(k, value)
+	}
+	return result, nil
+}
+
+func flatten(in any, depth int) (any, error) {
+	items, ok := toSlice(in)
+	if !ok {
+		return nil, fmt.Errorf("%s cannot be flattened, as it is not an array", describe(in))
+	}
+	result := []any{}
+	for _, item := range items {
+		if inner, ok := toSlice(item); ok && depth > 0 {
+			sub, _ := flatten(inner, depth-1)
+			result = append(result, sub.([]any)...)
+			continue
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// extreme returns the smallest element of an array when sign is -1, the
+// largest when it is 1.
+func extreme(in any, sign int) (any, error) {
+	items, ok := toSlice(in)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an array", describe(in))
+	}
+	var best any
+	for i, item := range items {
+		if i == 0 || compare(item, best)*sign >= 0 {
+			best = item
+		}
+	}
+	return best, nil
+}
+
+// sortedOrder returns the indexes of keys in sorted order, stable.
+func sortedOrder(keys []any) []int {
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return compare(keys[order[i]], keys[order[j]]) < 0 })
+	return order
+}
+
+func splitString(s, sep string) []any {
+	parts := []any{}
+	if s == "" {
+		return parts
+	}
+	for _, p := range strings.Split(s, sep) {
+		parts = append(parts, p)
+	}
+	return parts
+}
+
+func contains(a, b any) bool {
+	if as, ok := queryString(a); ok {
+		bs, _ := queryString(b)
+		return strings.Contains(as, bs)
+	}
+	if aobj, ok := toObject(a); ok {
+		bobj, _ := toObject(b)
+		for k, bv := range bobj.values {
+			av, found := aobj.Get(k)
+			if !found || queryType(av) != queryType(bv) || !contains(av, bv) {
+				return false
+			}
+		}
+		return true
+	}
+	if aitems, ok := toSlice(a); ok {
+		bitems, _ := toSlice(b)
+		for _, bv := range bitems {
+			if !slices.ContainsFunc(aitems, func(av any) bool { return queryType(av) == queryType(bv) && contains(av, bv) }) {
+				return false
+			}
+		}
+		return true
+	}
+	return compare(a, b) == 0
+}
+
+func containsValue(items []any, v any) bool {
+	return slices.ContainsFunc(items, func(item any) bool { return compare(item, v) == 0 })
+}
@@ -0,0 +1,577 @@
+package polymorph
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type identityNode struct{}
+
+func (identityNode) eval(in any) ([]any, error) { return []any{in}, nil }
+
+type literalNode struct{ value any }
+
+func (n literalNode) eval(any) ([]any, error) { return []any{n.value}, nil }
+
+type pipeNode struct{ left, right queryNode }
+
+func (n pipeNode) eval(in any) ([]any, error) {
+	left, err := n.left.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	var result []any
+	for _, v := range left {
+		out, err := n.right.eval(v)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, out...)
+	}
+	return result, nil
+}
+
+type commaNode struct{ left, right queryNode }
+
+func (n commaNode) eval(in any) ([]any, error) {
+	left, err := n.left.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	return append(left, right...), nil
+}
+
+// indexNode is .[key] on the results of target; the key is evaluated
+// against the same input as target.
+type indexNode struct{ target, key queryNode }
+
+func (n indexNode) eval(in any) ([]any, error) {
+	return product(in, n.target, n.key, func(v, k any) (any, error) {
+		return index(v, k)
+	})
+}
+
+func index(v, key any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if s, ok := key.(string); ok {
+		if obj, ok := toObject(v); ok {
+			return obj.values[s], nil
+		}
+	}
+	if f, ok := queryNumber(key); ok {
+		if items, ok := toSlice(v); ok {
+			i := int(math.Floor(f))
+			if i < 0 {
+				i += len(items)
+			}
+			if i < 0 || i >= len(items) {
+				return nil, nil
+			}
+			return items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("cannot index %s with %s", queryType(v), describe(key))
+}
+
+type sliceNode struct{ target, from, to queryNode }
+
+func (n sliceNode) eval(in any) ([]any, error) {
+	targets, err := n.target.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	bound := func(node queryNode, length int, def int) (int, error) {
+		if node == nil {
+			return def, nil
+		}
+		out, err := node.eval(in)
+		if err != nil {
+			return 0, err
+		}
+		if len(out) != 1 {
+			return 0, fmt.Errorf("slice bounds must be single values")
+		}
+		if out[0] == nil {
+			return def, nil
+		}
+		f, ok := queryNumber(out[0])
+		if !ok {
+			return 0, fmt.Errorf("slice bounds must be numbers, not %s", queryType(out[0]))
+		}
+		i := int(math.Floor(f))
+		if i < 0 {
+			i += length
+		}
+		return max(0, min(i, length)), nil
+	}
+
+	var result []any
+	for _, v := range targets {
+		var length int
+		items, isArray := toSlice(v)
+		s, isString := v.(string)
+		switch {
+		case v == nil:
+			result = append(result, nil)
+			continue
+		case isArray:
+			length = len(items)
+		case isString:
+			length = utf8.RuneCountInString(s)
+		default:
+			return nil, fmt.Errorf("cannot slice %s", queryType(v))
+		}
+		from, err := bound(n.from, length, 0)
+		if err != nil {
+			return nil, err
+		}
+		to, err := bound(n.to, length, length)
+		if err != nil {
+			return nil, err
+		}
+		to = max(from, to)
+		if isArray {
+			result = append(result, append([]any{}, items[from:to]...))
+		} else {
+			result = append(result, string([]rune(s)[from:to]))
+		}
+	}
+	return result, nil
+}
+
+type iterateNode struct{ target queryNode }
+
+func (n iterateNode) eval(in any) ([]any, error) {
+	targets, err := n.target.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	var result []any
+	for _, v := range targets {
+		items, err := iterate(v)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, items...)
+	}
+	return result, nil
+}
+
+// iterate returns the elements of an array, or the values of an object in
+// order.
+func iterate(v any) ([]any, error) {
+	if items, ok := toSlice(v); ok {
+		return items, nil
+	}
+	if obj, ok := toObject(v); ok {
+		values := make([]any, 0, obj.Len())
+		for _, k := range obj.Keys() {
+			values = append(values, obj.values[k])
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("cannot iterate over %s", describe(v))
+}
+
+// recurseNode is .., every value in the input, the input first.
+type recurseNode struct{}
+
+func (recurseNode) eval(in any) ([]any, error) {
+	result := []any{in}
+	if items, err := iterate(in); err == nil {
+		for _, item := range items {
+			sub, _ := recurseNode{}.eval(item)
+			result = append(result, sub...)
+		}
+	}
+	return result, nil
+}
+
+// tryNode is expr?, which yields nothing instead of failing.
+type tryNode struct{ expr queryNode }
+
+func (n tryNode) eval(in any) ([]any, error) {
+	result, err := n.expr.eval(in)
+	if err != nil {
+		return nil, nil
+	}
+	return result, nil
+}
+
+type arrayNode struct{ expr queryNode }
+
+func (n arrayNode) eval(in any) ([]any, error) {
+	if n.expr == nil {
+		return []any{[]any{}}, nil
+	}
+	items, err := n.expr.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []any{}
+	}
+	return []any{items}, nil
+}
+
+// objectNode builds one object for each combination of the results of its
+// keys and values.
+type objectNode struct{ keys, values []queryNode }
+
+func (n objectNode) eval(in any) ([]any, error) {
+	result := []any{NewObject()}
+	for i := range n.keys {
+		keys, err := n.keys[i].eval(in)
+		if err != nil {
+			return nil, err
+		}
+		values, err := n.values[i].eval(in)
+		if err != nil {
+			return nil, err
+		}
+		var next []any
+		for _, partial := range result {
+			for _, k := range keys {
+				key, ok := k.(string)
+				if !ok {
+					return nil, fmt.Errorf("object keys must be strings, not %s", describe(k))
+				}
+				for _, v := range values {
+					obj := partial.(*Object).clone()
+					obj.Set(key, v)
+					next = append(next, obj)
+				}
+			}
+		}
+		result = next
+	}
+	return result, nil
+}
+
+type ifNode struct{ cond, then, otherwise queryNode }
+
+func (n ifNode) eval(in any) ([]any, error) {
+	conds, err := n.cond.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	var result []any
+	for _, c := range conds {
+		branch := n.otherwise
+		if truthy(c) {
+			branch = n.then
+		}
+		out, err := branch.eval(in)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, out...)
+	}
+	return result, nil
+}
+
+type binaryNode struct {
+	op          string
+	left, right queryNode
+}
+
+func (n binaryNode) eval(in any) ([]any, error) {
+	switch n.op {
+	case "and", "or":
+		lefts, err := n.left.eval(in)
+		if err != nil {
+			return nil, err
+		}
+		var result []any
+		for _, l := range lefts {
+			if truthy(l) == (n.op == "or") {
+				result = append(result, n.op == "or")
+				continue
+			}
+			rights, err := n.right.eval(in)
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range rights {
+				result = append(result, truthy(r))
+			}
+		}
+		return result, nil
+	case "//":
+		lefts, err := n.left.eval(in)
+		var result []any
+		if err == nil {
+			for _, l := range lefts {
+				if truthy(l) {
+					result = append(result, l)
+				}
+			}
+		}
+		if len(result) > 0 {
+			return result, nil
+		}
+		return n.right.eval(in)
+	}
+	return product(in, n.left, n.right, func(l, r any) (any, error) {
+		return arithmetic(n.op, l, r)
+	})
+}
+
+// product applies fn to every pair of results of left and right.
+func product(in any, left, right queryNode, fn func(l, r any) (any, error)) ([]any, error) {
+	lefts, err := left.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	rights, err := right.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	var result []any
+	for _, l := range lefts {
+		for _, r := range rights {
+			v, err := fn(l, r)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, v)
+		}
+	}
+	return result, nil
+}
+
+func arithmetic(op string, l, r any) (any, error) {
+	switch op {
+	case "==":
+		return compare(l, r) == 0, nil
+	case "!=":
+		return compare(l, r) != 0, nil
+	case "<":
+		return compare(l, r) < 0, nil
+	case "<=":
+		return compare(l, r) <= 0, nil
+	case ">":
+		return compare(l, r) > 0, nil
+	case ">=":
+		return compare(l, r) >= 0, nil
+	}
+
+	lf, lnum := queryNumber(l)
+	rf, rnum := queryNumber(r)
+	if lnum && rnum {
+		switch op {
+		case "+":
+			return lf + rf, nil
+		case "-":
+			return lf - rf, nil
+		case "*":
+			return lf * rf, nil
+		case "/":
+			if rf == 0 {
+				return nil, fmt.Errorf("%s and %s cannot be divided because the divisor is zero", describe(l), describe(r))
+			}
+			return lf / rf, nil
+		case "%":
+			if int64(rf) == 0 {
+				return nil, fmt.Errorf("%s and %s cannot be divided because the divisor is zero", describe(l), describe(r))
+			}
+			return float64(int64(lf) % int64(rf)), nil
+		}
+	}
+
+	ls, lstr := queryString(l)
+	rs, rstr := queryString(r)
+	switch {
+	case op == "+" && l == nil:
+		return r, nil
+	case op == "+" && r == nil:
+		return l, nil
+	case op == "+" && lstr && rstr:
+		return ls + rs, nil
+	case op == "/" && lstr && rstr:
+		return splitString(ls, rs), nil
+	}
+
+	litems, larr := toSlice(l)
+	ritems, rarr := toSlice(r)
+	switch {
+	case op == "+" && larr && rarr:
+		return append(append([]any{}, litems...), ritems...), nil
+	case op == "-" && larr && rarr:
+		result := []any{}
+		for _, item := range litems {
+			if !containsValue(ritems, item) {
+				result = append(result, item)
+			}
+		}
+		return result, nil
+	}
+
+	lobj, lisObj := toObject(l)
+	robj, risObj := toObject(r)
+	if op == "+" && lisObj && risObj {
+		merged := lobj.clone()
+		for _, k := range robj.Keys() {
+			merged.Set(k, robj.values[k])
+		}
+		return merged, nil
+	}
+
+	verb := map[string]string{"+": "added", "-": "subtracted", "*": "multiplied", "/": "divided", "%": "divided"}[op]
+	return nil, fmt.Errorf("%s and %s cannot be %s", describe(l), describe(r), verb)
+}
+
+func truthy(v any) bool {
+	b, isBool := v.(bool)
+	return v != nil && (!isBool || b)
+}
+
+func isArray(v any) bool {
+	_, ok := toSlice(v)
+	return ok
+}
+
+func isObject(v any) bool {
+	_, ok := toObject(v)
+	return ok
+}
+
+// queryNumber returns any number as a float64.
+func queryNumber(v any) (float64, bool) {
+	n, ok := toNumber(v)
+	if !ok {
+		return 0, false
+	}
+	switch n := n.(type) {
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+	return n.(float64), true
+}
+
+// queryString returns a string, or a time as written in RFC 3339.
+func queryString(v any) (string, bool) {
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case time.Time:
+		return s.Format(time.RFC3339Nano), true
+	}
+	return "", false
+}
+
+func queryType(v any) string {
+	if _, ok := queryNumber(v); ok {
+		return "number"
+	}
+	if _, ok := queryString(v); ok {
+		return "string"
+	}
+	switch {
+	case v == nil:
+		return "null"
+	case isArray(v):
+		return "array"
+	case isObject(v):
+		return "object"
+	}
+	if _, ok := v.(bool); ok {
+		return "boolean"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// describe names the type of v with a short excerpt of it, for errors.
+func describe(v any) string {
+	if v == nil {
+		return "null"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return queryType(v)
+	}
+	s := string(b)
+	if len(s) > 30 {
+		s = s[:27] + "..."
+	}
+	return fmt.Sprintf("%s (%s)", queryType(v), s)
+}
+
+// queryTypeOrder ranks the types the way jq sorts them.
+var queryTypeOrder = map[string]int{"null": 0, "boolean": 1, "number": 2, "string": 3, "array": 4, "object": 5}
+
+// compare orders any two values: null, false, true, numbers, strings,
+// arrays, then objects.
+func compare(a, b any) int {
+	ta, tb := queryType(a), queryType(b)
+	if ta != tb {
+		return queryTypeOrder[ta] - queryTypeOrder[tb]
+	}
+	switch ta {
+	case "boolean":
+		ab, bb := a.(bool), b.(bool)
+		switch {
+		case ab == bb:
+			return 0
+		case bb:
+			return -1
+		}
+		return 1
+	case "number":
+		af, _ := queryNumber(a)
+		bf, _ := queryNumber(b)
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+		return 0
+	case "string":
+		as, _ := queryString(a)
+		bs, _ := queryString(b)
+		return strings.Compare(as, bs)
+	case "array":
+		aitems, _ := toSlice(a)
+		bitems, _ := toSlice(b)
+		for i := 0; i < len(aitems) && i < len(bitems); i++ {
+			if c := compare(aitems[i], bitems[i]); c != 0 {
+				return c
+			}
+		}
+		return len(aitems) - len(bitems)
+	case "object":
+		aobj, _ := toObject(a)
+		bobj, _ := toObject(b)
+		akeys, bkeys := sortedKeys(aobj.values), sortedKeys(bobj.values)
+		if c := compare(toAnySlice(akeys), toAnySlice(bkeys)); c != 0 {
+			return c
+		}
+		for _, k := range akeys {
+			if c := compare(aobj.values[k], bobj.values[k]); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
+
+func toAnySlice(s []string) []any {
+	result := make([]any, len(s))
+	for i, v := range s {
+		result[i] = v
+	}
+	return result
+}
@@ -0,0 +1,146 @@
+package polymorph
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const queryInput = `{
+  "users": [
+    {"name": "Ann", "email": "ann@x.io", "active": true, "age": 31, "team": "core", "tags": ["a", "b"]},
+    {"name": "Bob", "email": "bob@x.io", "active": false, "age": 25, "team": "web", "tags": []},
+    {"name": "Cid", "email": "cid@x.io", "active": true, "age": 40, "team": "core", "tags": ["b", ["c"]]}
+  ],
+  "meta": {"version": 2, "owner": null}
+}`
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{".", queryInput},
+		{"", queryInput},
+		{".meta.version", `2`},
+		{`.meta."version"`, `2`},
+		{`.["meta"].owner`, `null`},
+		{".users[0].name", `"Ann"`},
+		{".users[-1].name", `"Cid"`},
+		{".users[5]", `null`},
+		{".users[1:].[0].name", `"Bob"`},
+		{".users[:2] | length", `2`},
+		{`.users[0].name[1:]`, `"nn"`},
+		{".users[].name", `["Ann", "Bob", "Cid"]`},
+		{".users[] | select(.active) | {name, email}", `[{"name": "Ann", "email": "ann@x.io"}, {"name": "Cid", "email": "cid@x.io"}]`},
+		{`.users[] | select(.age > 30 and .team == "core") | .name`, `["Ann", "Cid"]`},
+		{".users | map(.age) | add", `96`},
+		{".users | map(.age * 2 - 1)", `[61, 49, 79]`},
+		{".users | sort_by(.age) | map(.name)", `["Bob", "Ann", "Cid"]`},
+		{".users | sort_by(.team, -.age) | map(.name)", `["Cid", "Ann", "Bob"]`},
+		{".users | group_by(.team) | map({team: .[0].team, count: length})", `[{"team": "core", "count": 2}, {"team": "web", "count": 1}]`},
+		{".users | unique_by(.team) | map(.name)", `["Ann", "Bob"]`},
+		{".users | max_by(.age) | .name", `"Cid"`},
+		{".users | map(.tags) | flatten", `["a", "b", "b", "c"]`},
+		{".users | map(.tags) | flatten(1)", `["a", "b", "b", ["c"]]`},
+		{"[.users[].team] | unique", `["core", "web"]`},
+		{"{(.users[0].name): .meta.version}", `{"Ann": 2}`},
+		{`{user: .users[].name, v: .meta.version} | select(.user != "Bob")`, `[{"user": "Ann", "v": 2}, {"user": "Cid", "v": 2}]`},
+		{`.meta.owner // "nobody"`, `"nobody"`},
+		{".meta | keys", `["owner", "version"]`},
+		{`.meta | has("owner")`, `true`},
+		{".meta | keys_unsorted", `["version", "owner"]`},
+		{".meta | to_entries | map(.key)", `["version", "owner"]`},
+		{".meta | with_entries(select(.value != null))", `{"version": 2}`},
+		{`.users | map(if .age >= 40 then "senior" elif .age >= 30 then "mid" else "junior" end)`, `["mid", "junior", "senior"]`},
+		{`.users | map(.name | ascii_downcase) | join(", ")`, `"ann, bob, cid"`},
+		{`.users[] | select(.email | test("^c")) | .name`, `"Cid"`},
+		{`.users | map(select(.tags | contains(["b"]))) | length`, `2`},
+		{`.users | first(.[] | select(.active | not)) | .name`, `"Bob"`},
+		{`[limit(2; .users[].name)]`, `["Ann", "Bob"]`},
+		{`[.. | .age? | select(. != null)]`, `[31, 25, 40]`},
+		{`.users[0] | .name, .age`, `["Ann", 31]`},
+		{`.users[0].name.first?`, `[]`},
+		{`[.users[] | .age] | min, max`, `[25, 40]`},
+		{`"a,b" | split(",")`, `["a", "b"]`},
+		{`.users[0] + {age: 32} | .age`, `32`},
+		{`[1, [2]] - [[2]]`, `[1]`},
+		{`10 % 3, -1`, `[1, -1]`},
+		{`.meta.version | tostring`, `"2"`},
+		{`"42" | tonumber`, `42`},
+		{`[.meta[] | type]`, `["number", "null"]`},
+		{`[null, true, 1, "a", [], {}] | sort | map(type)`, `["null", "boolean", "number", "string", "array", "object"]`},
+	}
+
+	data, err := parseJSON([]byte(queryInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := Query(data, tt.query)
+			if err != nil {
+				t.Fatalf("Query(%q) error = %v", tt.query, err)
+			}
+			var want any
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if compare(got, want) != 0 {
+				b, _ := json.Marshal(got)
+				t.Errorf("Query(%q) = %s, want %s", tt.query, b, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{".users[", "unexpected end of query at position 8"},
+		{".users | sort_by(.age", `expected ")" but found end of query at position 22`},
+		{".users | frobnicate", "unknown function frobnicate/0 at position 10"},
+		{".users | select(.a; .b)", "unknown function select/2"},
+		{".users[0] | {(.age): 1}", `object keys must be strings, not number (31)`},
+		{".users.name", `cannot index array with string ("name")`},
+		{".meta.version[]", "cannot iterate over number (2)"},
+		{`.users[0].name + 1`, `string ("Ann") and number (1) cannot be added`},
+		{`1 / 0`, "cannot be divided because the divisor is zero"},
+		{`.meta | sort`, "sort: object"},
+		{`.x as $v | $v`, "variables are not supported"},
+		{`reduce .[] as $x (0; . + $x)`, `"reduce" is not supported`},
+		{`"\(1)"`, "string interpolation is not supported"},
+		{`.a }`, `unexpected "}" at position 4`},
+	}
+
+	var data any
+	json.Unmarshal([]byte(queryInput), &data)
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := Query(data, tt.query)
+			if err == nil {
+				t.Fatalf("Query(%q) succeeded, want an error", tt.query)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Query(%q) error = %q, want it to contain %q", tt.query, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertWithQuery(t *testing.T) {
+	input := []byte("users:\n  - name: Ann\n    email: ann@x.io\n    active: true\n  - name: Bob\n    email: bob@x.io\n    active: false\n")
+	output, err := ConvertWith(input, "yaml", "csv", Options{Query: ".users[] | select(.active) | {name, email}"})
+	if err != nil {
+		t.Fatalf("ConvertWith failed: %v", err)
+	}
+	if got, want := string(output), "name,email\nAnn,ann@x.io\n"; got != want {
+		t.Errorf("ConvertWith() = %q, want %q", got, want)
+	}
+
+	if _, err := ConvertWith(input, "yaml", "json", Options{Query: ".users[0].name.x"}); err == nil || !strings.HasPrefix(err.Error(), "query error: ") {
+		t.Errorf("ConvertWith() error = %v, want a query error", err)
+	}
+}
@@ -0,0 +1,322 @@
+package polymorph
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// schemaDraft is the JSON Schema dialect polymorph validates and infers.
+const schemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// schemaBase is the base URI of a schema without an $id, against which
+// its references are resolved.
+const schemaBase = "file:///schema.json"
+
+// Schema is a JSON Schema (draft 2020-12) ready to validate data.
+type Schema struct {
+	root any
+	// resources are the schemas with an $id, by absolute URI.
+	resources map[string]any
+	// anchors are the schemas with an $anchor or $dynamicAnchor, by
+	// absolute URI with the anchor as fragment.
+	anchors map[string]any
+	// refs are the targets of each schema's $ref and $dynamicRef.
+	refs        map[*Object]any
+	dynamicRefs map[*Object]any
+	patterns    map[string]*regexp.Regexp
+}
+
+// SchemaError is a value that does not match a schema.
+type SchemaError struct {
+	// Path is the JSON Pointer of the value, empty for the document.
+	Path string
+	// KeywordPath is the JSON Pointer of the schema keyword it fails.
+	KeywordPath string
+	Message     string
+}
+
+func (e SchemaError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "/"
+	}
+	return path + ": " + e.Message
+}
+
+// schemaTypes are the values of the "type" keyword.
+var schemaTypes = []string{"null", "boolean", "object", "array", "number", "integer", "string"}
+
+// schemaRef is a $ref or $dynamicRef waiting to be resolved.
+type schemaRef struct {
+	from    *Object
+	base    string
+	ref     string
+	pointer string
+	dynamic bool
+}
+
+// CompileSchema checks schema, a JSON Schema as returned by Parse, and
+// resolves its references. Only draft 2020-12 is supported, and
+// references must point inside the schema. $dynamicRef is resolved like
+// $ref.
+func CompileSchema(schema any) (*Schema, error) {
+	if obj, ok := toObject(schema); ok {
+		if v, ok := obj.Get("$schema"); ok && strings.TrimSuffix(fmt.Sprint(v), "#") != schemaDraft {
+			return nil, fmt.Errorf("unsupported $schema %q: only draft 2020-12 (%s) is supported", v, schemaDraft)
+		}
+	}
+
+	s := &Schema{
+		root:        schema,
+		resources:   map[string]any{schemaBase: schema},
+		anchors:     make(map[string]any),
+		refs:        make(map[*Object]any),
+		dynamicRefs: make(map[*Object]any),
+		patterns:    make(map[string]*regexp.Regexp),
+	}
+	var refs []schemaRef
+	if err := s.compile(schema, schemaBase, "", &refs); err != nil {
+		return nil, err
+	}
+	for _, r := range refs {
+		target, err := s.resolve(r.base, r.ref)
+		if err != nil {
+			keyword := "$ref"
+			if r.dynamic {
+				keyword = "$dynamicRef"
+			}
+			return nil, fmt.Errorf("%s/%s: %w", r.pointer, keyword, err)
+		}
+		if r.dynamic {
+			s.dynamicRefs[r.from] = target
+		} else {
+			s.refs[r.from] = target
+		}
+	}
+	return s, nil
+}
+
+// compile walks the schema at pointer, registering its $id and anchors,
+// compiling its patterns and collecting its references.
+func (s *Schema) compile(schema any, base, pointer string, refs *[]schemaRef) error {
+	if _, ok := schema.(bool); ok {
+		return nil
+	}
+	obj, ok := toObject(schema)
+	if !ok {
+		return fmt.Errorf("%s: a schema must be an object or a boolean, not %s", pointerOrRoot(pointer), describe(schema))
+	}
+
+	if v, ok := obj.Get("$id"); ok {
+		id, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s/$id: must be a string", pointer)
+		}
+		resolved, err := resolveURI(base, id)
+		if err != nil {
+			return fmt.Errorf("%s/$id: %w", pointer, err)
+		}
+		base = resolved
+		s.resources[base] = obj
+	}
+	for _, keyword := range []string{"$anchor", "$dynamicAnchor"} {
+		if v, ok := obj.Get(keyword); ok {
+			anchor, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("%s/%s: must be a string", pointer, keyword)
+			}
+			s.anchors[base+"#"+anchor] = obj
+		}
+	}
+	for _, keyword := range []string{"$ref", "$dynamicRef"} {
+		if v, ok := obj.Get(keyword); ok {
+			ref, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("%s/%s: must be a string", pointer, keyword)
+			}
+			*refs = append(*refs, schemaRef{from: obj, base: base, ref: ref, pointer: pointer, dynamic: keyword == "$dynamicRef"})
+		}
+	}
+
+	if v, ok := obj.Get("type"); ok {
+		names, ok := typeNames(v)
+		if !ok {
+			return fmt.Errorf("%s/type: must be one of %s, or an array of them", pointer, strings.Join(schemaTypes, ", "))
+		}
+		for _, name := range names {
+			if !slices.Contains(schemaTypes, name) {
+				return fmt.Errorf("%s/type: unknown type %q", pointer, name)
+			}
+		}
+	}
+	if v, ok := obj.Get("required"); ok {
+		if _, ok := stringList(v); !ok {
+			return fmt.Errorf("%s/required: must be an array of strings", pointer)
+		}
+	}
+	if v, ok := obj.Get("pattern"); ok {
+		if err := s.compilePattern(v, pointer+"/pattern"); err != nil {
+			return err
+		}
+	}
+
+	for _, keyword := range []string{"additionalProperties", "propertyNames", "items", "contains", "not", "if", "then", "else", "unevaluatedItems", "unevaluatedProperties", "contentSchema"} {
+		if sub, ok := obj.Get(keyword); ok {
+			if err := s.compile(sub, base, pointer+"/"+keyword, refs); err != nil {
+				return err
+			}
+		}
+	}
+	for _, keyword := range []string{"properties", "patternProperties", "dependentSchemas", "$defs", "definitions"} {
+		v, ok := obj.Get(keyword)
+		if !ok {
+			continue
+		}
+		subs, ok := toObject(v)
+		if !ok {
+			return fmt.Errorf("%s/%s: must be an object", pointer, keyword)
+		}
+		for _, k := range subs.Keys() {
+			if keyword == "patternProperties" {
+				if err := s.compilePattern(k, pointer+"/"+keyword); err != nil {
+					return err
+				}
+			}
+			if err := s.compile(subs.values[k], base, pointer+"/"+keyword+"/"+escapePointer(k), refs); err != nil {
+				return err
+			}
+		}
+	}
+	for _, keyword := range []string{"allOf", "anyOf", "oneOf", "prefixItems"} {
+		v, ok := obj.Get(keyword)
+		if !ok {
+			continue
+		}
+		subs, ok := toSlice(v)
+		if !ok || len(subs) == 0 {
+			return fmt.Errorf("%s/%s: must be a non-empty array", pointer, keyword)
+		}
+		for i, sub := range subs {
+			if err := s.compile(sub, base, pointer+"/"+keyword+"/"+strconv.Itoa(i), refs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) compilePattern(v any, pointer string) error {
+	pattern, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("%s: must be a string", pointer)
+	}
+	if _, ok := s.patterns[pattern]; ok {
+		return nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("%s: invalid pattern %q: %w", pointer, pattern, err)
+	}
+	s.patterns[pattern] = re
+	return nil
+}
+
+// resolve finds the schema ref points to, from a schema with the given
+// base URI.
+func (s *Schema) resolve(base, ref string) (any, error) {
+	target, err := resolveURI(base, ref)
+	if err != nil {
+		return nil, err
+	}
+	uri, fragment, _ := strings.Cut(target, "#")
+	resource, ok := s.resources[uri]
+	if !ok {
+		return nil, fmt.Errorf("cannot resolve %q: only references inside the schema are supported", ref)
+	}
+	if fragment == "" {
+		return resource, nil
+	}
+	if !strings.HasPrefix(fragment, "/") {
+		if anchor, ok := s.anchors[uri+"#"+fragment]; ok {
+			return anchor, nil
+		}
+		return nil, fmt.Errorf("cannot resolve %q: no $anchor %q", ref, fragment)
+	}
+
+	current := resource
+	for _, part := range strings.Split(fragment[1:], "/") {
+		part = strings.NewReplacer("~1", "/", "~0", "~").Replace(part)
+		if obj, ok := toObject(current); ok {
+			if current, ok = obj.Get(part); ok {
+				continue
+			}
+		} else if items, ok := toSlice(current); ok {
+			if i, err := strconv.Atoi(part); err == nil && i >= 0 && i < len(items) {
+				current = items[i]
+				continue
+			}
+		}
+		return nil, fmt.Errorf("cannot resolve %q: no schema at %s", ref, fragment)
+	}
+	return current, nil
+}
+
+// resolveURI resolves ref against base, returning it with its fragment
+// unescaped.
+func resolveURI(base, ref string) (string, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	resolved := b.ResolveReference(r)
+	fragment := resolved.Fragment
+	resolved.Fragment = ""
+	if fragment == "" {
+		return resolved.String(), nil
+	}
+	return resolved.String() + "#" + fragment, nil
+}
+
+// typeNames returns the value of a "type" keyword as a list.
+func typeNames(v any) ([]string, bool) {
+	if name, ok := v.(string); ok {
+		return []string{name}, true
+	}
+	return stringList(v)
+}
+
+func stringList(v any) ([]string, bool) {
+	items, ok := toSlice(v)
+	if !ok {
+		return nil, false
+	}
+	list := make([]string, len(items))
+	for i, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, false
+		}
+		list[i] = s
+	}
+	return list, true
+}
+
+// escapePointer escapes a key for use in a JSON Pointer.
+func escapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+func pointerOrRoot(pointer string) string {
+	if pointer == "" {
+		return "/"
+	}
+	return pointer
+}
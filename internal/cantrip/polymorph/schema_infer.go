@@ -0,0 +1,177 @@
+package polymorph
+
+import (
+	"regexp"
+	"time"
+)
+
+// InferSchema returns a JSON Schema (draft 2020-12) that each of samples,
+// as returned by Parse, matches. Properties present in every object at
+// the same place are required, integers seen with fractions become
+// numbers, and strings that are all dates, date-times, emails or UUIDs
+// get a format.
+func InferSchema(samples ...any) *Object {
+	s := &shape{}
+	for _, sample := range samples {
+		s.add(sample)
+	}
+	schema := NewObject()
+	schema.Set("$schema", schemaDraft)
+	inferred := s.schema()
+	for _, k := range inferred.Keys() {
+		schema.Set(k, inferred.values[k])
+	}
+	return schema
+}
+
+// shape gathers the types and structure of the values seen at one place
+// in the samples.
+type shape struct {
+	types map[string]bool
+
+	// objects counts the objects seen, and seen how many of them had each
+	// property.
+	objects int
+	props   *Object
+	seen    map[string]int
+
+	items *shape
+
+	// strings counts the strings seen, and format is the format all of
+	// them have, empty if they differ.
+	strings int
+	format  string
+}
+
+// schemaTypeOrder is the order of types in an inferred "type" array.
+var schemaTypeOrder = []string{"object", "array", "string", "number", "integer", "boolean", "null"}
+
+var (
+	inferEmail = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	inferUUID  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+func (s *shape) add(v any) {
+	if s.types == nil {
+		s.types = make(map[string]bool)
+	}
+	if obj, ok := toObject(v); ok {
+		s.types["object"] = true
+		s.objects++
+		if s.props == nil {
+			s.props, s.seen = NewObject(), make(map[string]int)
+		}
+		for _, k := range obj.Keys() {
+			child, ok := s.props.values[k].(*shape)
+			if !ok {
+				child = &shape{}
+				s.props.Set(k, child)
+			}
+			child.add(obj.values[k])
+			s.seen[k]++
+		}
+		return
+	}
+	if items, ok := toSlice(v); ok {
+		s.types["array"] = true
+		for _, item := range items {
+			if s.items == nil {
+				s.items = &shape{}
+			}
+			s.items.add(item)
+		}
+		return
+	}
+
+	switch {
+	case v == nil:
+		s.types["null"] = true
+	case isInteger(v):
+		s.types["integer"] = true
+	default:
+		if _, ok := queryNumber(v); ok {
+			s.types["number"] = true
+			return
+		}
+		if str, ok := schemaString(v); ok {
+			s.types["string"] = true
+			if format := stringFormat(v, str); s.strings == 0 {
+				s.format = format
+			} else if format != s.format {
+				s.format = ""
+			}
+			s.strings++
+			return
+		}
+		if _, ok := v.(bool); ok {
+			s.types["boolean"] = true
+		}
+	}
+}
+
+// stringFormat returns the format of a string, or "" if it has none that
+// InferSchema detects.
+func stringFormat(v any, s string) string {
+	if t, ok := v.(time.Time); ok {
+		if _, local := localTimeString(t); !local {
+			return "date-time"
+		}
+		if t.Location() == localDate {
+			return "date"
+		}
+		return ""
+	}
+	if _, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return "date-time"
+	}
+	if _, err := time.Parse(time.DateOnly, s); err == nil {
+		return "date"
+	}
+	if inferUUID.MatchString(s) {
+		return "uuid"
+	}
+	if inferEmail.MatchString(s) {
+		return "email"
+	}
+	return ""
+}
+
+func (s *shape) schema() *Object {
+	schema := NewObject()
+	var types []string
+	for _, t := range schemaTypeOrder {
+		if s.types[t] && !(t == "integer" && s.types["number"]) {
+			types = append(types, t)
+		}
+	}
+	switch len(types) {
+	case 0:
+		return schema
+	case 1:
+		schema.Set("type", types[0])
+	default:
+		schema.Set("type", toAnySlice(types))
+	}
+
+	if s.format != "" {
+		schema.Set("format", s.format)
+	}
+	if s.props != nil {
+		props := NewObject()
+		var required []string
+		for _, k := range s.props.Keys() {
+			props.Set(k, s.props.values[k].(*shape).schema())
+			if s.seen[k] == s.objects {
+				required = append(required, k)
+			}
+		}
+		schema.Set("properties", props)
+		if len(required) > 0 {
+			schema.Set("required", toAnySlice(required))
+		}
+	}
+	if s.items != nil {
+		schema.Set("items", s.items.schema())
+	}
+	return schema
+}
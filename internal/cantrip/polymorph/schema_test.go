@@ -0,0 +1,210 @@
+package polymorph
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mustCompile(t *testing.T, schema string) *Schema {
+	t.Helper()
+	data, err := parseJSON([]byte(schema))
+	if err != nil {
+		t.Fatalf("parseJSON(%s) error = %v", schema, err)
+	}
+	s, err := CompileSchema(data)
+	if err != nil {
+		t.Fatalf("CompileSchema(%s) error = %v", schema, err)
+	}
+	return s
+}
+
+func TestSchemaValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		data   string
+		want   []string
+	}{
+		{"true", `true`, `1`, nil},
+		{"false", `false`, `1`, []string{"/: no value is allowed here"}},
+		{"type", `{"type": "string"}`, `1`, []string{"/: expected string, got number (1)"}},
+		{"type list", `{"type": ["string", "null"]}`, `null`, nil},
+		{"integer", `{"type": "integer"}`, `1.0`, nil},
+		{"not integer", `{"type": "integer"}`, `1.5`, []string{"/: expected integer, got number (1.5)"}},
+		{"enum", `{"enum": ["a", 1]}`, `"b"`, []string{`/: must be one of "a", 1, got "b"`}},
+		{"const", `{"const": {"a": [1]}}`, `{"a": [1.0]}`, nil},
+		{"numbers", `{"minimum": 1, "exclusiveMaximum": 10, "multipleOf": 0.5}`, `10`, []string{"/: must be < 10, got 10"}},
+		{"multipleOf", `{"multipleOf": 0.1}`, `0.35`, []string{"/: must be a multiple of 0.1, got 0.35"}},
+		{"strings", `{"minLength": 2, "maxLength": 3, "pattern": "^a"}`, `"b"`, []string{`/: must be at least 2 characters long, got 1`, `/: must match pattern "^a", got "b"`}},
+		{"length counts runes", `{"maxLength": 2}`, `"àè"`, nil},
+		{
+			"objects",
+			`{"required": ["id", "name"], "properties": {"id": {"type": "integer"}}, "additionalProperties": false}`,
+			`{"id": "x", "extra": 1}`,
+			[]string{`/: missing required property "name"`, "/id: expected integer, got string (\"x\")", `/extra: property "extra" is not allowed`},
+		},
+		{
+			"patternProperties",
+			`{"patternProperties": {"^x-": {"type": "string"}}, "additionalProperties": {"type": "integer"}}`,
+			`{"x-a": "ok", "x-b": 1, "n": 2, "m": "no"}`,
+			[]string{"/x-b: expected string, got number (1)", "/m: expected integer, got string (\"no\")"},
+		},
+		{"propertyNames", `{"propertyNames": {"pattern": "^[a-z]+$"}}`, `{"ok": 1, "No": 2}`, []string{`/No: property name "No": must match pattern "^[a-z]+$", got "No"`}},
+		{"dependentRequired", `{"dependentRequired": {"card": ["cvv"]}}`, `{"card": 1}`, []string{`/: property "cvv" is required when "card" is set`}},
+		{"dependentSchemas", `{"dependentSchemas": {"card": {"required": ["cvv"]}}}`, `{"card": 1}`, []string{`/: missing required property "cvv"`}},
+		{"properties count", `{"minProperties": 2}`, `{"a": 1}`, []string{"/: must have at least 2 properties, got 1"}},
+		{"escaped path", `{"properties": {"a/b": {"type": "string"}}}`, `{"a/b": 1}`, []string{"/a~1b: expected string, got number (1)"}},
+		{"arrays", `{"minItems": 2, "uniqueItems": true, "items": {"type": "integer"}}`, `[1, 1.0, "x"]`, []string{"/: items 0 and 1 are equal", "/2: expected integer, got string (\"x\")"}},
+		{"prefixItems", `{"prefixItems": [{"type": "string"}, {"type": "integer"}], "items": false}`, `["a", 1, true]`, []string{"/2: item 2 is not allowed: the array takes at most 2 items"}},
+		{"contains", `{"contains": {"type": "string"}, "maxContains": 1}`, `["a", "b", 1]`, []string{"/: must contain at most 1 item matching the contains schema, got 2"}},
+		{"minContains", `{"contains": {"type": "string"}, "minContains": 0}`, `[1]`, nil},
+		{"allOf", `{"allOf": [{"type": "integer"}, {"minimum": 5}]}`, `3`, []string{"/: must be >= 5, got 3"}},
+		{"anyOf", `{"anyOf": [{"type": "string"}, {"type": "null"}]}`, `1`, []string{"/: does not match any schema in anyOf (0: expected string, got number (1); 1: expected null, got number (1))"}},
+		{
+			"oneOf deepest",
+			`{"oneOf": [{"type": "string"}, {"properties": {"port": {"type": "integer"}}, "required": ["port"]}]}`,
+			`{"port": "80"}`,
+			[]string{"/: does not match any schema in oneOf", "/port: expected integer, got string (\"80\")"},
+		},
+		{"oneOf many", `{"oneOf": [{"type": "integer"}, {"minimum": 0}]}`, `1`, []string{"/: matches schemas 0 and 1 of oneOf, want exactly one"}},
+		{"not", `{"not": {"type": "null"}}`, `null`, []string{"/: must not match the schema in not"}},
+		{"if then", `{"if": {"properties": {"kind": {"const": "tcp"}}}, "then": {"required": ["port"]}, "else": {"required": ["path"]}}`, `{"kind": "tcp"}`, []string{`/: missing required property "port"`}},
+		{"if else", `{"if": {"properties": {"kind": {"const": "tcp"}}}, "then": {"required": ["port"]}, "else": {"required": ["path"]}}`, `{"kind": "unix"}`, []string{`/: missing required property "path"`}},
+		{
+			"ref",
+			`{"$defs": {"port": {"type": "integer", "maximum": 65535}}, "properties": {"port": {"$ref": "#/$defs/port"}}}`,
+			`{"port": 70000}`,
+			[]string{"/port: must be <= 65535, got 70000"},
+		},
+		{
+			"recursive ref",
+			`{"properties": {"name": {"type": "string"}, "children": {"items": {"$ref": "#"}}}}`,
+			`{"name": "a", "children": [{"name": "b", "children": [{"name": 3}]}]}`,
+			[]string{"/children/0/children/0/name: expected string, got number (3)"},
+		},
+		{
+			"anchor and id",
+			`{"$id": "https://example.com/root.json", "$defs": {"n": {"$id": "name.json", "$anchor": "name", "type": "string"}}, "properties": {"a": {"$ref": "name.json"}, "b": {"$ref": "https://example.com/name.json#name"}}}`,
+			`{"a": 1, "b": 2}`,
+			[]string{"/a: expected string, got number (1)", "/b: expected string, got number (2)"},
+		},
+		{
+			"unevaluatedProperties",
+			`{"allOf": [{"properties": {"a": true}}], "properties": {"b": true}, "unevaluatedProperties": false}`,
+			`{"a": 1, "b": 2, "c": 3}`,
+			[]string{`/c: property "c" is not allowed`},
+		},
+		{
+			"unevaluatedItems",
+			`{"prefixItems": [true], "contains": {"type": "string"}, "unevaluatedItems": {"type": "integer"}}`,
+			`[null, "a", 1, true]`,
+			[]string{"/3: expected integer, got boolean (true)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := mustCompile(t, tt.schema)
+			data, err := parseJSON([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range schema.Validate(data) {
+				got = append(got, e.Error())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate(%s) =\n%q\nwant\n%q", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemaValidate_KeywordPath(t *testing.T) {
+	schema := mustCompile(t, `{"properties": {"users": {"items": {"$ref": "#/$defs/user"}}}, "$defs": {"user": {"required": ["email"]}}}`)
+	data, _ := parseJSON([]byte(`{"users": [{"email": "a@x.io"}, {}]}`))
+
+	errs := schema.Validate(data)
+	want := []SchemaError{{Path: "/users/1", KeywordPath: "/properties/users/items/$ref/required", Message: `missing required property "email"`}}
+	if !reflect.DeepEqual(errs, want) {
+		t.Errorf("Validate() = %+v, want %+v", errs, want)
+	}
+}
+
+func TestSchemaValidate_AnyFormat(t *testing.T) {
+	schema, err := Parse([]byte("type: object\nproperties:\n  port: {type: integer}\n  day: {type: string}\n"), "yaml", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := CompileSchema(schema)
+	if err != nil {
+		t.Fatalf("CompileSchema() error = %v", err)
+	}
+
+	for _, input := range []struct{ text, format string }{
+		{"port = 8080\nday = 2024-05-01\n", "toml"},
+		{"<r><port>8080</port></r>", "xml"},
+		{"port,day\n8080,2024-05-01\n", "csv"},
+	} {
+		data, err := Parse([]byte(input.text), input.format, Options{InferTypes: true, Query: "if type == \"array\" then .[0] elif has(\"r\") then .r else . end"})
+		if err != nil {
+			t.Fatalf("Parse(%s) error = %v", input.format, err)
+		}
+		if errs := s.Validate(data); len(errs) != 0 {
+			t.Errorf("Validate(%s) = %v", input.format, errs)
+		}
+	}
+}
+
+func TestCompileSchemaErrors(t *testing.T) {
+	tests := []struct {
+		schema string
+		want   string
+	}{
+		{`{"$schema": "http://json-schema.org/draft-07/schema#"}`, "only draft 2020-12"},
+		{`{"type": "text"}`, `/type: unknown type "text"`},
+		{`{"properties": {"a": 1}}`, "/properties/a: a schema must be an object or a boolean"},
+		{`{"pattern": "("}`, "/pattern: invalid pattern"},
+		{`{"anyOf": []}`, "/anyOf: must be a non-empty array"},
+		{`{"$ref": "#/$defs/missing"}`, `/$ref: cannot resolve "#/$defs/missing"`},
+		{`{"$ref": "https://example.com/other.json"}`, "only references inside the schema are supported"},
+		{`{"required": "id"}`, "/required: must be an array of strings"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.schema, func(t *testing.T) {
+			data, _ := parseJSON([]byte(tt.schema))
+			_, err := CompileSchema(data)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("CompileSchema() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestInferSchema(t *testing.T) {
+	first, _ := parseJSON([]byte(`{"name": "Ann", "age": 31, "email": "ann@x.io", "joined": "2024-05-01", "tags": ["a"], "address": {"city": "Rome"}}`))
+	second, _ := parseJSON([]byte(`{"name": "Bob", "age": 25.5, "email": null, "joined": "2024-05-02", "tags": [], "id": "6ba7b810-9dad-11d1-80b4-00c04fd430c8"}`))
+
+	schema := InferSchema(first, second)
+	got, _ := json.Marshal(schema)
+	want := `{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"object","properties":{` +
+		`"name":{"type":"string"},"age":{"type":"number"},"email":{"type":["string","null"],"format":"email"},` +
+		`"joined":{"type":"string","format":"date"},"tags":{"type":"array","items":{"type":"string"}},` +
+		`"address":{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]},` +
+		`"id":{"type":"string","format":"uuid"}},"required":["name","age","email","joined","tags"]}`
+	if string(got) != want {
+		t.Errorf("InferSchema() =\n%s\nwant\n%s", got, want)
+	}
+
+	compiled, err := CompileSchema(schema)
+	if err != nil {
+		t.Fatalf("CompileSchema(inferred) error = %v", err)
+	}
+	for _, sample := range []any{first, second} {
+		if errs := compiled.Validate(sample); len(errs) != 0 {
+			t.Errorf("sample does not match its inferred schema: %v", errs)
+		}
+	}
+}
@@ -0,0 +1,510 @@
+package polymorph
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxSchemaDepth bounds the $ref chains followed without moving into the
+// data, so that a schema that refers to itself cannot loop forever.
+const maxSchemaDepth = 256
+
+// Validate checks data, as returned by Parse, against the schema and
+// returns the values that do not match it, in the order they are found.
+// "format" is an annotation, as the draft specifies by default, and
+// patterns use Go's regexp syntax.
+func (s *Schema) Validate(data any) []SchemaError {
+	v := &validator{schema: s}
+	errs, _ := v.validate(s.root, data, "", "")
+	return errs
+}
+
+type validator struct {
+	schema *Schema
+	depth  int
+}
+
+// evaluated are the properties and items of a value that a schema has
+// applied subschemas to, for unevaluatedProperties and unevaluatedItems.
+type evaluated struct {
+	props    map[string]bool
+	items    map[int]bool
+	allItems bool
+}
+
+func (e *evaluated) prop(k string) {
+	if e.props == nil {
+		e.props = make(map[string]bool)
+	}
+	e.props[k] = true
+}
+
+func (e *evaluated) item(i int) {
+	if e.items == nil {
+		e.items = make(map[int]bool)
+	}
+	e.items[i] = true
+}
+
+func (e *evaluated) merge(o evaluated) {
+	for k := range o.props {
+		e.prop(k)
+	}
+	for i := range o.items {
+		e.item(i)
+	}
+	e.allItems = e.allItems || o.allItems
+}
+
+// validate checks value, at path in the data, against schema, at kw in the
+// schema. It returns the errors and, if there are none, what the schema
+// evaluated.
+func (v *validator) validate(schema, value any, path, kw string) ([]SchemaError, evaluated) {
+	var ev evaluated
+	if b, ok := schema.(bool); ok {
+		if !b {
+			return []SchemaError{{Path: path, KeywordPath: kw, Message: "no value is allowed here"}}, ev
+		}
+		return nil, ev
+	}
+	obj, ok := toObject(schema)
+	if !ok {
+		return nil, ev
+	}
+
+	var errs []SchemaError
+	fail := func(keyword, format string, args ...any) {
+		errs = append(errs, SchemaError{Path: path, KeywordPath: kw + "/" + keyword, Message: fmt.Sprintf(format, args...)})
+	}
+	// apply validates value against a subschema of the same value,
+	// keeping what it evaluated if it matches.
+	apply := func(sub any, keyword string) bool {
+		e, subEv := v.validate(sub, value, path, kw+"/"+keyword)
+		errs = append(errs, e...)
+		if len(e) == 0 {
+			ev.merge(subEv)
+		}
+		return len(e) == 0
+	}
+	// applyTo validates a property or item. A false schema reports that
+	// the value is not allowed.
+	applyTo := func(sub, child any, childPath, keyword, notAllowed string) {
+		if b, ok := sub.(bool); ok && !b {
+			errs = append(errs, SchemaError{Path: childPath, KeywordPath: kw + "/" + keyword, Message: notAllowed})
+			return
+		}
+		e, _ := v.validate(sub, child, childPath, kw+"/"+keyword)
+		errs = append(errs, e...)
+	}
+
+	for _, keyword := range []string{"$ref", "$dynamicRef"} {
+		targets := v.schema.refs
+		if keyword == "$dynamicRef" {
+			targets = v.schema.dynamicRefs
+		}
+		target, ok := targets[obj]
+		if !ok {
+			continue
+		}
+		if v.depth >= maxSchemaDepth {
+			fail(keyword, "the schema refers to itself more than %d times without reaching a value", maxSchemaDepth)
+			continue
+		}
+		v.depth++
+		apply(target, keyword)
+		v.depth--
+	}
+
+	if subs, ok := schemaList(obj, "allOf"); ok {
+		for i, sub := range subs {
+			apply(sub, "allOf/"+strconv.Itoa(i))
+		}
+	}
+	if subs, ok := schemaList(obj, "anyOf"); ok {
+		matched, best, reasons := v.alternatives(subs, value, path, kw+"/anyOf", &ev)
+		if len(matched) == 0 {
+			fail("anyOf", "does not match any schema in anyOf%s", reasons)
+			errs = append(errs, best...)
+		}
+	}
+	if subs, ok := schemaList(obj, "oneOf"); ok {
+		var oneEv evaluated
+		matched, best, reasons := v.alternatives(subs, value, path, kw+"/oneOf", &oneEv)
+		switch len(matched) {
+		case 0:
+			fail("oneOf", "does not match any schema in oneOf%s", reasons)
+			errs = append(errs, best...)
+		case 1:
+			ev.merge(oneEv)
+		default:
+			fail("oneOf", "matches schemas %d and %d of oneOf, want exactly one", matched[0], matched[1])
+		}
+	}
+	if sub, ok := obj.Get("not"); ok {
+		if e, _ := v.validate(sub, value, path, kw+"/not"); len(e) == 0 {
+			fail("not", "must not match the schema in not")
+		}
+	}
+	if cond, ok := obj.Get("if"); ok {
+		e, condEv := v.validate(cond, value, path, kw+"/if")
+		if len(e) == 0 {
+			ev.merge(condEv)
+			if sub, ok := obj.Get("then"); ok {
+				apply(sub, "then")
+			}
+		} else if sub, ok := obj.Get("else"); ok {
+			apply(sub, "else")
+		}
+	}
+
+	if t, ok := obj.Get("type"); ok {
+		names, _ := typeNames(t)
+		if !matchesAnyType(value, names) {
+			fail("type", "expected %s, got %s", strings.Join(names, " or "), describe(value))
+		}
+	}
+	if list, ok := schemaList(obj, "enum"); ok && !containsValue(list, value) {
+		texts := make([]string, len(list))
+		for i, item := range list {
+			texts[i] = schemaJSON(item)
+		}
+		fail("enum", "must be one of %s, got %s", strings.Join(texts, ", "), schemaJSON(value))
+	}
+	if c, ok := obj.Get("const"); ok && compare(c, value) != 0 {
+		fail("const", "must be %s, got %s", schemaJSON(c), schemaJSON(value))
+	}
+
+	if n, ok := queryNumber(value); ok {
+		v.validateNumber(obj, n, fail)
+	}
+	if s, ok := schemaString(value); ok {
+		v.validateString(obj, s, fail)
+	}
+	if items, ok := toSlice(value); ok {
+		v.validateArray(obj, items, path, kw, &ev, fail, applyTo)
+	}
+	if o, ok := toObject(value); ok {
+		v.validateObject(obj, o, path, kw, &ev, &errs, fail, apply, applyTo)
+	}
+	return errs, ev
+}
+
+// alternatives validates value against each of subs, returning the
+// indexes of those it matches. If there are none, it returns the errors
+// of the one that came closest, or, when none got past the value itself,
+// why each failed.
+func (v *validator) alternatives(subs []any, value any, path, kw string, ev *evaluated) ([]int, []SchemaError, string) {
+	var matched []int
+	var best []SchemaError
+	var reasons []string
+	bestDepth := -1
+	for i, sub := range subs {
+		e, subEv := v.validate(sub, value, path, kw+"/"+strconv.Itoa(i))
+		if len(e) == 0 {
+			matched = append(matched, i)
+			ev.merge(subEv)
+			continue
+		}
+		reasons = append(reasons, fmt.Sprintf("%d: %s", i, e[0].Message))
+		// The alternative that failed deepest in the data is the one the
+		// value was most likely meant to match.
+		depth := 0
+		for _, err := range e {
+			depth = max(depth, strings.Count(err.Path, "/"))
+		}
+		if depth > bestDepth || (depth == bestDepth && len(e) < len(best)) {
+			best, bestDepth = e, depth
+		}
+	}
+	if bestDepth == strings.Count(path, "/") {
+		return matched, nil, " (" + strings.Join(reasons, "; ") + ")"
+	}
+	return matched, best, ""
+}
+
+func (v *validator) validateNumber(obj *Object, n float64, fail func(string, string, ...any)) {
+	if m, ok := schemaNumber(obj, "multipleOf"); ok && m > 0 {
+		if q := n / m; math.Abs(q-math.Round(q)) > 1e-9 {
+			fail("multipleOf", "must be a multiple of %s, got %s", formatFloat(m), formatFloat(n))
+		}
+	}
+	if limit, ok := schemaNumber(obj, "minimum"); ok && n < limit {
+		fail("minimum", "must be >= %s, got %s", formatFloat(limit), formatFloat(n))
+	}
+	if limit, ok := schemaNumber(obj, "exclusiveMinimum"); ok && n <= limit {
+		fail("exclusiveMinimum", "must be > %s, got %s", formatFloat(limit), formatFloat(n))
+	}
+	if limit, ok := schemaNumber(obj, "maximum"); ok && n > limit {
+		fail("maximum", "must be <= %s, got %s", formatFloat(limit), formatFloat(n))
+	}
+	if limit, ok := schemaNumber(obj, "exclusiveMaximum"); ok && n >= limit {
+		fail("exclusiveMaximum", "must be < %s, got %s", formatFloat(limit), formatFloat(n))
+	}
+}
+
+func (v *validator) validateString(obj *Object, s string, fail func(string, string, ...any)) {
+	length := utf8.RuneCountInString(s)
+	if limit, ok := schemaCount(obj, "minLength"); ok && length < limit {
+		fail("minLength", "must be at least %s long, got %d", plural(limit, "character", "characters"), length)
+	}
+	if limit, ok := schemaCount(obj, "maxLength"); ok && length > limit {
+		fail("maxLength", "must be at most %s long, got %d", plural(limit, "character", "characters"), length)
+	}
+	if p, ok := obj.Get("pattern"); ok {
+		if re := v.schema.patterns[p.(string)]; !re.MatchString(s) {
+			fail("pattern", "must match pattern %q, got %q", re.String(), s)
+		}
+	}
+}
+
+func (v *validator) validateArray(obj *Object, items []any, path, kw string, ev *evaluated, fail func(string, string, ...any), applyTo func(any, any, string, string, string)) {
+	if limit, ok := schemaCount(obj, "minItems"); ok && len(items) < limit {
+		fail("minItems", "must have at least %s, got %d", plural(limit, "item", "items"), len(items))
+	}
+	if limit, ok := schemaCount(obj, "maxItems"); ok && len(items) > limit {
+		fail("maxItems", "must have at most %s, got %d", plural(limit, "item", "items"), len(items))
+	}
+	if unique, _ := obj.values["uniqueItems"].(bool); unique {
+	outer:
+		for i := range items {
+			for j := i + 1; j < len(items); j++ {
+				if compare(items[i], items[j]) == 0 {
+					fail("uniqueItems", "items %d and %d are equal", i, j)
+					break outer
+				}
+			}
+		}
+	}
+
+	prefix, _ := schemaList(obj, "prefixItems")
+	for i, sub := range prefix {
+		if i >= len(items) {
+			break
+		}
+		applyTo(sub, items[i], path+"/"+strconv.Itoa(i), "prefixItems/"+strconv.Itoa(i), fmt.Sprintf("item %d is not allowed", i))
+		ev.item(i)
+	}
+	if sub, ok := obj.Get("items"); ok {
+		for i := len(prefix); i < len(items); i++ {
+			applyTo(sub, items[i], path+"/"+strconv.Itoa(i), "items", fmt.Sprintf("item %d is not allowed: the array takes at most %s", i, plural(len(prefix), "item", "items")))
+		}
+		ev.allItems = true
+	}
+	if sub, ok := obj.Get("contains"); ok {
+		count := 0
+		for i, item := range items {
+			if e, _ := v.validate(sub, item, path+"/"+strconv.Itoa(i), kw+"/contains"); len(e) == 0 {
+				count++
+				ev.item(i)
+			}
+		}
+		least, ok := schemaCount(obj, "minContains")
+		if !ok {
+			least = 1
+		}
+		if count < least {
+			fail("contains", "must contain at least %s matching the contains schema, got %d", plural(least, "item", "items"), count)
+		}
+		if most, ok := schemaCount(obj, "maxContains"); ok && count > most {
+			fail("maxContains", "must contain at most %s matching the contains schema, got %d", plural(most, "item", "items"), count)
+		}
+	}
+	if sub, ok := obj.Get("unevaluatedItems"); ok && !ev.allItems {
+		for i := range items {
+			if !ev.items[i] {
+				applyTo(sub, items[i], path+"/"+strconv.Itoa(i), "unevaluatedItems", fmt.Sprintf("item %d is not allowed", i))
+			}
+		}
+		ev.allItems = true
+	}
+}
+
+func (v *validator) validateObject(obj, o *Object, path, kw string, ev *evaluated, errs *[]SchemaError, fail func(string, string, ...any), apply func(any, string) bool, applyTo func(any, any, string, string, string)) {
+	if limit, ok := schemaCount(obj, "minProperties"); ok && o.Len() < limit {
+		fail("minProperties", "must have at least %s, got %d", plural(limit, "property", "properties"), o.Len())
+	}
+	if limit, ok := schemaCount(obj, "maxProperties"); ok && o.Len() > limit {
+		fail("maxProperties", "must have at most %s, got %d", plural(limit, "property", "properties"), o.Len())
+	}
+	if required, ok := obj.Get("required"); ok {
+		names, _ := stringList(required)
+		for _, name := range names {
+			if !o.has(name) {
+				fail("required", "missing required property %q", name)
+			}
+		}
+	}
+	if deps, ok := toObject(obj.values["dependentRequired"]); ok {
+		for _, name := range deps.Keys() {
+			if !o.has(name) {
+				continue
+			}
+			required, _ := stringList(deps.values[name])
+			for _, dep := range required {
+				if !o.has(dep) {
+					fail("dependentRequired/"+escapePointer(name), "property %q is required when %q is set", dep, name)
+				}
+			}
+		}
+	}
+	if deps, ok := toObject(obj.values["dependentSchemas"]); ok {
+		for _, name := range deps.Keys() {
+			if o.has(name) {
+				apply(deps.values[name], "dependentSchemas/"+escapePointer(name))
+			}
+		}
+	}
+
+	props, _ := toObject(obj.values["properties"])
+	patterns, _ := toObject(obj.values["patternProperties"])
+	additional, hasAdditional := obj.Get("additionalProperties")
+	for _, k := range o.Keys() {
+		childPath := path + "/" + escapePointer(k)
+		notAllowed := fmt.Sprintf("property %q is not allowed", k)
+		matched := false
+		if props != nil {
+			if sub, ok := props.Get(k); ok {
+				applyTo(sub, o.values[k], childPath, "properties/"+escapePointer(k), notAllowed)
+				matched = true
+			}
+		}
+		if patterns != nil {
+			for _, p := range patterns.Keys() {
+				if v.schema.patterns[p].MatchString(k) {
+					applyTo(patterns.values[p], o.values[k], childPath, "patternProperties/"+escapePointer(p), notAllowed)
+					matched = true
+				}
+			}
+		}
+		if !matched && hasAdditional {
+			applyTo(additional, o.values[k], childPath, "additionalProperties", notAllowed)
+			matched = true
+		}
+		if matched {
+			ev.prop(k)
+		}
+	}
+
+	if sub, ok := obj.Get("propertyNames"); ok {
+		for _, k := range o.Keys() {
+			e, _ := v.validate(sub, k, path+"/"+escapePointer(k), kw+"/propertyNames")
+			for _, err := range e {
+				err.Message = fmt.Sprintf("property name %q: %s", k, err.Message)
+				*errs = append(*errs, err)
+			}
+		}
+	}
+	if sub, ok := obj.Get("unevaluatedProperties"); ok {
+		for _, k := range o.Keys() {
+			if !ev.props[k] {
+				applyTo(sub, o.values[k], path+"/"+escapePointer(k), "unevaluatedProperties", fmt.Sprintf("property %q is not allowed", k))
+				ev.prop(k)
+			}
+		}
+	}
+}
+
+// matchesAnyType reports whether v is of one of the schema types names.
+func matchesAnyType(v any, names []string) bool {
+	for _, name := range names {
+		switch name {
+		case "null":
+			if v == nil {
+				return true
+			}
+		case "boolean":
+			if _, ok := v.(bool); ok {
+				return true
+			}
+		case "object":
+			if isObject(v) {
+				return true
+			}
+		case "array":
+			if isArray(v) {
+				return true
+			}
+		case "string":
+			if _, ok := schemaString(v); ok {
+				return true
+			}
+		case "number":
+			if _, ok := queryNumber(v); ok {
+				return true
+			}
+		case "integer":
+			if isInteger(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isInteger reports whether v is a number with no fraction, as JSON
+// Schema counts 1.0 as an integer.
+func isInteger(v any) bool {
+	n, ok := toNumber(v)
+	if !ok {
+		return false
+	}
+	if f, ok := n.(float64); ok {
+		return f == math.Trunc(f) && !math.IsInf(f, 0)
+	}
+	return true
+}
+
+// schemaString returns a string, or a time as the text it was read from.
+func schemaString(v any) (string, bool) {
+	if t, ok := v.(time.Time); ok {
+		if s, ok := localTimeString(t); ok {
+			return s, true
+		}
+	}
+	return queryString(v)
+}
+
+func schemaList(obj *Object, keyword string) ([]any, bool) {
+	v, ok := obj.Get(keyword)
+	if !ok {
+		return nil, false
+	}
+	return toSlice(v)
+}
+
+func schemaNumber(obj *Object, keyword string) (float64, bool) {
+	v, ok := obj.Get(keyword)
+	if !ok {
+		return 0, false
+	}
+	return queryNumber(v)
+}
+
+func schemaCount(obj *Object, keyword string) (int, bool) {
+	n, ok := schemaNumber(obj, keyword)
+	return int(n), ok
+}
+
+// schemaJSON writes a value for an error message.
+func schemaJSON(v any) string {
+	if s, ok := schemaString(v); ok {
+		return strconv.Quote(s)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return strconv.Itoa(n) + " " + many
+}
@@ -0,0 +1,380 @@
+package polymorph
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// recordFormats are the formats made of one record per row or line, which
+// convert as a stream.
+var recordFormats = map[string]rune{
+	"csv":   ',',
+	"tsv":   '\t',
+	"jsonl": 0,
+}
+
+// record is one row, with its fields in the order they were read.
+type record struct {
+	keys   []string
+	values []any
+}
+
+type recordReader interface {
+	// Read returns the next record, or io.EOF after the last one.
+	Read() (*record, error)
+}
+
+type recordWriter interface {
+	Write(rec *record) error
+	Flush() error
+}
+
+// Streamable reports whether converting from one format to the other can
+// be done record by record, without holding the whole input in memory.
+func Streamable(from, to string) bool {
+	_, fromOK := recordFormats[normalizeFormat(from)]
+	_, toOK := recordFormats[normalizeFormat(to)]
+	return fromOK && toOK
+}
+
+// ConvertStream converts the records read from r and writes them to w one
+// at a time, so memory stays constant whatever the input size. Both formats
+// must be Streamable. It returns the number of records written.
+//
+// The CSV and TSV header of JSON Lines read from a file, or any r that can
+// seek, is every field of every record, found by reading r once before
+// converting it. Otherwise it is taken from the first record, and a later
+// record with a field that is not in it is an error rather than being
+// dropped. Nested values are flattened into dotted columns such as
+// "address.city", as they are when not streaming. A query needs the whole
+// input, so opts.Query must be empty.
+func ConvertStream(r io.Reader, w io.Writer, from, to string, opts Options) (int, error) {
+	from, to = normalizeFormat(from), normalizeFormat(to)
+	if !Streamable(from, to) {
+		return 0, fmt.Errorf("cannot stream %s to %s: both must be one of csv, tsv, jsonl", from, to)
+	}
+	if opts.Query != "" {
+		return 0, errors.New("cannot stream a query")
+	}
+
+	reader := newRecordReader(r, from, true, opts.InferTypes)
+	writer := newRecordWriter(w, to)
+	if delimited, ok := writer.(*delimitedWriter); ok && from == "jsonl" {
+		if err := scanHeader(r, delimited); err != nil {
+			return 0, err
+		}
+	}
+	n := 0
+	for {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return n, fmt.Errorf("parse error: record %d: %w", n+1, err)
+		}
+		if err := writer.Write(rec); err != nil {
+			return n, fmt.Errorf("record %d: %w", n+1, err)
+		}
+		n++
+	}
+	return n, writer.Flush()
+}
+
+// scanHeader starts d with the flattened fields of all the JSON Lines
+// records in r, in the order they first appear, then seeks r back to where it was. It
+// does nothing if r cannot seek.
+func scanHeader(r io.Reader, d *delimitedWriter) error {
+	seeker, ok := r.(io.Seeker)
+	if !ok {
+		return nil
+	}
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil
+	}
+
+	reader := newRecordReader(r, "jsonl", true, false)
+	var header []string
+	seen := make(map[string]bool)
+	for n := 1; ; n++ {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("parse error: record %d: %w", n, err)
+		}
+		for _, k := range rec.flat().keys {
+			if !seen[k] {
+				seen[k] = true
+				header = append(header, k)
+			}
+		}
+	}
+	if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	if len(header) == 0 {
+		return nil
+	}
+	return d.start(header)
+}
+
+// parseRecords reads a whole record-oriented input into a slice of
+// objects, for conversions to document formats. With infer, CSV and TSV
+// columns are typed as in parseCSV.
+func parseRecords(input []byte, format string, infer bool) (any, error) {
+	reader := newRecordReader(bytes.NewReader(input), format, false, false)
+	result := []any{}
+	for {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			if infer && format != "jsonl" {
+				return inferRecords(result), nil
+			}
+			return result, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", len(result)+1, err)
+		}
+		result = append(result, rec.object())
+	}
+}
+
+// newRecordReader reads records of format from r. With exactNumbers, JSON
+// numbers are kept as written instead of becoming int64 or float64; with infer, CSV
+// and TSV cells are read with inferValue one at a time, as there are no
+// whole columns to look at, and dotted headers make nested objects.
+func newRecordReader(r io.Reader, format string, exactNumbers, infer bool) recordReader {
+	if format == "jsonl" {
+		dec := json.NewDecoder(bufio.NewReader(r))
+		dec.UseNumber()
+		return &jsonLinesReader{dec: dec, exactNumbers: exactNumbers}
+	}
+	reader := csv.NewReader(bufio.NewReader(r))
+	reader.Comma = recordFormats[format]
+	reader.ReuseRecord = true
+	if format == "tsv" {
+		reader.LazyQuotes = true
+	}
+	return &delimitedReader{reader: reader, infer: infer}
+}
+
+func newRecordWriter(w io.Writer, format string) recordWriter {
+	buf := bufio.NewWriter(w)
+	if format == "jsonl" {
+		return &jsonLinesWriter{w: buf}
+	}
+	writer := csv.NewWriter(buf)
+	writer.Comma = recordFormats[format]
+	return &delimitedWriter{writer: writer, buf: buf}
+}
+
+// delimitedReader reads CSV or TSV rows, named by the header row.
+type delimitedReader struct {
+	reader *csv.Reader
+	header []string
+	infer  bool
+}
+
+func (d *delimitedReader) Read() (*record, error) {
+	if d.header == nil {
+		header, err := d.reader.Read()
+		if err != nil {
+			return nil, err
+		}
+		d.header = append([]string(nil), header...)
+	}
+	row, err := d.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	rec := &record{keys: d.header, values: make([]any, 0, len(row))}
+	for i, val := range row {
+		if i >= len(d.header) {
+			break
+		}
+		if d.infer {
+			rec.values = append(rec.values, inferValue(val))
+		} else {
+			rec.values = append(rec.values, val)
+		}
+	}
+	rec.keys = rec.keys[:len(rec.values)]
+	if d.infer {
+		return nestRecord(rec), nil
+	}
+	return rec, nil
+}
+
+// object returns the record as an Object.
+func (r *record) object() *Object {
+	obj := NewObject()
+	for i, k := range r.keys {
+		obj.Set(k, r.values[i])
+	}
+	return obj
+}
+
+// flat returns the record with nested objects and arrays flattened into
+// dotted keys with flattenDotted, as renderDelimited writes them.
+func (r *record) flat() *record {
+	flat := NewObject()
+	for i, k := range r.keys {
+		flattenDotted(k, r.values[i], flat)
+	}
+	result := &record{keys: flat.Keys()}
+	for _, k := range result.keys {
+		result.values = append(result.values, flat.values[k])
+	}
+	return result
+}
+
+// nestRecord rebuilds the nested objects of dotted keys with nestDotted.
+func nestRecord(rec *record) *record {
+	nested := nestDotted(rec.object())
+	result := &record{keys: nested.Keys()}
+	for _, k := range result.keys {
+		result.values = append(result.values, nested.values[k])
+	}
+	return result
+}
+
+// jsonLinesReader reads one JSON object after another, keeping the order
+// of their keys.
+type jsonLinesReader struct {
+	dec          *json.Decoder
+	exactNumbers bool
+}
+
+func (j *jsonLinesReader) Read() (*record, error) {
+	tok, err := j.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("expected a JSON object, got %v", tok)
+	}
+
+	rec, err := j.readFields()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return rec, err
+}
+
+func (j *jsonLinesReader) readFields() (*record, error) {
+	rec := &record{}
+	for j.dec.More() {
+		tok, err := j.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		value, err := decodeJSON(j.dec, j.exactNumbers)
+		if err != nil {
+			return nil, err
+		}
+		rec.keys = append(rec.keys, tok.(string))
+		rec.values = append(rec.values, value)
+	}
+	if _, err := j.dec.Token(); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// delimitedWriter writes CSV or TSV rows, flattened, under the header of
+// the first record.
+type delimitedWriter struct {
+	writer  *csv.Writer
+	buf     *bufio.Writer
+	header  []string
+	columns map[string]int
+	row     []string
+}
+
+// start writes the header that the rows follow.
+func (d *delimitedWriter) start(header []string) error {
+	d.header = append([]string(nil), header...)
+	d.columns = make(map[string]int, len(d.header))
+	for i, k := range d.header {
+		d.columns[k] = i
+	}
+	d.row = make([]string, len(d.header))
+	return d.writer.Write(d.header)
+}
+
+func (d *delimitedWriter) Write(rec *record) error {
+	rec = rec.flat()
+	if d.header == nil {
+		if len(rec.keys) == 0 {
+			return errors.New("the first record has no fields to use as the header")
+		}
+		if err := d.start(rec.keys); err != nil {
+			return err
+		}
+	}
+
+	clear(d.row)
+	for i, k := range rec.keys {
+		col, ok := d.columns[k]
+		if !ok {
+			return fmt.Errorf("field %q is not in the header (%s)", k, strings.Join(d.header, ", "))
+		}
+		d.row[col] = cell(rec.values[i])
+	}
+	return d.writer.Write(d.row)
+}
+
+func (d *delimitedWriter) Flush() error {
+	d.writer.Flush()
+	if err := d.writer.Error(); err != nil {
+		return err
+	}
+	return d.buf.Flush()
+}
+
+// jsonLinesWriter writes each record as a JSON object on its own line.
+type jsonLinesWriter struct {
+	w *bufio.Writer
+}
+
+func (j *jsonLinesWriter) Write(rec *record) error {
+	j.w.WriteByte('{')
+	for i, k := range rec.keys {
+		if i > 0 {
+			j.w.WriteByte(',')
+		}
+		key, _ := json.Marshal(k)
+		value, err := json.Marshal(localTimesToStrings(rec.values[i]))
+		if err != nil {
+			return err
+		}
+		j.w.Write(key)
+		j.w.WriteByte(':')
+		j.w.Write(value)
+	}
+	j.w.WriteString("}\n")
+	return nil
+}
+
+func (j *jsonLinesWriter) Flush() error {
+	return j.w.Flush()
+}
+
+// cell renders a value for a CSV or TSV field. Nested values left after
+// flattening, empty objects and arrays, are written as JSON.
+func cell(v any) string {
+	if s, ok := formatScalar(v); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,220 @@
+package polymorph
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStreamable(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{"csv", "jsonl", true},
+		{"ndjson", "tsv", true},
+		{"TSV", "csv", true},
+		{"csv", "json", false},
+		{"yaml", "jsonl", false},
+	}
+	for _, tt := range tests {
+		if got := Streamable(tt.from, tt.to); got != tt.want {
+			t.Errorf("Streamable(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestConvertStream(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		input    string
+		want     string
+		records  int
+	}{
+		{
+			name:    "csv to jsonl keeps column order",
+			from:    "csv",
+			to:      "jsonl",
+			input:   "name,age,note\nAlice,30,\"says \"\"hi\"\"\"\nBob,25,\n",
+			want:    "{\"name\":\"Alice\",\"age\":\"30\",\"note\":\"says \\\"hi\\\"\"}\n{\"name\":\"Bob\",\"age\":\"25\",\"note\":\"\"}\n",
+			records: 2,
+		},
+		{
+			name:    "jsonl to csv",
+			from:    "ndjson",
+			to:      "csv",
+			input:   "{\"id\": 1, \"tags\": [\"a\", \"b\"], \"ratio\": 1.50}\n\n{\"ratio\": 1e6, \"id\": 2}\n",
+			want:    "id,tags.0,tags.1,ratio\n1,a,b,1.50\n2,,,1e6\n",
+			records: 2,
+		},
+		{
+			name:    "jsonl to csv header has every field",
+			from:    "jsonl",
+			to:      "csv",
+			input:   "{\"a\": 1}\n{\"b\": 2, \"a\": 3}\n{\"c\": 4}\n",
+			want:    "a,b,c\n1,,\n3,2,\n,,4\n",
+			records: 3,
+		},
+		{
+			name:    "tsv to csv",
+			from:    "tsv",
+			to:      "csv",
+			input:   "a\tb\n1,5\tx\n",
+			want:    "a,b\n\"1,5\",x\n",
+			records: 1,
+		},
+		{
+			name:    "jsonl to jsonl keeps numbers as written",
+			from:    "jsonl",
+			to:      "jsonl",
+			input:   "{\"big\":12345678901234567890,\"n\":null}",
+			want:    "{\"big\":12345678901234567890,\"n\":null}\n",
+			records: 1,
+		},
+		{
+			name:    "header only",
+			from:    "csv",
+			to:      "jsonl",
+			input:   "a,b\n",
+			want:    "",
+			records: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			n, err := ConvertStream(strings.NewReader(tt.input), &out, tt.from, tt.to, Options{})
+			if err != nil {
+				t.Fatalf("ConvertStream() error = %v", err)
+			}
+			if n != tt.records {
+				t.Errorf("ConvertStream() = %d records, want %d", n, tt.records)
+			}
+			if out.String() != tt.want {
+				t.Errorf("ConvertStream() output =\n%q\nwant\n%q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertStreamErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		input    string
+		want     string
+	}{
+		{"field missing from header of a pipe", "jsonl", "csv", "{\"a\":1}\n{\"a\":2,\"b\":3}\n", `record 2: field "b" is not in the header (a)`},
+		{"not an object", "jsonl", "csv", "[1,2]\n", "record 1: expected a JSON object"},
+		{"invalid json", "jsonl", "tsv", "{\"a\":1}\n{\"a\":\n", "parse error: record 2"},
+		{"ragged csv", "csv", "jsonl", "a,b\n1,2\n3\n", "parse error: record 2"},
+		{"document format", "json", "csv", "[]", "cannot stream json to csv"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A pipe cannot be read twice to find the header.
+			pipe := io.MultiReader(strings.NewReader(tt.input))
+			_, err := ConvertStream(pipe, io.Discard, tt.from, tt.to, Options{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ConvertStream() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestConvertStreamLarge checks that records are written as they are read,
+// not collected first.
+func TestConvertStreamLarge(t *testing.T) {
+	const rows = 100000
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte("id,value\n"))
+		for i := 0; i < rows; i++ {
+			pw.Write([]byte("1,abcdefghijklmnopqrstuvwxyz\n"))
+		}
+		pw.Close()
+	}()
+
+	var count countingWriter
+	n, err := ConvertStream(pr, &count, "csv", "jsonl", Options{})
+	if err != nil {
+		t.Fatalf("ConvertStream() error = %v", err)
+	}
+	if n != rows || count.lines != rows {
+		t.Errorf("ConvertStream() = %d records, %d lines, want %d", n, count.lines, rows)
+	}
+}
+
+type countingWriter struct {
+	lines int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.lines += bytes.Count(p, []byte("\n"))
+	return len(p), nil
+}
+
+func TestConvert_RecordFormats(t *testing.T) {
+	output, err := Convert([]byte("{\"name\":\"Alice\",\"age\":30}\n{\"name\":\"Bob\",\"age\":25}\n"), "jsonl", "json")
+	if err != nil {
+		t.Fatalf("Convert(jsonl, json) error = %v", err)
+	}
+	var result []map[string]any
+	if err := json.Unmarshal(output, &result); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	if len(result) != 2 || result[1]["name"] != "Bob" || result[1]["age"] != float64(25) {
+		t.Errorf("Convert(jsonl, json) = %v", result)
+	}
+
+	output, err = Convert([]byte(`[{"b": 2, "a": "x y"}, {"a": "z", "b": 3}]`), "json", "tsv")
+	if err != nil {
+		t.Fatalf("Convert(json, tsv) error = %v", err)
+	}
+	if want := "b\ta\n2\tx y\n3\tz\n"; string(output) != want {
+		t.Errorf("Convert(json, tsv) = %q, want %q", output, want)
+	}
+
+	output, err = Convert([]byte(`[{"b": 2, "a": 1}, "x"]`), "json", "jsonl")
+	if err != nil {
+		t.Fatalf("Convert(json, jsonl) error = %v", err)
+	}
+	if want := "{\"b\":2,\"a\":1}\n\"x\"\n"; string(output) != want {
+		t.Errorf("Convert(json, jsonl) = %q, want %q", output, want)
+	}
+}
+
+func TestConvertStreamHeaderFromOffset(t *testing.T) {
+	input := strings.NewReader("skipped\n{\"a\":1}\n{\"b\":2}\n")
+	input.Seek(8, io.SeekStart)
+
+	var out bytes.Buffer
+	if _, err := ConvertStream(input, &out, "jsonl", "tsv", Options{}); err != nil {
+		t.Fatalf("ConvertStream() error = %v", err)
+	}
+	if want := "a\tb\n1\t\n\t2\n"; out.String() != want {
+		t.Errorf("ConvertStream() = %q, want %q", out.String(), want)
+	}
+}
+
+func TestConvertStreamMatchesConvert(t *testing.T) {
+	input := "{\"id\": 1, \"address\": {\"city\": \"Rome\", \"zip\": \"00100\"}, \"tags\": [\"a\"]}\n" +
+		"{\"id\": 2, \"tags\": [\"b\", \"c\"], \"meta\": {}}\n" +
+		"{\"id\": 3, \"address\": {\"city\": \"Milan\"}, \"note\": \"x, y\"}\n"
+	for _, to := range []string{"csv", "tsv"} {
+		whole, err := Convert([]byte(input), "jsonl", to)
+		if err != nil {
+			t.Fatalf("Convert(jsonl, %s) error = %v", to, err)
+		}
+		var streamed bytes.Buffer
+		if _, err := ConvertStream(strings.NewReader(input), &streamed, "jsonl", to, Options{}); err != nil {
+			t.Fatalf("ConvertStream(jsonl, %s) error = %v", to, err)
+		}
+		if streamed.String() != string(whole) {
+			t.Errorf("ConvertStream(jsonl, %s) =\n%s\nConvert() =\n%s", to, streamed.String(), whole)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package polymorph
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTextproto reads the Protobuf text format without a schema. Fields
+// become keys and messages nested objects; a repeated field, or one written
+// with list syntax, becomes an array. Enum values are kept as strings.
+func parseTextproto(input []byte) (any, error) {
+	p := &textprotoParser{src: string(input)}
+	msg, err := p.message(0)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", strings.Count(p.src[:p.pos], "\n")+1, err)
+	}
+	return msg, nil
+}
+
+type textprotoParser struct {
+	src string
+	pos int
+}
+
+func (p *textprotoParser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *textprotoParser) skip() {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			p.pos++
+		case c == '#':
+			end := strings.IndexByte(p.src[p.pos:], '\n')
+			if end < 0 {
+				p.pos = len(p.src)
+				return
+			}
+			p.pos += end
+		default:
+			return
+		}
+	}
+}
+
+// token reads a field name, enum value, number or bool.
+func (p *textprotoParser) token() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if !isAlnum(c) && !strings.ContainsRune("_.-+[]/", rune(c)) {
+			break
+		}
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// message reads fields up to end, or to the end of the input when end is
+// 0.
+func (p *textprotoParser) message(end byte) (*Object, error) {
+	result := NewObject()
+	for {
+		p.skip()
+		if p.pos >= len(p.src) {
+			if end != 0 {
+				return nil, fmt.Errorf("missing closing %q", end)
+			}
+			return result, nil
+		}
+		if p.peek() == end {
+			p.pos++
+			return result, nil
+		}
+
+		name := p.token()
+		if name == "" {
+			return nil, fmt.Errorf("unexpected %q", p.peek())
+		}
+		p.skip()
+		colon := p.peek() == ':'
+		if colon {
+			p.pos++
+			p.skip()
+		}
+
+		var value any
+		var err error
+		list := p.peek() == '['
+		if list {
+			value, err = p.list()
+		} else {
+			value, err = p.value(colon)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		existing, ok := result.Get(name)
+		if !ok {
+			result.Set(name, value)
+		} else {
+			items, repeated := existing.([]any)
+			if !repeated {
+				items = []any{existing}
+			}
+			if list {
+				items = append(items, value.([]any)...)
+			} else {
+				items = append(items, value)
+			}
+			result.Set(name, items)
+		}
+
+		p.skip()
+		if c := p.peek(); c == ',' || c == ';' {
+			p.pos++
+		}
+	}
+}
+
+// value reads a scalar, or a message in braces or angle brackets, which
+// may be written without a colon.
+func (p *textprotoParser) value(colon bool) (any, error) {
+	switch c := p.peek(); {
+	case c == '{':
+		p.pos++
+		return p.message('}')
+	case c == '<':
+		p.pos++
+		return p.message('>')
+	case !colon:
+		return nil, fmt.Errorf("expected : or {")
+	case c == '"' || c == '\'':
+		var b strings.Builder
+		for p.peek() == '"' || p.peek() == '\'' {
+			s, err := p.quoted()
+			if err != nil {
+				return nil, err
+			}
+			b.WriteString(s)
+			p.skip()
+		}
+		return b.String(), nil
+	}
+
+	tok := p.token()
+	switch tok {
+	case "":
+		return nil, fmt.Errorf("missing value")
+	case "true", "True", "t":
+		return true, nil
+	case "false", "False", "f":
+		return false, nil
+	}
+	if n, err := strconv.ParseInt(tok, 0, 64); err == nil {
+		return n, nil
+	}
+	if n, err := strconv.ParseUint(tok, 0, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(strings.TrimRight(tok, "fF"), 64); err == nil && isAlnum(tok[len(tok)-1]) {
+		return f, nil
+	}
+	return tok, nil
+}
+
+func (p *textprotoParser) list() ([]any, error) {
+	p.pos++
+	result := []any{}
+	for {
+		p.skip()
+		if p.peek() == ']' {
+			p.pos++
+			return result, nil
+		}
+		v, err := p.value(true)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v)
+		p.skip()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			return nil, fmt.Errorf("expected , or ] in list")
+		}
+	}
+}
+
+// quoted reads a single- or double-quoted string with C-style escapes.
+func (p *textprotoParser) quoted() (string, error) {
+	quote := p.src[p.pos]
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case '\\':
+			p.pos += 2
+			continue
+		case '\n':
+			return "", fmt.Errorf("unterminated string")
+		case quote:
+			p.pos++
+			raw := p.src[start+1 : p.pos-1]
+			if quote == '\'' {
+				raw = strings.ReplaceAll(strings.ReplaceAll(raw, `\'`, `'`), `"`, `\"`)
+			}
+			s, err := strconv.Unquote(`"` + raw + `"`)
+			if err != nil {
+				return "", fmt.Errorf("invalid string %s", p.src[start:p.pos])
+			}
+			return s, nil
+		}
+		p.pos++
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+// renderTextproto writes an object in the Protobuf text format, fields in
+// order. Arrays use list syntax and null fields are left out.
+func renderTextproto(data any) ([]byte, error) {
+	obj, ok := toObject(data)
+	if !ok {
+		return nil, fmt.Errorf("Protobuf text output requires an object")
+	}
+	var buf bytes.Buffer
+	if err := writeTextprotoMessage(&buf, obj, ""); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeTextprotoMessage(buf *bytes.Buffer, obj *Object, indent string) error {
+	for _, k := range obj.Keys() {
+		if !hclIdentPattern.MatchString(k) || strings.Contains(k, "-") {
+			return fmt.Errorf("Protobuf field name %q is not an identifier", k)
+		}
+		v := obj.values[k]
+		if v == nil {
+			continue
+		}
+		if m, ok := toObject(v); ok {
+			fmt.Fprintf(buf, "%s%s {\n", indent, k)
+			if err := writeTextprotoMessage(buf, m, indent+"  "); err != nil {
+				return err
+			}
+			fmt.Fprintf(buf, "%s}\n", indent)
+			continue
+		}
+		if items, ok := toSlice(v); ok {
+			if err := writeTextprotoList(buf, k, items, indent); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Fprintf(buf, "%s%s: %s\n", indent, k, textprotoScalar(v))
+	}
+	return nil
+}
+
+func writeTextprotoList(buf *bytes.Buffer, name string, items []any, indent string) error {
+	fmt.Fprintf(buf, "%s%s: [", indent, name)
+	for i, item := range items {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		if m, ok := toObject(item); ok {
+			buf.WriteString("{\n")
+			if err := writeTextprotoMessage(buf, m, indent+"  "); err != nil {
+				return err
+			}
+			buf.WriteString(indent + "}")
+			continue
+		}
+		if _, ok := toSlice(item); ok || item == nil {
+			return fmt.Errorf("Protobuf text cannot represent nested arrays or null in arrays (%s)", name)
+		}
+		buf.WriteString(textprotoScalar(item))
+	}
+	buf.WriteString("]\n")
+	return nil
+}
+
+func textprotoScalar(v any) string {
+	switch x := v.(type) {
+	case string:
+		return strconv.Quote(x)
+	case bool:
+		return strconv.FormatBool(x)
+	}
+	s, _ := formatScalar(v)
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return s
+	}
+	return strconv.Quote(s)
+}
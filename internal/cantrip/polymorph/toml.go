@@ -2,23 +2,482 @@ package polymorph
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
 
+// parseTOML reads a document, keeping keys in the order they are written
+// and the comments around them.
 func parseTOML(input []byte) (any, error) {
-	var data any
-	if err := toml.Unmarshal(input, &data); err != nil {
+	var data map[string]any
+	md, err := toml.Decode(string(input), &data)
+	if err != nil {
 		return nil, err
 	}
-	return data, nil
+	order := make(map[string]int)
+	for i, key := range md.Keys() {
+		path := strings.Join(key, "\x00")
+		if _, ok := order[path]; !ok {
+			order[path] = i
+		}
+	}
+	obj := orderTOML(data, "", order).(*Object)
+	tomlComments(input, obj)
+	return obj, nil
+}
+
+// orderTOML turns the maps of a decoded document into Objects, with keys
+// ordered by their first position in the document. path is the key of v,
+// its parts joined by NUL as in order.
+func orderTOML(v any, path string, order map[string]int) any {
+	switch x := v.(type) {
+	case map[string]any:
+		keys := sortedKeys(x)
+		position := func(k string) int {
+			if i, ok := order[joinTOMLPath(path, k)]; ok {
+				return i
+			}
+			return math.MaxInt
+		}
+		slices.SortStableFunc(keys, func(a, b string) int { return position(a) - position(b) })
+		obj := NewObject()
+		for _, k := range keys {
+			obj.Set(k, orderTOML(x[k], joinTOMLPath(path, k), order))
+		}
+		return obj
+	case []map[string]any:
+		list := make([]any, len(x))
+		for i, m := range x {
+			list[i] = orderTOML(m, path, order)
+		}
+		return list
+	case []any:
+		for i, item := range x {
+			x[i] = orderTOML(item, path, order)
+		}
+	}
+	return v
+}
+
+func joinTOMLPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "\x00" + key
+}
+
+// tomlComments attaches the comments of a document to the keys and tables
+// of root: comment lines to the key or table header after them, and a
+// comment at the end of a line to the key or header on it. Comments at the
+// top of the document, set apart by a blank line, belong to the document.
+// Comments inside multi-line values are dropped.
+func tomlComments(input []byte, root *Object) {
+	table := root
+	var pending []string
+	seenKey := false
+	inString := ""
+	tables := make(map[string]int)
+
+	for _, line := range strings.Split(string(input), "\n") {
+		line = strings.TrimSpace(line)
+		if inString != "" {
+			if strings.Count(line, inString)%2 == 1 {
+				inString = ""
+			}
+			continue
+		}
+
+		switch {
+		case line == "":
+			if !seenKey && len(pending) > 0 {
+				root.setComment("", comment{head: strings.Join(pending, "\n")})
+				pending = nil
+			}
+			continue
+		case line[0] == '#':
+			pending = append(pending, commentText(line))
+			continue
+		}
+		seenKey = true
+		head := strings.Join(pending, "\n")
+		pending = nil
+
+		code, trailing := splitTOMLComment(line)
+		if strings.HasPrefix(code, "[") {
+			array := strings.HasPrefix(code, "[[")
+			path := parseTOMLKey(strings.Trim(code, "[] \t"))
+			parent, obj := findTOMLTable(root, path, array, tables)
+			if parent != nil {
+				if !array || tables[strings.Join(path, "\x00")] == 1 {
+					parent.setComment(path[len(path)-1], comment{head: head, line: trailing})
+				}
+			}
+			if obj != nil {
+				table = obj
+			} else {
+				table = NewObject()
+			}
+			continue
+		}
+
+		keyText, value, found := strings.Cut(code, "=")
+		if !found {
+			continue
+		}
+		for _, delim := range []string{`"""`, "'''"} {
+			if strings.Count(value, delim)%2 == 1 {
+				inString = delim
+			}
+		}
+		path := parseTOMLKey(keyText)
+		obj := table
+		for _, name := range path[:len(path)-1] {
+			next, ok := obj.values[name].(*Object)
+			if !ok {
+				obj = nil
+				break
+			}
+			obj = next
+		}
+		if obj != nil && obj.has(path[len(path)-1]) {
+			obj.setComment(path[len(path)-1], comment{head: head, line: trailing})
+		}
+	}
+}
+
+// splitTOMLComment splits a line into its code and the text of a comment
+// at its end, skipping "#" inside strings.
+func splitTOMLComment(line string) (string, string) {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return strings.TrimSpace(line[:i]), commentText(line[i:])
+		}
+	}
+	return line, ""
+}
+
+// parseTOMLKey splits a dotted key into its parts, unquoting quoted ones.
+func parseTOMLKey(s string) []string {
+	var parts []string
+	s = strings.TrimSpace(s)
+	for s != "" {
+		var part string
+		if s[0] == '"' || s[0] == '\'' {
+			end := strings.IndexByte(s[1:], s[0])
+			if end < 0 {
+				return append(parts, s)
+			}
+			part = s[1 : end+1]
+			if s[0] == '"' {
+				if unquoted, err := strconv.Unquote(s[:end+2]); err == nil {
+					part = unquoted
+				}
+			}
+			s = s[end+2:]
+		} else {
+			end := strings.IndexByte(s, '.')
+			if end < 0 {
+				end = len(s)
+			}
+			part = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		parts = append(parts, part)
+		s = strings.TrimSpace(s)
+		s = strings.TrimSpace(strings.TrimPrefix(s, "."))
+	}
+	return parts
+}
+
+// findTOMLTable returns the table a header names and the object holding
+// it. Arrays of tables are followed to their last element so far; tables
+// counts the elements seen of each one.
+func findTOMLTable(root *Object, path []string, array bool, tables map[string]int) (*Object, *Object) {
+	if len(path) == 0 {
+		return nil, nil
+	}
+	if array {
+		tables[strings.Join(path, "\x00")]++
+	}
+	parent := root
+	for i, name := range path {
+		v := parent.values[name]
+		if list, ok := v.([]any); ok {
+			n := len(list)
+			if count, seen := tables[strings.Join(path[:i+1], "\x00")]; seen {
+				n = count
+			}
+			if n < 1 || n > len(list) {
+				return nil, nil
+			}
+			v = list[n-1]
+		}
+		obj, ok := v.(*Object)
+		if !ok {
+			return nil, nil
+		}
+		if i == len(path)-1 {
+			return parent, obj
+		}
+		parent = obj
+	}
+	return nil, nil
 }
 
+var tomlBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// renderTOML writes an object as TOML in the order of its keys, with its
+// comments. Within each table, plain keys come first, then sub-tables and
+// arrays of tables; null values are left out, as TOML has no null.
 func renderTOML(data any) ([]byte, error) {
+	obj, ok := toObject(data)
+	if !ok {
+		return nil, errors.New("TOML output requires an object")
+	}
 	var buf bytes.Buffer
-	encoder := toml.NewEncoder(&buf)
-	if err := encoder.Encode(data); err != nil {
+	if c := obj.comments[""].head; c != "" {
+		writeTOMLComment(&buf, c, "")
+		buf.WriteString("\n")
+	}
+	if err := writeTOMLTable(&buf, nil, obj); err != nil {
 		return nil, err
 	}
 	return buf.Bytes(), nil
 }
+
+func writeTOMLTable(buf *bytes.Buffer, path []string, obj *Object) error {
+	indent := strings.Repeat("  ", len(path))
+	var tables []string
+	for _, k := range obj.Keys() {
+		v := obj.values[k]
+		if v == nil {
+			continue
+		}
+		if isTOMLTable(v) || isTOMLArrayOfTables(v) {
+			tables = append(tables, k)
+			continue
+		}
+		value, err := tomlValue(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", strings.Join(append(path, k), "."), err)
+		}
+		c := obj.comments[k]
+		writeTOMLComment(buf, c.head, indent)
+		buf.WriteString(indent + tomlKey(k) + " = " + value)
+		writeTOMLLineComment(buf, c.line)
+	}
+
+	for _, k := range tables {
+		childPath := append(slices.Clip(path), k)
+		header := make([]string, len(childPath))
+		for i, p := range childPath {
+			header[i] = tomlKey(p)
+		}
+		headerIndent := strings.Repeat("  ", len(path))
+		c := obj.comments[k]
+
+		if child, ok := toObject(obj.values[k]); ok {
+			buf.WriteString("\n")
+			writeTOMLComment(buf, c.head, headerIndent)
+			buf.WriteString(headerIndent + "[" + strings.Join(header, ".") + "]")
+			writeTOMLLineComment(buf, c.line)
+			if err := writeTOMLTable(buf, childPath, child); err != nil {
+				return err
+			}
+			continue
+		}
+		items, _ := toSlice(obj.values[k])
+		for i, item := range items {
+			child, _ := toObject(item)
+			buf.WriteString("\n")
+			if i == 0 {
+				writeTOMLComment(buf, c.head, headerIndent)
+			}
+			buf.WriteString(headerIndent + "[[" + strings.Join(header, ".") + "]]")
+			if i == 0 {
+				writeTOMLLineComment(buf, c.line)
+			} else {
+				buf.WriteString("\n")
+			}
+			if err := writeTOMLTable(buf, childPath, child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func isTOMLTable(v any) bool {
+	_, ok := toObject(v)
+	return ok
+}
+
+// isTOMLArrayOfTables reports whether v is a non-empty array of objects.
+func isTOMLArrayOfTables(v any) bool {
+	items, ok := toSlice(v)
+	if !ok || len(items) == 0 {
+		return false
+	}
+	for _, item := range items {
+		if !isTOMLTable(item) {
+			return false
+		}
+	}
+	return true
+}
+
+func writeTOMLComment(buf *bytes.Buffer, text, indent string) {
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(text, "\n") {
+		buf.WriteString(strings.TrimRight(indent+"# "+line, " ") + "\n")
+	}
+}
+
+func writeTOMLLineComment(buf *bytes.Buffer, text string) {
+	if text != "" {
+		buf.WriteString(" # " + text)
+	}
+	buf.WriteString("\n")
+}
+
+func tomlKey(k string) string {
+	if tomlBareKey.MatchString(k) {
+		return k
+	}
+	return tomlString(k)
+}
+
+// tomlValue writes a value inline. Objects inside arrays become inline
+// tables.
+func tomlValue(v any) (string, error) {
+	switch x := v.(type) {
+	case nil:
+		return "", errors.New("TOML cannot represent null in arrays")
+	case string:
+		return tomlString(x), nil
+	case bool:
+		return strconv.FormatBool(x), nil
+	case float64:
+		return tomlFloat(x), nil
+	case float32:
+		return tomlFloat(float64(x)), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", x), nil
+	case json.Number:
+		if _, err := x.Int64(); err == nil {
+			return x.String(), nil
+		}
+		f, err := x.Float64()
+		return tomlFloat(f), err
+	case time.Time:
+		if s, ok := localTimeString(x); ok {
+			return s, nil
+		}
+		return x.Format(time.RFC3339Nano), nil
+	}
+
+	if obj, ok := toObject(v); ok {
+		parts := make([]string, 0, obj.Len())
+		for _, k := range obj.Keys() {
+			if obj.values[k] == nil {
+				continue
+			}
+			value, err := tomlValue(obj.values[k])
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, tomlKey(k)+" = "+value)
+		}
+		if len(parts) == 0 {
+			return "{}", nil
+		}
+		return "{" + strings.Join(parts, ", ") + "}", nil
+	}
+	if items, ok := toSlice(v); ok {
+		parts := make([]string, len(items))
+		for i, item := range items {
+			value, err := tomlValue(item)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = value
+		}
+		return "[" + strings.Join(parts, ", ") + "]", nil
+	}
+	return "", fmt.Errorf("TOML cannot encode %T", v)
+}
+
+// tomlFloat writes a float so that it reads back as one, with a decimal
+// point or exponent.
+func tomlFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if a := math.Abs(f); a == 0 || a >= 1e-6 && a < 1e21 {
+		s = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// tomlString quotes s as a basic string.
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,372 @@
+package polymorph
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xlsxText is a shared or inline string, either plain or made of runs.
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, r := range t.Runs {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Cells []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// parseXLSX reads the sheets of a workbook, each as an array of objects
+// keyed by its first row. A workbook with one sheet gives that array; with
+// more, an object of them keyed by sheet name. Dates are left as the
+// serial numbers Excel stores them as.
+func parseXLSX(input []byte) (any, error) {
+	zr, err := zip.NewReader(bytes.NewReader(input), int64(len(input)))
+	if err != nil {
+		return nil, fmt.Errorf("not an XLSX file: %w", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	var workbook xlsxWorkbook
+	if err := readXLSXPart(files, "xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	var rels xlsxRelationships
+	if err := readXLSXPart(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	targets := make(map[string]string, len(rels.Relationships))
+	for _, r := range rels.Relationships {
+		if strings.HasPrefix(r.Target, "/") {
+			targets[r.ID] = strings.TrimPrefix(r.Target, "/")
+		} else {
+			targets[r.ID] = path.Join("xl", r.Target)
+		}
+	}
+
+	var shared []string
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		var sst struct {
+			Items []xlsxText `xml:"si"`
+		}
+		if err := readXLSXPart(files, "xl/sharedStrings.xml", &sst); err != nil {
+			return nil, err
+		}
+		for _, si := range sst.Items {
+			shared = append(shared, si.String())
+		}
+	}
+
+	sheets := NewObject()
+	var first []any
+	for _, s := range workbook.Sheets {
+		var sheet xlsxSheet
+		if err := readXLSXPart(files, targets[s.ID], &sheet); err != nil {
+			return nil, fmt.Errorf("sheet %s: %w", s.Name, err)
+		}
+		rows, err := xlsxRows(&sheet, shared)
+		if err != nil {
+			return nil, fmt.Errorf("sheet %s: %w", s.Name, err)
+		}
+		if first == nil {
+			first = rows
+		}
+		sheets.Set(s.Name, rows)
+	}
+	if len(workbook.Sheets) == 1 {
+		return first, nil
+	}
+	return sheets, nil
+}
+
+func readXLSXPart(files map[string]*zip.File, name string, v any) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("missing %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(io.LimitReader(rc, 1<<30)).Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// xlsxRows turns the rows of a sheet into objects keyed by its first row.
+// Columns without a header are keyed by their letter.
+func xlsxRows(sheet *xlsxSheet, shared []string) ([]any, error) {
+	result := []any{}
+	var header map[int]string
+	for _, row := range sheet.Rows {
+		values := make(map[int]any)
+		for i, c := range row.Cells {
+			col := i
+			if c.Ref != "" {
+				col = xlsxColumn(c.Ref)
+			}
+			switch c.Type {
+			case "s":
+				n, err := strconv.Atoi(c.Value)
+				if err != nil || n < 0 || n >= len(shared) {
+					return nil, fmt.Errorf("cell %s: invalid shared string %q", c.Ref, c.Value)
+				}
+				values[col] = shared[n]
+			case "inlineStr":
+				values[col] = c.Inline.String()
+			case "b":
+				values[col] = c.Value == "1"
+			case "str", "e":
+				values[col] = c.Value
+			default:
+				if c.Value == "" {
+					continue
+				}
+				f, err := strconv.ParseFloat(c.Value, 64)
+				if err != nil {
+					return nil, fmt.Errorf("cell %s: invalid number %q", c.Ref, c.Value)
+				}
+				values[col] = f
+			}
+		}
+
+		if header == nil {
+			if len(values) == 0 {
+				continue
+			}
+			header = make(map[int]string, len(values))
+			for col, v := range values {
+				header[col], _ = formatScalar(v)
+			}
+			continue
+		}
+		if len(values) == 0 {
+			continue
+		}
+		obj := NewObject()
+		for _, col := range slices.Sorted(maps.Keys(values)) {
+			key, ok := header[col]
+			if !ok || key == "" {
+				key = xlsxColumnName(col)
+			}
+			obj.Set(key, values[col])
+		}
+		result = append(result, obj)
+	}
+	return result, nil
+}
+
+// xlsxColumn returns the zero-based column of a cell reference like "AB12".
+func xlsxColumn(ref string) int {
+	col := 0
+	for _, c := range ref {
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		col = col*26 + int(c-'A') + 1
+	}
+	return col - 1
+}
+
+func xlsxColumnName(col int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name
+}
+
+// renderXLSX writes an array of objects as a workbook with one sheet, or
+// an object of such arrays as one sheet per key. The header row holds the
+// keys of all the objects, in order.
+func renderXLSX(data any) ([]byte, error) {
+	var names []string
+	var sheets [][]any
+	if rows, ok := toSlice(data); ok {
+		names, sheets = []string{"Sheet1"}, [][]any{rows}
+	} else if obj, ok := toObject(data); ok && obj.Len() > 0 {
+		used := make(map[string]bool)
+		for _, k := range obj.Keys() {
+			rows, ok := toSlice(obj.values[k])
+			if !ok {
+				return nil, fmt.Errorf("XLSX output requires an array of objects, or an object of such arrays (%s is not an array)", k)
+			}
+			names = append(names, xlsxSheetName(k, used))
+			sheets = append(sheets, rows)
+		}
+	} else {
+		return nil, fmt.Errorf("XLSX output requires an array of objects, or an object of such arrays")
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	write := func(name, content string) error {
+		w, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, xml.Header+content)
+		return err
+	}
+
+	var overrides, sheetList, rels strings.Builder
+	for i, name := range names {
+		n := i + 1
+		fmt.Fprintf(&overrides, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+		fmt.Fprintf(&sheetList, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(name), n, n)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n)
+
+		sheet, err := xlsxSheetXML(sheets[i])
+		if err != nil {
+			return nil, fmt.Errorf("sheet %s: %w", name, err)
+		}
+		if err := write(fmt.Sprintf("xl/worksheets/sheet%d.xml", n), sheet); err != nil {
+			return nil, err
+		}
+	}
+	fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(names)+1)
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` + overrides.String() + `</Types>`},
+		{"_rels/.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+		{"xl/workbook.xml", `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>` + sheetList.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` + rels.String() + `</Relationships>`},
+		{"xl/styles.xml", `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><fonts count="1"><font/></fonts><fills count="1"><fill/></fills><borders count="1"><border/></borders><cellStyleXfs count="1"><xf/></cellStyleXfs><cellXfs count="1"><xf/></cellXfs></styleSheet>`},
+	}
+	for _, p := range parts {
+		if err := write(p.name, p.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func xlsxSheetXML(rows []any) (string, error) {
+	headers := extractHeaders(rows)
+	if len(headers) == 0 && len(rows) > 0 {
+		return "", fmt.Errorf("XLSX output requires objects with keys")
+	}
+
+	var b strings.Builder
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	if len(headers) > 0 {
+		b.WriteString(`<row r="1">`)
+		for col, h := range headers {
+			writeXLSXCell(&b, col, 1, h)
+		}
+		b.WriteString(`</row>`)
+	}
+	for i, row := range rows {
+		obj, ok := toObject(row)
+		if !ok {
+			return "", fmt.Errorf("row %d is not an object", i+1)
+		}
+		fmt.Fprintf(&b, `<row r="%d">`, i+2)
+		for col, h := range headers {
+			if v, ok := obj.Get(h); ok && v != nil {
+				writeXLSXCell(&b, col, i+2, v)
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.String(), nil
+}
+
+func writeXLSXCell(b *strings.Builder, col, row int, v any) {
+	ref := xlsxColumnName(col) + strconv.Itoa(row)
+	if x, ok := v.(bool); ok {
+		value := "0"
+		if x {
+			value = "1"
+		}
+		fmt.Fprintf(b, `<c r="%s" t="b"><v>%s</v></c>`, ref, value)
+		return
+	}
+	if n, ok := toNumber(v); ok {
+		s, _ := formatScalar(n)
+		fmt.Fprintf(b, `<c r="%s"><v>%s</v></c>`, ref, s)
+		return
+	}
+	fmt.Fprintf(b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, xmlEscape(cell(v)))
+}
+
+// xlsxSheetName makes name a valid sheet name that is not in used: at most
+// 31 characters, none of them []:*?/\.
+func xlsxSheetName(name string, used map[string]bool) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" {
+		name = "Sheet"
+	}
+	runes := []rune(name)
+	if len(runes) > 31 {
+		runes = runes[:31]
+	}
+	candidate := string(runes)
+	for n := 2; used[strings.ToLower(candidate)]; n++ {
+		suffix := fmt.Sprintf(" (%d)", n)
+		candidate = string(runes[:min(len(runes), 31-len(suffix))]) + suffix
+	}
+	used[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -9,17 +9,19 @@ import (
 
 type xmlNode struct {
 	name     string
-	attrs    map[string]string
+	attrs    []xml.Attr
 	children []xmlNode
 	text     string
 }
 
-func parseXML(input []byte) (any, error) {
+func parseXML(input []byte, infer bool) (any, error) {
 	decoder := xml.NewDecoder(bytes.NewReader(input))
-	return parseXMLDocument(decoder)
+	return parseXMLDocument(decoder, textValue(infer))
 }
 
-func parseXMLDocument(decoder *xml.Decoder) (any, error) {
+// parseXMLDocument reads elements into objects, passing text and attribute
+// values through value.
+func parseXMLDocument(decoder *xml.Decoder, value func(string) any) (any, error) {
 	var stack []*xmlNode
 	var root *xmlNode
 
@@ -31,13 +33,7 @@ func parseXMLDocument(decoder *xml.Decoder) (any, error) {
 
 		switch t := token.(type) {
 		case xml.StartElement:
-			node := &xmlNode{
-				name:  t.Name.Local,
-				attrs: make(map[string]string),
-			}
-			for _, attr := range t.Attr {
-				node.attrs[attr.Name.Local] = attr.Value
-			}
+			node := &xmlNode{name: t.Name.Local, attrs: t.Attr}
 			if len(stack) > 0 {
 				parent := stack[len(stack)-1]
 				parent.children = append(parent.children, *node)
@@ -61,42 +57,47 @@ func parseXMLDocument(decoder *xml.Decoder) (any, error) {
 		}
 	}
 
-	if root == nil {
-		return map[string]any{}, nil
+	result := NewObject()
+	if root != nil {
+		result.Set(root.name, nodeToMap(root, value))
 	}
-	return map[string]any{root.name: nodeToMap(root)}, nil
+	return result, nil
 }
 
-func nodeToMap(node *xmlNode) any {
+func nodeToMap(node *xmlNode, value func(string) any) any {
 	if len(node.children) == 0 && len(node.attrs) == 0 {
-		return node.text
+		return value(node.text)
 	}
 
-	result := make(map[string]any)
+	result := NewObject()
 
-	for k, v := range node.attrs {
-		result["@"+k] = v
+	for _, attr := range node.attrs {
+		result.Set("@"+attr.Name.Local, value(attr.Value))
 	}
 
 	if node.text != "" && len(node.children) == 0 {
 		if len(node.attrs) > 0 {
-			result["#text"] = node.text
+			result.Set("#text", value(node.text))
 		} else {
-			return node.text
+			return value(node.text)
 		}
 	}
 
+	var names []string
 	childMap := make(map[string][]any)
 	for i := range node.children {
 		child := &node.children[i]
-		childMap[child.name] = append(childMap[child.name], nodeToMap(child))
+		if _, ok := childMap[child.name]; !ok {
+			names = append(names, child.name)
+		}
+		childMap[child.name] = append(childMap[child.name], nodeToMap(child, value))
 	}
 
-	for name, values := range childMap {
-		if len(values) == 1 {
-			result[name] = values[0]
+	for _, name := range names {
+		if values := childMap[name]; len(values) == 1 {
+			result.Set(name, values[0])
 		} else {
-			result[name] = values
+			result.Set(name, values)
 		}
 	}
 
@@ -107,11 +108,10 @@ func renderXML(data any) ([]byte, error) {
 	var buf bytes.Buffer
 	buf.WriteString(xml.Header)
 
-	if obj, ok := toMap(data); ok {
-		if len(obj) == 1 {
-			for k, v := range obj {
-				writeXMLElement(&buf, k, v, 0)
-			}
+	if obj, ok := toObject(data); ok {
+		if obj.Len() == 1 {
+			k := obj.Keys()[0]
+			writeXMLElement(&buf, k, obj.values[k], 0)
 		} else {
 			buf.WriteString("<root>\n")
 			writeXMLContent(&buf, obj, 1)
@@ -124,7 +124,7 @@ func renderXML(data any) ([]byte, error) {
 		}
 		buf.WriteString("</root>\n")
 	} else {
-		buf.WriteString(fmt.Sprintf("<value>%s</value>\n", escapeXML(fmt.Sprintf("%v", data))))
+		buf.WriteString(fmt.Sprintf("<value>%s</value>\n", escapeXML(cell(data))))
 	}
 
 	return buf.Bytes(), nil
@@ -133,16 +133,16 @@ func renderXML(data any) ([]byte, error) {
 func writeXMLElement(buf *bytes.Buffer, name string, value any, indent int) {
 	indentStr := strings.Repeat("  ", indent)
 
-	if obj, ok := toMap(value); ok {
+	if obj, ok := toObject(value); ok {
 		attrs, children := separateXMLAttrs(obj)
 		buf.WriteString(indentStr + "<" + xmlSafeName(name))
-		for k, v := range attrs {
-			buf.WriteString(fmt.Sprintf(" %s=\"%s\"", k[1:], escapeXML(fmt.Sprintf("%v", v))))
+		for _, k := range attrs.Keys() {
+			buf.WriteString(fmt.Sprintf(" %s=\"%s\"", k[1:], escapeXML(cell(attrs.values[k]))))
 		}
-		if len(children) == 0 {
+		if children.Len() == 0 {
 			buf.WriteString("/>\n")
-		} else if text, hasText := children["#text"]; hasText && len(children) == 1 {
-			buf.WriteString(">" + escapeXML(fmt.Sprintf("%v", text)) + "</" + xmlSafeName(name) + ">\n")
+		} else if text, hasText := children.Get("#text"); hasText && children.Len() == 1 {
+			buf.WriteString(">" + escapeXML(cell(text)) + "</" + xmlSafeName(name) + ">\n")
 		} else {
 			buf.WriteString(">\n")
 			writeXMLContent(buf, children, indent+1)
@@ -153,28 +153,27 @@ func writeXMLElement(buf *bytes.Buffer, name string, value any, indent int) {
 			writeXMLElement(buf, name, item, indent)
 		}
 	} else {
-		buf.WriteString(indentStr + "<" + xmlSafeName(name) + ">" + escapeXML(fmt.Sprintf("%v", value)) + "</" + xmlSafeName(name) + ">\n")
+		buf.WriteString(indentStr + "<" + xmlSafeName(name) + ">" + escapeXML(cell(value)) + "</" + xmlSafeName(name) + ">\n")
 	}
 }
 
-func writeXMLContent(buf *bytes.Buffer, obj map[string]any, indent int) {
-	keys := sortedKeys(obj)
-	for _, k := range keys {
+func writeXMLContent(buf *bytes.Buffer, obj *Object, indent int) {
+	for _, k := range obj.Keys() {
 		if k == "#text" {
 			continue
 		}
-		writeXMLElement(buf, k, obj[k], indent)
+		writeXMLElement(buf, k, obj.values[k], indent)
 	}
 }
 
-func separateXMLAttrs(obj map[string]any) (attrs map[string]any, children map[string]any) {
-	attrs = make(map[string]any)
-	children = make(map[string]any)
-	for k, v := range obj {
+func separateXMLAttrs(obj *Object) (attrs *Object, children *Object) {
+	attrs = NewObject()
+	children = NewObject()
+	for _, k := range obj.Keys() {
 		if strings.HasPrefix(k, "@") {
-			attrs[k] = v
+			attrs.Set(k, obj.values[k])
 		} else {
-			children[k] = v
+			children.Set(k, obj.values[k])
 		}
 	}
 	return
@@ -1,43 +1,159 @@
 package polymorph
 
 import (
+	"bytes"
 	"fmt"
 
 	"gopkg.in/yaml.v3"
 )
 
+// parseYAML reads the first document of input, keeping the order of keys
+// and the comments before and after them.
 func parseYAML(input []byte) (any, error) {
-	var data any
-	if err := yaml.Unmarshal(input, &data); err != nil {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(input, &doc); err != nil {
 		return nil, err
 	}
-	return normalizeYAMLMaps(data), nil
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	data, err := yamlValue(doc.Content[0])
+	if err != nil {
+		return nil, err
+	}
+	if obj, ok := data.(*Object); ok {
+		obj.setComment("", comment{head: commentText(doc.HeadComment)})
+	}
+	return data, nil
 }
 
-func renderYAML(data any) ([]byte, error) {
-	return yaml.Marshal(data)
+func yamlValue(node *yaml.Node) (any, error) {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return yamlValue(node.Alias)
+	case yaml.SequenceNode:
+		list := make([]any, 0, len(node.Content))
+		for _, item := range node.Content {
+			v, err := yamlValue(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case yaml.MappingNode:
+		obj := NewObject()
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			v, err := yamlValue(value)
+			if err != nil {
+				return nil, err
+			}
+			if key.Tag == "!!merge" {
+				mergeYAML(obj, v)
+				continue
+			}
+			name := key.Value
+			if key.Kind != yaml.ScalarNode {
+				var k any
+				if err := key.Decode(&k); err != nil {
+					return nil, err
+				}
+				name = fmt.Sprintf("%v", k)
+			}
+			obj.Set(name, v)
+			line := key.LineComment
+			if line == "" {
+				line = value.LineComment
+			}
+			obj.setComment(name, comment{head: commentText(key.HeadComment), line: commentText(line)})
+		}
+		return obj, nil
+	}
+	var v any
+	if err := node.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
 }
 
-func normalizeYAMLMaps(v any) any {
-	switch x := v.(type) {
-	case map[any]any:
-		m := make(map[string]any)
-		for k, val := range x {
-			m[fmt.Sprintf("%v", k)] = normalizeYAMLMaps(val)
+// mergeYAML adds the keys of a "<<" merge, an object or an array of them,
+// that obj does not set itself.
+func mergeYAML(obj *Object, v any) {
+	sources := []any{v}
+	if list, ok := v.([]any); ok {
+		sources = list
+	}
+	for _, source := range sources {
+		from, ok := source.(*Object)
+		if !ok {
+			continue
 		}
-		return m
-	case map[string]any:
-		m := make(map[string]any)
-		for k, val := range x {
-			m[k] = normalizeYAMLMaps(val)
+		for _, k := range from.Keys() {
+			if !obj.has(k) {
+				obj.Set(k, from.values[k])
+			}
 		}
-		return m
-	case []any:
-		for i, val := range x {
-			x[i] = normalizeYAMLMaps(val)
+	}
+}
+
+// renderYAML writes data with objects in order and their comments.
+func renderYAML(data any) ([]byte, error) {
+	node, err := yamlNode(data)
+	if err != nil {
+		return nil, err
+	}
+	doc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{node}}
+	if obj, ok := data.(*Object); ok {
+		doc.HeadComment = obj.comments[""].head
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(4)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), enc.Close()
+}
+
+// yamlNode builds the node for v. Objects and arrays are built here rather
+// than by Node.Encode, which drops the comments of nested nodes.
+func yamlNode(v any) (*yaml.Node, error) {
+	if obj, ok := toObject(v); ok {
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, k := range obj.keys {
+			c := obj.comments[k]
+			key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k, HeadComment: c.head}
+			value, err := yamlNode(obj.values[k])
+			if err != nil {
+				return nil, err
+			}
+			if c.line != "" {
+				if value.Kind == yaml.ScalarNode {
+					value.LineComment = c.line
+				} else {
+					key.LineComment = c.line
+				}
+			}
+			node.Content = append(node.Content, key, value)
+		}
+		return node, nil
+	}
+	if list, ok := v.([]any); ok {
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range list {
+			n, err := yamlNode(item)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, n)
 		}
-		return x
-	default:
-		return v
+		return node, nil
+	}
+	node := &yaml.Node{}
+	if err := node.Encode(v); err != nil {
+		return nil, err
 	}
+	return node, nil
 }
@@ -0,0 +1,52 @@
+// Package chat runs the follow-up conversation of spells cast with --chat.
+package chat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Sender is a conversation that answers one message at a time, such as a
+// claude.Session.
+type Sender interface {
+	Send(prompt string) (string, error)
+}
+
+// Start sends the spell's prompt as the first message, prints the answer
+// and then takes follow-up questions from in until EOF or "exit".
+func Start(s Sender, prompt string, in io.Reader, out io.Writer) error {
+	answer, err := s.Send(prompt)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, answer)
+
+	fmt.Fprintln(out, "\nAsk a follow-up question, or \"exit\" to finish.")
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for {
+		fmt.Fprint(out, "\n> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return scanner.Err()
+		}
+
+		question := strings.TrimSpace(scanner.Text())
+		switch question {
+		case "":
+			continue
+		case "exit", "quit":
+			return nil
+		}
+
+		answer, err := s.Send(question)
+		if err != nil {
+			// Keep the conversation going, the question can be asked again
+			fmt.Fprintf(out, "error: %v\n", err)
+			continue
+		}
+		fmt.Fprintf(out, "\n%s\n", answer)
+	}
+}
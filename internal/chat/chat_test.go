@@ -0,0 +1,62 @@
+package chat
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type fakeSender struct {
+	prompts []string
+	fail    map[string]bool
+}
+
+func (f *fakeSender) Send(prompt string) (string, error) {
+	f.prompts = append(f.prompts, prompt)
+	if f.fail[prompt] {
+		return "", errors.New("overloaded")
+	}
+	return "answer to " + prompt, nil
+}
+
+func TestStart(t *testing.T) {
+	s := &fakeSender{fail: map[string]bool{"flaky": true}}
+	var out strings.Builder
+
+	err := Start(s, "explain", strings.NewReader("why?\n\n  flaky \nshow the fix\nexit\nignored\n"), &out)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	want := []string{"explain", "why?", "flaky", "show the fix"}
+	if strings.Join(s.prompts, "|") != strings.Join(want, "|") {
+		t.Errorf("prompts = %q, want %q", s.prompts, want)
+	}
+	for _, text := range []string{"answer to explain\n", "answer to why?\n", "error: overloaded\n", "answer to show the fix\n"} {
+		if !strings.Contains(out.String(), text) {
+			t.Errorf("output missing %q in:\n%s", text, out.String())
+		}
+	}
+}
+
+func TestStartEOF(t *testing.T) {
+	s := &fakeSender{}
+	var out strings.Builder
+
+	if err := Start(s, "review", strings.NewReader("and tests?"), &out); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if len(s.prompts) != 2 {
+		t.Errorf("prompts = %q, want the last line without newline sent", s.prompts)
+	}
+}
+
+func TestStartFirstError(t *testing.T) {
+	s := &fakeSender{fail: map[string]bool{"review": true}}
+	if err := Start(s, "review", strings.NewReader("why?\n"), &strings.Builder{}); err == nil {
+		t.Fatal("Start() expected error when the first message fails")
+	}
+	if len(s.prompts) != 1 {
+		t.Errorf("prompts = %q, want no follow-ups after a failed start", s.prompts)
+	}
+}
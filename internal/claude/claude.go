@@ -8,7 +8,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/emiliopalmerini/grimorio/internal/cache"
+	"github.com/emiliopalmerini/grimorio/internal/config"
 	"github.com/emiliopalmerini/grimorio/internal/metrics"
+	"github.com/emiliopalmerini/grimorio/internal/redact"
 )
 
 type Model string
@@ -35,26 +38,112 @@ func (r ExecRunner) Run(model Model, command, prompt string) (string, error) {
 // DefaultRunner is the default Runner implementation.
 var DefaultRunner Runner = ExecRunner{}
 
+// Run sends prompt to the Claude CLI. Secrets and personal data are
+// redacted from the prompt first, and responses of cacheable commands,
+// such as identify, are served from the cache when the same redacted
+// prompt was sent recently. Transient failures are
+// retried with backoff, then the command's fallback models are tried.
 func Run(model Model, command, prompt string) (string, error) {
-	start := time.Now()
-	cmd := exec.Command("claude", "-p", "--no-session-persistence", "--model", string(model), prompt)
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	return RunContext(context.Background(), model, command, prompt)
+}
 
-	err := cmd.Run()
-	latency := time.Since(start).Milliseconds()
-	response := strings.TrimSpace(stdout.String())
+// RunContext is Run with a context bounding all attempts.
+func RunContext(ctx context.Context, model Model, command, prompt string) (string, error) {
+	redactor, policy, err := load()
+	if err != nil {
+		return "", err
+	}
+	prompt, redactions := redactor.Redact(prompt)
 
 	if command == "" {
 		command = "unknown"
 	}
+	return policy.run(ctx, model, command, prompt, redactions, "")
+}
 
+// load reads the redaction rules and retry policy of the repository.
+func load() (*redact.Redactor, Policy, error) {
+	cfg, err := config.LoadRepo()
+	if err != nil {
+		return nil, Policy{}, err
+	}
+	redactor, err := redact.New(cfg.Redaction)
 	if err != nil {
-		metrics.Default.RecordAI(context.Background(), command, string(model), len(prompt), 0, latency, false, err.Error())
-		return "", fmt.Errorf("claude failed: %w\n%s", err, stderr.String())
+		return nil, Policy{}, err
+	}
+	policy, err := PolicyFromConfig(cfg.Claude)
+	if err != nil {
+		return nil, Policy{}, err
+	}
+	return redactor, policy, nil
+}
+
+// run sends an already redacted prompt, trying each model of the fallback
+// chain in turn. Every attempt is recorded separately, linked to session
+// when it is part of a conversation.
+func (p Policy) run(ctx context.Context, model Model, command, prompt string, redactions int, session string) (string, error) {
+	cacheable := cache.Cacheable(command)
+
+	// Metrics and the cache must be written even once the deadline passes.
+	record := context.WithoutCancel(ctx)
+	if p.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Deadline)
+		defer cancel()
 	}
 
-	metrics.Default.RecordAI(context.Background(), command, string(model), len(prompt), len(response), latency, true, "")
-	return response, nil
+	attempt := 0
+	var lastErr error
+	for _, m := range p.Models(command, model) {
+		// A fallback model's answer is only used where that model would
+		// be tried anyway.
+		if cacheable {
+			if response, ok, _ := cache.Default.Get(ctx, string(m), command, prompt); ok {
+				metrics.Default.RecordCacheHit(record, command, string(m), len(prompt), len(response), session)
+				return response, nil
+			}
+		}
+		for try := 1; try <= max(p.MaxAttempts, 1); try++ {
+			if try > 1 {
+				if err := sleep(ctx, p.Backoff(try-1)); err != nil {
+					return "", fmt.Errorf("%w\ngave up retrying: %v", lastErr, err)
+				}
+			}
+			attempt++
+
+			start := time.Now()
+			stdout, stderr, err := execute(ctx, m, prompt)
+			latency := time.Since(start).Milliseconds()
+			response := strings.TrimSpace(stdout)
+
+			if err == nil {
+				metrics.Default.RecordAI(record, command, string(m), len(prompt), len(response), latency, true, "", redactions, attempt, session)
+				if response != "" && cacheable {
+					cache.Default.Put(record, string(m), command, prompt, response)
+				}
+				return response, nil
+			}
+
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				err = fmt.Errorf("%w: %v", ctxErr, err)
+			}
+			callErr := classify(m, attempt, err, stderr)
+			metrics.Default.RecordAI(record, command, string(m), len(prompt), 0, latency, false, err.Error(), redactions, attempt, session)
+			lastErr = callErr
+			if !callErr.Retryable {
+				return "", callErr
+			}
+		}
+	}
+	return "", lastErr
+}
+
+// execute runs the Claude CLI once and returns its stdout and stderr.
+var execute = func(ctx context.Context, model Model, prompt string) (string, string, error) {
+	cmd := exec.CommandContext(ctx, "claude", "-p", "--no-session-persistence", "--model", string(model), prompt)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	return stdout.String(), stderr.String(), err
 }
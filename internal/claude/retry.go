@@ -0,0 +1,239 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emiliopalmerini/grimorio/internal/config"
+)
+
+// Policy controls how Run retries failed calls and which models it falls
+// back to.
+type Policy struct {
+	// MaxAttempts is the number of tries per model, including the first.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry; it doubles on each
+	// retry up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Deadline caps the whole call, retries and fallbacks included.
+	Deadline time.Duration
+	// Fallback maps a command to the models tried after its own.
+	Fallback map[string][]Model
+}
+
+// DefaultPolicy is used when the repository does not configure [claude].
+var DefaultPolicy = Policy{
+	MaxAttempts: 3,
+	BaseDelay:   2 * time.Second,
+	MaxDelay:    30 * time.Second,
+	Deadline:    5 * time.Minute,
+}
+
+// PolicyFromConfig applies the repository's [claude] config on top of
+// DefaultPolicy.
+func PolicyFromConfig(cfg config.Claude) (Policy, error) {
+	p := DefaultPolicy
+
+	if cfg.MaxAttempts < 0 {
+		return Policy{}, fmt.Errorf("claude.max_attempts: must not be negative, got %d", cfg.MaxAttempts)
+	}
+	if cfg.MaxAttempts > 0 {
+		p.MaxAttempts = cfg.MaxAttempts
+	}
+
+	durations := []struct {
+		key   string
+		value string
+		dst   *time.Duration
+	}{
+		{"base_delay", cfg.BaseDelay, &p.BaseDelay},
+		{"max_delay", cfg.MaxDelay, &p.MaxDelay},
+		{"deadline", cfg.Deadline, &p.Deadline},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil || parsed < 0 {
+			return Policy{}, fmt.Errorf("claude.%s: invalid duration %q", d.key, d.value)
+		}
+		*d.dst = parsed
+	}
+
+	if len(cfg.Fallback) > 0 {
+		p.Fallback = make(map[string][]Model, len(cfg.Fallback))
+		for command, models := range cfg.Fallback {
+			for _, m := range models {
+				m = strings.TrimSpace(m)
+				if m == "" {
+					return Policy{}, fmt.Errorf("claude.fallback.%s: empty model name", command)
+				}
+				p.Fallback[command] = append(p.Fallback[command], Model(m))
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// Models returns the models tried for command, starting with model.
+func (p Policy) Models(command string, model Model) []Model {
+	models := []Model{model}
+	for _, m := range p.Fallback[command] {
+		seen := false
+		for _, existing := range models {
+			if existing == m {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			models = append(models, m)
+		}
+	}
+	return models
+}
+
+// Backoff returns how long to wait before retry n (starting at 1): the
+// base delay doubled n-1 times, capped at MaxDelay, with jitter in the
+// upper half so concurrent callers spread out.
+func (p Policy) Backoff(n int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+	d := p.BaseDelay
+	for i := 1; i < n && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
+
+// Error is a failed call to the Claude CLI.
+type Error struct {
+	Model     Model
+	Attempt   int
+	Status    int
+	Stderr    string
+	Retryable bool
+	Err       error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("claude failed: %v\n%s", e.Err, e.Stderr)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable reports whether err is a Claude failure worth retrying.
+func IsRetryable(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Retryable
+}
+
+// statusRe finds an HTTP status code in the CLI's error output.
+var statusRe = regexp.MustCompile(`(?i)(?:api error|status(?: code)?|http(?:/[\d.]+)?)\D{0,3}([1-5]\d\d)\b`)
+
+// fatalMarkers identify failures that retrying cannot fix.
+var fatalMarkers = []string{
+	"invalid api key",
+	"authentication",
+	"unauthorized",
+	"permission denied",
+	"forbidden",
+	"invalid model",
+	"model not found",
+	"prompt is too long",
+	"credit balance",
+}
+
+// retryableMarkers identify transient failures.
+var retryableMarkers = []string{
+	"rate limit",
+	"rate_limit",
+	"overloaded",
+	"timeout",
+	"timed out",
+	"connection reset",
+	"connection refused",
+	"econnreset",
+	"etimedout",
+	"temporarily unavailable",
+	"service unavailable",
+	"bad gateway",
+	"try again",
+}
+
+// classify turns a failed CLI run into an Error, deciding from the HTTP
+// status or the wording of stderr whether it is worth retrying. Failures
+// with no recognizable cause are treated as transient CLI hiccups.
+func classify(model Model, attempt int, err error, stderr string) *Error {
+	e := &Error{Model: model, Attempt: attempt, Stderr: stderr, Err: err}
+
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return e
+	}
+
+	if m := statusRe.FindStringSubmatch(stderr); m != nil {
+		e.Status, _ = strconv.Atoi(m[1])
+		e.Retryable = retryableStatus(e.Status)
+		return e
+	}
+
+	lower := strings.ToLower(stderr)
+	for _, marker := range fatalMarkers {
+		if strings.Contains(lower, marker) {
+			return e
+		}
+	}
+	for _, marker := range retryableMarkers {
+		if strings.Contains(lower, marker) {
+			e.Retryable = true
+			return e
+		}
+	}
+
+	e.Retryable = true
+	return e
+}
+
+// retryableStatus reports whether an HTTP status is transient.
+func retryableStatus(status int) bool {
+	switch {
+	case status == 408, status == 409, status == 425, status == 429:
+		return true
+	case status >= 500:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleep waits for d or until ctx is done.
+var sleep = func(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
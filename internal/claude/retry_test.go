@@ -0,0 +1,278 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/emiliopalmerini/grimorio/internal/cache"
+	"github.com/emiliopalmerini/grimorio/internal/config"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		stderr    string
+		retryable bool
+		status    int
+	}{
+		{"rate limited", errors.New("exit status 1"), "API Error: 429 rate_limit_error", true, 429},
+		{"overloaded", errors.New("exit status 1"), "API Error: 529 {\"type\":\"overloaded_error\"}", true, 529},
+		{"bad request", errors.New("exit status 1"), "API Error: 400 invalid_request_error", false, 400},
+		{"unauthorized", errors.New("exit status 1"), "HTTP 401 Unauthorized", false, 401},
+		{"auth wording", errors.New("exit status 1"), "Invalid API key · Please run /login", false, 0},
+		{"network", errors.New("exit status 1"), "Error: read ECONNRESET", true, 0},
+		{"unknown crash", errors.New("signal: segmentation fault"), "", true, 0},
+		{"missing cli", exec.ErrNotFound, "", false, 0},
+		{"deadline", context.DeadlineExceeded, "", false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := classify(Sonnet, 1, tt.err, tt.stderr)
+			if e.Retryable != tt.retryable {
+				t.Errorf("Retryable = %v, want %v", e.Retryable, tt.retryable)
+			}
+			if e.Status != tt.status {
+				t.Errorf("Status = %d, want %d", e.Status, tt.status)
+			}
+			if IsRetryable(e) != tt.retryable {
+				t.Errorf("IsRetryable = %v, want %v", IsRetryable(e), tt.retryable)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := Policy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	tests := []struct {
+		n    int
+		want time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 5 * time.Second},
+		{50, 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		for range 20 {
+			got := p.Backoff(tt.n)
+			if got < tt.want/2 || got > tt.want {
+				t.Fatalf("Backoff(%d) = %v, want within [%v, %v]", tt.n, got, tt.want/2, tt.want)
+			}
+		}
+	}
+}
+
+func TestPolicyFromConfig(t *testing.T) {
+	p, err := PolicyFromConfig(config.Claude{
+		MaxAttempts: 5,
+		BaseDelay:   "500ms",
+		Deadline:    "1m",
+		Fallback:    map[string][]string{"scrying": {"sonnet", " haiku "}},
+	})
+	if err != nil {
+		t.Fatalf("PolicyFromConfig: %v", err)
+	}
+	if p.MaxAttempts != 5 || p.BaseDelay != 500*time.Millisecond || p.Deadline != time.Minute {
+		t.Errorf("unexpected policy: %+v", p)
+	}
+	if p.MaxDelay != DefaultPolicy.MaxDelay {
+		t.Errorf("MaxDelay = %v, want default %v", p.MaxDelay, DefaultPolicy.MaxDelay)
+	}
+
+	got := p.Models("scrying", Opus)
+	want := []Model{Opus, Sonnet, Haiku}
+	if len(got) != len(want) {
+		t.Fatalf("Models = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Models = %v, want %v", got, want)
+		}
+	}
+	if got := p.Models("identify", Sonnet); len(got) != 1 {
+		t.Errorf("Models without fallback = %v, want only the primary", got)
+	}
+
+	invalid := []config.Claude{
+		{MaxAttempts: -1},
+		{BaseDelay: "soon"},
+		{Deadline: "-1s"},
+		{Fallback: map[string][]string{"scrying": {""}}},
+	}
+	for _, cfg := range invalid {
+		if _, err := PolicyFromConfig(cfg); err == nil {
+			t.Errorf("PolicyFromConfig(%+v) expected error", cfg)
+		}
+	}
+}
+
+type call struct {
+	model  Model
+	prompt string
+}
+
+// fakeExecute replaces the CLI with scripted results, one per call.
+func fakeExecute(t *testing.T, results ...func(Model) (string, string, error)) *[]call {
+	t.Helper()
+	calls := &[]call{}
+	origExecute, origSleep := execute, sleep
+	execute = func(ctx context.Context, model Model, prompt string) (string, string, error) {
+		i := len(*calls)
+		*calls = append(*calls, call{model: model, prompt: prompt})
+		if i >= len(results) {
+			t.Fatalf("unexpected call %d to %s", i+1, model)
+		}
+		return results[i](model)
+	}
+	sleep = func(ctx context.Context, d time.Duration) error { return ctx.Err() }
+	t.Cleanup(func() { execute, sleep = origExecute, origSleep })
+	return calls
+}
+
+func ok(response string) func(Model) (string, string, error) {
+	return func(Model) (string, string, error) { return response, "", nil }
+}
+
+func fail(stderr string) func(Model) (string, string, error) {
+	return func(Model) (string, string, error) { return "", stderr, errors.New("exit status 1") }
+}
+
+func TestRunRetries(t *testing.T) {
+	calls := fakeExecute(t, fail("API Error: 529 overloaded"), ok("looks good\n"))
+
+	p := Policy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	got, err := p.run(context.Background(), Sonnet, "scrying", "prompt", 0, "")
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got != "looks good" {
+		t.Errorf("response = %q", got)
+	}
+	if len(*calls) != 2 {
+		t.Errorf("calls = %d, want 2", len(*calls))
+	}
+}
+
+func TestRunFatalStops(t *testing.T) {
+	calls := fakeExecute(t, fail("API Error: 401 authentication_error"))
+
+	p := Policy{MaxAttempts: 3, Fallback: map[string][]Model{"scrying": {Sonnet}}}
+	_, err := p.run(context.Background(), Opus, "scrying", "prompt", 0, "")
+	if err == nil || IsRetryable(err) {
+		t.Fatalf("expected fatal error, got %v", err)
+	}
+	if len(*calls) != 1 {
+		t.Errorf("calls = %d, want 1", len(*calls))
+	}
+}
+
+func TestRunFallback(t *testing.T) {
+	calls := fakeExecute(t,
+		fail("API Error: 529 overloaded"),
+		fail("API Error: 529 overloaded"),
+		ok("from sonnet"),
+	)
+
+	p := Policy{MaxAttempts: 2, Fallback: map[string][]Model{"scrying": {Sonnet}}}
+	got, err := p.run(context.Background(), Opus, "scrying", "prompt", 0, "")
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got != "from sonnet" {
+		t.Errorf("response = %q", got)
+	}
+	want := []Model{Opus, Opus, Sonnet}
+	for i, c := range *calls {
+		if c.model != want[i] {
+			t.Errorf("call %d model = %s, want %s", i+1, c.model, want[i])
+		}
+	}
+}
+
+func TestRunExhausted(t *testing.T) {
+	fakeExecute(t, fail("rate limit exceeded"), fail("rate limit exceeded"))
+
+	p := Policy{MaxAttempts: 2}
+	_, err := p.run(context.Background(), Haiku, "modify-memory", "prompt", 0, "")
+
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf("expected *Error, got %v", err)
+	}
+	if e.Attempt != 2 || e.Model != Haiku {
+		t.Errorf("last error = attempt %d on %s, want attempt 2 on haiku", e.Attempt, e.Model)
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	calls := fakeExecute(t, fail("overloaded"), ok("too late"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := Policy{MaxAttempts: 3}
+	_, err := p.run(ctx, Sonnet, "scrying", "prompt", 0, "")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(*calls) != 1 {
+		t.Errorf("calls = %d, want 1", len(*calls))
+	}
+}
+
+func TestRunCache(t *testing.T) {
+	store := cache.NewSQLiteStore(filepath.Join(t.TempDir(), "cache.db"), time.Hour)
+	defer store.Close()
+	orig := cache.Default
+	cache.Default = store
+	t.Cleanup(func() { cache.Default = orig })
+
+	calls := fakeExecute(t, ok("looks good"), ok("feat: add x"), ok("fix: add x"))
+	p := Policy{MaxAttempts: 1}
+	for range 2 {
+		if got, err := p.run(context.Background(), Sonnet, "scrying", "prompt", 0, ""); err != nil || got != "looks good" {
+			t.Fatalf("run(scrying) = %q, %v", got, err)
+		}
+	}
+	for _, want := range []string{"feat: add x", "fix: add x"} {
+		if got, err := p.run(context.Background(), Sonnet, "sending", "prompt", 0, ""); err != nil || got != want {
+			t.Fatalf("run(sending) = %q, %v, want %q", got, err, want)
+		}
+	}
+	if len(*calls) != 3 {
+		t.Errorf("calls = %d, want 3", len(*calls))
+	}
+}
+
+func TestRunCacheFallback(t *testing.T) {
+	store := cache.NewSQLiteStore(filepath.Join(t.TempDir(), "cache.db"), time.Hour)
+	defer store.Close()
+	orig := cache.Default
+	cache.Default = store
+	t.Cleanup(func() { cache.Default = orig })
+	store.Put(context.Background(), string(Haiku), "scrying", "prompt", "from haiku")
+
+	// The fallback's cached answer does not stand in for the primary...
+	calls := fakeExecute(t, ok("from sonnet"), fail("API Error: 529 overloaded"))
+	p := Policy{MaxAttempts: 1, Fallback: map[string][]Model{"scrying": {Haiku}}}
+	if got, err := p.run(context.Background(), Sonnet, "scrying", "prompt", 0, ""); err != nil || got != "from sonnet" {
+		t.Fatalf("run() = %q, %v, want the primary's answer", got, err)
+	}
+	// ...but is used once the primary fails and the fallback is reached.
+	store.Put(context.Background(), string(Haiku), "scrying", "other", "from haiku")
+	if got, err := p.run(context.Background(), Sonnet, "scrying", "other", 0, ""); err != nil || got != "from haiku" {
+		t.Fatalf("run() = %q, %v, want the fallback's cached answer", got, err)
+	}
+	if len(*calls) != 2 {
+		t.Errorf("calls = %d, want 2", len(*calls))
+	}
+}
@@ -0,0 +1,81 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Session is a conversation with Claude. Every message is sent along with
+// the earlier turns, so follow-up questions keep the context of the first
+// answer. Replaying the transcript, rather than resuming a CLI session,
+// keeps retries, fallback models and the cache working on every turn.
+type Session struct {
+	ID      string
+	Model   Model
+	Command string
+
+	frame Frame
+	turns []Turn
+}
+
+// Frame builds the prompt of a follow-up message from the earlier turns,
+// such as prompt.Transcript, which fits them in the model's budget.
+type Frame func(model Model, turns []Turn, prompt string) (string, error)
+
+// Turn is one exchange of a session. Prompt is the redacted message.
+type Turn struct {
+	Prompt   string
+	Response string
+}
+
+// NewSession starts an empty conversation for command, framing follow-ups
+// with frame.
+func NewSession(model Model, command string, frame Frame) *Session {
+	if command == "" {
+		command = "unknown"
+	}
+	return &Session{ID: uuid.NewString(), Model: model, Command: command, frame: frame}
+}
+
+// Send adds a message to the conversation and returns Claude's answer. The
+// first message is sent as is, like Run would; later ones carry the
+// transcript. Each call is recorded with the session ID.
+func (s *Session) Send(prompt string) (string, error) {
+	return s.SendContext(context.Background(), prompt)
+}
+
+// SendContext is Send with a context bounding all attempts.
+func (s *Session) SendContext(ctx context.Context, prompt string) (string, error) {
+	redactor, policy, err := load()
+	if err != nil {
+		return "", err
+	}
+	prompt, redactions := redactor.Redact(prompt)
+	return s.send(ctx, policy, prompt, redactions)
+}
+
+func (s *Session) send(ctx context.Context, policy Policy, prompt string, redactions int) (string, error) {
+	text := prompt
+	if len(s.turns) > 0 {
+		var err error
+		if text, err = s.frame(s.Model, s.turns, prompt); err != nil {
+			return "", err
+		}
+	}
+	response, err := policy.run(ctx, s.Model, s.Command, text, redactions, s.ID)
+	if err != nil {
+		return "", err
+	}
+	if response == "" {
+		return "", fmt.Errorf("claude returned empty response")
+	}
+	s.turns = append(s.turns, Turn{Prompt: prompt, Response: response})
+	return response, nil
+}
+
+// Turns returns the exchanges so far, oldest first.
+func (s *Session) Turns() []Turn {
+	return s.turns
+}
@@ -0,0 +1,59 @@
+package claude
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// countTurns frames a follow-up with the number of earlier turns.
+func countTurns(model Model, turns []Turn, prompt string) (string, error) {
+	return fmt.Sprintf("%d earlier turn(s), then: %s", len(turns), prompt), nil
+}
+
+func TestSessionReplaysTurns(t *testing.T) {
+	calls := fakeExecute(t, ok("It parses diffs.\n"), ok("Because hunks overlap."))
+
+	s := NewSession(Sonnet, "identify", countTurns)
+	if s.ID == "" {
+		t.Fatal("NewSession() has no ID")
+	}
+
+	p := Policy{MaxAttempts: 1}
+	if _, err := s.send(context.Background(), p, "Explain diff.go", 0); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	got, err := s.send(context.Background(), p, "why?", 0)
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if got != "Because hunks overlap." {
+		t.Errorf("response = %q", got)
+	}
+
+	if (*calls)[0].prompt != "Explain diff.go" {
+		t.Errorf("first prompt = %q, want it unchanged", (*calls)[0].prompt)
+	}
+	if follow := (*calls)[1].prompt; follow != "1 earlier turn(s), then: why?" {
+		t.Errorf("follow-up prompt = %q, want it framed after the first turn", follow)
+	}
+	if len(s.Turns()) != 2 {
+		t.Errorf("turns = %d, want 2", len(s.Turns()))
+	}
+}
+
+func TestSessionFailedTurn(t *testing.T) {
+	fakeExecute(t, ok("answer"), fail("API Error: 401 authentication_error"))
+
+	s := NewSession(Haiku, "augury", countTurns)
+	p := Policy{MaxAttempts: 1}
+	if _, err := s.send(context.Background(), p, "analyze", 0); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if _, err := s.send(context.Background(), p, "show the fix", 0); err == nil {
+		t.Fatal("send: expected error")
+	}
+	if len(s.Turns()) != 1 {
+		t.Errorf("turns = %d, want the failed turn dropped", len(s.Turns()))
+	}
+}
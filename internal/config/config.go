@@ -0,0 +1,111 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/emiliopalmerini/grimorio/internal/git"
+)
+
+// FileName is the name of the repository config file, at the repo root.
+const FileName = ".grimorio.toml"
+
+// Config is the repository configuration.
+type Config struct {
+	Scoring   Scoring   `toml:"scoring"`
+	Redaction Redaction `toml:"redaction"`
+	Cache     Cache     `toml:"cache"`
+	Claude    Claude    `toml:"claude"`
+}
+
+// Claude tunes how spells call the Claude CLI.
+type Claude struct {
+	// MaxAttempts is the number of tries per model, including the first.
+	MaxAttempts int `toml:"max_attempts"`
+	// BaseDelay and MaxDelay bound the exponential backoff between tries,
+	// as Go durations such as "2s".
+	BaseDelay string `toml:"base_delay"`
+	MaxDelay  string `toml:"max_delay"`
+	// Deadline caps the whole call, retries and fallbacks included.
+	Deadline string `toml:"deadline"`
+	// Fallback maps a command to the models tried, in order, once its own
+	// model keeps failing.
+	Fallback map[string][]string `toml:"fallback"`
+	// Budgets maps a model to the estimated tokens its prompts may use.
+	Budgets map[string]int `toml:"budgets"`
+}
+
+// Cache tunes the spell response cache.
+type Cache struct {
+	// TTL is how long responses are reused, as a Go duration such as "12h".
+	TTL string `toml:"ttl"`
+}
+
+// Scoring tunes diff prioritization.
+type Scoring struct {
+	// Paths maps path globs to a file category or priority, in file order.
+	Paths []PathRule `toml:"-"`
+	// Multipliers overrides the score multiplier of a file category.
+	Multipliers map[string]float64 `toml:"multipliers"`
+	// Weights overrides symbol kind weights and bonuses.
+	Weights map[string]float64 `toml:"weights"`
+	// Budgets sets the high-priority line budget per command.
+	Budgets map[string]int `toml:"budgets"`
+
+	RawPaths map[string]string `toml:"paths"`
+}
+
+// Redaction tunes what is scrubbed from prompts before they leave the machine.
+type Redaction struct {
+	// Allow lists regular expressions for values that are never redacted.
+	Allow []string `toml:"allow"`
+	// Exclude lists globs of files whose contents are never sent, on top
+	// of the built-in ones.
+	Exclude []string `toml:"exclude"`
+}
+
+// PathRule assigns a category or priority to files matching Glob.
+type PathRule struct {
+	Glob   string
+	Target string
+}
+
+// Load reads the config file in root. A missing file yields an empty config.
+func Load(root string) (*Config, error) {
+	path := filepath.Join(root, FileName)
+
+	cfg := &Config{}
+	md, err := toml.DecodeFile(path, cfg)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	// Go maps lose the file order, which decides rule precedence.
+	for _, key := range md.Keys() {
+		if len(key) == 3 && key[0] == "scoring" && key[1] == "paths" {
+			cfg.Scoring.Paths = append(cfg.Scoring.Paths, PathRule{
+				Glob:   key[2],
+				Target: strings.ToLower(strings.TrimSpace(cfg.Scoring.RawPaths[key[2]])),
+			})
+		}
+	}
+
+	return cfg, nil
+}
+
+// LoadRepo reads the config file at the root of the current repository.
+// Outside a repository it returns an empty config.
+func LoadRepo() (*Config, error) {
+	root, err := git.GetRootDir()
+	if err != nil {
+		return &Config{}, nil
+	}
+	return Load(root)
+}
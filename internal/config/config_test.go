@@ -0,0 +1,64 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	content := `[scoring.paths]
+"migrations/**" = "high"
+"*.pb.go" = "Generated"
+"docs/**" = "ignore"
+
+[scoring.budgets]
+scrying = 250
+`
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := []PathRule{
+		{Glob: "migrations/**", Target: "high"},
+		{Glob: "*.pb.go", Target: "generated"},
+		{Glob: "docs/**", Target: "ignore"},
+	}
+	if len(cfg.Scoring.Paths) != len(want) {
+		t.Fatalf("Paths = %v, want %v", cfg.Scoring.Paths, want)
+	}
+	for i := range want {
+		if cfg.Scoring.Paths[i] != want[i] {
+			t.Errorf("Paths[%d] = %v, want %v", i, cfg.Scoring.Paths[i], want[i])
+		}
+	}
+	if cfg.Scoring.Budgets["scrying"] != 250 {
+		t.Errorf("Budgets[scrying] = %d, want 250", cfg.Scoring.Budgets["scrying"])
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	cfg, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cfg.Scoring.Paths) != 0 {
+		t.Errorf("Paths = %v, want empty", cfg.Scoring.Paths)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte("[scoring\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir); err == nil {
+		t.Error("Load() expected error for invalid TOML")
+	}
+}
@@ -0,0 +1,26 @@
+package config
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.pb.go", "api/v1/user.pb.go", true},
+		{"*.pb.go", "api/v1/user.go", false},
+		{"migrations/**", "migrations/001_init.sql", true},
+		{"migrations/**", "db/migrations/001_init.sql", false},
+		{"**/migrations/*.sql", "db/migrations/001_init.sql", true},
+		{"**/migrations/*.sql", "migrations/001_init.sql", true},
+		{"docs/*.md", "docs/guide/intro.md", false},
+		{"internal/**/*.go", "internal/diff/profile.go", true},
+	}
+
+	for _, tt := range tests {
+		if got := MatchGlob(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
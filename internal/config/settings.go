@@ -0,0 +1,330 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/emiliopalmerini/grimorio/internal/git"
+)
+
+// Source names the layer a setting comes from, lowest precedence first.
+// Flags passed on the command line override all of them.
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceUser    Source = "user"
+	SourceRepo    Source = "repo"
+	SourceEnv     Source = "env"
+)
+
+// EnvPrefix starts the environment variables that override settings.
+const EnvPrefix = "GRIMORIO_"
+
+// Setting is a resolved per-command default.
+type Setting struct {
+	Value  string
+	Source Source
+	// Origin is the file or environment variable the value was read from.
+	Origin string
+}
+
+// Settings holds per-command defaults from the user and repository config
+// files and the environment. Keys are the command path and the flag name
+// joined by dots, such as "sending.base" or "diff.explain.command"; root
+// flags have no command prefix.
+//
+// In both files the defaults live under [commands]:
+//
+//	[commands.sending]
+//	base = "develop"
+type Settings struct {
+	layers    []settingsLayer
+	lookupEnv func(string) (string, bool)
+}
+
+type settingsLayer struct {
+	source Source
+	path   string
+	values map[string]string
+}
+
+// UserPath returns the user config file, honoring XDG_CONFIG_HOME.
+func UserPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("get home directory: %w", err)
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "grimorio", "config.toml"), nil
+}
+
+// DataPath returns the path of a file in ~/.grimorio, where the metrics
+// and the response cache are kept, creating the directory if needed.
+func DataPath(name string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("get home directory: %w", err)
+	}
+	dir := filepath.Join(home, ".grimorio")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("create config directory: %w", err)
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// RepoPath returns the config file of the current repository.
+func RepoPath() (string, error) {
+	root, err := git.GetRootDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, FileName), nil
+}
+
+// LoadSettings reads the user config file, then the repository one.
+// Either may be missing.
+func LoadSettings() (*Settings, error) {
+	userPath, err := UserPath()
+	if err != nil {
+		return nil, err
+	}
+	repoPath, err := RepoPath()
+	if err != nil {
+		repoPath = ""
+	}
+	return NewSettings(userPath, repoPath)
+}
+
+// NewSettings reads settings from the given files. An empty path skips
+// that layer.
+func NewSettings(userPath, repoPath string) (*Settings, error) {
+	s := &Settings{lookupEnv: os.LookupEnv}
+	for _, l := range []struct {
+		source Source
+		path   string
+	}{{SourceUser, userPath}, {SourceRepo, repoPath}} {
+		if l.path == "" {
+			continue
+		}
+		values, err := readCommands(l.path)
+		if err != nil {
+			return nil, err
+		}
+		s.layers = append(s.layers, settingsLayer{source: l.source, path: l.path, values: values})
+	}
+	return s, nil
+}
+
+// Lookup returns the value of key from the highest-precedence layer that
+// sets it: environment, then repository, then user file.
+func (s *Settings) Lookup(key string) (Setting, bool) {
+	env := EnvVar(key)
+	if value, ok := s.lookupEnv(env); ok {
+		return Setting{Value: value, Source: SourceEnv, Origin: env}, true
+	}
+	for i := len(s.layers) - 1; i >= 0; i-- {
+		l := s.layers[i]
+		if value, ok := l.values[key]; ok {
+			return Setting{Value: value, Source: l.source, Origin: l.path}, true
+		}
+	}
+	return Setting{}, false
+}
+
+// Keys returns every key set in the config files, sorted.
+func (s *Settings) Keys() []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, l := range s.layers {
+		for key := range l.values {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var envUnsafeRe = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// EnvVar returns the environment variable overriding key, such as
+// GRIMORIO_SENDING_BASE for "sending.base".
+func EnvVar(key string) string {
+	return EnvPrefix + envUnsafeRe.ReplaceAllString(strings.ToUpper(key), "_")
+}
+
+// readCommands reads the [commands] table of a config file as flat keys.
+func readCommands(path string) (map[string]string, error) {
+	var file struct {
+		Commands map[string]any `toml:"commands"`
+	}
+	if _, err := toml.DecodeFile(path, &file); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]string{}, nil
+		}
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flatten("", file.Commands, values); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, nil
+}
+
+func flatten(prefix string, table map[string]any, values map[string]string) error {
+	for name, v := range table {
+		key := prefix + name
+		if sub, ok := v.(map[string]any); ok {
+			if err := flatten(key+".", sub, values); err != nil {
+				return err
+			}
+			continue
+		}
+		value, err := formatValue(v)
+		if err != nil {
+			return fmt.Errorf("commands.%s: %w", key, err)
+		}
+		values[key] = value
+	}
+	return nil
+}
+
+// formatValue renders a TOML value the way it would be passed as a flag.
+// Arrays become comma-separated lists.
+func formatValue(v any) (string, error) {
+	switch val := v.(type) {
+	case string:
+		return val, nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	case int64:
+		return strconv.FormatInt(val, 10), nil
+	case float64:
+		return strconv.FormatFloat(val, 'g', -1, 64), nil
+	case []any:
+		parts := make([]string, len(val))
+		for i, item := range val {
+			s, err := formatValue(item)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return strings.Join(parts, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", v)
+	}
+}
+
+// tableHeaderRe matches a TOML table header, ignoring a trailing comment.
+var tableHeaderRe = regexp.MustCompile(`^\s*\[([^\[\]]+)\]\s*(#.*)?$`)
+
+// SetValue writes key = value into the [commands] table of the config file
+// at path, creating the file if needed. Only the affected line changes, so
+// comments and the rest of the file are kept. value is a string, bool,
+// int64, float64 or []string.
+func SetValue(path, key string, value any) error {
+	encoded, err := encodeValue(value)
+	if err != nil {
+		return err
+	}
+
+	table := "commands"
+	name := key
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		table += "." + key[:i]
+		name = key[i+1:]
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	updated := setLine(string(content), table, name, name+" = "+encoded)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(updated), 0o644)
+}
+
+// setLine replaces the assignment of name in table, or adds it at the end
+// of the table, or adds the table at the end of the file.
+func setLine(content, table, name, line string) string {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	if content == "" {
+		lines = nil
+	}
+	assignRe := regexp.MustCompile(`^\s*"?` + regexp.QuoteMeta(name) + `"?\s*=`)
+
+	start := -1
+	for i, l := range lines {
+		if m := tableHeaderRe.FindStringSubmatch(l); m != nil && strings.TrimSpace(m[1]) == table {
+			start = i
+			break
+		}
+	}
+
+	if start < 0 {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "["+table+"]", line)
+		return strings.Join(lines, "\n") + "\n"
+	}
+
+	end := len(lines)
+	for i := start + 1; i < len(lines); i++ {
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), "[") {
+			end = i
+			break
+		}
+		if assignRe.MatchString(lines[i]) {
+			lines[i] = line
+			return strings.Join(lines, "\n") + "\n"
+		}
+	}
+
+	// Insert after the last non-blank line of the table.
+	at := end
+	for at > start+1 && strings.TrimSpace(lines[at-1]) == "" {
+		at--
+	}
+	lines = append(lines[:at], append([]string{line}, lines[at:]...)...)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func encodeValue(value any) (string, error) {
+	switch val := value.(type) {
+	case string:
+		return strconv.Quote(val), nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	case int64:
+		return strconv.FormatInt(val, 10), nil
+	case float64:
+		return strconv.FormatFloat(val, 'g', -1, 64), nil
+	case []string:
+		parts := make([]string, len(val))
+		for i, s := range val {
+			parts[i] = strconv.Quote(s)
+		}
+		return "[" + strings.Join(parts, ", ") + "]", nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", value)
+	}
+}
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSettingsLayers(t *testing.T) {
+	dir := t.TempDir()
+	userPath := filepath.Join(dir, "user.toml")
+	repoPath := filepath.Join(dir, "repo.toml")
+
+	writeFile(t, userPath, `[commands]
+no-cache = true
+
+[commands.sending]
+base = "develop"
+dry-run = true
+
+[commands.conjure]
+transport = ["http", "grpc"]
+`)
+	writeFile(t, repoPath, `[scoring.budgets]
+scrying = 250
+
+[commands.sending]
+base = "trunk"
+
+[commands.diff.explain]
+command = "scrying"
+`)
+
+	s, err := NewSettings(userPath, repoPath)
+	if err != nil {
+		t.Fatalf("NewSettings() error = %v", err)
+	}
+	env := map[string]string{"GRIMORIO_SENDING_DRY_RUN": "false"}
+	s.lookupEnv = func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+
+	tests := []struct {
+		key    string
+		value  string
+		source Source
+	}{
+		{"no-cache", "true", SourceUser},
+		{"sending.base", "trunk", SourceRepo},
+		{"sending.dry-run", "false", SourceEnv},
+		{"conjure.transport", "http,grpc", SourceUser},
+		{"diff.explain.command", "scrying", SourceRepo},
+	}
+	for _, tt := range tests {
+		got, ok := s.Lookup(tt.key)
+		if !ok {
+			t.Errorf("Lookup(%q) not found", tt.key)
+			continue
+		}
+		if got.Value != tt.value || got.Source != tt.source {
+			t.Errorf("Lookup(%q) = %q from %s, want %q from %s", tt.key, got.Value, got.Source, tt.value, tt.source)
+		}
+	}
+
+	if _, ok := s.Lookup("scrying.model"); ok {
+		t.Error("Lookup(scrying.model) found, want missing")
+	}
+
+	want := []string{"conjure.transport", "diff.explain.command", "no-cache", "sending.base", "sending.dry-run"}
+	keys := s.Keys()
+	if len(keys) != len(want) {
+		t.Fatalf("Keys() = %v, want %v", keys, want)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Errorf("Keys() = %v, want %v", keys, want)
+		}
+	}
+}
+
+func TestSettingsMissingAndInvalid(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewSettings(filepath.Join(dir, "missing.toml"), ""); err != nil {
+		t.Errorf("NewSettings() with missing file error = %v", err)
+	}
+
+	bad := filepath.Join(dir, "bad.toml")
+	writeFile(t, bad, "[commands\n")
+	if _, err := NewSettings(bad, ""); err == nil {
+		t.Error("NewSettings() with invalid file expected error")
+	}
+}
+
+func TestEnvVar(t *testing.T) {
+	tests := map[string]string{
+		"sending.base":         "GRIMORIO_SENDING_BASE",
+		"modify-memory.model":  "GRIMORIO_MODIFY_MEMORY_MODEL",
+		"diff.explain.command": "GRIMORIO_DIFF_EXPLAIN_COMMAND",
+		"no-cache":             "GRIMORIO_NO_CACHE",
+	}
+	for key, want := range tests {
+		if got := EnvVar(key); got != want {
+			t.Errorf("EnvVar(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestSetValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", FileName)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, path, `# repo settings
+[scoring.budgets]
+scrying = 250
+
+[commands.sending]
+base = "main" # old
+dry-run = false
+
+[redaction]
+allow = []
+`)
+
+	steps := []struct {
+		key   string
+		value any
+	}{
+		{"sending.base", "develop"},
+		{"sending.description", "weekly release"},
+		{"conjure.transport", []string{"http", "grpc"}},
+		{"no-cache", true},
+		{"dashboard.port", int64(9090)},
+	}
+	for _, step := range steps {
+		if err := SetValue(path, step.key, step.value); err != nil {
+			t.Fatalf("SetValue(%q) error = %v", step.key, err)
+		}
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# repo settings
+[scoring.budgets]
+scrying = 250
+
+[commands.sending]
+base = "develop"
+dry-run = false
+description = "weekly release"
+
+[redaction]
+allow = []
+
+[commands.conjure]
+transport = ["http", "grpc"]
+
+[commands]
+no-cache = true
+
+[commands.dashboard]
+port = 9090
+`
+	if string(got) != want {
+		t.Errorf("file =\n%s\nwant\n%s", got, want)
+	}
+
+	s, err := NewSettings("", path)
+	if err != nil {
+		t.Fatalf("NewSettings() error = %v", err)
+	}
+	s.lookupEnv = func(string) (string, bool) { return "", false }
+	if v, _ := s.Lookup("conjure.transport"); v.Value != "http,grpc" {
+		t.Errorf("conjure.transport = %q, want http,grpc", v.Value)
+	}
+
+	cfg, err := Load(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Scoring.Budgets["scrying"] != 250 {
+		t.Errorf("scoring config lost: %+v", cfg.Scoring)
+	}
+}
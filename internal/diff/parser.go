@@ -14,14 +14,29 @@ var (
 	// Matches: Binary files ... differ
 	binaryRe = regexp.MustCompile(`^Binary files .+ differ$`)
 	// Matches: new file mode
-	newFileRe = regexp.MustCompile(`^new file mode`)
+	newFileRe = regexp.MustCompile(`^new file mode (\d+)`)
 	// Matches: deleted file mode
-	deletedFileRe = regexp.MustCompile(`^deleted file mode`)
+	deletedFileRe = regexp.MustCompile(`^deleted file mode (\d+)`)
+	// Matches: old mode/new mode
+	oldModeRe = regexp.MustCompile(`^old mode (\d+)$`)
+	newModeRe = regexp.MustCompile(`^new mode (\d+)$`)
+	// Matches: index abc123..def456 100644
+	indexRe = regexp.MustCompile(`^index ([0-9a-f]+)\.\.([0-9a-f]+)(?: (\d+))?$`)
 	// Matches: rename from/to
 	renameFromRe = regexp.MustCompile(`^rename from (.+)$`)
 	renameToRe   = regexp.MustCompile(`^rename to (.+)$`)
+	// Matches: copy from/to
+	copyFromRe = regexp.MustCompile(`^copy from (.+)$`)
+	copyToRe   = regexp.MustCompile(`^copy to (.+)$`)
+	// Matches: similarity index 95%
+	similarityRe = regexp.MustCompile(`^similarity index (\d+)%$`)
+	// Matches: -Subproject commit abc123 inside a submodule hunk
+	subprojectRe = regexp.MustCompile(`^([-+])Subproject commit ([0-9a-f]+)`)
 )
 
+// ModeSubmodule is the git file mode of a submodule (gitlink) entry.
+const ModeSubmodule = "160000"
+
 // Parse parses a unified diff into structured FileDiff objects.
 func Parse(diffText string) []FileDiff {
 	if diffText == "" {
@@ -46,6 +61,10 @@ func Parse(diffText string) []FileDiff {
 	flushFile := func() {
 		flushHunk()
 		if currentFile != nil {
+			// Submodule hunks only carry the commit ids, already recorded
+			if currentFile.IsSubmodule {
+				currentFile.Hunks = nil
+			}
 			files = append(files, *currentFile)
 			currentFile = nil
 		}
@@ -72,21 +91,8 @@ func Parse(diffText string) []FileDiff {
 			continue
 		}
 
-		// Check for new file
-		if newFileRe.MatchString(line) {
-			currentFile.IsNew = true
-			continue
-		}
-
-		// Check for deleted file
-		if deletedFileRe.MatchString(line) {
-			currentFile.IsDelete = true
-			continue
-		}
-
-		// Check for rename
-		if renameFromRe.MatchString(line) || renameToRe.MatchString(line) {
-			currentFile.IsRename = true
+		// Extended headers only appear before the first hunk
+		if currentHunk == nil && parseExtendedHeader(currentFile, line) {
 			continue
 		}
 
@@ -130,6 +136,14 @@ func Parse(diffText string) []FileDiff {
 
 		// Hunk content lines
 		if currentHunk != nil {
+			if matches := subprojectRe.FindStringSubmatch(line); matches != nil {
+				currentFile.IsSubmodule = true
+				if matches[1] == "-" {
+					currentFile.OldCommit = matches[2]
+				} else {
+					currentFile.NewCommit = matches[2]
+				}
+			}
 			hunkContent.WriteString(line)
 			hunkContent.WriteString("\n")
 		}
@@ -139,6 +153,66 @@ func Parse(diffText string) []FileDiff {
 	return files
 }
 
+// parseExtendedHeader records a git extended header line on the file diff.
+// It reports whether the line was recognized.
+func parseExtendedHeader(fd *FileDiff, line string) bool {
+	if matches := newFileRe.FindStringSubmatch(line); matches != nil {
+		fd.IsNew = true
+		fd.NewMode = matches[1]
+		fd.IsSubmodule = fd.IsSubmodule || matches[1] == ModeSubmodule
+		return true
+	}
+	if matches := deletedFileRe.FindStringSubmatch(line); matches != nil {
+		fd.IsDelete = true
+		fd.OldMode = matches[1]
+		fd.IsSubmodule = fd.IsSubmodule || matches[1] == ModeSubmodule
+		return true
+	}
+	if matches := oldModeRe.FindStringSubmatch(line); matches != nil {
+		fd.OldMode = matches[1]
+		return true
+	}
+	if matches := newModeRe.FindStringSubmatch(line); matches != nil {
+		fd.NewMode = matches[1]
+		return true
+	}
+	if matches := indexRe.FindStringSubmatch(line); matches != nil {
+		// The mode is only present when unchanged
+		if matches[3] != "" {
+			fd.OldMode, fd.NewMode = matches[3], matches[3]
+			fd.IsSubmodule = fd.IsSubmodule || matches[3] == ModeSubmodule
+		}
+		return true
+	}
+	if matches := similarityRe.FindStringSubmatch(line); matches != nil {
+		if n, err := strconv.Atoi(matches[1]); err == nil {
+			fd.Similarity = n
+		}
+		return true
+	}
+	if matches := renameFromRe.FindStringSubmatch(line); matches != nil {
+		fd.IsRename = true
+		fd.OldPath = matches[1]
+		return true
+	}
+	if matches := renameToRe.FindStringSubmatch(line); matches != nil {
+		fd.IsRename = true
+		fd.NewPath = matches[1]
+		return true
+	}
+	if matches := copyFromRe.FindStringSubmatch(line); matches != nil {
+		fd.IsCopy = true
+		fd.OldPath = matches[1]
+		return true
+	}
+	if matches := copyToRe.FindStringSubmatch(line); matches != nil {
+		fd.IsCopy = true
+		fd.NewPath = matches[1]
+		return true
+	}
+	return false
+}
+
 // CountLines counts the total number of changed lines in a diff.
 func CountLines(diffText string) int {
 	count := 0
@@ -192,3 +192,96 @@ Binary files a/image.png and b/image.png differ`
 		t.Error("expected IsBinary to be true")
 	}
 }
+
+func TestParseRename(t *testing.T) {
+	diff := `diff --git a/old/name.go b/new/name.go
+similarity index 100%
+rename from old/name.go
+rename to new/name.go`
+
+	files := Parse(diff)
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, got %d", len(files))
+	}
+
+	fd := files[0]
+	if !fd.IsRename || fd.Similarity != 100 {
+		t.Errorf("expected rename with 100%% similarity, got IsRename=%v Similarity=%d", fd.IsRename, fd.Similarity)
+	}
+	if fd.OldPath != "old/name.go" || fd.NewPath != "new/name.go" {
+		t.Errorf("unexpected paths %q -> %q", fd.OldPath, fd.NewPath)
+	}
+	if len(fd.Hunks) != 0 {
+		t.Errorf("expected no hunks, got %d", len(fd.Hunks))
+	}
+}
+
+func TestParseCopy(t *testing.T) {
+	diff := `diff --git a/a.go b/b.go
+similarity index 90%
+copy from a.go
+copy to b.go
+index 1234567..abcdef0 100644
+--- a/a.go
++++ b/b.go
+@@ -1,2 +1,2 @@
+-package a
++package b`
+
+	files := Parse(diff)
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, got %d", len(files))
+	}
+
+	fd := files[0]
+	if !fd.IsCopy || fd.IsRename || fd.Similarity != 90 {
+		t.Errorf("expected copy with 90%% similarity, got %+v", fd)
+	}
+	if fd.OldMode != "100644" || fd.ModeChanged() {
+		t.Errorf("expected unchanged mode 100644, got %q -> %q", fd.OldMode, fd.NewMode)
+	}
+	if len(fd.Hunks) != 1 {
+		t.Errorf("expected 1 hunk, got %d", len(fd.Hunks))
+	}
+}
+
+func TestParseModeChange(t *testing.T) {
+	diff := `diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755`
+
+	files := Parse(diff)
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, got %d", len(files))
+	}
+
+	if !files[0].ModeChanged() || files[0].NewMode != "100755" {
+		t.Errorf("expected mode change to 100755, got %q -> %q", files[0].OldMode, files[0].NewMode)
+	}
+}
+
+func TestParseSubmodule(t *testing.T) {
+	diff := `diff --git a/vendor/lib b/vendor/lib
+index 1111111..2222222 160000
+--- a/vendor/lib
++++ b/vendor/lib
+@@ -1 +1 @@
+-Subproject commit 1111111111111111111111111111111111111111
++Subproject commit 2222222222222222222222222222222222222222`
+
+	files := Parse(diff)
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, got %d", len(files))
+	}
+
+	fd := files[0]
+	if !fd.IsSubmodule {
+		t.Fatal("expected IsSubmodule to be true")
+	}
+	if fd.OldCommit != "1111111111111111111111111111111111111111" || fd.NewCommit != "2222222222222222222222222222222222222222" {
+		t.Errorf("unexpected commits %q -> %q", fd.OldCommit, fd.NewCommit)
+	}
+	if len(fd.Hunks) != 0 {
+		t.Errorf("expected submodule hunks to be dropped, got %d", len(fd.Hunks))
+	}
+}
@@ -9,23 +9,35 @@ import (
 	"strings"
 	"time"
 
+	"github.com/emiliopalmerini/grimorio/internal/git"
+	"github.com/emiliopalmerini/grimorio/internal/goast"
 	"github.com/emiliopalmerini/grimorio/internal/lsp"
+	"github.com/emiliopalmerini/grimorio/internal/prompt"
 )
 
 // Options configures the prioritization behavior.
 type Options struct {
-	MaxHighPriorityLines int           // Maximum lines in high-priority section (default: 400)
-	IncludeSummary       bool          // Include summary of low-priority changes (default: true)
-	LSPTimeout           time.Duration // Timeout for LSP operations (default: 5s)
-	WorkDir              string        // Working directory for file paths
+	MaxHighPriorityLines  int           // Maximum lines in high-priority section (default: 400 without a token budget)
+	MaxHighPriorityTokens int           // Maximum estimated tokens in high-priority section (0 = no limit)
+	IncludeSummary        bool          // Include summary of low-priority changes (default: true)
+	LSPTimeout            time.Duration // Timeout for LSP operations (default: 5s)
+	WorkDir               string        // Working directory for file paths
+	BaseRev               string        // Revision holding the old side of the diff, the merge base for branch diffs (default: HEAD)
+	NewRev                string        // Revision holding the new side: IndexRev for staged diffs, empty for the working tree
+	Breaking              []Location    // API-breaking locations; hunks touching them are always kept
+	Profile               *Profile      // Scoring profile (default: DefaultProfile())
 }
 
+// IndexRev is the NewRev of staged diffs, whose new side is the index.
+const IndexRev = ":"
+
 // DefaultOptions returns sensible default options.
 func DefaultOptions() Options {
 	return Options{
 		MaxHighPriorityLines: 400,
 		IncludeSummary:       true,
 		LSPTimeout:           5 * time.Second,
+		BaseRev:              "HEAD",
 	}
 }
 
@@ -35,11 +47,15 @@ type scoredHunk struct {
 	FileDiff *FileDiff
 }
 
+// HunkExplanation describes how a hunk was scored and where it ended up.
+type HunkExplanation struct {
+	Hunk         Hunk
+	HighPriority bool
+}
+
 // Prioritize analyzes a raw diff and returns a prioritized version.
 func Prioritize(rawDiff string, opts Options) (*PrioritizedDiff, error) {
-	if opts.MaxHighPriorityLines == 0 {
-		opts.MaxHighPriorityLines = 400
-	}
+	opts = withDefaults(opts)
 
 	files := Parse(rawDiff)
 	if len(files) == 0 {
@@ -48,13 +64,66 @@ func Prioritize(rawDiff string, opts Options) (*PrioritizedDiff, error) {
 		}, nil
 	}
 
+	highPriority, lowPriority := partition(files, opts)
+
+	// Build the result
+	result := &PrioritizedDiff{
+		Stats: computeStats(files, opts.Profile),
+	}
+
+	// Generate high-priority diff output
+	result.HighPriority = buildHighPriorityDiff(highPriority, files)
+
+	// Generate summary for low-priority changes and hunk-less file changes
+	if opts.IncludeSummary {
+		result.Summary = buildSummary(lowPriority, opts.Profile) + buildFileChanges(files, highPriority)
+	}
+
+	return result, nil
+}
+
+// Explain scores a raw diff like Prioritize and returns every hunk in
+// priority order, with its score breakdown.
+func Explain(rawDiff string, opts Options) []HunkExplanation {
+	opts = withDefaults(opts)
+
+	highPriority, lowPriority := partition(Parse(rawDiff), opts)
+
+	var result []HunkExplanation
+	for _, sh := range highPriority {
+		result = append(result, HunkExplanation{Hunk: sh.Hunk, HighPriority: true})
+	}
+	for _, sh := range lowPriority {
+		result = append(result, HunkExplanation{Hunk: sh.Hunk})
+	}
+	return result
+}
+
+func withDefaults(opts Options) Options {
+	if opts.MaxHighPriorityLines == 0 && opts.MaxHighPriorityTokens == 0 {
+		opts.MaxHighPriorityLines = 400
+	}
+	if opts.LSPTimeout == 0 {
+		opts.LSPTimeout = 5 * time.Second
+	}
+	if opts.Profile == nil {
+		opts.Profile = DefaultProfile()
+	}
+	return opts
+}
+
+// partition scores all hunks and splits them into high- and low-priority
+// sets, each sorted by descending score.
+func partition(files []FileDiff, opts Options) (high, low []scoredHunk) {
 	// Score all hunks
 	ctx, cancel := context.WithTimeout(context.Background(), opts.LSPTimeout)
 	defer cancel()
 
 	for i := range files {
-		symbols := getSymbolsForFile(ctx, &files[i], opts.WorkDir)
-		ScoreFileDiff(&files[i], symbols)
+		symbols := getSymbolsForFile(ctx, &files[i], opts)
+		files[i].ChangedSignatures = getChangedSignatures(&files[i], opts)
+		opts.Profile.ScoreFileDiff(&files[i], symbols)
+		markBreaking(&files[i], opts.Breaking)
 	}
 
 	// Collect all hunks and sort by score
@@ -65,90 +134,152 @@ func Prioritize(rawDiff string, opts Options) (*PrioritizedDiff, error) {
 		}
 	}
 
-	sort.Slice(allHunks, func(i, j int) bool {
+	sort.SliceStable(allHunks, func(i, j int) bool {
+		pi, pj := isPinned(&allHunks[i].Hunk), isPinned(&allHunks[j].Hunk)
+		if pi != pj {
+			return pi
+		}
 		return allHunks[i].Hunk.Score > allHunks[j].Hunk.Score
 	})
 
 	// Partition into high-priority and low-priority
-	var highPriority []scoredHunk
-	var lowPriority []scoredHunk
-	currentLines := 0
-
+	currentLines, currentTokens := 0, 0
 	for _, sh := range allHunks {
 		hunkLines := CountHunkLines(&sh.Hunk)
-		if currentLines+hunkLines <= opts.MaxHighPriorityLines {
-			highPriority = append(highPriority, sh)
+		hunkTokens := prompt.EstimateTokens(sh.Hunk.Content)
+		if sh.Hunk.Breakdown.Ignored && !sh.Hunk.Breaking {
+			low = append(low, sh)
+			continue
+		}
+		fits := (opts.MaxHighPriorityLines == 0 || currentLines+hunkLines <= opts.MaxHighPriorityLines) &&
+			(opts.MaxHighPriorityTokens == 0 || currentTokens+hunkTokens <= opts.MaxHighPriorityTokens)
+		if isPinned(&sh.Hunk) || fits {
+			high = append(high, sh)
 			currentLines += hunkLines
+			currentTokens += hunkTokens
 		} else {
-			lowPriority = append(lowPriority, sh)
+			low = append(low, sh)
 		}
 	}
 
-	// Build the result
-	result := &PrioritizedDiff{
-		Stats: computeStats(files),
-	}
+	return high, low
+}
 
-	// Generate high-priority diff output
-	result.HighPriority = buildHighPriorityDiff(highPriority, files)
+// isPinned reports whether a hunk is always kept in the high-priority set.
+func isPinned(h *Hunk) bool {
+	return h.Breaking || h.Breakdown.Pinned
+}
 
-	// Generate summary for low-priority changes
-	if opts.IncludeSummary && len(lowPriority) > 0 {
-		result.Summary = buildSummary(lowPriority)
+// markBreaking flags hunks whose line range contains a breaking location.
+func markBreaking(fd *FileDiff, locations []Location) {
+	for _, loc := range locations {
+		for i := range fd.Hunks {
+			h := &fd.Hunks[i]
+			if loc.Old && loc.Path == fd.OldPath && overlaps(loc.Line, loc.Line, h.OldStart, h.OldStart+h.OldCount-1) {
+				h.Breaking = true
+			}
+			if !loc.Old && loc.Path == fd.NewPath && overlaps(loc.Line, loc.Line, h.NewStart, h.NewStart+h.NewCount-1) {
+				h.Breaking = true
+			}
+		}
 	}
-
-	return result, nil
 }
 
 // getSymbolsForFile attempts to get LSP symbols for a file.
-func getSymbolsForFile(ctx context.Context, fd *FileDiff, workDir string) []lsp.DocumentSymbol {
+// Go files fall back to the built-in go/ast extractor when gopls is missing.
+func getSymbolsForFile(ctx context.Context, fd *FileDiff, opts Options) []lsp.DocumentSymbol {
 	if fd.IsBinary || fd.IsDelete {
 		return nil
 	}
 
-	path := fd.NewPath
-	if workDir != "" {
-		path = filepath.Join(workDir, path)
+	absPath, content, err := readNewFile(fd, opts)
+	if err != nil {
+		return nil
 	}
 
-	// Check if file exists
-	absPath, err := filepath.Abs(path)
-	if err != nil {
+	lang := lsp.DetectLanguage(absPath)
+	if lang == nil {
 		return nil
 	}
 
-	content, err := os.ReadFile(absPath)
+	if !lang.Available() {
+		if isGoFile(absPath) {
+			symbols, _ := goast.Symbols(content)
+			return symbols
+		}
+		return nil
+	}
+
+	symbols, err := lsp.FileSymbols(ctx, absPath, content, lang)
 	if err != nil {
 		return nil
 	}
+	return symbols
+}
 
-	lang := lsp.DetectLanguage(path)
-	if lang == nil || !lang.Available() {
+// getChangedSignatures compares a Go file against its version at opts.BaseRev
+// and returns the exported symbols whose signature changed.
+func getChangedSignatures(fd *FileDiff, opts Options) []string {
+	if fd.IsBinary || fd.IsNew || fd.IsDelete || !isGoFile(fd.NewPath) {
 		return nil
 	}
 
-	client, err := lsp.NewClient(lang)
+	_, newContent, err := readNewFile(fd, opts)
 	if err != nil {
 		return nil
 	}
-	defer client.Close()
 
-	if err := client.Initialize(ctx, filepath.Dir(absPath)); err != nil {
+	baseRev := opts.BaseRev
+	if baseRev == "" {
+		baseRev = "HEAD"
+	}
+	oldContent, err := git.ShowFile(baseRev, fd.OldPath)
+	if err != nil {
 		return nil
 	}
 
-	uri := "file://" + absPath
-	if err := client.OpenDocument(uri, lang.Name, string(content)); err != nil {
+	changed, err := goast.ChangedSignatures(oldContent, newContent)
+	if err != nil {
 		return nil
 	}
-	defer client.CloseDocument(uri)
+	return changed
+}
+
+// readNewFile reads the new side of a file diff from opts.NewRev, or the
+// working tree, and returns it with the file's absolute path.
+func readNewFile(fd *FileDiff, opts Options) (string, string, error) {
+	path := fd.NewPath
+	if opts.WorkDir != "" {
+		path = filepath.Join(opts.WorkDir, path)
+	}
 
-	symbols, err := client.DocumentSymbols(uri)
+	absPath, err := filepath.Abs(path)
 	if err != nil {
-		return nil
+		return "", "", err
 	}
 
-	return symbols
+	if rev := opts.NewRev; rev != "" {
+		if rev == IndexRev {
+			// git show :path reads the index
+			rev = ""
+		}
+		content, err := git.ShowFile(rev, fd.NewPath)
+		if err != nil {
+			return "", "", err
+		}
+		return absPath, content, nil
+	}
+
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return "", "", err
+	}
+
+	return absPath, string(content), nil
+}
+
+func isGoFile(path string) bool {
+	return filepath.Ext(path) == ".go"
 }
 
 // buildHighPriorityDiff reconstructs the diff for high-priority hunks.
@@ -179,12 +310,7 @@ func buildHighPriorityDiff(hunks []scoredHunk, allFiles []FileDiff) string {
 
 		// Write file header
 		result.WriteString(fmt.Sprintf("diff --git a/%s b/%s\n", fd.OldPath, fd.NewPath))
-		if fd.IsNew {
-			result.WriteString("new file mode 100644\n")
-		}
-		if fd.IsDelete {
-			result.WriteString("deleted file mode 100644\n")
-		}
+		writeExtendedHeaders(&result, &fd)
 
 		// Handle /dev/null for new and deleted files
 		oldPath := "a/" + fd.OldPath
@@ -207,8 +333,106 @@ func buildHighPriorityDiff(hunks []scoredHunk, allFiles []FileDiff) string {
 	return result.String()
 }
 
+// writeExtendedHeaders writes the git extended header lines of a file diff.
+func writeExtendedHeaders(sb *strings.Builder, fd *FileDiff) {
+	if fd.IsNew {
+		sb.WriteString(fmt.Sprintf("new file mode %s\n", modeOrDefault(fd.NewMode)))
+	}
+	if fd.IsDelete {
+		sb.WriteString(fmt.Sprintf("deleted file mode %s\n", modeOrDefault(fd.OldMode)))
+	}
+	if fd.ModeChanged() {
+		sb.WriteString(fmt.Sprintf("old mode %s\nnew mode %s\n", fd.OldMode, fd.NewMode))
+	}
+	if fd.IsRename || fd.IsCopy {
+		verb := "rename"
+		if fd.IsCopy {
+			verb = "copy"
+		}
+		if fd.Similarity > 0 {
+			sb.WriteString(fmt.Sprintf("similarity index %d%%\n", fd.Similarity))
+		}
+		sb.WriteString(fmt.Sprintf("%s from %s\n%s to %s\n", verb, fd.OldPath, verb, fd.NewPath))
+	}
+}
+
+func modeOrDefault(mode string) string {
+	if mode == "" {
+		return "100644"
+	}
+	return mode
+}
+
+// describeFile returns a one-line description of a file's rename, copy,
+// mode or submodule change, or "" for a plain content change.
+func describeFile(fd *FileDiff) string {
+	var parts []string
+
+	switch {
+	case fd.IsSubmodule:
+		parts = append(parts, fmt.Sprintf("submodule %s: %s -> %s",
+			fd.NewPath, shortCommit(fd.OldCommit), shortCommit(fd.NewCommit)))
+	case fd.IsRename || fd.IsCopy:
+		verb := "renamed"
+		if fd.IsCopy {
+			verb = "copied"
+		}
+		desc := fmt.Sprintf("%s %s -> %s", verb, fd.OldPath, fd.NewPath)
+		if fd.Similarity > 0 {
+			desc += fmt.Sprintf(" (%d%% similar)", fd.Similarity)
+		}
+		parts = append(parts, desc)
+	case fd.IsBinary:
+		parts = append(parts, "binary "+fd.NewPath)
+	}
+
+	if fd.ModeChanged() {
+		if len(parts) == 0 {
+			parts = append(parts, fd.NewPath)
+		}
+		parts = append(parts, fmt.Sprintf("mode %s -> %s", fd.OldMode, fd.NewMode))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+func shortCommit(commit string) string {
+	if commit == "" {
+		return "none"
+	}
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
+
+// buildFileChanges lists renames, copies, mode and submodule changes of
+// files that have no hunk in the high-priority diff, where their headers
+// would already describe them.
+func buildFileChanges(files []FileDiff, highPriority []scoredHunk) string {
+	shown := make(map[string]bool)
+	for _, sh := range highPriority {
+		shown[sh.FileDiff.NewPath] = true
+	}
+
+	var lines []string
+	for i := range files {
+		if shown[files[i].NewPath] {
+			continue
+		}
+		if desc := describeFile(&files[i]); desc != "" {
+			lines = append(lines, desc)
+		}
+	}
+
+	if len(lines) == 0 {
+		return ""
+	}
+	return "\n[File changes]\n- " + strings.Join(lines, "\n- ")
+}
+
 // buildSummary generates a summary of low-priority changes.
-func buildSummary(lowPriority []scoredHunk) string {
+func buildSummary(lowPriority []scoredHunk, profile *Profile) string {
 	if len(lowPriority) == 0 {
 		return ""
 	}
@@ -216,7 +440,7 @@ func buildSummary(lowPriority []scoredHunk) string {
 	// Group by category
 	categoryFiles := make(map[FileCategory]map[string]int) // category -> file -> lines
 	for _, sh := range lowPriority {
-		cat := CategorizeFile(sh.Hunk.FilePath)
+		cat := profile.Categorize(sh.Hunk.FilePath)
 		if categoryFiles[cat] == nil {
 			categoryFiles[cat] = make(map[string]int)
 		}
@@ -258,11 +482,11 @@ func buildSummary(lowPriority []scoredHunk) string {
 }
 
 // computeStats calculates aggregate statistics for a diff.
-func computeStats(files []FileDiff) DiffStats {
+func computeStats(files []FileDiff, profile *Profile) DiffStats {
 	var stats DiffStats
 
 	for _, fd := range files {
-		cat := CategorizeFile(fd.NewPath)
+		cat := profile.Categorize(fd.NewPath)
 		fileLines := 0
 
 		for _, h := range fd.Hunks {
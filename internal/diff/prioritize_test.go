@@ -0,0 +1,186 @@
+package diff
+
+import (
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/emiliopalmerini/grimorio/internal/git"
+)
+
+func TestMarkBreaking(t *testing.T) {
+	fd := &FileDiff{
+		OldPath: "api.go",
+		NewPath: "api.go",
+		Hunks: []Hunk{
+			{FilePath: "api.go", OldStart: 1, OldCount: 3, NewStart: 1, NewCount: 3},
+			{FilePath: "api.go", OldStart: 10, OldCount: 2, NewStart: 12, NewCount: 4},
+			{FilePath: "api.go", OldStart: 30, OldCount: 2, NewStart: 40, NewCount: 2},
+		},
+	}
+
+	markBreaking(fd, []Location{
+		{Path: "api.go", Line: 13},
+		{Path: "api.go", Line: 31, Old: true},
+		{Path: "other.go", Line: 2},
+	})
+
+	want := []bool{false, true, true}
+	for i, h := range fd.Hunks {
+		if h.Breaking != want[i] {
+			t.Errorf("hunk %d Breaking = %v, want %v", i, h.Breaking, want[i])
+		}
+	}
+}
+
+func TestPrioritizeFileChanges(t *testing.T) {
+	rawDiff := `diff --git a/old.txt b/new.txt
+similarity index 100%
+rename from old.txt
+rename to new.txt
+diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+diff --git a/vendor/lib b/vendor/lib
+index 1111111..2222222 160000
+--- a/vendor/lib
++++ b/vendor/lib
+@@ -1 +1 @@
+-Subproject commit 1111111111111111111111111111111111111111
++Subproject commit 2222222222222222222222222222222222222222`
+
+	pd, err := Prioritize(rawDiff, DefaultOptions())
+	if err != nil {
+		t.Fatalf("Prioritize() error = %v", err)
+	}
+
+	if pd.HighPriority != "" {
+		t.Errorf("expected no high-priority hunks, got %q", pd.HighPriority)
+	}
+	for _, want := range []string{
+		"renamed old.txt -> new.txt (100% similar)",
+		"run.sh, mode 100644 -> 100755",
+		"submodule vendor/lib: 1111111 -> 2222222",
+	} {
+		if !strings.Contains(pd.Summary, want) {
+			t.Errorf("summary missing %q:\n%s", want, pd.Summary)
+		}
+	}
+}
+
+func TestWriteExtendedHeaders(t *testing.T) {
+	var sb strings.Builder
+	writeExtendedHeaders(&sb, &FileDiff{
+		OldPath: "a.go", NewPath: "b.go",
+		IsRename: true, Similarity: 87,
+		OldMode: "100644", NewMode: "100755",
+	})
+
+	want := "old mode 100644\nnew mode 100755\nsimilarity index 87%\nrename from a.go\nrename to b.go\n"
+	if sb.String() != want {
+		t.Errorf("writeExtendedHeaders() = %q, want %q", sb.String(), want)
+	}
+}
+
+func TestPrioritizeTokenBudget(t *testing.T) {
+	rawDiff := `diff --git a/a.txt b/a.txt
+--- a/a.txt
++++ b/a.txt
+@@ -1,1 +1,2 @@
+ one
++` + strings.Repeat("word ", 40) + `
+diff --git a/b.txt b/b.txt
+--- a/b.txt
++++ b/b.txt
+@@ -1,1 +1,2 @@
+ one
++two`
+
+	opts := DefaultOptions()
+	opts.MaxHighPriorityLines = 0
+	opts.MaxHighPriorityTokens = 20
+
+	pd, err := Prioritize(rawDiff, opts)
+	if err != nil {
+		t.Fatalf("Prioritize() error = %v", err)
+	}
+
+	if strings.Contains(pd.HighPriority, "a.txt") {
+		t.Error("expected the hunk over the token budget to be left out")
+	}
+	if !strings.Contains(pd.HighPriority, "b.txt") {
+		t.Error("expected the small hunk to fit the token budget")
+	}
+}
+
+func TestGetChangedSignaturesStaged(t *testing.T) {
+	t.Chdir(t.TempDir())
+	run := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	run("init", "-q")
+	run("config", "user.email", "dev@example.com")
+	run("config", "user.name", "dev")
+	os.WriteFile("api.go", []byte("package api\n\nfunc New(name string) {}\n\nfunc Close() {}\n"), 0o644)
+	run("add", "-A")
+	run("commit", "-q", "-m", "base")
+
+	// Staged: New changes and Close is removed; the working tree then
+	// reverts New.
+	os.WriteFile("api.go", []byte("package api\n\nfunc New(name string, debug bool) {}\n"), 0o644)
+	run("add", "-A")
+	os.WriteFile("api.go", []byte("package api\n\nfunc New(name string) {}\n"), 0o644)
+
+	fd := &FileDiff{OldPath: "api.go", NewPath: "api.go"}
+	opts := DefaultOptions()
+	opts.NewRev = IndexRev
+	if got := getChangedSignatures(fd, opts); !reflect.DeepEqual(got, []string{"New"}) {
+		t.Errorf("getChangedSignatures(index) = %v, want [New]", got)
+	}
+	opts.NewRev = ""
+	if got := getChangedSignatures(fd, opts); got != nil {
+		t.Errorf("getChangedSignatures(working tree) = %v, want none", got)
+	}
+}
+
+func TestGetChangedSignaturesDivergedBase(t *testing.T) {
+	t.Chdir(t.TempDir())
+	run := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	run("init", "-q", "-b", "main")
+	run("config", "user.email", "dev@example.com")
+	run("config", "user.name", "dev")
+	os.WriteFile("api.go", []byte("package api\n\nfunc New(name string) {}\n\nfunc Close() {}\n"), 0o644)
+	run("add", "-A")
+	run("commit", "-q", "-m", "base")
+
+	// The branch changes New; main changes Close after the fork.
+	run("checkout", "-q", "-b", "feature")
+	os.WriteFile("api.go", []byte("package api\n\nfunc New(name string, debug bool) {}\n\nfunc Close() {}\n"), 0o644)
+	run("commit", "-q", "-am", "feature")
+	run("checkout", "-q", "main")
+	os.WriteFile("api.go", []byte("package api\n\nfunc New(name string) {}\n\nfunc Close(force bool) {}\n"), 0o644)
+	run("commit", "-q", "-am", "upstream")
+	run("checkout", "-q", "feature")
+
+	fork, err := git.MergeBase("main", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd := &FileDiff{OldPath: "api.go", NewPath: "api.go"}
+	opts := DefaultOptions()
+	opts.BaseRev = fork
+	opts.NewRev = "HEAD"
+	if got := getChangedSignatures(fd, opts); !reflect.DeepEqual(got, []string{"New"}) {
+		t.Errorf("getChangedSignatures(merge base) = %v, want [New]", got)
+	}
+}
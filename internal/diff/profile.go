@@ -0,0 +1,280 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/emiliopalmerini/grimorio/internal/config"
+	"github.com/emiliopalmerini/grimorio/internal/lsp"
+)
+
+// Path rule targets besides file categories.
+const (
+	TargetHigh   = "high"   // Always keep matching hunks in the high-priority section
+	TargetIgnore = "ignore" // Never show matching hunks in full, only in the summary
+)
+
+// PathRule overrides how files matching a glob are scored.
+// Globs without a slash match the file name; "**" matches any number of
+// directories.
+type PathRule struct {
+	Glob   string
+	Target string // A category name, TargetHigh or TargetIgnore
+}
+
+// Profile holds the weights and heuristics used to score hunks.
+type Profile struct {
+	Weights        map[string]float64 // Symbol kind (LSP name) to weight
+	DefaultWeight  float64
+	ExportedBonus  float64
+	SignatureBonus float64
+	MaxSizeBonus   float64
+	Multipliers    map[FileCategory]float64
+	Rules          []PathRule
+	Budgets        map[string]int // Command name to high-priority line budget
+}
+
+// ScoreBreakdown explains how a hunk's score was computed.
+type ScoreBreakdown struct {
+	Category       FileCategory
+	Rule           string // Glob of the matching path rule, if any
+	Multiplier     float64
+	Symbol         string // Highest-weighted symbol touched by the hunk
+	SymbolKind     string
+	SymbolWeight   float64
+	ExportedBonus  float64
+	SizeBonus      float64
+	SignatureBonus float64
+	Pinned         bool // Matched a TargetHigh rule
+	Ignored        bool // Matched a TargetIgnore rule
+}
+
+// Total returns the score described by the breakdown.
+func (b ScoreBreakdown) Total() float64 {
+	if b.Ignored {
+		return 0
+	}
+	return (b.SymbolWeight + b.ExportedBonus + b.SizeBonus + b.SignatureBonus) * b.Multiplier
+}
+
+// DefaultProfile returns the built-in scoring profile.
+func DefaultProfile() *Profile {
+	return &Profile{
+		Weights: map[string]float64{
+			"Function":    WeightFunction,
+			"Method":      WeightMethod,
+			"Class":       WeightClass,
+			"Struct":      WeightStruct,
+			"Interface":   WeightInterface,
+			"Constructor": WeightConstructor,
+			"Enum":        WeightEnum,
+			"Property":    WeightProperty,
+			"Field":       WeightField,
+			"Variable":    WeightVariable,
+			"Constant":    WeightConstant,
+			"Module":      WeightModule,
+			"Package":     WeightPackage,
+		},
+		DefaultWeight:  WeightDefault,
+		ExportedBonus:  BonusExported,
+		SignatureBonus: BonusSignatureChange,
+		MaxSizeBonus:   MaxSizeBonus,
+		Multipliers: map[FileCategory]float64{
+			CategorySource:    CategorySource.Multiplier(),
+			CategoryTest:      CategoryTest.Multiplier(),
+			CategoryConfig:    CategoryConfig.Multiplier(),
+			CategoryDoc:       CategoryDoc.Multiplier(),
+			CategoryGenerated: CategoryGenerated.Multiplier(),
+			CategoryUnknown:   CategoryUnknown.Multiplier(),
+		},
+		Budgets: map[string]int{},
+	}
+}
+
+// ProfileFromConfig builds a profile from the defaults and the [scoring]
+// section of the repository config.
+func ProfileFromConfig(cfg config.Scoring) (*Profile, error) {
+	p := DefaultProfile()
+
+	for _, rule := range cfg.Paths {
+		if rule.Target != TargetHigh && rule.Target != TargetIgnore {
+			if _, ok := ParseCategory(rule.Target); !ok {
+				return nil, fmt.Errorf("scoring.paths: unknown target %q for %q", rule.Target, rule.Glob)
+			}
+		}
+		p.Rules = append(p.Rules, PathRule{Glob: rule.Glob, Target: rule.Target})
+	}
+
+	for name, mult := range cfg.Multipliers {
+		cat, ok := ParseCategory(name)
+		if !ok {
+			return nil, fmt.Errorf("scoring.multipliers: unknown category %q", name)
+		}
+		p.Multipliers[cat] = mult
+	}
+
+	for name, weight := range cfg.Weights {
+		switch strings.ToLower(name) {
+		case "default":
+			p.DefaultWeight = weight
+		case "exported":
+			p.ExportedBonus = weight
+		case "signature":
+			p.SignatureBonus = weight
+		case "size":
+			p.MaxSizeBonus = weight
+		default:
+			kind := kindName(name)
+			if kind == "" {
+				return nil, fmt.Errorf("scoring.weights: unknown symbol kind %q", name)
+			}
+			p.Weights[kind] = weight
+		}
+	}
+
+	for command, budget := range cfg.Budgets {
+		p.Budgets[command] = budget
+	}
+
+	return p, nil
+}
+
+// LoadProfile loads the scoring profile of the current repository.
+func LoadProfile() (*Profile, error) {
+	cfg, err := config.LoadRepo()
+	if err != nil {
+		return nil, err
+	}
+	return ProfileFromConfig(cfg.Scoring)
+}
+
+// kindName maps a case-insensitive symbol kind to its LSP name.
+func kindName(name string) string {
+	for kind := range DefaultProfile().Weights {
+		if strings.EqualFold(kind, name) {
+			return kind
+		}
+	}
+	return ""
+}
+
+// Budget returns the configured line budget for a command, or fallback.
+func (p *Profile) Budget(command string, fallback int) int {
+	if b, ok := p.Budgets[command]; ok && b > 0 {
+		return b
+	}
+	return fallback
+}
+
+// Categorize returns the category of a file, honoring path rules.
+func (p *Profile) Categorize(filePath string) FileCategory {
+	cat, _ := p.classify(filePath)
+	return cat
+}
+
+// classify returns the file category and the first matching path rule.
+func (p *Profile) classify(filePath string) (FileCategory, *PathRule) {
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if !config.MatchGlob(rule.Glob, filePath) {
+			continue
+		}
+		if cat, ok := ParseCategory(rule.Target); ok {
+			return cat, rule
+		}
+		return CategorizeFile(filePath), rule
+	}
+	return CategorizeFile(filePath), nil
+}
+
+func (p *Profile) multiplier(cat FileCategory) float64 {
+	if m, ok := p.Multipliers[cat]; ok {
+		return m
+	}
+	return cat.Multiplier()
+}
+
+func (p *Profile) symbolWeight(kind string) float64 {
+	if w, ok := p.Weights[kind]; ok {
+		return w
+	}
+	return p.DefaultWeight
+}
+
+// ScoreHunk calculates the priority score for a hunk and records the
+// breakdown on it.
+func (p *Profile) ScoreHunk(hunk *Hunk, symbols []lsp.DocumentSymbol) float64 {
+	cat, rule := p.classify(hunk.FilePath)
+
+	b := ScoreBreakdown{
+		Category:   cat,
+		Multiplier: p.multiplier(cat),
+	}
+	if rule != nil {
+		b.Rule = rule.Glob
+		b.Pinned = rule.Target == TargetHigh
+		b.Ignored = rule.Target == TargetIgnore
+	}
+
+	// Base score from hunk size
+	b.SizeBonus = float64(CountHunkLines(hunk)) * 0.1
+	if b.SizeBonus > p.MaxSizeBonus {
+		b.SizeBonus = p.MaxSizeBonus
+	}
+
+	// Hunk affects lines NewStart to NewStart+NewCount-1
+	hunkStart := hunk.NewStart
+	hunkEnd := hunk.NewStart + hunk.NewCount - 1
+
+	var affectedSymbols []string
+	best := 0.0
+	for _, sym := range symbols {
+		if !overlaps(hunkStart, hunkEnd, sym.Line, sym.EndLine) {
+			continue
+		}
+		affectedSymbols = append(affectedSymbols, sym.Name)
+
+		weight := p.symbolWeight(sym.Kind)
+		bonus := 0.0
+		if isExported(sym.Name) {
+			bonus = p.ExportedBonus
+		}
+		if weight+bonus > best {
+			best = weight + bonus
+			b.Symbol, b.SymbolKind = sym.Name, sym.Kind
+			b.SymbolWeight, b.ExportedBonus = weight, bonus
+		}
+	}
+
+	// If no symbols found via LSP, use a base score
+	if best == 0 {
+		b.SymbolWeight = p.DefaultWeight
+	}
+
+	hunk.Symbols = affectedSymbols
+	hunk.Breakdown = b
+	hunk.Score = b.Total()
+	return hunk.Score
+}
+
+// ScoreFileDiff calculates scores for all hunks in a file diff.
+// Hunks touching a symbol listed in fd.ChangedSignatures get an extra bonus.
+func (p *Profile) ScoreFileDiff(fd *FileDiff, symbols []lsp.DocumentSymbol) {
+	changed := make(map[string]bool, len(fd.ChangedSignatures))
+	for _, name := range fd.ChangedSignatures {
+		changed[name] = true
+	}
+
+	for i := range fd.Hunks {
+		hunk := &fd.Hunks[i]
+		p.ScoreHunk(hunk, symbols)
+
+		for _, name := range hunk.Symbols {
+			if changed[name] {
+				hunk.Breakdown.SignatureBonus = p.SignatureBonus
+				hunk.Score = hunk.Breakdown.Total()
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/emiliopalmerini/grimorio/internal/config"
+)
+
+func TestProfileFromConfig(t *testing.T) {
+	p, err := ProfileFromConfig(config.Scoring{
+		Paths: []config.PathRule{
+			{Glob: "migrations/**", Target: "high"},
+			{Glob: "*.pb.go", Target: "generated"},
+		},
+		Multipliers: map[string]float64{"test": 0.8},
+		Weights:     map[string]float64{"function": 120, "exported": 40},
+		Budgets:     map[string]int{"scrying": 250},
+	})
+	if err != nil {
+		t.Fatalf("ProfileFromConfig() error = %v", err)
+	}
+
+	if p.Weights["Function"] != 120 {
+		t.Errorf("Function weight = %v, want 120", p.Weights["Function"])
+	}
+	if p.ExportedBonus != 40 {
+		t.Errorf("ExportedBonus = %v, want 40", p.ExportedBonus)
+	}
+	if p.multiplier(CategoryTest) != 0.8 {
+		t.Errorf("test multiplier = %v, want 0.8", p.multiplier(CategoryTest))
+	}
+	if p.Categorize("api/user.pb.go") != CategoryGenerated {
+		t.Errorf("Categorize(api/user.pb.go) = %v, want generated", p.Categorize("api/user.pb.go"))
+	}
+	if got := p.Budget("scrying", 400); got != 250 {
+		t.Errorf("Budget(scrying) = %d, want 250", got)
+	}
+	if got := p.Budget("sending", 400); got != 400 {
+		t.Errorf("Budget(sending) = %d, want 400", got)
+	}
+}
+
+func TestProfileFromConfigInvalid(t *testing.T) {
+	tests := []config.Scoring{
+		{Paths: []config.PathRule{{Glob: "*.go", Target: "urgent"}}},
+		{Multipliers: map[string]float64{"vendor": 0.1}},
+		{Weights: map[string]float64{"lambda": 10}},
+	}
+
+	for _, cfg := range tests {
+		if _, err := ProfileFromConfig(cfg); err == nil {
+			t.Errorf("ProfileFromConfig(%+v) expected error", cfg)
+		}
+	}
+}
+
+func TestProfileScoreHunkRules(t *testing.T) {
+	p := DefaultProfile()
+	p.Rules = []PathRule{
+		{Glob: "migrations/**", Target: TargetHigh},
+		{Glob: "*.md", Target: TargetIgnore},
+	}
+
+	pinned := &Hunk{FilePath: "migrations/001.sql", NewStart: 1, NewCount: 5}
+	p.ScoreHunk(pinned, nil)
+	if !pinned.Breakdown.Pinned || pinned.Breakdown.Rule != "migrations/**" {
+		t.Errorf("migration hunk breakdown = %+v, want pinned by migrations/**", pinned.Breakdown)
+	}
+
+	ignored := &Hunk{FilePath: "README.md", NewStart: 1, NewCount: 5}
+	if score := p.ScoreHunk(ignored, nil); score != 0 || !ignored.Breakdown.Ignored {
+		t.Errorf("README hunk score = %v, ignored = %v, want 0 and ignored", score, ignored.Breakdown.Ignored)
+	}
+}
@@ -28,47 +28,20 @@ const (
 	// Bonus for exported/public symbols
 	BonusExported = 50.0
 
+	// Bonus for hunks touching an exported signature that changed
+	BonusSignatureChange = 75.0
+
 	// Maximum bonus from hunk size
 	MaxSizeBonus = 50.0
 )
 
-// symbolKindWeight returns the weight for a given LSP symbol kind.
-func symbolKindWeight(kind string) float64 {
-	switch kind {
-	case "Function":
-		return WeightFunction
-	case "Method":
-		return WeightMethod
-	case "Class":
-		return WeightClass
-	case "Struct":
-		return WeightStruct
-	case "Interface":
-		return WeightInterface
-	case "Constructor":
-		return WeightConstructor
-	case "Enum":
-		return WeightEnum
-	case "Property":
-		return WeightProperty
-	case "Field":
-		return WeightField
-	case "Variable":
-		return WeightVariable
-	case "Constant":
-		return WeightConstant
-	case "Module":
-		return WeightModule
-	case "Package":
-		return WeightPackage
-	default:
-		return WeightDefault
-	}
-}
-
 // isExported checks if a symbol name is exported (starts with uppercase).
 // This is primarily for Go, but the heuristic works for many languages.
+// Go method names of the form "(*T).M" are judged by the method name.
 func isExported(name string) bool {
+	if i := strings.LastIndex(name, ")."); i >= 0 && strings.HasPrefix(name, "(") {
+		name = name[i+2:]
+	}
 	if name == "" {
 		return false
 	}
@@ -137,56 +110,15 @@ func CategorizeFile(path string) FileCategory {
 	return CategoryUnknown
 }
 
-// ScoreHunk calculates the priority score for a hunk.
+// ScoreHunk calculates the priority score for a hunk using the default profile.
 func ScoreHunk(hunk *Hunk, symbols []lsp.DocumentSymbol) float64 {
-	category := CategorizeFile(hunk.FilePath)
-	multiplier := category.Multiplier()
-
-	// Base score from hunk size
-	lineCount := CountHunkLines(hunk)
-	sizeBonus := float64(lineCount) * 0.1
-	if sizeBonus > MaxSizeBonus {
-		sizeBonus = MaxSizeBonus
-	}
-
-	// Find symbols affected by this hunk
-	var maxSymbolScore float64
-	var affectedSymbols []string
-
-	for _, sym := range symbols {
-		// Check if hunk overlaps with symbol range
-		// Hunk affects lines NewStart to NewStart+NewCount-1
-		hunkStart := hunk.NewStart
-		hunkEnd := hunk.NewStart + hunk.NewCount - 1
-
-		if overlaps(hunkStart, hunkEnd, sym.Line, sym.EndLine) {
-			weight := symbolKindWeight(sym.Kind)
-			if isExported(sym.Name) {
-				weight += BonusExported
-			}
-			if weight > maxSymbolScore {
-				maxSymbolScore = weight
-			}
-			affectedSymbols = append(affectedSymbols, sym.Name)
-		}
-	}
-
-	// If no symbols found via LSP, use a base score
-	if maxSymbolScore == 0 {
-		maxSymbolScore = WeightDefault
-	}
-
-	hunk.Symbols = affectedSymbols
-	hunk.Score = (maxSymbolScore + sizeBonus) * multiplier
-
-	return hunk.Score
+	return DefaultProfile().ScoreHunk(hunk, symbols)
 }
 
-// ScoreFileDiff calculates scores for all hunks in a file diff.
+// ScoreFileDiff calculates scores for all hunks in a file diff using the
+// default profile.
 func ScoreFileDiff(fd *FileDiff, symbols []lsp.DocumentSymbol) {
-	for i := range fd.Hunks {
-		ScoreHunk(&fd.Hunks[i], symbols)
-	}
+	DefaultProfile().ScoreFileDiff(fd, symbols)
 }
 
 // overlaps checks if two ranges overlap.
@@ -78,7 +78,7 @@ func TestCategoryMultiplier(t *testing.T) {
 	}
 }
 
-func TestSymbolKindWeight(t *testing.T) {
+func TestDefaultProfileSymbolWeight(t *testing.T) {
 	tests := []struct {
 		kind   string
 		weight float64
@@ -94,9 +94,9 @@ func TestSymbolKindWeight(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.kind, func(t *testing.T) {
-			got := symbolKindWeight(tt.kind)
+			got := DefaultProfile().symbolWeight(tt.kind)
 			if got != tt.weight {
-				t.Errorf("symbolKindWeight(%q) = %v, want %v", tt.kind, got, tt.weight)
+				t.Errorf("symbolWeight(%q) = %v, want %v", tt.kind, got, tt.weight)
 			}
 		})
 	}
@@ -111,6 +111,8 @@ func TestIsExported(t *testing.T) {
 		{"handleRequest", false},
 		{"MyStruct", true},
 		{"myStruct", false},
+		{"(*Client).Close", true},
+		{"(client).close", false},
 		{"", false},
 	}
 
@@ -168,6 +170,28 @@ func TestScoreHunkNoSymbols(t *testing.T) {
 	}
 }
 
+func TestScoreFileDiffSignatureChange(t *testing.T) {
+	fd := &FileDiff{
+		NewPath: "client.go",
+		Hunks: []Hunk{
+			{FilePath: "client.go", NewStart: 10, NewCount: 2, Content: "+a\n"},
+			{FilePath: "client.go", NewStart: 30, NewCount: 2, Content: "+b\n"},
+		},
+		ChangedSignatures: []string{"(*Client).Do"},
+	}
+	symbols := []lsp.DocumentSymbol{
+		{Name: "(*Client).Do", Kind: "Method", Line: 9, EndLine: 15},
+		{Name: "(*Client).Close", Kind: "Method", Line: 29, EndLine: 35},
+	}
+
+	ScoreFileDiff(fd, symbols)
+
+	changed, unchanged := fd.Hunks[0].Score, fd.Hunks[1].Score
+	if changed-unchanged != BonusSignatureChange {
+		t.Errorf("signature bonus = %v, want %v", changed-unchanged, BonusSignatureChange)
+	}
+}
+
 func TestOverlaps(t *testing.T) {
 	tests := []struct {
 		name                       string
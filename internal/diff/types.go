@@ -1,5 +1,7 @@
 package diff
 
+import "strings"
+
 // Hunk represents a single change block in a diff.
 type Hunk struct {
 	FilePath string
@@ -10,6 +12,16 @@ type Hunk struct {
 	Content  string
 	Score    float64
 	Symbols  []string // Symbol names affected by this hunk
+	Breaking bool     // Hunk touches an API-breaking change
+
+	Breakdown ScoreBreakdown // How Score was computed
+}
+
+// Location identifies a line on one side of a diff.
+type Location struct {
+	Path string
+	Line int  // 1-based line number
+	Old  bool // Line refers to the old side of the diff
 }
 
 // FileDiff represents all changes to a single file.
@@ -21,6 +33,28 @@ type FileDiff struct {
 	IsNew    bool
 	IsDelete bool
 	IsRename bool
+	IsCopy   bool
+
+	// Similarity is the rename or copy similarity index, in percent.
+	Similarity int
+	// OldMode and NewMode hold the git file modes, when known.
+	OldMode string
+	NewMode string
+
+	// IsSubmodule is set for gitlink entries; OldCommit and NewCommit
+	// hold the submodule commits instead of hunks.
+	IsSubmodule bool
+	OldCommit   string
+	NewCommit   string
+
+	// ChangedSignatures lists exported symbols whose signature changed
+	// compared to the previous version of the file.
+	ChangedSignatures []string
+}
+
+// ModeChanged reports whether the file mode changed between both sides.
+func (fd *FileDiff) ModeChanged() bool {
+	return fd.OldMode != "" && fd.NewMode != "" && fd.OldMode != fd.NewMode
 }
 
 // DiffStats contains aggregate statistics about a diff.
@@ -76,6 +110,16 @@ func (c FileCategory) Multiplier() float64 {
 	}
 }
 
+// ParseCategory returns the category with the given name.
+func ParseCategory(name string) (FileCategory, bool) {
+	for _, c := range []FileCategory{CategorySource, CategoryTest, CategoryConfig, CategoryDoc, CategoryGenerated, CategoryUnknown} {
+		if c.String() == strings.ToLower(name) {
+			return c, true
+		}
+	}
+	return CategoryUnknown, false
+}
+
 func (c FileCategory) String() string {
 	switch c {
 	case CategorySource:
@@ -1,8 +1,13 @@
 package git
 
 import (
+	"archive/tar"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 )
 
@@ -43,8 +48,6 @@ type DiffOptions struct {
 	MaxLines int // 0 = unlimited
 }
 
-const DefaultMaxDiffLines = 500
-
 func TruncateDiff(diff string, maxLines int) string {
 	if maxLines <= 0 || diff == "" {
 		return diff
@@ -63,9 +66,9 @@ func TruncateDiff(diff string, maxLines int) string {
 func GetDiff(opts DiffOptions) (string, error) {
 	var cmd *exec.Cmd
 	if opts.All {
-		cmd = exec.Command("git", "diff", "HEAD")
+		cmd = exec.Command("git", "diff", "-M", "-C", "HEAD")
 	} else {
-		cmd = exec.Command("git", "diff", "--cached")
+		cmd = exec.Command("git", "diff", "-M", "-C", "--cached")
 	}
 
 	out, err := cmd.Output()
@@ -144,7 +147,7 @@ func GetBaseBranch() (string, error) {
 }
 
 func GetBranchDiff(base string, maxLines int) (string, error) {
-	cmd := exec.Command("git", "diff", base+"...HEAD")
+	cmd := exec.Command("git", "diff", "-M", "-C", base+"...HEAD")
 	out, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("failed to get branch diff: %w", err)
@@ -159,6 +162,16 @@ func GetBranchDiff(base string, maxLines int) (string, error) {
 	return diff, nil
 }
 
+// ShowFile returns the content of a file at the given revision.
+func ShowFile(rev, path string) (string, error) {
+	cmd := exec.Command("git", "show", rev+":"+path)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to show %s at %s: %w", path, rev, err)
+	}
+	return string(out), nil
+}
+
 func GetBranchCommits(base string) (string, error) {
 	cmd := exec.Command("git", "log", base+"..HEAD", "--pretty=format:%s%n%b---")
 	out, err := cmd.Output()
@@ -167,3 +180,151 @@ func GetBranchCommits(base string) (string, error) {
 	}
 	return strings.TrimSpace(string(out)), nil
 }
+
+// GetRootDir returns the top-level directory of the current repository.
+func GetRootDir() (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get repository root: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// MergeBase returns the commit where head forked from base: the old side
+// of git diff base...head.
+func MergeBase(base, head string) (string, error) {
+	out, err := exec.Command("git", "merge-base", base, head).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the merge base of %s and %s: %w", base, head, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// GetChangedFiles lists files that differ between base and head.
+// An empty head compares against the working tree.
+func GetChangedFiles(base, head string) ([]string, error) {
+	args := []string{"diff", "--name-only", base}
+	if head != "" {
+		args = append(args, head)
+	}
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changed files: %w", err)
+	}
+
+	var files []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, line)
+		}
+	}
+	return files, nil
+}
+
+// ExportTree writes the tree at rev into dir, like an untracked checkout.
+// Given pathspecs, only the files they match are written; it fails if one
+// of them matches nothing.
+func ExportTree(rev, dir string, pathspecs ...string) error {
+	args := []string{"archive", "--format=tar", rev}
+	if len(pathspecs) > 0 {
+		args = append(append(args, "--"), pathspecs...)
+	}
+	cmd := exec.Command("git", args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to get stdout pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start git archive: %w", err)
+	}
+
+	extractErr := extractTar(stdout, dir)
+	io.Copy(io.Discard, stdout)
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("git archive %s failed: %w", rev, err)
+	}
+	return extractErr
+}
+
+func extractTar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(hdr.Name))
+		if !strings.HasPrefix(target, filepath.Clean(dir)+string(os.PathSeparator)) {
+			continue
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode)&0777)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+				return err
+			}
+			f.Close()
+		}
+	}
+}
+
+// ApplyOptions controls ApplyPatch.
+type ApplyOptions struct {
+	Check   bool // Only verify that the patch applies
+	Reverse bool // Undo the patch
+}
+
+// ApplyPatch applies a unified diff to the working tree. Paths are resolved
+// from the repository root, and hunk line counts are recomputed so slightly
+// miscounted patches still apply.
+func ApplyPatch(patch string, opts ApplyOptions) error {
+	root, err := GetRootDir()
+	if err != nil {
+		return err
+	}
+
+	args := []string{"apply", "--recount", "--whitespace=nowarn"}
+	if opts.Check {
+		args = append(args, "--check")
+	}
+	if opts.Reverse {
+		args = append(args, "--reverse")
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = root
+	cmd.Stdin = strings.NewReader(patch)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("git apply failed: %s", msg)
+		}
+		return fmt.Errorf("git apply failed: %w", err)
+	}
+	return nil
+}
+
+// GetGitDir returns the absolute path of the repository's .git directory.
+func GetGitDir() (string, error) {
+	out, err := exec.Command("git", "rev-parse", "--absolute-git-dir").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get git directory: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
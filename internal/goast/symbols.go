@@ -0,0 +1,457 @@
+package goast
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/emiliopalmerini/grimorio/internal/lsp"
+)
+
+// Symbols parses Go source and returns its declarations in the same shape
+// produced by an LSP documentSymbol request. Lines are 0-based, matching LSP.
+// Methods are named "(T).M" or "(*T).M", as gopls does. Source with syntax
+// errors yields the symbols that could be recovered along with the error.
+func Symbols(src string) ([]lsp.DocumentSymbol, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if err != nil && file == nil {
+		return nil, err
+	}
+
+	line := func(p token.Pos) int { return fset.Position(p).Line - 1 }
+
+	var symbols []lsp.DocumentSymbol
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			kind := "Function"
+			if d.Recv != nil {
+				kind = "Method"
+			}
+			symbols = append(symbols, lsp.DocumentSymbol{
+				Name:    funcName(d),
+				Kind:    kind,
+				Line:    line(d.Pos()),
+				EndLine: line(d.End()),
+			})
+
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					symbols = append(symbols, lsp.DocumentSymbol{
+						Name:    s.Name.Name,
+						Kind:    typeKind(s.Type),
+						Line:    line(s.Pos()),
+						EndLine: line(s.End()),
+					})
+					if st, ok := s.Type.(*ast.StructType); ok {
+						for _, field := range st.Fields.List {
+							for _, name := range field.Names {
+								symbols = append(symbols, lsp.DocumentSymbol{
+									Name:    name.Name,
+									Kind:    "Field",
+									Line:    line(field.Pos()),
+									EndLine: line(field.End()),
+								})
+							}
+						}
+					}
+
+				case *ast.ValueSpec:
+					kind := "Variable"
+					if d.Tok == token.CONST {
+						kind = "Constant"
+					}
+					for _, name := range s.Names {
+						symbols = append(symbols, lsp.DocumentSymbol{
+							Name:    name.Name,
+							Kind:    kind,
+							Line:    line(s.Pos()),
+							EndLine: line(s.End()),
+						})
+					}
+				}
+			}
+		}
+	}
+
+	return symbols, err
+}
+
+// ExportedSignatures returns the printed signature of every exported
+// top-level function, method and type in the source, keyed by symbol name.
+func ExportedSignatures(src string) (map[string]string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	sigs := make(map[string]string)
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() || (d.Recv != nil && !exportedReceiver(d)) {
+				continue
+			}
+			sig := &ast.FuncDecl{Recv: d.Recv, Name: d.Name, Type: d.Type}
+			sigs[funcName(d)] = printNode(fset, sig)
+
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				s, ok := spec.(*ast.TypeSpec)
+				if !ok || !s.Name.IsExported() {
+					continue
+				}
+				sigs[s.Name.Name] = printNode(fset, exportedSurface(s))
+			}
+		}
+	}
+
+	return sigs, nil
+}
+
+// ChangedSignatures compares two versions of a Go file and returns the names
+// of exported symbols whose signature changed. Removed symbols are left
+// out: no hunk of the new side touches them.
+func ChangedSignatures(oldSrc, newSrc string) ([]string, error) {
+	oldSigs, err := ExportedSignatures(oldSrc)
+	if err != nil {
+		return nil, err
+	}
+	newSigs, err := ExportedSignatures(newSrc)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for name, oldSig := range oldSigs {
+		if newSig, ok := newSigs[name]; ok && newSig != oldSig {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// Undocumented returns the exported top-level declarations of the source
+// that have no doc comment. Line is where the comment belongs: the line of
+// the declaration, or of the spec inside a grouped declaration. A grouped
+// const or var block is documented by a comment on the group; one without
+// any comments is reported once, at the block.
+func Undocumented(src string) ([]lsp.DocumentSymbol, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	line := func(p token.Pos) int { return fset.Position(p).Line - 1 }
+
+	var symbols []lsp.DocumentSymbol
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Doc != nil || !d.Name.IsExported() || (d.Recv != nil && !exportedReceiver(d)) {
+				continue
+			}
+			kind := "Function"
+			if d.Recv != nil {
+				kind = "Method"
+			}
+			symbols = append(symbols, lsp.DocumentSymbol{Name: funcName(d), Kind: kind, Line: line(d.Pos()), EndLine: line(d.End())})
+
+		case *ast.GenDecl:
+			grouped := d.Lparen.IsValid()
+			if d.Doc != nil && (!grouped || d.Tok != token.TYPE) {
+				continue
+			}
+			if grouped && (d.Tok == token.CONST || d.Tok == token.VAR) && !anyDocumented(d.Specs) {
+				// A block with no comments at all wants one on the block
+				if name, ok := firstExported(d.Specs); ok {
+					kind := "Variable"
+					if d.Tok == token.CONST {
+						kind = "Constant"
+					}
+					symbols = append(symbols, lsp.DocumentSymbol{Name: name, Kind: kind, Line: line(d.Pos()), EndLine: line(d.End())})
+				}
+				continue
+			}
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if s.Doc != nil || !s.Name.IsExported() {
+						continue
+					}
+					at := d.Pos()
+					if grouped {
+						at = s.Pos()
+					}
+					symbols = append(symbols, lsp.DocumentSymbol{Name: s.Name.Name, Kind: typeKind(s.Type), Line: line(at), EndLine: line(s.End())})
+
+				case *ast.ValueSpec:
+					if s.Doc != nil || (grouped && s.Comment != nil) {
+						continue
+					}
+					kind := "Variable"
+					if d.Tok == token.CONST {
+						kind = "Constant"
+					}
+					for _, name := range s.Names {
+						if !name.IsExported() {
+							continue
+						}
+						at := d.Pos()
+						if grouped {
+							at = s.Pos()
+						}
+						symbols = append(symbols, lsp.DocumentSymbol{Name: name.Name, Kind: kind, Line: line(at), EndLine: line(s.End())})
+						break
+					}
+				}
+			}
+		}
+	}
+
+	return symbols, nil
+}
+
+// RemoveFuncs deletes the named top-level functions from the source, along
+// with their doc comments, then drops the imports that only they used.
+// Imports the rest of the file still refers to, or might refer to when the
+// package name cannot be told from the path, are kept.
+func RemoveFuncs(src string, names []string) (string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return "", err
+	}
+
+	remove := make(map[string]bool, len(names))
+	for _, name := range names {
+		remove[name] = true
+	}
+	var spans [][2]int
+	removedUses := make(map[string]bool)
+	for _, decl := range file.Decls {
+		d, ok := decl.(*ast.FuncDecl)
+		if !ok || d.Recv != nil || !remove[d.Name.Name] {
+			continue
+		}
+		start := d.Pos()
+		if d.Doc != nil {
+			start = d.Doc.Pos()
+		}
+		spans = append(spans, lineSpan(src, fset.Position(start).Offset, fset.Position(d.End()).Offset))
+		qualifiers(d, removedUses)
+	}
+	if len(spans) == 0 {
+		return src, nil
+	}
+	// The blank line before the last declarations is left over
+	src = strings.TrimRight(cutSpans(src, spans), "\n") + "\n"
+
+	fset = token.NewFileSet()
+	file, err = parser.ParseFile(fset, "", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return "", err
+	}
+	keptUses := make(map[string]bool)
+	qualifiers(file, keptUses)
+
+	spans = nil
+	for _, decl := range file.Decls {
+		d, ok := decl.(*ast.GenDecl)
+		if !ok || d.Tok != token.IMPORT {
+			continue
+		}
+		var unused [][2]int
+		for _, spec := range d.Specs {
+			s := spec.(*ast.ImportSpec)
+			if name, ok := importName(s); ok && removedUses[name] && !keptUses[name] {
+				unused = append(unused, lineSpan(src, fset.Position(s.Pos()).Offset, fset.Position(s.End()).Offset))
+			}
+		}
+		if len(unused) > 0 && len(unused) == len(d.Specs) {
+			unused = [][2]int{lineSpan(src, fset.Position(d.Pos()).Offset, fset.Position(d.End()).Offset)}
+		}
+		spans = append(spans, unused...)
+	}
+	return cutSpans(src, spans), nil
+}
+
+// qualifiers adds the identifiers on the left of selector expressions in n,
+// such as the package names of qualified identifiers, to used.
+func qualifiers(n ast.Node, used map[string]bool) {
+	ast.Inspect(n, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				used[id.Name] = true
+			}
+		}
+		return true
+	})
+}
+
+// importName returns the name an import is likely referred to by: its
+// alias, or the last path element without a major version suffix, a
+// gopkg.in version or a go- prefix. Blank and dot imports have none.
+func importName(s *ast.ImportSpec) (string, bool) {
+	if s.Name != nil {
+		return s.Name.Name, s.Name.Name != "_" && s.Name.Name != "."
+	}
+	path, err := strconv.Unquote(s.Path.Value)
+	if err != nil {
+		return "", false
+	}
+	elems := strings.Split(path, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = elems[len(elems)-2]
+	}
+	if i := strings.Index(name, ".v"); i > 0 {
+		name = name[:i]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	return name, token.IsIdentifier(name)
+}
+
+// lineSpan widens the byte range [start, end) to whole lines, taking the
+// blank line that follows too, so that removing it leaves a single blank
+// line between declarations.
+func lineSpan(src string, start, end int) [2]int {
+	start = strings.LastIndex(src[:start], "\n") + 1
+	if i := strings.Index(src[end:], "\n"); i >= 0 {
+		end += i + 1
+	} else {
+		end = len(src)
+	}
+	if strings.HasPrefix(src[end:], "\n") {
+		end++
+	}
+	return [2]int{start, end}
+}
+
+// cutSpans removes non-overlapping byte ranges from src.
+func cutSpans(src string, spans [][2]int) string {
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] > spans[j][0] })
+	for _, span := range spans {
+		src = src[:span[0]] + src[span[1]:]
+	}
+	return src
+}
+
+func anyDocumented(specs []ast.Spec) bool {
+	for _, spec := range specs {
+		if s, ok := spec.(*ast.ValueSpec); ok && (s.Doc != nil || s.Comment != nil) {
+			return true
+		}
+	}
+	return false
+}
+
+func firstExported(specs []ast.Spec) (string, bool) {
+	for _, spec := range specs {
+		s, ok := spec.(*ast.ValueSpec)
+		if !ok {
+			continue
+		}
+		for _, name := range s.Names {
+			if name.IsExported() {
+				return name.Name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// funcName returns the gopls-style name of a function or method.
+func funcName(d *ast.FuncDecl) string {
+	if d.Recv == nil || len(d.Recv.List) == 0 {
+		return d.Name.Name
+	}
+	return "(" + receiverType(d.Recv.List[0].Type) + ")." + d.Name.Name
+}
+
+// receiverType renders a receiver type expression without type parameters.
+func receiverType(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return "*" + receiverType(t.X)
+	case *ast.Ident:
+		return t.Name
+	case *ast.IndexExpr:
+		return receiverType(t.X)
+	case *ast.IndexListExpr:
+		return receiverType(t.X)
+	default:
+		return ""
+	}
+}
+
+func exportedReceiver(d *ast.FuncDecl) bool {
+	name := strings.TrimPrefix(receiverType(d.Recv.List[0].Type), "*")
+	return ast.IsExported(name)
+}
+
+func typeKind(expr ast.Expr) string {
+	switch expr.(type) {
+	case *ast.StructType:
+		return "Struct"
+	case *ast.InterfaceType:
+		return "Interface"
+	default:
+		return "Class"
+	}
+}
+
+// exportedSurface strips unexported struct fields so that internal changes
+// to a type don't count as signature changes.
+func exportedSurface(s *ast.TypeSpec) *ast.TypeSpec {
+	st, ok := s.Type.(*ast.StructType)
+	if !ok {
+		return s
+	}
+
+	fields := &ast.FieldList{}
+	for _, field := range st.Fields.List {
+		if len(field.Names) == 0 {
+			fields.List = append(fields.List, &ast.Field{Type: field.Type, Tag: field.Tag})
+			continue
+		}
+		var names []*ast.Ident
+		for _, name := range field.Names {
+			if name.IsExported() {
+				names = append(names, name)
+			}
+		}
+		if len(names) > 0 {
+			fields.List = append(fields.List, &ast.Field{Names: names, Type: field.Type, Tag: field.Tag})
+		}
+	}
+
+	return &ast.TypeSpec{
+		Name:       s.Name,
+		TypeParams: s.TypeParams,
+		Assign:     s.Assign,
+		Type:       &ast.StructType{Fields: fields},
+	}
+}
+
+func printNode(fset *token.FileSet, node any) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
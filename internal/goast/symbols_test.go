@@ -0,0 +1,264 @@
+package goast
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const sampleSrc = `package sample
+
+type Client struct {
+	Name  string
+	token string
+}
+
+type Reader interface {
+	Read() error
+}
+
+const Version = "1.0"
+
+var debug bool
+
+func New(name string) *Client {
+	return &Client{Name: name}
+}
+
+func (c *Client) Close() error {
+	return nil
+}
+
+func helper() {}
+`
+
+func TestSymbols(t *testing.T) {
+	symbols, err := Symbols(sampleSrc)
+	if err != nil {
+		t.Fatalf("Symbols() error: %v", err)
+	}
+
+	got := make(map[string]string)
+	for _, s := range symbols {
+		got[s.Name] = s.Kind
+	}
+
+	want := map[string]string{
+		"Client":          "Struct",
+		"Name":            "Field",
+		"token":           "Field",
+		"Reader":          "Interface",
+		"Version":         "Constant",
+		"debug":           "Variable",
+		"New":             "Function",
+		"(*Client).Close": "Method",
+		"helper":          "Function",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Symbols() kinds = %v, want %v", got, want)
+	}
+
+	for _, s := range symbols {
+		if s.Name == "New" && (s.Line != 15 || s.EndLine != 17) {
+			t.Errorf("New range = %d-%d, want 15-17 (0-based)", s.Line, s.EndLine)
+		}
+	}
+}
+
+func TestSymbolsInvalidSource(t *testing.T) {
+	if _, err := Symbols("not go code"); err == nil {
+		t.Error("expected error for invalid source")
+	}
+}
+
+func TestChangedSignatures(t *testing.T) {
+	tests := []struct {
+		name   string
+		newSrc string
+		want   []string
+	}{
+		{
+			name:   "body change only",
+			newSrc: replace(sampleSrc, "return nil\n}", "return fmt.Errorf(\"x\")\n}"),
+			want:   nil,
+		},
+		{
+			name:   "function signature change",
+			newSrc: replace(sampleSrc, "func New(name string)", "func New(name string, debug bool)"),
+			want:   []string{"New"},
+		},
+		{
+			name:   "method removed",
+			newSrc: replace(sampleSrc, "func (c *Client) Close() error {\n\treturn nil\n}", ""),
+			want:   nil,
+		},
+		{
+			name:   "unexported field change",
+			newSrc: replace(sampleSrc, "token string", "token []byte"),
+			want:   nil,
+		},
+		{
+			name:   "exported field change",
+			newSrc: replace(sampleSrc, "Name  string", "Name  []byte"),
+			want:   []string{"Client"},
+		},
+		{
+			name:   "unexported function change",
+			newSrc: replace(sampleSrc, "func helper() {}", "func helper(x int) {}"),
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ChangedSignatures(sampleSrc, tt.newSrc)
+			if err != nil {
+				t.Fatalf("ChangedSignatures() error: %v", err)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ChangedSignatures() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func replace(s, old, new string) string {
+	return strings.Replace(s, old, new, 1)
+}
+
+func TestUndocumented(t *testing.T) {
+	src := `package sample
+
+// Documented is fine.
+type Documented struct{}
+
+type Client struct{}
+
+type (
+	// Option is documented.
+	Option func(*Client)
+
+	Mode int
+)
+
+// Levels are documented as a group.
+const (
+	Debug = iota
+	Info
+)
+
+const (
+	Red   = "red" // trailing comments count in groups
+	Green = "green"
+	blue  = "blue"
+)
+
+var ErrClosed = errors.New("closed")
+
+const (
+	KB = 1 << 10
+	MB = 1 << 20
+)
+
+func New() *Client { return nil }
+
+// Close is documented.
+func (c *Client) Close() error { return nil }
+
+func (c *Client) Open() error { return nil }
+
+func (c *client) Hidden() {}
+
+func helper() {}
+`
+	symbols, err := Undocumented(src)
+	if err != nil {
+		t.Fatalf("Undocumented() error: %v", err)
+	}
+
+	var got []string
+	for _, s := range symbols {
+		got = append(got, fmt.Sprintf("%s %s %d", s.Kind, s.Name, s.Line))
+	}
+	want := []string{
+		"Struct Client 5",
+		"Class Mode 11",
+		"Constant Green 22",
+		"Variable ErrClosed 26",
+		"Constant KB 28",
+		"Function New 33",
+		"Method (*Client).Open 38",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Undocumented() = %q, want %q", got, want)
+	}
+}
+
+func TestRemoveFuncs(t *testing.T) {
+	src := `package sample
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"example.com/mod/v2"
+	"example.com/sdk/client"
+	m "github.com/golang-migrate/migrate/v4"
+	"gopkg.in/yaml.v3"
+)
+
+func TestKeep(t *testing.T) {
+	if strings.TrimSpace(" a ") != "a" {
+		t.Fail()
+	}
+	_ = sdk.New
+}
+
+// TestDrop uses errors.
+func TestDrop(t *testing.T) {
+	_ = errors.New("x")
+	_ = yaml.Marshal
+	_ = m.New
+	_ = sdk.Close
+}
+
+func TestLast(t *testing.T) {}
+`
+	want := `package sample
+
+import (
+	"strings"
+	"testing"
+
+	"example.com/mod/v2"
+	"example.com/sdk/client"
+)
+
+func TestKeep(t *testing.T) {
+	if strings.TrimSpace(" a ") != "a" {
+		t.Fail()
+	}
+	_ = sdk.New
+}
+`
+	got, err := RemoveFuncs(src, []string{"TestDrop", "TestLast", "TestMissing"})
+	if err != nil {
+		t.Fatalf("RemoveFuncs() error = %v", err)
+	}
+	if got != want {
+		t.Errorf("RemoveFuncs() =\n%s\nwant\n%s", got, want)
+	}
+
+	single := "package sample\n\nimport \"errors\"\n\nfunc TestDrop() { _ = errors.New(\"x\") }\n\nfunc TestKeep() {}\n"
+	got, err = RemoveFuncs(single, []string{"TestDrop"})
+	if err != nil {
+		t.Fatalf("RemoveFuncs() error = %v", err)
+	}
+	if want := "package sample\n\nfunc TestKeep() {}\n"; got != want {
+		t.Errorf("RemoveFuncs() = %q, want %q", got, want)
+	}
+}
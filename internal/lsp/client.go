@@ -86,6 +86,12 @@ func (c *Client) Initialize(ctx context.Context, rootPath string) error {
 		"rootUri":   rootURI,
 		"capabilities": map[string]any{
 			"textDocument": map[string]any{
+				"documentSymbol": map[string]any{
+					"hierarchicalDocumentSymbolSupport": true,
+				},
+				"hover": map[string]any{
+					"contentFormat": []string{"markdown", "plaintext"},
+				},
 				"formatting": map[string]any{
 					"dynamicRegistration": false,
 				},
@@ -227,10 +233,11 @@ func (c *Client) DocumentSymbols(uri string) ([]DocumentSymbol, error) {
 					kind = "Unknown"
 				}
 				symbols = append(symbols, DocumentSymbol{
-					Name:    s.Name,
-					Kind:    kind,
-					Line:    s.Range.Start.Line,
-					EndLine: s.Range.End.Line,
+					Name:      s.Name,
+					Kind:      kind,
+					Line:      s.Range.Start.Line,
+					EndLine:   s.Range.End.Line,
+					Selection: s.SelectionRange.Start,
 				})
 				if len(s.Children) > 0 {
 					flatten(s.Children)
@@ -249,10 +256,11 @@ func (c *Client) DocumentSymbols(uri string) ([]DocumentSymbol, error) {
 				kind = "Unknown"
 			}
 			symbols = append(symbols, DocumentSymbol{
-				Name:    s.Name,
-				Kind:    kind,
-				Line:    s.Location.Range.Start.Line,
-				EndLine: s.Location.Range.End.Line,
+				Name:      s.Name,
+				Kind:      kind,
+				Line:      s.Location.Range.Start.Line,
+				EndLine:   s.Location.Range.End.Line,
+				Selection: s.Location.Range.Start,
 			})
 		}
 		return symbols, nil
@@ -261,6 +269,185 @@ func (c *Client) DocumentSymbols(uri string) ([]DocumentSymbol, error) {
 	return nil, fmt.Errorf("failed to parse document symbols")
 }
 
+// FileSymbols starts lang's server in the directory of path, opens content
+// as path and returns its symbols.
+func FileSymbols(ctx context.Context, path, content string, lang *Language) ([]DocumentSymbol, error) {
+	if !lang.Available() {
+		return nil, fmt.Errorf("LSP server not found: %s (required for %s files)", lang.Command, lang.Name)
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	uri := "file://" + absPath
+
+	client, err := NewClient(lang)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start LSP client: %w", err)
+	}
+	defer client.Close()
+
+	if err := client.Initialize(ctx, filepath.Dir(absPath)); err != nil {
+		return nil, fmt.Errorf("failed to initialize LSP: %w", err)
+	}
+	if err := client.OpenDocument(uri, lang.Name, content); err != nil {
+		return nil, fmt.Errorf("failed to open document: %w", err)
+	}
+	defer client.CloseDocument(uri)
+
+	return client.DocumentSymbols(uri)
+}
+
+// WorkspaceSymbols searches the whole workspace for symbols matching query.
+func (c *Client) WorkspaceSymbols(query string) ([]SymbolInformation, error) {
+	result, err := c.call("workspace/symbol", map[string]any{"query": query})
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []rawSymbolInformation
+	if err := json.Unmarshal(result, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse workspace symbols: %w", err)
+	}
+
+	symbols := make([]SymbolInformation, 0, len(raw))
+	for _, s := range raw {
+		kind := symbolKindNames[s.Kind]
+		if kind == "" {
+			kind = "Unknown"
+		}
+		symbols = append(symbols, SymbolInformation{
+			Name:      s.Name,
+			Kind:      kind,
+			Container: s.ContainerName,
+			Location:  s.Location,
+		})
+	}
+	return symbols, nil
+}
+
+// Hover returns the hover text for the symbol at pos, usually its type or
+// signature and documentation. It is empty when the server has none.
+func (c *Client) Hover(uri string, pos Position) (string, error) {
+	params := map[string]any{
+		"textDocument": map[string]any{
+			"uri": uri,
+		},
+		"position": pos,
+	}
+
+	result, err := c.call("textDocument/hover", params)
+	if err != nil {
+		return "", err
+	}
+	if len(result) == 0 || string(result) == "null" {
+		return "", nil
+	}
+
+	var hover rawHover
+	if err := json.Unmarshal(result, &hover); err != nil {
+		return "", fmt.Errorf("failed to parse hover: %w", err)
+	}
+	return hoverText(hover.Contents), nil
+}
+
+// hoverText flattens the contents of a hover: MarkupContent, a
+// MarkedString, or a list of MarkedStrings.
+func hoverText(contents json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(contents, &text); err == nil {
+		return text
+	}
+
+	var marked struct {
+		Language string `json:"language"`
+		Value    string `json:"value"`
+	}
+	if err := json.Unmarshal(contents, &marked); err == nil && marked.Value != "" {
+		if marked.Language != "" {
+			return "```" + marked.Language + "\n" + marked.Value + "\n```"
+		}
+		return marked.Value
+	}
+
+	var list []json.RawMessage
+	if err := json.Unmarshal(contents, &list); err == nil {
+		var parts []string
+		for _, item := range list {
+			if t := hoverText(item); t != "" {
+				parts = append(parts, t)
+			}
+		}
+		return strings.Join(parts, "\n\n")
+	}
+	return ""
+}
+
+// Definition returns where the symbol at pos is defined.
+func (c *Client) Definition(uri string, pos Position) ([]Location, error) {
+	params := map[string]any{
+		"textDocument": map[string]any{
+			"uri": uri,
+		},
+		"position": pos,
+	}
+
+	result, err := c.call("textDocument/definition", params)
+	if err != nil {
+		return nil, err
+	}
+	return parseLocations(result)
+}
+
+// References returns the places where the symbol at pos is used.
+func (c *Client) References(uri string, pos Position, includeDeclaration bool) ([]Location, error) {
+	params := map[string]any{
+		"textDocument": map[string]any{
+			"uri": uri,
+		},
+		"position": pos,
+		"context": map[string]any{
+			"includeDeclaration": includeDeclaration,
+		},
+	}
+
+	result, err := c.call("textDocument/references", params)
+	if err != nil {
+		return nil, err
+	}
+	return parseLocations(result)
+}
+
+// parseLocations accepts the shapes servers answer location requests with:
+// null, a single Location, a list of them, or a list of LocationLinks.
+func parseLocations(result json.RawMessage) ([]Location, error) {
+	if len(result) == 0 || string(result) == "null" {
+		return nil, nil
+	}
+
+	var single Location
+	if err := json.Unmarshal(result, &single); err == nil && single.URI != "" {
+		return []Location{single}, nil
+	}
+
+	var locations []Location
+	if err := json.Unmarshal(result, &locations); err == nil && (len(locations) == 0 || locations[0].URI != "") {
+		return locations, nil
+	}
+
+	var links []rawLocationLink
+	if err := json.Unmarshal(result, &links); err == nil {
+		locations = nil
+		for _, l := range links {
+			locations = append(locations, Location{URI: l.TargetURI, Range: l.TargetSelectionRange})
+		}
+		return locations, nil
+	}
+
+	return nil, fmt.Errorf("failed to parse locations")
+}
+
 func (c *Client) call(method string, params any) (json.RawMessage, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
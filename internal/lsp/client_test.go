@@ -0,0 +1,80 @@
+package lsp
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseLocations(t *testing.T) {
+	tests := []struct {
+		name   string
+		result string
+		want   []Location
+	}{
+		{"null", `null`, nil},
+		{"empty", `[]`, []Location{}},
+		{
+			name:   "single",
+			result: `{"uri":"file:///a.go","range":{"start":{"line":3,"character":5},"end":{"line":3,"character":9}}}`,
+			want:   []Location{{URI: "file:///a.go", Range: Range{Start: Position{3, 5}, End: Position{3, 9}}}},
+		},
+		{
+			name:   "list",
+			result: `[{"uri":"file:///a.go","range":{"start":{"line":1,"character":0},"end":{"line":1,"character":2}}},{"uri":"file:///b.go","range":{"start":{"line":2,"character":0},"end":{"line":2,"character":2}}}]`,
+			want: []Location{
+				{URI: "file:///a.go", Range: Range{Start: Position{1, 0}, End: Position{1, 2}}},
+				{URI: "file:///b.go", Range: Range{Start: Position{2, 0}, End: Position{2, 2}}},
+			},
+		},
+		{
+			name:   "links",
+			result: `[{"targetUri":"file:///c.go","targetRange":{"start":{"line":0,"character":0},"end":{"line":9,"character":1}},"targetSelectionRange":{"start":{"line":4,"character":5},"end":{"line":4,"character":8}}}]`,
+			want:   []Location{{URI: "file:///c.go", Range: Range{Start: Position{4, 5}, End: Position{4, 8}}}},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := parseLocations(json.RawMessage(tt.result))
+		if err != nil {
+			t.Errorf("%s: parseLocations() error = %v", tt.name, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: parseLocations() = %+v, want %+v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: location %d = %+v, want %+v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+
+	for uri, want := range map[string]string{
+		"file:///src/a.go":         "/src/a.go",
+		"file:///my%20src/a%2B.go": "/my src/a+.go",
+	} {
+		if got := (Location{URI: uri}).Path(); got != want {
+			t.Errorf("Path(%s) = %q, want %q", uri, got, want)
+		}
+	}
+}
+
+func TestHoverText(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		want     string
+	}{
+		{"markup", `{"kind":"markdown","value":"func Parse(s string) error"}`, "func Parse(s string) error"},
+		{"string", `"type Hunk struct"`, "type Hunk struct"},
+		{"marked", `{"language":"go","value":"func Parse()"}`, "```go\nfunc Parse()\n```"},
+		{"list", `[{"language":"python","value":"def f()"},"Does things."]`, "```python\ndef f()\n```\n\nDoes things."},
+		{"empty", `[]`, ""},
+	}
+	for _, tt := range tests {
+		if got := hoverText(json.RawMessage(tt.contents)); got != tt.want {
+			t.Errorf("%s: hoverText() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
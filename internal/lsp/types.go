@@ -1,5 +1,11 @@
 package lsp
 
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
 type TextEdit struct {
 	Range   Range  `json:"range"`
 	NewText string `json:"newText"`
@@ -32,10 +38,33 @@ type TextDocumentEdit struct {
 }
 
 type DocumentSymbol struct {
-	Name    string
-	Kind    string
-	Line    int
-	EndLine int
+	Name      string
+	Kind      string
+	Line      int
+	EndLine   int
+	Selection Position // Position of the name, for position-based requests
+}
+
+// SymbolInformation is a symbol found by a workspace search.
+type SymbolInformation struct {
+	Name      string
+	Kind      string
+	Container string
+	Location  Location
+}
+
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// Path returns the file path of a file:// location, with escapes such as
+// %20 decoded.
+func (l Location) Path() string {
+	if u, err := url.Parse(l.URI); err == nil && u.Scheme == "file" {
+		return u.Path
+	}
+	return strings.TrimPrefix(l.URI, "file://")
 }
 
 var symbolKindNames = map[int]string{
@@ -48,16 +77,25 @@ var symbolKindNames = map[int]string{
 }
 
 type rawDocumentSymbol struct {
-	Name     string              `json:"name"`
-	Kind     int                 `json:"kind"`
-	Range    Range               `json:"range"`
-	Children []rawDocumentSymbol `json:"children"`
+	Name           string              `json:"name"`
+	Kind           int                 `json:"kind"`
+	Range          Range               `json:"range"`
+	SelectionRange Range               `json:"selectionRange"`
+	Children       []rawDocumentSymbol `json:"children"`
 }
 
 type rawSymbolInformation struct {
-	Name     string `json:"name"`
-	Kind     int    `json:"kind"`
-	Location struct {
-		Range Range `json:"range"`
-	} `json:"location"`
+	Name          string   `json:"name"`
+	Kind          int      `json:"kind"`
+	ContainerName string   `json:"containerName"`
+	Location      Location `json:"location"`
+}
+
+type rawHover struct {
+	Contents json.RawMessage `json:"contents"`
+}
+
+type rawLocationLink struct {
+	TargetURI            string `json:"targetUri"`
+	TargetSelectionRange Range  `json:"targetSelectionRange"`
 }
@@ -0,0 +1 @@
+ALTER TABLE ai_invocations ADD COLUMN redactions INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE ai_invocations ADD COLUMN cache_hit INTEGER NOT NULL DEFAULT 0;
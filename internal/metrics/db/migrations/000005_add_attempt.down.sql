@@ -0,0 +1 @@
+ALTER TABLE ai_invocations DROP COLUMN attempt;
@@ -0,0 +1 @@
+ALTER TABLE ai_invocations ADD COLUMN attempt INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1 @@
+ALTER TABLE ai_invocations ADD COLUMN session_id TEXT;
@@ -20,6 +20,10 @@ type AiInvocation struct {
 	CreatedAt      sql.NullTime
 	MachineID      string
 	Synced         int64
+	Redactions     int64
+	CacheHit       int64
+	Attempt        int64
+	SessionID      sql.NullString
 }
 
 type CommandExecution struct {
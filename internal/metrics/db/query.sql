@@ -3,8 +3,12 @@ INSERT INTO command_executions (command, command_type, duration_ms, exit_code, f
 VALUES (?, ?, ?, ?, ?, ?) RETURNING *;
 
 -- name: InsertAIInvocation :one
-INSERT INTO ai_invocations (command, model, prompt_length, response_length, latency_ms, success, error, machine_id)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING *;
+INSERT INTO ai_invocations (command, model, prompt_length, response_length, latency_ms, success, error, machine_id, redactions, attempt, session_id)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING *;
+
+-- name: InsertCacheHit :one
+INSERT INTO ai_invocations (command, model, prompt_length, response_length, latency_ms, success, machine_id, cache_hit, session_id)
+VALUES (?, ?, ?, ?, 0, 1, ?, 1, ?) RETURNING *;
 
 -- name: GetDistinctCommands :many
 SELECT DISTINCT command FROM command_executions ORDER BY command;
@@ -22,9 +26,20 @@ ORDER BY count DESC;
 SELECT COUNT(*) as total_calls,
        COALESCE(SUM(prompt_length), 0) as total_prompt_tokens,
        COALESCE(SUM(response_length), 0) as total_response_tokens,
-       COALESCE(AVG(latency_ms), 0) as avg_latency_ms
+       COALESCE(AVG(latency_ms), 0) as avg_latency_ms,
+       COALESCE(SUM(redactions), 0) as total_redactions
+FROM ai_invocations
+WHERE cache_hit = 0
+  AND datetime(created_at) >= datetime(sqlc.arg(from_date))
+  AND datetime(created_at) <= datetime(sqlc.arg(to_date));
+
+-- name: GetCacheStats :one
+SELECT COUNT(*) as hits,
+       COALESCE(SUM(prompt_length), 0) as saved_prompt_tokens,
+       COALESCE(SUM(response_length), 0) as saved_response_tokens
 FROM ai_invocations
-WHERE datetime(created_at) >= datetime(sqlc.arg(from_date))
+WHERE cache_hit = 1
+  AND datetime(created_at) >= datetime(sqlc.arg(from_date))
   AND datetime(created_at) <= datetime(sqlc.arg(to_date));
 
 -- name: GetAIStatsByModel :many
@@ -33,7 +48,8 @@ SELECT model, COUNT(*) as count,
        COALESCE(SUM(response_length), 0) as response_tokens,
        COALESCE(AVG(latency_ms), 0) as avg_latency_ms
 FROM ai_invocations
-WHERE datetime(created_at) >= datetime(sqlc.arg(from_date))
+WHERE cache_hit = 0
+  AND datetime(created_at) >= datetime(sqlc.arg(from_date))
   AND datetime(created_at) <= datetime(sqlc.arg(to_date))
 GROUP BY model ORDER BY count DESC;
 
@@ -15,9 +15,11 @@ const getAIStats = `-- name: GetAIStats :one
 SELECT COUNT(*) as total_calls,
        COALESCE(SUM(prompt_length), 0) as total_prompt_tokens,
        COALESCE(SUM(response_length), 0) as total_response_tokens,
-       COALESCE(AVG(latency_ms), 0) as avg_latency_ms
+       COALESCE(AVG(latency_ms), 0) as avg_latency_ms,
+       COALESCE(SUM(redactions), 0) as total_redactions
 FROM ai_invocations
-WHERE datetime(created_at) >= datetime(?1)
+WHERE cache_hit = 0
+  AND datetime(created_at) >= datetime(?1)
   AND datetime(created_at) <= datetime(?2)
 `
 
@@ -31,6 +33,7 @@ type GetAIStatsRow struct {
 	TotalPromptTokens   interface{}
 	TotalResponseTokens interface{}
 	AvgLatencyMs        interface{}
+	TotalRedactions     interface{}
 }
 
 func (q *Queries) GetAIStats(ctx context.Context, arg GetAIStatsParams) (GetAIStatsRow, error) {
@@ -41,6 +44,7 @@ func (q *Queries) GetAIStats(ctx context.Context, arg GetAIStatsParams) (GetAISt
 		&i.TotalPromptTokens,
 		&i.TotalResponseTokens,
 		&i.AvgLatencyMs,
+		&i.TotalRedactions,
 	)
 	return i, err
 }
@@ -51,7 +55,8 @@ SELECT model, COUNT(*) as count,
        COALESCE(SUM(response_length), 0) as response_tokens,
        COALESCE(AVG(latency_ms), 0) as avg_latency_ms
 FROM ai_invocations
-WHERE datetime(created_at) >= datetime(?1)
+WHERE cache_hit = 0
+  AND datetime(created_at) >= datetime(?1)
   AND datetime(created_at) <= datetime(?2)
 GROUP BY model ORDER BY count DESC
 `
@@ -98,6 +103,34 @@ func (q *Queries) GetAIStatsByModel(ctx context.Context, arg GetAIStatsByModelPa
 	return items, nil
 }
 
+const getCacheStats = `-- name: GetCacheStats :one
+SELECT COUNT(*) as hits,
+       COALESCE(SUM(prompt_length), 0) as saved_prompt_tokens,
+       COALESCE(SUM(response_length), 0) as saved_response_tokens
+FROM ai_invocations
+WHERE cache_hit = 1
+  AND datetime(created_at) >= datetime(?1)
+  AND datetime(created_at) <= datetime(?2)
+`
+
+type GetCacheStatsParams struct {
+	FromDate interface{}
+	ToDate   interface{}
+}
+
+type GetCacheStatsRow struct {
+	Hits                int64
+	SavedPromptTokens   interface{}
+	SavedResponseTokens interface{}
+}
+
+func (q *Queries) GetCacheStats(ctx context.Context, arg GetCacheStatsParams) (GetCacheStatsRow, error) {
+	row := q.db.QueryRowContext(ctx, getCacheStats, arg.FromDate, arg.ToDate)
+	var i GetCacheStatsRow
+	err := row.Scan(&i.Hits, &i.SavedPromptTokens, &i.SavedResponseTokens)
+	return i, err
+}
+
 const getCommandStats = `-- name: GetCommandStats :many
 SELECT command, COUNT(*) as count, AVG(duration_ms) as avg_duration_ms
 FROM command_executions
@@ -235,7 +268,7 @@ func (q *Queries) GetMachineStats(ctx context.Context, arg GetMachineStatsParams
 }
 
 const getRecentAIInvocations = `-- name: GetRecentAIInvocations :many
-SELECT id, command, model, prompt_length, response_length, latency_ms, success, error, created_at, machine_id, synced FROM ai_invocations
+SELECT id, command, model, prompt_length, response_length, latency_ms, success, error, created_at, machine_id, synced, redactions, cache_hit, attempt, session_id FROM ai_invocations
 ORDER BY created_at DESC
 LIMIT ?
 `
@@ -261,6 +294,10 @@ func (q *Queries) GetRecentAIInvocations(ctx context.Context, limit int64) ([]Ai
 			&i.CreatedAt,
 			&i.MachineID,
 			&i.Synced,
+			&i.Redactions,
+			&i.CacheHit,
+			&i.Attempt,
+			&i.SessionID,
 		); err != nil {
 			return nil, err
 		}
@@ -341,7 +378,7 @@ func (q *Queries) GetTotalCommands(ctx context.Context, arg GetTotalCommandsPara
 }
 
 const getUnsyncedAIInvocations = `-- name: GetUnsyncedAIInvocations :many
-SELECT id, command, model, prompt_length, response_length, latency_ms, success, error, created_at, machine_id, synced FROM ai_invocations
+SELECT id, command, model, prompt_length, response_length, latency_ms, success, error, created_at, machine_id, synced, redactions, cache_hit, attempt, session_id FROM ai_invocations
 WHERE synced = 0
 ORDER BY id ASC
 LIMIT ?
@@ -368,6 +405,10 @@ func (q *Queries) GetUnsyncedAIInvocations(ctx context.Context, limit int64) ([]
 			&i.CreatedAt,
 			&i.MachineID,
 			&i.Synced,
+			&i.Redactions,
+			&i.CacheHit,
+			&i.Attempt,
+			&i.SessionID,
 		); err != nil {
 			return nil, err
 		}
@@ -423,8 +464,8 @@ func (q *Queries) GetUnsyncedCommandExecutions(ctx context.Context, limit int64)
 }
 
 const insertAIInvocation = `-- name: InsertAIInvocation :one
-INSERT INTO ai_invocations (command, model, prompt_length, response_length, latency_ms, success, error, machine_id)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING id, command, model, prompt_length, response_length, latency_ms, success, error, created_at, machine_id, synced
+INSERT INTO ai_invocations (command, model, prompt_length, response_length, latency_ms, success, error, machine_id, redactions, attempt, session_id)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id, command, model, prompt_length, response_length, latency_ms, success, error, created_at, machine_id, synced, redactions, cache_hit, attempt, session_id
 `
 
 type InsertAIInvocationParams struct {
@@ -436,6 +477,9 @@ type InsertAIInvocationParams struct {
 	Success        int64
 	Error          sql.NullString
 	MachineID      string
+	Redactions     int64
+	Attempt        int64
+	SessionID      sql.NullString
 }
 
 func (q *Queries) InsertAIInvocation(ctx context.Context, arg InsertAIInvocationParams) (AiInvocation, error) {
@@ -448,6 +492,53 @@ func (q *Queries) InsertAIInvocation(ctx context.Context, arg InsertAIInvocation
 		arg.Success,
 		arg.Error,
 		arg.MachineID,
+		arg.Redactions,
+		arg.Attempt,
+		arg.SessionID,
+	)
+	var i AiInvocation
+	err := row.Scan(
+		&i.ID,
+		&i.Command,
+		&i.Model,
+		&i.PromptLength,
+		&i.ResponseLength,
+		&i.LatencyMs,
+		&i.Success,
+		&i.Error,
+		&i.CreatedAt,
+		&i.MachineID,
+		&i.Synced,
+		&i.Redactions,
+		&i.CacheHit,
+		&i.Attempt,
+		&i.SessionID,
+	)
+	return i, err
+}
+
+const insertCacheHit = `-- name: InsertCacheHit :one
+INSERT INTO ai_invocations (command, model, prompt_length, response_length, latency_ms, success, machine_id, cache_hit, session_id)
+VALUES (?, ?, ?, ?, 0, 1, ?, 1, ?) RETURNING id, command, model, prompt_length, response_length, latency_ms, success, error, created_at, machine_id, synced, redactions, cache_hit, attempt, session_id
+`
+
+type InsertCacheHitParams struct {
+	Command        string
+	Model          string
+	PromptLength   sql.NullInt64
+	ResponseLength sql.NullInt64
+	MachineID      string
+	SessionID      sql.NullString
+}
+
+func (q *Queries) InsertCacheHit(ctx context.Context, arg InsertCacheHitParams) (AiInvocation, error) {
+	row := q.db.QueryRowContext(ctx, insertCacheHit,
+		arg.Command,
+		arg.Model,
+		arg.PromptLength,
+		arg.ResponseLength,
+		arg.MachineID,
+		arg.SessionID,
 	)
 	var i AiInvocation
 	err := row.Scan(
@@ -462,6 +553,10 @@ func (q *Queries) InsertAIInvocation(ctx context.Context, arg InsertAIInvocation
 		&i.CreatedAt,
 		&i.MachineID,
 		&i.Synced,
+		&i.Redactions,
+		&i.CacheHit,
+		&i.Attempt,
+		&i.SessionID,
 	)
 	return i, err
 }
@@ -15,7 +15,11 @@ func (NoopTracker) RecordCommand(context.Context, string, CommandType, int64, in
 	return nil
 }
 
-func (NoopTracker) RecordAI(context.Context, string, string, int, int, int64, bool, string) error {
+func (NoopTracker) RecordAI(context.Context, string, string, int, int, int64, bool, string, int, int, string) error {
+	return nil
+}
+
+func (NoopTracker) RecordCacheHit(context.Context, string, string, int, int, string) error {
 	return nil
 }
 
@@ -6,11 +6,10 @@ import (
 	"embed"
 	"fmt"
 	"log"
-	"os"
-	"path/filepath"
 	"sync"
 	"time"
 
+	"github.com/emiliopalmerini/grimorio/internal/config"
 	"github.com/emiliopalmerini/grimorio/internal/metrics/db"
 	"github.com/emiliopalmerini/grimorio/internal/metrics/turso"
 	"github.com/golang-migrate/migrate/v4"
@@ -65,6 +64,10 @@ type Summary struct {
 	TotalPromptTokens   int64
 	TotalResponseTokens int64
 	AvgLatencyMs        float64
+	TotalRedactions     int64
+	CacheHits           int64
+	SavedPromptTokens   int64
+	SavedResponseTokens int64
 	CommandStats        []CommandStat
 }
 
@@ -76,7 +79,8 @@ type CommandStat struct {
 
 type Tracker interface {
 	RecordCommand(ctx context.Context, command string, cmdType CommandType, durationMs int64, exitCode int, flags string) error
-	RecordAI(ctx context.Context, command, model string, promptLen, responseLen int, latencyMs int64, success bool, errMsg string) error
+	RecordAI(ctx context.Context, command, model string, promptLen, responseLen int, latencyMs int64, success bool, errMsg string, redactions, attempt int, sessionID string) error
+	RecordCacheHit(ctx context.Context, command, model string, promptLen, responseLen int, sessionID string) error
 	GetSummary(ctx context.Context, filter Filter) (Summary, error)
 	Queries(ctx context.Context) (*db.Queries, error)
 	Close() error
@@ -150,15 +154,7 @@ func (t *SQLiteTracker) syncToTurso(client *turso.Client, job tursoSyncJob) {
 }
 
 func DefaultDBPath() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("get home directory: %w", err)
-	}
-	dir := filepath.Join(home, ".grimorio")
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return "", fmt.Errorf("create config directory: %w", err)
-	}
-	return filepath.Join(dir, "metrics.db"), nil
+	return config.DataPath("metrics.db")
 }
 
 func (t *SQLiteTracker) ensureInit(ctx context.Context) error {
@@ -247,7 +243,7 @@ func (t *SQLiteTracker) RecordCommand(ctx context.Context, command string, cmdTy
 	return nil
 }
 
-func (t *SQLiteTracker) RecordAI(ctx context.Context, command, model string, promptLen, responseLen int, latencyMs int64, success bool, errMsg string) error {
+func (t *SQLiteTracker) RecordAI(ctx context.Context, command, model string, promptLen, responseLen int, latencyMs int64, success bool, errMsg string, redactions, attempt int, sessionID string) error {
 	if err := t.ensureInit(ctx); err != nil {
 		return err
 	}
@@ -269,6 +265,9 @@ func (t *SQLiteTracker) RecordAI(ctx context.Context, command, model string, pro
 		Success:        successInt,
 		Error:          toNullString(errMsg),
 		MachineID:      machineID,
+		Redactions:     int64(redactions),
+		Attempt:        int64(attempt),
+		SessionID:      toNullString(sessionID),
 	})
 	if err != nil {
 		return fmt.Errorf("insert ai invocation: %w", err)
@@ -279,9 +278,44 @@ func (t *SQLiteTracker) RecordAI(ctx context.Context, command, model string, pro
 		go t.syncToTurso(client, tursoSyncJob{
 			recordType: "AI invocation",
 			localID:    result.ID,
-			query: `INSERT INTO ai_invocations (command, model, prompt_length, response_length, latency_ms, success, error, created_at, machine_id, synced)
-				 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 1)`,
-			args:       []interface{}{command, model, promptLen, responseLen, latencyMs, successInt, toNullableArg(errMsg), createdAt.Format(timestampFormat), machineID},
+			query: `INSERT INTO ai_invocations (command, model, prompt_length, response_length, latency_ms, success, error, created_at, machine_id, redactions, attempt, session_id, synced)
+				 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1)`,
+			args:       []interface{}{command, model, promptLen, responseLen, latencyMs, successInt, toNullableArg(errMsg), createdAt.Format(timestampFormat), machineID, redactions, attempt, toNullableArg(sessionID)},
+			markSynced: t.queries.MarkAIInvocationsSynced,
+		})
+	}
+
+	return nil
+}
+
+func (t *SQLiteTracker) RecordCacheHit(ctx context.Context, command, model string, promptLen, responseLen int, sessionID string) error {
+	if err := t.ensureInit(ctx); err != nil {
+		return err
+	}
+
+	machineID := GetMachineID()
+	createdAt := time.Now()
+
+	result, err := t.queries.InsertCacheHit(ctx, db.InsertCacheHitParams{
+		Command:        command,
+		Model:          model,
+		PromptLength:   sql.NullInt64{Int64: int64(promptLen), Valid: true},
+		ResponseLength: sql.NullInt64{Int64: int64(responseLen), Valid: true},
+		MachineID:      machineID,
+		SessionID:      toNullString(sessionID),
+	})
+	if err != nil {
+		return fmt.Errorf("insert cache hit: %w", err)
+	}
+
+	if client := t.getTursoClient(); client != nil {
+		t.syncWg.Add(1)
+		go t.syncToTurso(client, tursoSyncJob{
+			recordType: "cache hit",
+			localID:    result.ID,
+			query: `INSERT INTO ai_invocations (command, model, prompt_length, response_length, latency_ms, success, created_at, machine_id, cache_hit, session_id, synced)
+				 VALUES (?, ?, ?, ?, 0, 1, ?, ?, 1, ?, 1)`,
+			args:       []interface{}{command, model, promptLen, responseLen, createdAt.Format(timestampFormat), machineID, toNullableArg(sessionID)},
 			markSynced: t.queries.MarkAIInvocationsSynced,
 		})
 	}
@@ -326,6 +360,14 @@ func (t *SQLiteTracker) GetSummary(ctx context.Context, filter Filter) (Summary,
 		return Summary{}, fmt.Errorf("get ai stats: %w", err)
 	}
 
+	cacheStats, err := t.queries.GetCacheStats(ctx, db.GetCacheStatsParams{
+		FromDate: fromStr,
+		ToDate:   toStr,
+	})
+	if err != nil {
+		return Summary{}, fmt.Errorf("get cache stats: %w", err)
+	}
+
 	cmdStats, err := t.queries.GetCommandStats(ctx, db.GetCommandStatsParams{
 		FromDate:      fromStr,
 		ToDate:        toStr,
@@ -351,6 +393,9 @@ func (t *SQLiteTracker) GetSummary(ctx context.Context, filter Filter) (Summary,
 	promptTokens, _ := aiStats.TotalPromptTokens.(int64)
 	responseTokens, _ := aiStats.TotalResponseTokens.(int64)
 	avgLatency, _ := aiStats.AvgLatencyMs.(float64)
+	redactions, _ := aiStats.TotalRedactions.(int64)
+	savedPrompt, _ := cacheStats.SavedPromptTokens.(int64)
+	savedResponse, _ := cacheStats.SavedResponseTokens.(int64)
 
 	return Summary{
 		TotalCommands:       total,
@@ -359,6 +404,10 @@ func (t *SQLiteTracker) GetSummary(ctx context.Context, filter Filter) (Summary,
 		TotalPromptTokens:   promptTokens,
 		TotalResponseTokens: responseTokens,
 		AvgLatencyMs:        avgLatency,
+		TotalRedactions:     redactions,
+		CacheHits:           cacheStats.Hits,
+		SavedPromptTokens:   savedPrompt,
+		SavedResponseTokens: savedResponse,
 		CommandStats:        commandStats,
 	}, nil
 }
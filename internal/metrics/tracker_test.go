@@ -45,12 +45,12 @@ func TestSQLiteTracker(t *testing.T) {
 	})
 
 	t.Run("record ai invocations", func(t *testing.T) {
-		err := tracker.RecordAI(ctx, "divine", "opus", 1000, 500, 2000, true, "")
+		err := tracker.RecordAI(ctx, "divine", "opus", 1000, 500, 2000, true, "", 3, 1, "")
 		if err != nil {
 			t.Fatalf("RecordAI: %v", err)
 		}
 
-		err = tracker.RecordAI(ctx, "scry", "opus", 800, 0, 100, false, "timeout")
+		err = tracker.RecordAI(ctx, "scry", "opus", 800, 0, 100, false, "timeout", 0, 2, "chat-1")
 		if err != nil {
 			t.Fatalf("RecordAI (failure): %v", err)
 		}
@@ -63,6 +63,43 @@ func TestSQLiteTracker(t *testing.T) {
 		if summary.TotalAICalls != 2 {
 			t.Errorf("TotalAICalls = %d, want 2", summary.TotalAICalls)
 		}
+		if summary.TotalRedactions != 3 {
+			t.Errorf("TotalRedactions = %d, want 3", summary.TotalRedactions)
+		}
+	})
+
+	t.Run("record cache hits", func(t *testing.T) {
+		if err := tracker.RecordCacheHit(ctx, "identify", "sonnet", 400, 100, ""); err != nil {
+			t.Fatalf("RecordCacheHit: %v", err)
+		}
+
+		summary, err := tracker.GetSummary(ctx, Filter{From: time.Now().Add(-time.Hour)})
+		if err != nil {
+			t.Fatalf("GetSummary: %v", err)
+		}
+
+		if summary.TotalAICalls != 2 {
+			t.Errorf("TotalAICalls = %d, want 2 (cache hits excluded)", summary.TotalAICalls)
+		}
+		if summary.CacheHits != 1 {
+			t.Errorf("CacheHits = %d, want 1", summary.CacheHits)
+		}
+		if summary.SavedPromptTokens+summary.SavedResponseTokens != 500 {
+			t.Errorf("saved tokens = %d, want 500", summary.SavedPromptTokens+summary.SavedResponseTokens)
+		}
+
+		queries, err := tracker.Queries(ctx)
+		if err != nil {
+			t.Fatalf("Queries: %v", err)
+		}
+		unsynced, err := queries.GetUnsyncedAIInvocations(ctx, 10)
+		if err != nil {
+			t.Fatalf("GetUnsyncedAIInvocations: %v", err)
+		}
+		if len(unsynced) != 3 || unsynced[2].CacheHit != 1 || unsynced[0].Redactions != 3 || unsynced[1].Attempt != 2 || unsynced[2].Attempt != 1 ||
+			unsynced[0].SessionID.Valid || unsynced[1].SessionID.String != "chat-1" {
+			t.Errorf("unexpected invocations: %+v", unsynced)
+		}
 	})
 }
 
@@ -74,10 +111,14 @@ func TestNoopTracker(t *testing.T) {
 		t.Errorf("RecordCommand: %v", err)
 	}
 
-	if err := tracker.RecordAI(ctx, "test", "sonnet", 100, 100, 100, true, ""); err != nil {
+	if err := tracker.RecordAI(ctx, "test", "sonnet", 100, 100, 100, true, "", 0, 1, ""); err != nil {
 		t.Errorf("RecordAI: %v", err)
 	}
 
+	if err := tracker.RecordCacheHit(ctx, "test", "sonnet", 100, 100, ""); err != nil {
+		t.Errorf("RecordCacheHit: %v", err)
+	}
+
 	summary, err := tracker.GetSummary(ctx, Filter{})
 	if err != nil {
 		t.Errorf("GetSummary: %v", err)
@@ -263,7 +263,11 @@ func (c *Client) InitSchema(ctx context.Context) error {
 			error TEXT,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			machine_id TEXT NOT NULL DEFAULT '',
-			synced INTEGER NOT NULL DEFAULT 0
+			synced INTEGER NOT NULL DEFAULT 0,
+			redactions INTEGER NOT NULL DEFAULT 0,
+			cache_hit INTEGER NOT NULL DEFAULT 0,
+			attempt INTEGER NOT NULL DEFAULT 1,
+			session_id TEXT
 		)`},
 		{SQL: `CREATE INDEX IF NOT EXISTS idx_executions_command ON command_executions(command)`},
 		{SQL: `CREATE INDEX IF NOT EXISTS idx_executions_date ON command_executions(executed_at)`},
@@ -272,6 +276,25 @@ func (c *Client) InitSchema(ctx context.Context) error {
 		{SQL: `CREATE INDEX IF NOT EXISTS idx_ai_machine ON ai_invocations(machine_id)`},
 	}
 
-	_, err := c.ExecuteBatch(ctx, statements)
-	return err
+	if _, err := c.ExecuteBatch(ctx, statements); err != nil {
+		return err
+	}
+
+	// Tables created by older versions lack the newer columns
+	columns := []struct {
+		name string
+		def  string
+	}{
+		{"redactions", "INTEGER NOT NULL DEFAULT 0"},
+		{"cache_hit", "INTEGER NOT NULL DEFAULT 0"},
+		{"attempt", "INTEGER NOT NULL DEFAULT 1"},
+		{"session_id", "TEXT"},
+	}
+	for _, column := range columns {
+		alter := fmt.Sprintf(`ALTER TABLE ai_invocations ADD COLUMN %s %s`, column.name, column.def)
+		if _, err := c.Execute(ctx, alter); err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			return err
+		}
+	}
+	return nil
 }
@@ -151,11 +151,15 @@ func (s *Syncer) syncAIInvocations(ctx context.Context) (int, error) {
 			if rec.Error.Valid {
 				errMsg = textArg(rec.Error.String)
 			}
+			sessionID := nullArg()
+			if rec.SessionID.Valid {
+				sessionID = textArg(rec.SessionID.String)
+			}
 
 			statements[i] = statement{
 				SQL: `INSERT INTO ai_invocations
-					(command, model, prompt_length, response_length, latency_ms, success, error, created_at, machine_id, synced)
-					VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 1)`,
+					(command, model, prompt_length, response_length, latency_ms, success, error, created_at, machine_id, redactions, cache_hit, attempt, session_id, synced)
+					VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1)`,
 				Args: []argValue{
 					textArg(rec.Command),
 					textArg(rec.Model),
@@ -166,6 +170,10 @@ func (s *Syncer) syncAIInvocations(ctx context.Context) (int, error) {
 					errMsg,
 					createdAt,
 					textArg(rec.MachineID),
+					intArg(rec.Redactions),
+					intArg(rec.CacheHit),
+					intArg(rec.Attempt),
+					sessionID,
 				},
 			}
 			ids[i] = rec.ID
@@ -290,7 +298,7 @@ func (s *Syncer) GetRemoteSummary(ctx context.Context, from, to time.Time) (*Rem
 			COALESCE(SUM(response_length), 0) as total_response_tokens,
 			COALESCE(AVG(latency_ms), 0) as avg_latency_ms
 		FROM ai_invocations
-		WHERE datetime(created_at) >= datetime(?) AND datetime(created_at) <= datetime(?)
+		WHERE cache_hit = 0 AND datetime(created_at) >= datetime(?) AND datetime(created_at) <= datetime(?)
 	`, fromStr, toStr)
 	if err != nil {
 		return nil, fmt.Errorf("get ai stats: %w", err)
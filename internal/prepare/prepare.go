@@ -31,9 +31,29 @@ func Commands() []Command {
 			Type:  Spell,
 			Short: "Run a command and analyze errors",
 			Description: `Augury runs a command, captures its output, and analyzes any errors using Claude.
-Use this when you need to run a shell command and get AI-powered analysis of any failures or errors.`,
+Use this when you need to run a shell command and get AI-powered analysis of any failures or errors.
+With --fix it applies suggested patches and re-runs the command until it succeeds; --undo reverts the last patch.`,
 			Usage: `grimorio augury "go build"
-grimorio augury "npm test"`,
+grimorio augury "npm test"
+grimorio augury --fix --yes "go test ./..."`,
+		},
+		{
+			Name:  "breaking",
+			Type:  Cantrip,
+			Short: "Detect API-breaking changes in Go packages",
+			Description: `Breaking compares the exported API of changed Go packages against a base revision and suggests a semver bump.
+Use this before releasing or opening a PR to find removed, renamed or changed exported identifiers.`,
+			Usage: `grimorio breaking
+grimorio breaking --base main --check`,
+		},
+		{
+			Name:  "config",
+			Type:  Cantrip,
+			Short: "Show and change per-command defaults",
+			Description: `Config lists, reads and writes flag defaults layered from the user config, .grimorio.toml, GRIMORIO_* environment variables and flags.
+Use this to make a flag such as sending --base or a spell's --model stick, and to see where an effective value comes from.`,
+			Usage: `grimorio config list sending
+grimorio config set scrying.model sonnet`,
 		},
 		{
 			Name:  "conjure",
@@ -43,15 +63,35 @@ grimorio augury "npm test"`,
 Use this to scaffold CQRS modules with commands, queries, handlers, and transport layers.`,
 			Usage: `grimorio conjure user
 grimorio conjure order --transport=http,grpc`,
+		},
+		{
+			Name:  "diff",
+			Type:  Cantrip,
+			Short: "Inspect how diffs are prioritized for spells",
+			Description: `Diff explain prints every hunk of the staged or branch diff with the breakdown of its priority score.
+Use this to tune the [scoring] section of .grimorio.toml when spells keep or drop the wrong hunks.`,
+			Usage: `grimorio diff explain
+grimorio diff explain --base main --command sending`,
 		},
 		{
 			Name:  "identify",
 			Type:  Spell,
 			Short: "Explain code in plain language",
-			Description: `Identify reads a file and explains its code using Claude.
-Use this when you need to understand what a piece of code does.`,
+			Description: `Identify reads a file and explains its code using Claude. Given a directory, it explains the whole package.
+Use this when you need to understand what a piece of code or a package does.`,
 			Usage: `grimorio identify main.go
-grimorio identify handler.go --symbol HandleLogin`,
+grimorio identify handler.go --symbol HandleLogin
+grimorio identify ./internal/diff`,
+		},
+		{
+			Name:  "inscribe",
+			Type:  Spell,
+			Short: "Write doc comments for undocumented exported symbols",
+			Description: `Inscribe finds exported symbols without doc comments and writes them using Claude, following each language's conventions.
+Use this to document a package before publishing it, or with --check to enforce doc comments in CI.`,
+			Usage: `grimorio inscribe ./internal/...
+grimorio inscribe handler.go --dry-run
+grimorio inscribe --check ./...`,
 		},
 		{
 			Name:  "mending",
@@ -77,9 +117,19 @@ grimorio modify-memory -a`,
 			Type:  Cantrip,
 			Short: "Transform data between formats",
 			Description: `Polymorph transforms data between different formats: JSON, YAML, TOML, XML, CSV, Markdown, HTML.
-Use this to convert data files from one format to another.`,
+Use this to convert data files from one format to another, or to validate them against a JSON Schema.`,
 			Usage: `grimorio polymorph data.json --to yaml
-grimorio polymorph config.xml --to json`,
+grimorio polymorph config.xml --to json
+grimorio polymorph validate config.yaml --schema config.schema.json`,
+		},
+		{
+			Name:  "prompts",
+			Type:  Cantrip,
+			Short: "Inspect the prompt templates used by spells",
+			Description: `Prompts lists and prints the text/template prompts of the spells, built-in or overridden in .grimorio/prompts/<spell>.tmpl.
+Use this to start a repository override from the built-in template or to check which template a spell uses.`,
+			Usage: `grimorio prompts show scrying
+grimorio prompts show sending --builtin > .grimorio/prompts/sending.tmpl`,
 		},
 		{
 			Name:  "scrying",
@@ -108,6 +158,16 @@ Use this to bootstrap new Go projects with proper scaffolding.`,
 			Usage: `grimorio summon myapp
 grimorio summon myapi --type=api
 grimorio summon mysite --type=web`,
+		},
+		{
+			Name:  "transmute",
+			Type:  Spell,
+			Short: "Write tests for changed functions",
+			Description: `Transmute writes table-driven tests for the functions changed in the staged or branch diff using Claude, runs them and iterates on failures.
+Use this when you want tests for new or changed code that compile and pass.`,
+			Usage: `grimorio transmute
+grimorio transmute --branch
+grimorio transmute --attempts 5`,
 		},
 		{
 			Name:  "stats",
@@ -0,0 +1,217 @@
+package prompt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/emiliopalmerini/grimorio/internal/claude"
+	"github.com/emiliopalmerini/grimorio/internal/config"
+)
+
+// Section fill priorities. Higher priorities get budget first.
+const (
+	PriorityLow    = 10
+	PriorityNormal = 20
+	PriorityHigh   = 30
+)
+
+// DefaultBudget is the prompt token budget of every model unless the
+// repository sets one under [claude.budgets]. Haiku, Sonnet and Opus share
+// a 200k token context window, so they share a budget too: it stays well
+// below the window because the point is to keep prompts cheap, not to
+// fill it.
+const DefaultBudget = 12000
+
+// Budgets maps a model to its prompt token budget, as set under
+// [claude.budgets].
+type Budgets map[string]int
+
+// LoadBudgets reads and checks the budgets of the repository.
+func LoadBudgets() (Budgets, error) {
+	cfg, err := config.LoadRepo()
+	if err != nil {
+		return nil, err
+	}
+	for model, budget := range cfg.Claude.Budgets {
+		if budget <= 0 {
+			return nil, fmt.Errorf("claude.budgets.%s: must be positive, got %d", model, budget)
+		}
+	}
+	return Budgets(cfg.Claude.Budgets), nil
+}
+
+// Context returns the prompt token budget for a model.
+func (b Budgets) Context(model claude.Model) int {
+	if budget, ok := b[string(model)]; ok {
+		return budget
+	}
+	return DefaultBudget
+}
+
+// repoBudgets loads the budgets once per run.
+var repoBudgets = sync.OnceValues(LoadBudgets)
+
+// ContextBudget returns the prompt token budget for a model, or the error
+// of an invalid [claude.budgets].
+func ContextBudget(model claude.Model) (int, error) {
+	budgets, err := repoBudgets()
+	if err != nil {
+		return 0, err
+	}
+	return budgets.Context(model), nil
+}
+
+// DiffBudget returns the share of a model's budget given to a prioritized
+// diff, leaving room for instructions and the other sections.
+func DiffBudget(model claude.Model) (int, error) {
+	budget, err := ContextBudget(model)
+	return budget * 3 / 4, err
+}
+
+// Truncate shrinks content to roughly maxTokens tokens.
+type Truncate func(content string, maxTokens int) string
+
+// Section is one part of a prompt.
+type Section struct {
+	Title    string   // Rendered as "Title:" above the content; empty for none
+	Content  string   // Sections with empty content are skipped
+	Priority int      // Fill order; ties keep insertion order
+	Required bool     // Always included in full, e.g. instructions
+	Truncate Truncate // Shrinks the section when it does not fit; nil drops it
+}
+
+func (s Section) render(content string) string {
+	if s.Title == "" {
+		return content
+	}
+	return "\n" + s.Title + ":\n" + content + "\n"
+}
+
+// Builder assembles a prompt from sections within a token budget.
+// Sections are rendered in the order they were added.
+type Builder struct {
+	budget   int
+	sections []Section
+}
+
+// New returns a builder for prompts sent to model.
+func New(model claude.Model) (*Builder, error) {
+	budget, err := ContextBudget(model)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithBudget(budget), nil
+}
+
+// NewWithBudget returns a builder with an explicit token budget.
+func NewWithBudget(budget int) *Builder {
+	return &Builder{budget: budget}
+}
+
+// Add appends a section to the prompt.
+func (b *Builder) Add(s Section) *Builder {
+	b.sections = append(b.sections, s)
+	return b
+}
+
+// Build fills sections by priority and renders the prompt. Required
+// sections are always kept; the others are kept whole, truncated or
+// dropped depending on the budget left.
+func (b *Builder) Build() string {
+	return strings.Join(b.fit(), "")
+}
+
+// fit renders each section as it fits in the budget, in insertion order.
+// Dropped sections are empty.
+func (b *Builder) fit() []string {
+	rendered := make([]string, len(b.sections))
+
+	order := make([]int, 0, len(b.sections))
+	for i := range b.sections {
+		order = append(order, i)
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		si, sj := b.sections[order[i]], b.sections[order[j]]
+		if si.Required != sj.Required {
+			return si.Required
+		}
+		return si.Priority > sj.Priority
+	})
+
+	remaining := b.budget
+	for _, i := range order {
+		s := b.sections[i]
+		if s.Content == "" {
+			continue
+		}
+
+		full := s.render(s.Content)
+		cost := EstimateTokens(full)
+		if s.Required || cost <= remaining {
+			rendered[i] = full
+			remaining -= cost
+			continue
+		}
+		if s.Truncate == nil {
+			continue
+		}
+
+		overhead := EstimateTokens(s.render(""))
+		if remaining <= overhead {
+			continue
+		}
+		shrunk := s.render(s.Truncate(s.Content, remaining-overhead))
+		rendered[i] = shrunk
+		remaining -= EstimateTokens(shrunk)
+	}
+
+	return rendered
+}
+
+// markerTokens is reserved for the omission marker added by Head and Tail.
+const markerTokens = 24
+
+// Head keeps the first lines of content that fit in maxTokens.
+func Head(content string, maxTokens int) string {
+	if EstimateTokens(content) <= maxTokens {
+		return content
+	}
+	maxTokens -= markerTokens
+
+	lines := strings.Split(content, "\n")
+	kept, used := 0, 0
+	for _, line := range lines {
+		cost := EstimateTokens(line) + 1
+		if used+cost > maxTokens {
+			break
+		}
+		used += cost
+		kept++
+	}
+	omitted := len(lines) - kept
+	return strings.Join(lines[:kept], "\n") + fmt.Sprintf("\n[... %d lines omitted, showing first %d ...]", omitted, kept)
+}
+
+// Tail keeps the last lines of content that fit in maxTokens. Use it for
+// command output, where errors tend to be at the end.
+func Tail(content string, maxTokens int) string {
+	if EstimateTokens(content) <= maxTokens {
+		return content
+	}
+	maxTokens -= markerTokens
+
+	lines := strings.Split(content, "\n")
+	kept, used := 0, 0
+	for i := len(lines) - 1; i >= 0; i-- {
+		cost := EstimateTokens(lines[i]) + 1
+		if used+cost > maxTokens {
+			break
+		}
+		used += cost
+		kept++
+	}
+	omitted := len(lines) - kept
+	return fmt.Sprintf("[... %d lines omitted, showing last %d ...]\n", omitted, kept) + strings.Join(lines[len(lines)-kept:], "\n")
+}
@@ -0,0 +1,131 @@
+package prompt
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/emiliopalmerini/grimorio/internal/claude"
+	"github.com/emiliopalmerini/grimorio/internal/config"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"", 0},
+		{"hello", 2},
+		{"a b c", 3},
+		{"func main() {}", 6},
+		{"line\nline", 3},
+	}
+
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.input); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestLoadBudgets(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if out, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	budgets, err := LoadBudgets()
+	if err != nil {
+		t.Fatalf("LoadBudgets() error = %v", err)
+	}
+	for _, m := range []claude.Model{claude.Haiku, claude.Sonnet, claude.Opus, "unknown"} {
+		if got := budgets.Context(m); got != DefaultBudget {
+			t.Errorf("Context(%s) = %d, want default %d", m, got, DefaultBudget)
+		}
+	}
+
+	os.WriteFile(config.FileName, []byte("[claude.budgets]\nopus = 20000\n"), 0o644)
+	if budgets, err = LoadBudgets(); err != nil {
+		t.Fatalf("LoadBudgets() error = %v", err)
+	}
+	if got := budgets.Context(claude.Opus); got != 20000 {
+		t.Errorf("Context(opus) = %d, want 20000 from [claude.budgets]", got)
+	}
+	if got := budgets.Context(claude.Sonnet); got != DefaultBudget {
+		t.Errorf("Context(sonnet) = %d, want default %d", got, DefaultBudget)
+	}
+
+	for _, invalid := range []string{"opus = 0", "opus = -5", "opus = \"big\""} {
+		os.WriteFile(config.FileName, []byte("[claude.budgets]\n"+invalid+"\n"), 0o644)
+		if _, err := LoadBudgets(); err == nil {
+			t.Errorf("LoadBudgets(%s) expected error", invalid)
+		}
+	}
+}
+
+func TestBuildFitsEverything(t *testing.T) {
+	got := NewWithBudget(1000).
+		Add(Section{Content: "Instructions\n", Required: true}).
+		Add(Section{Title: "History", Content: "one\ntwo", Priority: PriorityLow}).
+		Add(Section{Title: "Empty", Content: "", Priority: PriorityHigh}).
+		Build()
+
+	want := "Instructions\n\nHistory:\none\ntwo\n"
+	if got != want {
+		t.Errorf("Build() = %q, want %q", got, want)
+	}
+}
+
+func TestBuildFillsByPriority(t *testing.T) {
+	long := strings.Repeat("word word word word\n", 50)
+
+	got := NewWithBudget(120).
+		Add(Section{Content: "Instructions\n", Required: true}).
+		Add(Section{Title: "History", Content: long, Priority: PriorityLow}).
+		Add(Section{Title: "Diff", Content: long, Priority: PriorityNormal, Truncate: Head}).
+		Build()
+
+	if !strings.HasPrefix(got, "Instructions\n") {
+		t.Errorf("expected required section first, got %q", got)
+	}
+	if strings.Contains(got, "History:") {
+		t.Error("expected low-priority section without truncation to be dropped")
+	}
+	if !strings.Contains(got, "Diff:") || !strings.Contains(got, "lines omitted") {
+		t.Errorf("expected truncated diff section, got %q", got)
+	}
+	if tokens := EstimateTokens(got); tokens > 120 {
+		t.Errorf("prompt uses %d tokens, budget is 120", tokens)
+	}
+}
+
+func TestBuildKeepsRequired(t *testing.T) {
+	instructions := strings.Repeat("rule\n", 100)
+
+	got := NewWithBudget(10).
+		Add(Section{Content: instructions, Required: true}).
+		Add(Section{Title: "Diff", Content: "+x", Priority: PriorityHigh, Truncate: Head}).
+		Build()
+
+	if got != instructions {
+		t.Errorf("expected only the required section, got %q", got)
+	}
+}
+
+func TestHeadTail(t *testing.T) {
+	content := "first\n" + strings.Repeat("middle\n", 40) + "last"
+
+	if got := Head(content, 1000); got != content {
+		t.Errorf("Head() with large budget = %q, want content unchanged", got)
+	}
+
+	head := Head(content, 40)
+	if !strings.HasPrefix(head, "first\n") || strings.Contains(head, "last") {
+		t.Errorf("Head() = %q, want leading lines", head)
+	}
+
+	tail := Tail(content, 40)
+	if !strings.HasSuffix(tail, "\nlast") || strings.Contains(tail, "first") {
+		t.Errorf("Tail() = %q, want trailing lines", tail)
+	}
+}
@@ -0,0 +1,197 @@
+package prompt
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/emiliopalmerini/grimorio/internal/claude"
+	"github.com/emiliopalmerini/grimorio/internal/git"
+)
+
+//go:embed templates/*.tmpl
+var builtin embed.FS
+
+// OverrideDir holds prompt overrides, relative to the repository root.
+const OverrideDir = ".grimorio/prompts"
+
+// SourceBuiltin is the Source of templates embedded in the binary.
+const SourceBuiltin = "built-in"
+
+// Spells lists the spells whose prompts are templates.
+var Spells = []string{"augury", "augury-fix", "identify", "identify-package", "inscribe", "modify-memory", "scrying", "sending", "transmute"}
+
+// Data holds the variables available to prompt templates. Not every spell
+// sets every field; unset ones are empty.
+type Data struct {
+	Diff        string // Staged, branch or working tree diff, or the changes to the file (transmute)
+	Commits     string // Commits on the branch (sending)
+	History     string // Recent commit subjects (modify-memory)
+	Description string // User-provided context or motivation
+	LSPContext  string // Symbols and hover info from the language server (identify), or the package layout (identify-package)
+	APIChanges  string // Exported API report (sending)
+	Code        string // File contents (identify, inscribe, transmute, user spells), or code at the error locations (augury)
+	Path        string // Path of that file (inscribe, transmute, user spells), or the directory (identify-package)
+	Symbol      string // Symbol to focus on (identify)
+	Command     string // Command that was run (augury), or runs the tests (transmute)
+	ExitCode    int    // Its exit code (augury, transmute)
+	Stdout      string // Its standard output (augury, transmute)
+	Stderr      string // Its standard error (augury, transmute)
+	Stdin       string // Piped standard input (user spells)
+	Errors      string // Errors parsed from the command output (augury, transmute)
+	Docs        string // README and package doc comments (identify-package)
+	Language    string // Language of the file (inscribe)
+	Symbols     string // Symbols to document (inscribe), or changed functions (transmute), one per line
+	Tests       string // Current contents of the test file (transmute)
+	TestPath    string // Path of that test file (transmute)
+}
+
+// field is a budgeted variable of Data.
+type field struct {
+	value    *string
+	priority int
+	truncate Truncate
+}
+
+// fields returns the variables that are fitted to the token budget, with
+// the fill priority and truncation of each.
+func (d *Data) fields() []field {
+	return []field{
+		{&d.Description, PriorityHigh, Head},
+		{&d.APIChanges, PriorityHigh, Head},
+		{&d.Stderr, PriorityHigh, Tail},
+		{&d.Errors, PriorityHigh, Head},
+		{&d.Diff, PriorityNormal, Head},
+		{&d.Code, PriorityNormal, Head},
+		{&d.Tests, PriorityNormal, Head},
+		{&d.Stdout, PriorityNormal, Tail},
+		{&d.Stdin, PriorityNormal, Head},
+		{&d.History, PriorityLow, Head},
+		{&d.Commits, PriorityLow, Head},
+		{&d.LSPContext, PriorityLow, Head},
+		{&d.Docs, PriorityLow, Head},
+	}
+}
+
+// Template is a spell prompt template.
+type Template struct {
+	Spell  string
+	Source string // File path of an override, or SourceBuiltin
+	Text   string
+
+	tmpl *template.Template
+}
+
+// Load returns the effective template of spell: the repository override
+// if there is one, the built-in template otherwise.
+func Load(spell string) (*Template, error) {
+	if path, err := OverridePath(spell); err == nil {
+		text, err := os.ReadFile(path)
+		if err == nil {
+			return Parse(spell, path, string(text))
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return Builtin(spell)
+}
+
+// Builtin returns the template embedded in the binary for spell.
+func Builtin(spell string) (*Template, error) {
+	if !isSpell(spell) {
+		return nil, fmt.Errorf("unknown spell %q (expected one of %s)", spell, strings.Join(Spells, ", "))
+	}
+	text, err := builtin.ReadFile("templates/" + spell + ".tmpl")
+	if err != nil {
+		return nil, err
+	}
+	return Parse(spell, SourceBuiltin, string(text))
+}
+
+// OverridePath returns where the repository override of spell lives.
+func OverridePath(spell string) (string, error) {
+	if !isSpell(spell) {
+		return "", fmt.Errorf("unknown spell %q (expected one of %s)", spell, strings.Join(Spells, ", "))
+	}
+	root, err := git.GetRootDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, OverrideDir, spell+".tmpl"), nil
+}
+
+// Parse parses and validates a template. It fails on syntax errors and on
+// references to variables that Data does not have.
+func Parse(spell, source, text string) (*Template, error) {
+	tmpl, err := template.New(spell).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt template %s: %w", source, err)
+	}
+
+	sample := Data{ExitCode: 1}
+	for _, f := range sample.fields() {
+		*f.value = "sample"
+	}
+	sample.Symbol, sample.Command, sample.Path = "sample", "sample", "sample"
+	sample.Language, sample.Symbols, sample.TestPath = "sample", "sample", "sample"
+	if err := tmpl.Execute(io.Discard, sample); err != nil {
+		return nil, fmt.Errorf("invalid prompt template %s: %w", source, err)
+	}
+
+	return &Template{Spell: spell, Source: source, Text: text, tmpl: tmpl}, nil
+}
+
+// Render fills the template for model. Large variables are fitted to what
+// is left of the model's budget after the template text, by priority, the
+// same way Builder fits sections.
+func (t *Template) Render(model claude.Model, data Data) (string, error) {
+	skeleton := data
+	for _, f := range skeleton.fields() {
+		if *f.value != "" {
+			*f.value = "x"
+		}
+	}
+	static, err := t.execute(skeleton)
+	if err != nil {
+		return "", err
+	}
+
+	budget, err := ContextBudget(model)
+	if err != nil {
+		return "", err
+	}
+	fields := data.fields()
+	b := NewWithBudget(budget - EstimateTokens(static))
+	for _, f := range fields {
+		b.Add(Section{Content: *f.value, Priority: f.priority, Truncate: f.truncate})
+	}
+	for i, content := range b.fit() {
+		*fields[i].value = content
+	}
+
+	return t.execute(data)
+}
+
+func (t *Template) execute(data Data) (string, error) {
+	var sb strings.Builder
+	if err := t.tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("render prompt template %s: %w", t.Source, err)
+	}
+	return sb.String(), nil
+}
+
+func isSpell(name string) bool {
+	for _, s := range Spells {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
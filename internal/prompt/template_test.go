@@ -0,0 +1,113 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/emiliopalmerini/grimorio/internal/claude"
+)
+
+func TestBuiltinTemplates(t *testing.T) {
+	for _, spell := range Spells {
+		tmpl, err := Builtin(spell)
+		if err != nil {
+			t.Errorf("Builtin(%q) error = %v", spell, err)
+			continue
+		}
+		if tmpl.Source != SourceBuiltin || tmpl.Text == "" {
+			t.Errorf("Builtin(%q) = %+v", spell, tmpl)
+		}
+	}
+
+	if _, err := Builtin("fireball"); err == nil {
+		t.Error("Builtin(fireball) expected error")
+	}
+}
+
+func TestRenderMatchesSections(t *testing.T) {
+	tmpl, err := Builtin("modify-memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := tmpl.Render(claude.Haiku, Data{
+		Diff:        "diff --git a/x b/x\n+added",
+		History:     "feat: one\nfix: two",
+		Description: "because",
+	})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"nothing else\n\nRecent commits (match this style):\nfeat: one\nfix: two\n",
+		"\nUser motivation:\nbecause\n",
+		"\nDiff:\ndiff --git a/x b/x\n+added\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Render() missing %q in:\n%s", want, got)
+		}
+	}
+
+	got, err = tmpl.Render(claude.Haiku, Data{Diff: "+added"})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if strings.Contains(got, "Recent commits") || strings.Contains(got, "User motivation") {
+		t.Errorf("Render() kept empty sections:\n%s", got)
+	}
+}
+
+func TestRenderAugury(t *testing.T) {
+	tmpl, err := Builtin("augury")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := tmpl.Render(claude.Sonnet, Data{Command: "go build", ExitCode: 2, Stderr: "undefined: foo"})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	want := "Analyze errors/warnings and suggest fixes.\n\nCommand: go build\nExit code: 2\n\nStderr:\nundefined: foo\n\n"
+	if got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+}
+
+func TestRenderBudget(t *testing.T) {
+	tmpl, err := Parse("scrying", "test", "Review.\n{{.Description}}\n{{.Diff}}\n{{.History}}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff := strings.Repeat("+ a changed line of code\n", 2000)
+	got, err := tmpl.Render(claude.Opus, Data{Diff: diff, Description: "keep me", History: "feat: one"})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	if tokens := EstimateTokens(got); tokens > DefaultBudget {
+		t.Errorf("Render() = %d tokens, over the %d budget", tokens, DefaultBudget)
+	}
+	if !strings.Contains(got, "keep me") {
+		t.Error("Render() dropped the high-priority description")
+	}
+	if !strings.Contains(got, "lines omitted") {
+		t.Error("Render() did not truncate the diff")
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := map[string]string{
+		"syntax":        "{{if .Diff}}unclosed",
+		"unknown field": "{{.Diffs}}",
+		"bad function":  "{{upper .Diff}}",
+	}
+	for name, text := range tests {
+		if _, err := Parse("scrying", "override.tmpl", text); err == nil {
+			t.Errorf("%s: Parse() expected error", name)
+		} else if !strings.Contains(err.Error(), "override.tmpl") {
+			t.Errorf("%s: error %q does not name the file", name, err)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package prompt
+
+import "unicode"
+
+// charsPerToken is the average length of a word piece in Claude's tokenizer.
+const charsPerToken = 4
+
+// EstimateTokens approximates the number of tokens in s without calling the
+// API. Runs of letters and digits count one token per charsPerToken
+// characters, other symbols count one token each, and whitespace is free
+// except for line breaks.
+func EstimateTokens(s string) int {
+	tokens := 0
+	word := 0
+
+	flushWord := func() {
+		if word > 0 {
+			tokens += (word + charsPerToken - 1) / charsPerToken
+			word = 0
+		}
+	}
+
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			word++
+		case r == '\n':
+			flushWord()
+			tokens++
+		case unicode.IsSpace(r):
+			flushWord()
+		default:
+			flushWord()
+			tokens++
+		}
+	}
+	flushWord()
+
+	return tokens
+}
@@ -0,0 +1,56 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/emiliopalmerini/grimorio/internal/claude"
+)
+
+// turnOverhead is reserved for the tags around a shortened turn.
+const turnOverhead = 24
+
+// Transcript frames next as the message after the earlier turns of a
+// session, within the model's budget. The latest turns are kept first;
+// older ones are shortened, then dropped, once the conversation outgrows
+// the budget. It is a claude.Frame.
+func Transcript(model claude.Model, turns []claude.Turn, next string) (string, error) {
+	b, err := New(model)
+	if err != nil {
+		return "", err
+	}
+	return transcript(b, turns, next), nil
+}
+
+func transcript(b *Builder, turns []claude.Turn, next string) string {
+	b.Add(Section{Content: "This is a follow-up in an ongoing conversation. The conversation so far:\n\n", Required: true})
+	for i, t := range turns {
+		b.Add(Section{
+			Content:  formatTurn(t.Prompt, t.Response),
+			Priority: PriorityHigh - (len(turns) - 1 - i),
+			Truncate: truncateTurn(t),
+		})
+	}
+	b.Add(Section{
+		Content:  fmt.Sprintf("Answer the next message, building on your earlier answers:\n\n<user>\n%s\n</user>\n", strings.TrimSpace(next)),
+		Required: true,
+	})
+	return b.Build()
+}
+
+func formatTurn(prompt, response string) string {
+	return fmt.Sprintf("<user>\n%s\n</user>\n\n<assistant>\n%s\n</assistant>\n\n", strings.TrimSpace(prompt), response)
+}
+
+// truncateTurn shortens the message of t to at most half the room and
+// gives the rest to the answer, keeping the tags around them.
+func truncateTurn(t claude.Turn) Truncate {
+	return func(_ string, maxTokens int) string {
+		room := maxTokens - turnOverhead
+		if room/2 <= markerTokens {
+			return ""
+		}
+		prompt := Head(strings.TrimSpace(t.Prompt), room/2)
+		return formatTurn(prompt, Head(t.Response, room-EstimateTokens(prompt)))
+	}
+}
@@ -0,0 +1,50 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/emiliopalmerini/grimorio/internal/claude"
+)
+
+func TestTranscript(t *testing.T) {
+	turns := []claude.Turn{{Prompt: "Explain diff.go", Response: "It parses diffs."}}
+	got, err := Transcript(claude.Sonnet, turns, "why?")
+	if err != nil {
+		t.Fatalf("Transcript() error = %v", err)
+	}
+	for _, want := range []string{
+		"<user>\nExplain diff.go\n</user>",
+		"<assistant>\nIt parses diffs.\n</assistant>",
+		"<user>\nwhy?\n</user>\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Transcript() missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestTranscriptCut(t *testing.T) {
+	long := strings.Repeat("a line of the first answer\n", 200)
+	turns := []claude.Turn{
+		{Prompt: "Explain diff.go", Response: "oldest " + long},
+		{Prompt: "and hunks?", Response: "middle " + long},
+		{Prompt: "and scores?", Response: "latest answer"},
+	}
+	got := transcript(NewWithBudget(1000), turns, "why?")
+
+	if tokens := EstimateTokens(got); tokens > 1000 {
+		t.Errorf("Transcript() = %d tokens, want at most 1000", tokens)
+	}
+	for _, want := range []string{"<assistant>\nlatest answer\n</assistant>", "<user>\nwhy?\n</user>", "lines omitted"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Transcript() missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "oldest") {
+		t.Errorf("Transcript() kept the oldest turn over the newer ones:\n%s", got)
+	}
+	if !strings.Contains(got, "middle") {
+		t.Errorf("Transcript() dropped the shortened middle turn:\n%s", got)
+	}
+}
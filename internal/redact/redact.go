@@ -0,0 +1,158 @@
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/emiliopalmerini/grimorio/internal/config"
+)
+
+// diffHeaderRe matches the first line of a file in a git diff.
+var diffHeaderRe = regexp.MustCompile(`^diff --git a/(.+) b/(.+)$`)
+
+// Redactor scrubs secrets and personal data from text sent to the model.
+type Redactor struct {
+	allow   []*regexp.Regexp
+	exclude []string
+}
+
+// New returns a redactor using the built-in rules and the repository's
+// [redaction] config.
+func New(cfg config.Redaction) (*Redactor, error) {
+	r := &Redactor{
+		exclude: append(append([]string{}, DefaultExclude...), cfg.Exclude...),
+	}
+	for _, pattern := range append(append([]string{}, defaultAllow...), cfg.Allow...) {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redaction.allow: invalid pattern %q: %w", pattern, err)
+		}
+		r.allow = append(r.allow, re)
+	}
+	return r, nil
+}
+
+// Load returns the redactor for the current repository.
+func Load() (*Redactor, error) {
+	cfg, err := config.LoadRepo()
+	if err != nil {
+		return nil, err
+	}
+	return New(cfg.Redaction)
+}
+
+// Excluded reports whether a file must never be sent.
+func (r *Redactor) Excluded(path string) bool {
+	for _, glob := range r.exclude {
+		if config.MatchGlob(glob, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact replaces secrets in text with placeholders and drops the contents
+// of excluded files from diffs. It returns the redacted text and the number
+// of redactions. The same value always maps to the same placeholder.
+func (r *Redactor) Redact(text string) (string, int) {
+	text, dropped := r.dropExcluded(text)
+
+	type span struct {
+		start, end int
+		kind       string
+	}
+
+	allowed := r.allowedSpans(text)
+	var found []span
+	for _, rl := range rules {
+		for _, m := range rl.re.FindAllStringSubmatchIndex(text, -1) {
+			start, end := m[0], m[1]
+			if rl.group > 0 {
+				start, end = m[2*rl.group], m[2*rl.group+1]
+			}
+			if start < 0 || overlapsAny(start, end, allowed) {
+				continue
+			}
+			if rl.check != nil && !rl.check(text[start:end]) {
+				continue
+			}
+			found = append(found, span{start, end, rl.kind})
+		}
+	}
+	if len(found) == 0 {
+		return text, dropped
+	}
+
+	// Earlier rules are more specific; keep them on overlaps
+	sort.SliceStable(found, func(i, j int) bool { return found[i].start < found[j].start })
+
+	var sb strings.Builder
+	last, count := 0, 0
+	for _, s := range found {
+		if s.start < last {
+			continue
+		}
+		sb.WriteString(text[last:s.start])
+		sb.WriteString(placeholder(s.kind, text[s.start:s.end]))
+		last = s.end
+		count++
+	}
+	sb.WriteString(text[last:])
+
+	return sb.String(), dropped + count
+}
+
+// dropExcluded replaces the body of excluded files in a git diff with a note.
+func (r *Redactor) dropExcluded(text string) (string, int) {
+	if !strings.Contains(text, "diff --git ") {
+		return text, 0
+	}
+
+	lines := strings.Split(text, "\n")
+	out := make([]string, 0, len(lines))
+	dropped := 0
+	skipping := false
+	for _, line := range lines {
+		if m := diffHeaderRe.FindStringSubmatch(line); m != nil {
+			skipping = r.Excluded(m[1]) || r.Excluded(m[2])
+			out = append(out, line)
+			if skipping {
+				out = append(out, "[contents excluded by redaction rules]")
+				dropped++
+			}
+			continue
+		}
+		if !skipping {
+			out = append(out, line)
+		}
+	}
+	return strings.Join(out, "\n"), dropped
+}
+
+func (r *Redactor) allowedSpans(text string) [][]int {
+	var spans [][]int
+	for _, re := range r.allow {
+		spans = append(spans, re.FindAllStringIndex(text, -1)...)
+	}
+	return spans
+}
+
+func overlapsAny(start, end int, spans [][]int) bool {
+	for _, s := range spans {
+		if start < s[1] && s[0] < end {
+			return true
+		}
+	}
+	return false
+}
+
+// placeholder derives a stable placeholder from the redacted value, so the
+// model can still tell repeated values apart.
+func placeholder(kind, value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return fmt.Sprintf("[REDACTED:%s:%s]", kind, hex.EncodeToString(sum[:3]))
+}
@@ -1,87 +1,64 @@
 package augury
 
 import (
-	"bytes"
-	"fmt"
-	"os"
-	"os/exec"
 	"strings"
 
 	"github.com/emiliopalmerini/grimorio/internal/claude"
 	"github.com/emiliopalmerini/grimorio/internal/git"
+	"github.com/emiliopalmerini/grimorio/internal/prompt"
 )
 
-const (
-	maxOutputLines = 100
-	maxDiffLines   = 200
-)
+// DefaultModel analyzes failures unless configured otherwise.
+const DefaultModel = claude.Sonnet
 
+// Result is a finished command and its output.
 type Result struct {
 	Command  string
 	ExitCode int
 	Stdout   string
 	Stderr   string
-}
 
-func truncateTail(s string, maxLines int) string {
-	if s == "" || maxLines <= 0 {
-		return s
-	}
-	lines := strings.Split(s, "\n")
-	if len(lines) <= maxLines {
-		return s
-	}
-	omitted := len(lines) - maxLines
-	kept := lines[omitted:]
-	return fmt.Sprintf("[... %d lines omitted, showing last %d ...]\n", omitted, maxLines) + strings.Join(kept, "\n")
+	// TimedOut and Interrupted tell whether the command was stopped by the
+	// timeout or by Ctrl+C rather than exiting by itself.
+	TimedOut    bool
+	Interrupted bool
 }
 
-func RunCommand(command string) (*Result, error) {
-	cmd := exec.Command("sh", "-c", command)
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	cmd.Env = os.Environ()
+func Analyze(model claude.Model, result *Result) (string, error) {
+	if result.ExitCode == 0 && result.Stderr == "" {
+		return "Command succeeded with no errors.", nil
+	}
 
-	err := cmd.Run()
-	exitCode := 0
+	text, err := Prompt(model, result)
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			exitCode = exitErr.ExitCode()
-		}
+		return "", err
 	}
 
-	return &Result{
-		Command:  command,
-		ExitCode: exitCode,
-		Stdout:   stdout.String(),
-		Stderr:   stderr.String(),
-	}, nil
+	return claude.Run(model, "augury", text)
 }
 
-func Analyze(result *Result) (string, error) {
-	if result.ExitCode == 0 && result.Stderr == "" {
-		return "Command succeeded with no errors.", nil
-	}
-
-	prompt := fmt.Sprintf("Analyze errors/warnings and suggest fixes.\n\nCommand: %s\nExit code: %d\n\n", result.Command, result.ExitCode)
-
-	if result.Stderr != "" {
-		prompt += "Stderr:\n" + truncateTail(result.Stderr, maxOutputLines) + "\n\n"
+// Prompt renders the augury prompt for result without sending it.
+func Prompt(model claude.Model, result *Result) (string, error) {
+	data := prompt.Data{
+		Command:  result.Command,
+		ExitCode: result.ExitCode,
+		Stdout:   result.Stdout,
+		Stderr:   result.Stderr,
 	}
-	if result.Stdout != "" {
-		prompt += "Stdout:\n" + truncateTail(result.Stdout, maxOutputLines) + "\n\n"
+	// Code around the error locations says more than the whole diff; fall
+	// back to the diff when the output has no recognizable locations
+	diags := ParseDiagnostics(result.Stderr + result.Stdout)
+	data.Errors = FormatDiagnostics(diags)
+	data.Code = Snippets(diags)
+	if data.Code == "" && looksCodeRelated(result.Stderr+result.Stdout) {
+		data.Diff, _ = git.GetDiff(git.DiffOptions{All: true})
 	}
 
-	errorOutput := result.Stderr + result.Stdout
-	if looksCodeRelated(errorOutput) {
-		diff, _ := git.GetDiff(git.DiffOptions{All: true, MaxLines: maxDiffLines})
-		if diff != "" {
-			prompt += "Recent changes:\n" + diff
-		}
+	tmpl, err := prompt.Load("augury")
+	if err != nil {
+		return "", err
 	}
-
-	return claude.Run(claude.Sonnet, "augury", prompt)
+	return tmpl.Render(model, data)
 }
 
 func looksCodeRelated(output string) bool {
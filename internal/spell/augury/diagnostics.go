@@ -0,0 +1,482 @@
+package augury
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/emiliopalmerini/grimorio/internal/git"
+	"github.com/emiliopalmerini/grimorio/internal/redact"
+)
+
+// Severity levels of a diagnostic.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// snippetContext is the number of lines shown around an error location.
+const snippetContext = 5
+
+// maxSnippets bounds how many error locations get a code snippet.
+const maxSnippets = 10
+
+// Diagnostic is one error, warning or failed test found in a command's
+// output.
+type Diagnostic struct {
+	Tool     string `json:"tool"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Code     string `json:"code,omitempty"` // Compiler or lint rule code, e.g. E0425 or no-unused-vars
+	Test     string `json:"test,omitempty"`
+}
+
+// Location returns file:line:col, or the parts of it that are known.
+func (d Diagnostic) Location() string {
+	switch {
+	case d.File == "":
+		return ""
+	case d.Line == 0:
+		return d.File
+	case d.Column == 0:
+		return fmt.Sprintf("%s:%d", d.File, d.Line)
+	default:
+		return fmt.Sprintf("%s:%d:%d", d.File, d.Line, d.Column)
+	}
+}
+
+func (d Diagnostic) String() string {
+	var sb strings.Builder
+	if loc := d.Location(); loc != "" {
+		sb.WriteString(loc + ": ")
+	}
+	sb.WriteString(d.Severity)
+	if d.Code != "" {
+		sb.WriteString(" " + d.Code)
+	}
+	sb.WriteString(": " + d.Message)
+	if d.Test != "" {
+		sb.WriteString(" (in " + d.Test + ")")
+	}
+	return sb.String()
+}
+
+// parser extracts diagnostics from the lines of one toolchain's output.
+type parser func(lines []string) []Diagnostic
+
+var parsers = []parser{
+	parseGo,
+	parseCargo,
+	parseMSBuild,
+	parseTSCPretty,
+	parseESLint,
+	parsePytest,
+	parseNix,
+}
+
+// ParseDiagnostics extracts the errors of go build/vet/test, cargo, tsc,
+// eslint, pytest, dotnet and nix from a command's output. Duplicates are
+// reported once, in order of appearance per toolchain.
+func ParseDiagnostics(output string) []Diagnostic {
+	lines := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+
+	var all []Diagnostic
+	seen := make(map[string]bool)
+	for _, parse := range parsers {
+		for _, d := range parse(lines) {
+			key := d.Location() + "\x00" + d.Message + "\x00" + d.Test
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			all = append(all, d)
+		}
+	}
+	return all
+}
+
+var (
+	goLocationRe = regexp.MustCompile(`^(?:vet: )?(\S+\.go):(\d+)(?::(\d+))?: (.+)$`)
+	goTestRunRe  = regexp.MustCompile(`^\s*=== RUN\s+(\S+)`)
+	goTestEndRe  = regexp.MustCompile(`^\s*--- (FAIL|PASS|SKIP): (\S+)`)
+	goTestLogRe  = regexp.MustCompile(`^\s+(\S+_test\.go):(\d+): (.+)$`)
+	goPanicRe    = regexp.MustCompile(`^panic: (.+?)(?: \[recovered\])?$`)
+)
+
+// parseGo handles go build, go vet and go test output. Lines logged by a
+// test are kept only if it fails: with -v they come before its FAIL line,
+// without it after.
+func parseGo(lines []string) []Diagnostic {
+	var diags []Diagnostic
+	var test string
+	var failed []string
+	isFailed := make(map[string]bool)
+	pending := make(map[string][]Diagnostic)
+
+	for _, line := range lines {
+		if m := goTestRunRe.FindStringSubmatch(line); m != nil {
+			test = m[1]
+			continue
+		}
+		if m := goTestEndRe.FindStringSubmatch(line); m != nil {
+			test = m[2]
+			if m[1] == "FAIL" {
+				isFailed[test] = true
+				failed = append(failed, test)
+				diags = append(diags, pending[test]...)
+			}
+			delete(pending, test)
+			continue
+		}
+		if m := goTestLogRe.FindStringSubmatch(line); m != nil {
+			d := Diagnostic{Tool: "go test", File: m[1], Line: atoi(m[2]), Severity: SeverityError, Message: m[3], Test: test}
+			if isFailed[test] {
+				diags = append(diags, d)
+			} else {
+				pending[test] = append(pending[test], d)
+			}
+			continue
+		}
+		if m := goLocationRe.FindStringSubmatch(line); m != nil {
+			tool := "go build"
+			if strings.HasPrefix(line, "vet: ") {
+				tool = "go vet"
+			}
+			diags = append(diags, Diagnostic{Tool: tool, File: m[1], Line: atoi(m[2]), Column: atoi(m[3]), Severity: SeverityError, Message: m[4]})
+			continue
+		}
+		if m := goPanicRe.FindStringSubmatch(line); m != nil {
+			diags = append(diags, Diagnostic{Tool: "go test", Severity: SeverityError, Message: "panic: " + m[1], Test: test})
+		}
+	}
+
+	// Tests that failed without logging anything still count
+	for _, t := range failed {
+		if !hasTest(diags, t) {
+			diags = append(diags, Diagnostic{Tool: "go test", Severity: SeverityError, Message: "test failed", Test: t})
+		}
+	}
+	return diags
+}
+
+func hasTest(diags []Diagnostic, test string) bool {
+	for _, d := range diags {
+		if d.Test == test {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	cargoHeaderRe   = regexp.MustCompile(`^(error|warning)(?:\[(\w+)\])?: (.+)$`)
+	cargoLocationRe = regexp.MustCompile(`^\s*--> (.+?):(\d+):(\d+)$`)
+)
+
+// parseCargo handles rustc diagnostics as printed by cargo build, check,
+// clippy and test.
+func parseCargo(lines []string) []Diagnostic {
+	var diags []Diagnostic
+	for i, line := range lines {
+		m := cargoHeaderRe.FindStringSubmatch(line)
+		if m == nil || i+1 >= len(lines) {
+			continue
+		}
+		loc := cargoLocationRe.FindStringSubmatch(lines[i+1])
+		if loc == nil {
+			continue
+		}
+		diags = append(diags, Diagnostic{Tool: "cargo", File: loc[1], Line: atoi(loc[2]), Column: atoi(loc[3]), Severity: m[1], Code: m[2], Message: m[3]})
+	}
+	return diags
+}
+
+var msbuildRe = regexp.MustCompile(`^\s*(.+?)\((\d+),(\d+)(?:,\d+,\d+)?\): (error|warning) (\w+): (.+?)(?: \[[^\]]+\])?$`)
+
+// parseMSBuild handles the file(line,col): error CODE: message format
+// shared by dotnet build and tsc without --pretty.
+func parseMSBuild(lines []string) []Diagnostic {
+	var diags []Diagnostic
+	for _, line := range lines {
+		m := msbuildRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		tool := "dotnet"
+		if strings.HasPrefix(m[5], "TS") {
+			tool = "tsc"
+		}
+		diags = append(diags, Diagnostic{Tool: tool, File: m[1], Line: atoi(m[2]), Column: atoi(m[3]), Severity: m[4], Code: m[5], Message: m[6]})
+	}
+	return diags
+}
+
+var tscPrettyRe = regexp.MustCompile(`^(\S+\.[cm]?[jt]sx?):(\d+):(\d+) - (error|warning) (TS\d+): (.+)$`)
+
+// parseTSCPretty handles tsc output in its default, pretty format.
+func parseTSCPretty(lines []string) []Diagnostic {
+	var diags []Diagnostic
+	for _, line := range lines {
+		if m := tscPrettyRe.FindStringSubmatch(stripANSI(line)); m != nil {
+			diags = append(diags, Diagnostic{Tool: "tsc", File: m[1], Line: atoi(m[2]), Column: atoi(m[3]), Severity: m[4], Code: m[5], Message: m[6]})
+		}
+	}
+	return diags
+}
+
+var (
+	eslintFileRe  = regexp.MustCompile(`^(/\S+|[A-Za-z]:\\\S+|\S+\.(?:[cm]?[jt]sx?|vue|svelte))$`)
+	eslintIssueRe = regexp.MustCompile(`^\s+(\d+):(\d+)\s+(error|warning)\s+(.+?)(?:\s{2,}(\S+))?$`)
+)
+
+// parseESLint handles eslint's default stylish format: a file name, then
+// one indented line per problem.
+func parseESLint(lines []string) []Diagnostic {
+	var diags []Diagnostic
+	var file string
+	for _, line := range lines {
+		if m := eslintFileRe.FindStringSubmatch(line); m != nil {
+			file = m[1]
+			continue
+		}
+		if file == "" {
+			continue
+		}
+		if m := eslintIssueRe.FindStringSubmatch(line); m != nil {
+			diags = append(diags, Diagnostic{Tool: "eslint", File: file, Line: atoi(m[1]), Column: atoi(m[2]), Severity: m[3], Message: m[4], Code: m[5]})
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			file = ""
+		}
+	}
+	return diags
+}
+
+var (
+	pytestFailedRe   = regexp.MustCompile(`^(?:FAILED|ERROR) (\S+?\.py)::(\S+)(?: - (.+))?$`)
+	pytestLocationRe = regexp.MustCompile(`^(\S+\.py):(\d+): (\w+(?:Error|Exception|Exit)\b.*)$`)
+)
+
+// parsePytest handles pytest's short test summary and the locations in its
+// tracebacks.
+func parsePytest(lines []string) []Diagnostic {
+	var diags []Diagnostic
+	located := make(map[string]int)
+	for _, line := range lines {
+		if m := pytestLocationRe.FindStringSubmatch(line); m != nil {
+			located[m[1]] = len(diags)
+			diags = append(diags, Diagnostic{Tool: "pytest", File: m[1], Line: atoi(m[2]), Severity: SeverityError, Message: m[3]})
+		}
+	}
+	for _, line := range lines {
+		m := pytestFailedRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		message := m[3]
+		if message == "" {
+			message = "test failed"
+		}
+		// Attach the test to the traceback location in its file, if any
+		if i, ok := located[m[1]]; ok && diags[i].Test == "" {
+			diags[i].Test = m[2]
+			continue
+		}
+		diags = append(diags, Diagnostic{Tool: "pytest", File: m[1], Severity: SeverityError, Message: message, Test: m[2]})
+	}
+	return diags
+}
+
+var (
+	nixErrorRe    = regexp.MustCompile(`^\s*error: (.+?)(?: at (/\S+?):(\d+):(\d+))?:?$`)
+	nixLocationRe = regexp.MustCompile(`^\s+at (?:«\w+»)?(\S+?):(\d+):(\d+):?$`)
+)
+
+// parseNix handles nix evaluation and build errors, with the location on
+// the error line (older nix) or on the following "at" line. Newer nix
+// indents the innermost error under a trace.
+func parseNix(lines []string) []Diagnostic {
+	var diags []Diagnostic
+	mentionsNix := strings.Contains(strings.Join(lines, "\n"), ".nix")
+	for i, line := range lines {
+		m := nixErrorRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		d := Diagnostic{Tool: "nix", Severity: SeverityError, Message: m[1], File: m[2], Line: atoi(m[3]), Column: atoi(m[4])}
+		if d.File == "" {
+			for j := i + 1; j < len(lines) && j <= i+3; j++ {
+				if loc := nixLocationRe.FindStringSubmatch(lines[j]); loc != nil {
+					d.File, d.Line, d.Column = loc[1], atoi(loc[2]), atoi(loc[3])
+					break
+				}
+			}
+		}
+		if d.File == "" && !mentionsNix {
+			// A bare "error:" line is not necessarily from nix
+			continue
+		}
+		diags = append(diags, d)
+	}
+	return diags
+}
+
+// Snippets returns the code around each error location, numbered, so the
+// prompt shows exactly the lines that failed. Locations outside the
+// repository or excluded by the redaction rules are skipped.
+func Snippets(diags []Diagnostic) string {
+	files := newFileReader()
+	if files == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	shown := make(map[string]bool)
+	count := 0
+	for _, d := range diags {
+		if d.File == "" || d.Line == 0 || count == maxSnippets {
+			continue
+		}
+		rel, lines, ok := files.read(d.File)
+		if !ok || d.Line > len(lines) {
+			continue
+		}
+		key := fmt.Sprintf("%s:%d", rel, d.Line)
+		if shown[key] {
+			continue
+		}
+		shown[key] = true
+		count++
+
+		start := max(d.Line-snippetContext, 1)
+		end := min(d.Line+snippetContext, len(lines))
+		fmt.Fprintf(&sb, "=== %s ===\n", key)
+		for n := start; n <= end; n++ {
+			marker := " "
+			if n == d.Line {
+				marker = ">"
+			}
+			fmt.Fprintf(&sb, "%s%5d | %s\n", marker, n, lines[n-1])
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// FormatDiagnostics lists diagnostics one per line, for the prompt.
+func FormatDiagnostics(diags []Diagnostic) string {
+	var sb strings.Builder
+	for _, d := range diags {
+		sb.WriteString("- " + d.String() + "\n")
+	}
+	return sb.String()
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// fileReader reads repository files named in command output, once each.
+type fileReader struct {
+	root     string
+	redactor *redact.Redactor
+	cache    map[string][]string
+	// byName lists the repository files by base name, once a bare name
+	// has not been found otherwise.
+	byName map[string][]string
+}
+
+// newFileReader returns nil outside a repository or when the redaction
+// rules cannot be loaded, since nothing should be sent then.
+func newFileReader() *fileReader {
+	root, err := git.GetRootDir()
+	if err != nil {
+		return nil
+	}
+	redactor, err := redact.Load()
+	if err != nil {
+		return nil
+	}
+	return &fileReader{root: root, redactor: redactor, cache: make(map[string][]string)}
+}
+
+// read resolves path against the working directory, then the repository
+// root, and returns it relative to the root with its lines. A bare file
+// name found in neither, as go test prints for a package in a
+// subdirectory, is looked up by name if only one file has it.
+func (r *fileReader) read(path string) (string, []string, bool) {
+	abs, rel, ok := resolve(r.root, path)
+	if ok && filepath.Base(path) == path {
+		if _, err := os.Stat(abs); err != nil {
+			abs, rel, ok = r.find(path)
+		}
+	}
+	if !ok || r.redactor.Excluded(rel) {
+		return "", nil, false
+	}
+	if lines, ok := r.cache[rel]; ok {
+		return rel, lines, lines != nil
+	}
+
+	content, err := os.ReadFile(abs)
+	if err != nil {
+		r.cache[rel] = nil
+		return "", nil, false
+	}
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	r.cache[rel] = lines
+	return rel, lines, true
+}
+
+// find returns the only file in the repository named name, skipping
+// hidden, vendor and node_modules directories.
+func (r *fileReader) find(name string) (string, string, bool) {
+	if r.byName == nil {
+		r.byName = make(map[string][]string)
+		filepath.WalkDir(r.root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if path != r.root && (strings.HasPrefix(d.Name(), ".") || d.Name() == "vendor" || d.Name() == "node_modules") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			r.byName[d.Name()] = append(r.byName[d.Name()], path)
+			return nil
+		})
+	}
+	if paths := r.byName[name]; len(paths) == 1 {
+		return resolve(r.root, paths[0])
+	}
+	return "", "", false
+}
+
+// resolve finds path in the repository and returns its absolute path and
+// its slash-separated path relative to root.
+func resolve(root, path string) (string, string, bool) {
+	if !filepath.IsAbs(path) {
+		if _, err := os.Stat(path); err != nil {
+			path = filepath.Join(root, path)
+		}
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", false
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", "", false
+	}
+	return abs, filepath.ToSlash(rel), true
+}
@@ -0,0 +1,240 @@
+package augury
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseDiagnostics(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []Diagnostic
+	}{
+		{
+			name:   "go build",
+			output: "# example.com/x\n./x.go:3:23: undefined: foo\n./y.go:10:2: declared and not used: z\n",
+			want: []Diagnostic{
+				{Tool: "go build", File: "./x.go", Line: 3, Column: 23, Severity: "error", Message: "undefined: foo"},
+				{Tool: "go build", File: "./y.go", Line: 10, Column: 2, Severity: "error", Message: "declared and not used: z"},
+			},
+		},
+		{
+			name:   "go vet",
+			output: "# example.com/x\nvet: ./x.go:5:2: fmt.Printf format %d has arg s of wrong type string\n",
+			want: []Diagnostic{
+				{Tool: "go vet", File: "./x.go", Line: 5, Column: 2, Severity: "error", Message: "fmt.Printf format %d has arg s of wrong type string"},
+			},
+		},
+		{
+			name: "go test",
+			output: `--- FAIL: TestParse (0.00s)
+    parse_test.go:12: got 1, want 2
+--- FAIL: TestEmpty (0.00s)
+FAIL
+FAIL	example.com/x	0.003s
+`,
+			want: []Diagnostic{
+				{Tool: "go test", File: "parse_test.go", Line: 12, Severity: "error", Message: "got 1, want 2", Test: "TestParse"},
+				{Tool: "go test", Severity: "error", Message: "test failed", Test: "TestEmpty"},
+			},
+		},
+		{
+			name: "go test -v",
+			output: `=== RUN   TestOK
+    ok_test.go:8: just logging
+--- PASS: TestOK (0.00s)
+=== RUN   TestTable
+=== RUN   TestTable/empty
+    table_test.go:20: unexpected error: boom
+--- FAIL: TestTable (0.00s)
+    --- FAIL: TestTable/empty (0.00s)
+FAIL
+`,
+			want: []Diagnostic{
+				{Tool: "go test", File: "table_test.go", Line: 20, Severity: "error", Message: "unexpected error: boom", Test: "TestTable/empty"},
+				{Tool: "go test", Severity: "error", Message: "test failed", Test: "TestTable"},
+			},
+		},
+		{
+			name: "go test panic",
+			output: `=== RUN   TestNil
+--- FAIL: TestNil (0.00s)
+panic: runtime error: invalid memory address or nil pointer dereference [recovered]
+`,
+			want: []Diagnostic{
+				{Tool: "go test", Severity: "error", Message: "panic: runtime error: invalid memory address or nil pointer dereference", Test: "TestNil"},
+			},
+		},
+		{
+			name: "cargo",
+			output: "error[E0425]: cannot find value `x` in this scope\n" +
+				" --> src/main.rs:2:5\n  |\n2 |     x\n  |     ^ not found in this scope\n\n" +
+				"warning: unused variable: `y`\n --> src/lib.rs:4:9\n\n" +
+				"error: could not compile `demo` (bin \"demo\") due to 1 previous error\n",
+			want: []Diagnostic{
+				{Tool: "cargo", File: "src/main.rs", Line: 2, Column: 5, Severity: "error", Code: "E0425", Message: "cannot find value `x` in this scope"},
+				{Tool: "cargo", File: "src/lib.rs", Line: 4, Column: 9, Severity: "warning", Message: "unused variable: `y`"},
+			},
+		},
+		{
+			name:   "tsc",
+			output: "src/app.ts(3,5): error TS2304: Cannot find name 'foo'.\n",
+			want: []Diagnostic{
+				{Tool: "tsc", File: "src/app.ts", Line: 3, Column: 5, Severity: "error", Code: "TS2304", Message: "Cannot find name 'foo'."},
+			},
+		},
+		{
+			name:   "tsc pretty",
+			output: "\x1b[96msrc/app.ts\x1b[0m:\x1b[93m3\x1b[0m:\x1b[93m5\x1b[0m - \x1b[91merror\x1b[0m\x1b[90m TS2304: \x1b[0mCannot find name 'foo'.\n\n3 foo()\n",
+			want: []Diagnostic{
+				{Tool: "tsc", File: "src/app.ts", Line: 3, Column: 5, Severity: "error", Code: "TS2304", Message: "Cannot find name 'foo'."},
+			},
+		},
+		{
+			name: "eslint",
+			output: `
+/home/dev/app/src/index.js
+   1:10  error    'foo' is defined but never used  no-unused-vars
+  12:3   warning  Unexpected console statement     no-console
+
+✖ 2 problems (1 error, 1 warning)
+`,
+			want: []Diagnostic{
+				{Tool: "eslint", File: "/home/dev/app/src/index.js", Line: 1, Column: 10, Severity: "error", Message: "'foo' is defined but never used", Code: "no-unused-vars"},
+				{Tool: "eslint", File: "/home/dev/app/src/index.js", Line: 12, Column: 3, Severity: "warning", Message: "Unexpected console statement", Code: "no-console"},
+			},
+		},
+		{
+			name: "pytest",
+			output: `    def test_add():
+>       assert add(1, 2) == 4
+E       assert 3 == 4
+
+tests/test_math.py:5: AssertionError
+=========================== short test summary info ============================
+FAILED tests/test_math.py::test_add - assert 3 == 4
+FAILED tests/test_io.py::test_read - FileNotFoundError: data.txt
+`,
+			want: []Diagnostic{
+				{Tool: "pytest", File: "tests/test_math.py", Line: 5, Severity: "error", Message: "AssertionError", Test: "test_add"},
+				{Tool: "pytest", File: "tests/test_io.py", Severity: "error", Message: "FileNotFoundError: data.txt", Test: "test_read"},
+			},
+		},
+		{
+			name:   "dotnet",
+			output: "/src/App/Program.cs(10,13): error CS0103: The name 'foo' does not exist in the current context [/src/App/App.csproj]\n",
+			want: []Diagnostic{
+				{Tool: "dotnet", File: "/src/App/Program.cs", Line: 10, Column: 13, Severity: "error", Code: "CS0103", Message: "The name 'foo' does not exist in the current context"},
+			},
+		},
+		{
+			name: "nix",
+			output: `error:
+       … while evaluating the attribute 'packages'
+
+       error: undefined variable 'pkgz'
+       at /home/dev/app/flake.nix:12:5:
+           11|   {
+           12|     pkgz.hello;
+`,
+			want: []Diagnostic{
+				{Tool: "nix", File: "/home/dev/app/flake.nix", Line: 12, Column: 5, Severity: "error", Message: "undefined variable 'pkgz'"},
+			},
+		},
+		{
+			name:   "nix legacy",
+			output: "error: undefined variable 'pkgz' at /home/dev/app/default.nix:3:5\n",
+			want: []Diagnostic{
+				{Tool: "nix", File: "/home/dev/app/default.nix", Line: 3, Column: 5, Severity: "error", Message: "undefined variable 'pkgz'"},
+			},
+		},
+		{
+			name:   "no errors",
+			output: "Build succeeded.\n    0 Warning(s)\n",
+		},
+	}
+
+	for _, tt := range tests {
+		got := ParseDiagnostics(tt.output)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: ParseDiagnostics() = %+v, want %+v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: diagnostic %d = %+v, want %+v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestDiagnosticString(t *testing.T) {
+	d := Diagnostic{File: "a.rs", Line: 2, Column: 5, Severity: "error", Code: "E0425", Message: "not found", Test: "it_works"}
+	if got, want := d.String(), "a.rs:2:5: error E0425: not found (in it_works)"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if got, want := (Diagnostic{Severity: "error", Message: "test failed"}).String(), "error: test failed"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestSnippets(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if out, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+
+	var lines []string
+	for i := 1; i <= 20; i++ {
+		lines = append(lines, "line")
+	}
+	lines[9] = "broken()"
+	os.WriteFile("main.go", []byte(strings.Join(lines, "\n")+"\n"), 0o644)
+	os.WriteFile(".env", []byte("TOKEN=secret\n"), 0o644)
+
+	got := Snippets([]Diagnostic{
+		{File: "./main.go", Line: 10, Column: 1},
+		{File: "main.go", Line: 10},
+		{File: ".env", Line: 1},
+		{File: "main.go", Line: 99},
+		{Message: "test failed"},
+	})
+
+	if strings.Count(got, "===") != 2 || !strings.HasPrefix(got, "=== main.go:10 ===\n") {
+		t.Fatalf("Snippets() = %q, want one snippet of main.go", got)
+	}
+	if !strings.Contains(got, ">   10 | broken()\n") || !strings.Contains(got, "     5 | line\n") || !strings.Contains(got, "    15 | line\n") {
+		t.Errorf("Snippets() does not show 5 lines around the error:\n%s", got)
+	}
+	if strings.Contains(got, "    16 |") || strings.Contains(got, "secret") {
+		t.Errorf("Snippets() shows too much:\n%s", got)
+	}
+}
+
+func TestSnippets_NestedPackage(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if out, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	os.MkdirAll(filepath.Join("internal", "foo"), 0o755)
+	os.MkdirAll(filepath.Join("internal", "bar"), 0o755)
+	os.WriteFile(filepath.Join("internal", "foo", "foo_test.go"), []byte("package foo\n\nfunc TestFoo() {}\n"), 0o644)
+	os.WriteFile(filepath.Join("internal", "foo", "util_test.go"), []byte("package foo\n"), 0o644)
+	os.WriteFile(filepath.Join("internal", "bar", "util_test.go"), []byte("package bar\n"), 0o644)
+
+	diags := ParseDiagnostics("--- FAIL: TestFoo (0.00s)\n    foo_test.go:3: boom\nFAIL\nFAIL\texample.com/m/internal/foo\t0.01s\n")
+	got := Snippets(append(diags, Diagnostic{File: "util_test.go", Line: 1}))
+
+	if !strings.HasPrefix(got, "=== internal/foo/foo_test.go:3 ===\n") || !strings.Contains(got, ">    3 | func TestFoo() {}\n") {
+		t.Errorf("Snippets() = %q, want the snippet of internal/foo/foo_test.go", got)
+	}
+	if strings.Contains(got, "util_test.go") {
+		t.Errorf("Snippets() = %q, want no snippet for an ambiguous name", got)
+	}
+}
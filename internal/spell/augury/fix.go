@@ -0,0 +1,226 @@
+package augury
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emiliopalmerini/grimorio/internal/claude"
+	"github.com/emiliopalmerini/grimorio/internal/git"
+	"github.com/emiliopalmerini/grimorio/internal/prompt"
+	"github.com/emiliopalmerini/grimorio/internal/textutil"
+)
+
+// DefaultIterations bounds the fix loop unless configured otherwise.
+const DefaultIterations = 3
+
+// maxReferencedFiles bounds how many files named in the output are sent.
+const maxReferencedFiles = 5
+
+// fixLogDir keeps applied patches, inside the .git directory so they never
+// show up as changes.
+const fixLogDir = "grimorio/fixes"
+
+// ErrNoFix is returned when Claude finds nothing to change in the code.
+var ErrNoFix = errors.New("no code fix suggested")
+
+// ErrNoFixes is returned by UndoFix when the log is empty.
+var ErrNoFixes = errors.New("no applied fixes to undo")
+
+var locationRe = regexp.MustCompile(`([A-Za-z0-9_.\-/]+\.[A-Za-z0-9]+):\d+`)
+
+// FixPrompt renders the augury-fix prompt for result. feedback tells Claude
+// why its previous patch was rejected, if it was.
+func FixPrompt(model claude.Model, result *Result, feedback string) (string, error) {
+	data := prompt.Data{
+		Command:     result.Command,
+		ExitCode:    result.ExitCode,
+		Stdout:      result.Stdout,
+		Stderr:      result.Stderr,
+		Description: feedback,
+		Errors:      FormatDiagnostics(ParseDiagnostics(result.Stderr + result.Stdout)),
+		Code:        referencedFiles(result.Stderr + result.Stdout),
+	}
+	data.Diff, _ = git.GetDiff(git.DiffOptions{All: true})
+
+	tmpl, err := prompt.Load("augury-fix")
+	if err != nil {
+		return "", err
+	}
+	return tmpl.Render(model, data)
+}
+
+// SuggestFix asks Claude for a patch fixing result and checks that it
+// applies to the working tree. A patch that does not apply is returned
+// along with the error, so it can be fed back.
+func SuggestFix(model claude.Model, result *Result, feedback string) (string, error) {
+	text, err := FixPrompt(model, result, feedback)
+	if err != nil {
+		return "", err
+	}
+	response, err := claude.Run(model, "augury-fix", text)
+	if err != nil {
+		return "", err
+	}
+
+	patch, err := ParsePatch(response)
+	if err != nil {
+		return response, err
+	}
+	if err := git.ApplyPatch(patch, git.ApplyOptions{Check: true}); err != nil {
+		return patch, err
+	}
+	return patch, nil
+}
+
+// ParsePatch extracts the unified diff from a response, rejecting anything
+// that is not one or touches paths outside the repository.
+func ParsePatch(response string) (string, error) {
+	response = strings.TrimSpace(textutil.StripCodeBlock(strings.TrimSpace(response)))
+	if response == "NO_FIX" {
+		return "", ErrNoFix
+	}
+
+	lines := strings.Split(response, "\n")
+	start := -1
+	for i, line := range lines {
+		if strings.HasPrefix(line, "diff --git ") || (strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ")) {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return "", fmt.Errorf("response is not a unified diff")
+	}
+	lines = lines[start:]
+
+	var files, hunks int
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "--- "), strings.HasPrefix(line, "+++ "):
+			path := strings.TrimSpace(line[4:])
+			if path == "/dev/null" {
+				continue
+			}
+			path = strings.TrimPrefix(strings.TrimPrefix(path, "a/"), "b/")
+			if filepath.IsAbs(path) || strings.HasPrefix(filepath.Clean(path), "..") {
+				return "", fmt.Errorf("patch touches %s, outside the repository", path)
+			}
+			if strings.HasPrefix(line, "+++ ") {
+				files++
+			}
+		case strings.HasPrefix(line, "@@ "):
+			hunks++
+		}
+	}
+	if files == 0 || hunks == 0 {
+		return "", fmt.Errorf("response is not a unified diff")
+	}
+
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// ApplyFix applies patch to the working tree and records it in the undo
+// log.
+func ApplyFix(patch string) (string, error) {
+	if err := git.ApplyPatch(patch, git.ApplyOptions{}); err != nil {
+		return "", err
+	}
+
+	dir, err := fixLog()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, time.Now().Format("20060102-150405.000000")+".patch")
+	if err := os.WriteFile(path, []byte(patch), 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// UndoFix reverts the most recently applied fix and removes it from the
+// log, like popping a stash. It returns the path of the reverted patch.
+func UndoFix() (string, error) {
+	fixes, err := Fixes()
+	if err != nil {
+		return "", err
+	}
+	if len(fixes) == 0 {
+		return "", ErrNoFixes
+	}
+
+	last := fixes[len(fixes)-1]
+	patch, err := os.ReadFile(last)
+	if err != nil {
+		return "", err
+	}
+	if err := git.ApplyPatch(string(patch), git.ApplyOptions{Reverse: true}); err != nil {
+		return "", fmt.Errorf("undo %s: %w", filepath.Base(last), err)
+	}
+	return last, os.Remove(last)
+}
+
+// Fixes lists the patches in the undo log, oldest first.
+func Fixes() ([]string, error) {
+	dir, err := fixLog()
+	if err != nil {
+		return nil, err
+	}
+	fixes, err := filepath.Glob(filepath.Join(dir, "*.patch"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(fixes)
+	return fixes, nil
+}
+
+func fixLog() (string, error) {
+	gitDir, err := git.GetGitDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(gitDir, fixLogDir), nil
+}
+
+// referencedFiles returns the contents of the repository files named in
+// output, so patches can be written against them. Files excluded by the
+// redaction rules are left out.
+func referencedFiles(output string) string {
+	files := newFileReader()
+	if files == nil {
+		return ""
+	}
+
+	var paths []string
+	for _, d := range ParseDiagnostics(output) {
+		if d.File != "" {
+			paths = append(paths, d.File)
+		}
+	}
+	for _, match := range locationRe.FindAllStringSubmatch(output, -1) {
+		paths = append(paths, match[1])
+	}
+
+	var sb strings.Builder
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		if len(seen) == maxReferencedFiles {
+			break
+		}
+		rel, lines, ok := files.read(path)
+		if !ok || seen[rel] {
+			continue
+		}
+		seen[rel] = true
+		fmt.Fprintf(&sb, "=== %s ===\n%s\n\n", rel, strings.Join(lines, "\n"))
+	}
+	return sb.String()
+}
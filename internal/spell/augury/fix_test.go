@@ -0,0 +1,110 @@
+package augury
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const patch = `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,3 @@
+ package main
+ 
+-func main() { undefined() }
++func main() {}
+`
+
+func TestParsePatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+		err      bool
+	}{
+		{name: "plain", response: patch, want: patch},
+		{name: "fenced", response: "```diff\n" + patch + "```", want: patch},
+		{name: "preamble", response: "Here is the fix:\n\n" + patch, want: patch},
+		{name: "no diff git header", response: "--- a/x.go\n+++ b/x.go\n@@ -1 +1 @@\n-a\n+b", want: "--- a/x.go\n+++ b/x.go\n@@ -1 +1 @@\n-a\n+b\n"},
+		{name: "prose", response: "Add the missing import.", err: true},
+		{name: "no hunks", response: "--- a/x.go\n+++ b/x.go\n", err: true},
+		{name: "outside repo", response: "--- a/../etc/passwd\n+++ b/../etc/passwd\n@@ -1 +1 @@\n-a\n+b", err: true},
+		{name: "absolute", response: "--- /etc/hosts\n+++ /etc/hosts\n@@ -1 +1 @@\n-a\n+b", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePatch(tt.response)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: ParsePatch() error = %v, want error %v", tt.name, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: ParsePatch() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := ParsePatch("NO_FIX"); !errors.Is(err, ErrNoFix) {
+		t.Errorf("ParsePatch(NO_FIX) error = %v, want ErrNoFix", err)
+	}
+}
+
+func TestApplyAndUndoFix(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	for _, args := range [][]string{{"init", "-q"}, {"config", "user.email", "test@example.com"}, {"config", "user.name", "test"}} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	original := "package main\n\nfunc main() { undefined() }\n"
+	if err := os.WriteFile("main.go", []byte(original), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := ApplyFix(patch)
+	if err != nil {
+		t.Fatalf("ApplyFix() error = %v", err)
+	}
+	if got, _ := os.ReadFile("main.go"); string(got) != "package main\n\nfunc main() {}\n" {
+		t.Errorf("main.go after fix = %q", got)
+	}
+	if !strings.Contains(filepath.ToSlash(path), ".git/grimorio/fixes/") {
+		t.Errorf("ApplyFix() logged to %s, want .git/grimorio/fixes", path)
+	}
+
+	if _, err := ApplyFix(patch); err == nil {
+		t.Error("ApplyFix() of an already applied patch expected error")
+	}
+	if fixes, _ := Fixes(); len(fixes) != 1 {
+		t.Errorf("Fixes() = %v, want the failed patch left out", fixes)
+	}
+
+	if _, err := UndoFix(); err != nil {
+		t.Fatalf("UndoFix() error = %v", err)
+	}
+	if got, _ := os.ReadFile("main.go"); string(got) != original {
+		t.Errorf("main.go after undo = %q", got)
+	}
+	if _, err := UndoFix(); !errors.Is(err, ErrNoFixes) {
+		t.Errorf("UndoFix() on empty log error = %v, want ErrNoFixes", err)
+	}
+}
+
+func TestReferencedFiles(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if out, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	os.MkdirAll("pkg", 0o755)
+	os.WriteFile("pkg/a.go", []byte("package pkg\n"), 0o644)
+	os.WriteFile(".env", []byte("TOKEN=secret\n"), 0o644)
+
+	got := referencedFiles("pkg/a.go:1:5: undefined: x\npkg/a.go:3: again\n.env:1: bad\nmissing.go:2: nope\n")
+	if got != "=== pkg/a.go ===\npackage pkg\n\n" {
+		t.Errorf("referencedFiles() = %q", got)
+	}
+}
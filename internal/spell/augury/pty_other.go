@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+
+package augury
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+)
+
+func startPTY(cmd *exec.Cmd, out io.Writer) (func() error, error) {
+	return nil, fmt.Errorf("pseudo-terminals are not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,67 @@
+//go:build linux || darwin
+
+package augury
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// ptyDrain bounds how long output is read after the command exits, in case
+// something it started in the background keeps the terminal open.
+const ptyDrain = time.Second
+
+// startPTY starts cmd with a pseudo-terminal as its stdout and stderr,
+// copying everything written to it to out. The returned function waits for
+// the command and the end of its output.
+func startPTY(cmd *exec.Cmd, out io.Writer) (func() error, error) {
+	master, slave, err := openPTY()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a pseudo-terminal: %w", err)
+	}
+	inheritSize(master)
+
+	cmd.Stdout, cmd.Stderr = slave, slave
+	// A new session makes the terminal its controlling one (fd 1 in the
+	// child) and the command its own process group
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 1}
+	if err := cmd.Start(); err != nil {
+		master.Close()
+		slave.Close()
+		return nil, fmt.Errorf("failed to run %s: %w", cmd.Args[len(cmd.Args)-1], err)
+	}
+	slave.Close()
+
+	copied := make(chan struct{})
+	go func() {
+		// Reading fails with EIO once the command closes the terminal
+		io.Copy(out, master)
+		close(copied)
+	}()
+
+	return func() error {
+		err := cmd.Wait()
+		select {
+		case <-copied:
+		case <-time.After(ptyDrain):
+		}
+		master.Close()
+		return err
+	}, nil
+}
+
+// inheritSize gives the pseudo-terminal the size of ours, so tools wrap
+// their output the same way they would without augury.
+func inheritSize(master *os.File) {
+	ws, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		ws = &unix.Winsize{Row: 40, Col: 120}
+	}
+	unix.IoctlSetWinsize(int(master.Fd()), unix.TIOCSWINSZ, ws)
+}
@@ -0,0 +1,169 @@
+package augury
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// killDelay is how long a command gets to exit after being asked to stop
+// before it is killed.
+const killDelay = 5 * time.Second
+
+// RunOptions controls how RunCommand runs a command.
+type RunOptions struct {
+	// Stdout and Stderr receive the output as it is produced, besides the
+	// capture. Nil writers only capture.
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// PTY runs the command in a pseudo-terminal, for tools that only color
+	// or stream their output on a terminal. Stderr is merged into Stdout.
+	PTY bool
+
+	// Timeout stops the command once it has run this long; zero means no
+	// limit.
+	Timeout time.Duration
+}
+
+// RunCommand runs command with sh and captures its output, with escape
+// sequences and carriage-return redraws removed. While it runs, Ctrl+C is
+// forwarded to the command instead of stopping grimorio; a second one
+// kills it.
+func RunCommand(command string, opts RunOptions) (*Result, error) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = os.Environ()
+
+	var stdout, stderr bytes.Buffer
+	var wait func() error
+	if opts.PTY {
+		var err error
+		if wait, err = startPTY(cmd, tee(&stdout, opts.Stdout)); err != nil {
+			return nil, err
+		}
+	} else {
+		cmd.Stdout = tee(&stdout, opts.Stdout)
+		cmd.Stderr = tee(&stderr, opts.Stderr)
+		setProcessGroup(cmd)
+		if err := cmd.Start(); err != nil {
+			return nil, fmt.Errorf("failed to run %s: %w", command, err)
+		}
+		wait = cmd.Wait
+	}
+
+	result := &Result{Command: command}
+	err := supervise(cmd.Process, wait, opts.Timeout, result)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("failed to run %s: %w", command, err)
+		}
+		result.ExitCode = exitErr.ExitCode()
+	}
+
+	result.Stdout = clean(stdout.String())
+	result.Stderr = clean(stderr.String())
+	if result.TimedOut {
+		result.Stderr += fmt.Sprintf("\n[stopped after the %s timeout]\n", opts.Timeout)
+	}
+	return result, nil
+}
+
+// Quote joins args into a command line for RunCommand that passes each of
+// them as given, quoted for sh where it holds anything but plain words.
+func Quote(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && strings.IndexFunc(arg, needsQuote) < 0 {
+			quoted[i] = arg
+			continue
+		}
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+// needsQuote reports whether r means something to sh outside quotes.
+func needsQuote(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	}
+	return !strings.ContainsRune("-_./,:@%+", r)
+}
+
+// supervise waits for the process, forwarding interrupts to it and stopping
+// it at the timeout.
+func supervise(p *os.Process, wait func() error, timeout time.Duration, result *Result) error {
+	done := make(chan error, 1)
+	go func() { done <- wait() }()
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, interruptSignals...)
+	defer signal.Stop(signals)
+
+	var deadline, kill <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		select {
+		case err := <-done:
+			return err
+		case sig := <-signals:
+			if result.Interrupted {
+				killGroup(p)
+				continue
+			}
+			result.Interrupted = true
+			signalGroup(p, sig)
+		case <-deadline:
+			result.TimedOut = true
+			terminateGroup(p)
+			kill = time.After(killDelay)
+		case <-kill:
+			killGroup(p)
+		}
+	}
+}
+
+func tee(capture *bytes.Buffer, live io.Writer) io.Writer {
+	if live == nil {
+		return capture
+	}
+	return io.MultiWriter(capture, live)
+}
+
+var ansiRe = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[()][0-9A-Za-z]|\x1b[=>78]`)
+
+func stripANSI(s string) string {
+	return ansiRe.ReplaceAllString(s, "")
+}
+
+// clean removes terminal escape sequences and keeps only the final state of
+// lines redrawn with carriage returns, like progress bars, so the output
+// reads as it ended up on screen.
+func clean(s string) string {
+	s = stripANSI(s)
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if j := strings.LastIndex(line, "\r"); j >= 0 {
+			line = line[j+1:]
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
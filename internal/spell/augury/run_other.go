@@ -0,0 +1,24 @@
+//go:build !unix
+
+package augury
+
+import (
+	"os"
+	"os/exec"
+)
+
+var interruptSignals = []os.Signal{os.Interrupt}
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func signalGroup(p *os.Process, sig os.Signal) {
+	p.Kill()
+}
+
+func terminateGroup(p *os.Process) {
+	p.Kill()
+}
+
+func killGroup(p *os.Process) {
+	p.Kill()
+}
@@ -0,0 +1,92 @@
+package augury
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunCommand(t *testing.T) {
+	var live bytes.Buffer
+	result, err := RunCommand("echo out; echo err >&2; exit 3", RunOptions{Stdout: &live})
+	if err != nil {
+		t.Fatalf("RunCommand() error = %v", err)
+	}
+	if result.ExitCode != 3 || result.Stdout != "out\n" || result.Stderr != "err\n" {
+		t.Errorf("RunCommand() = %+v", result)
+	}
+	if live.String() != "out\n" {
+		t.Errorf("streamed stdout = %q, want %q", live.String(), "out\n")
+	}
+}
+
+func TestRunCommandTimeout(t *testing.T) {
+	start := time.Now()
+	result, err := RunCommand("echo started; sleep 10", RunOptions{Timeout: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("RunCommand() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("RunCommand() took %s, want it stopped at the timeout", elapsed)
+	}
+	if !result.TimedOut || result.ExitCode == 0 {
+		t.Errorf("RunCommand() = %+v, want a timed out failure", result)
+	}
+	if result.Stdout != "started\n" || !strings.Contains(result.Stderr, "timeout") {
+		t.Errorf("RunCommand() = %+v, want the output so far and a timeout note", result)
+	}
+}
+
+func TestRunCommandPTY(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("pseudo-terminals are not supported on " + runtime.GOOS)
+	}
+
+	var live bytes.Buffer
+	result, err := RunCommand(`test -t 1 && printf '\033[31mtty\033[0m\n'; echo err >&2`, RunOptions{Stdout: &live, PTY: true})
+	if err != nil {
+		t.Fatalf("RunCommand() error = %v", err)
+	}
+	if result.ExitCode != 0 || result.Stdout != "tty\nerr\n" || result.Stderr != "" {
+		t.Errorf("RunCommand() = %+v, want both streams on the terminal without colors", result)
+	}
+	if !strings.Contains(live.String(), "\033[31m") {
+		t.Errorf("streamed output = %q, want the colors kept", live.String())
+	}
+}
+
+func TestClean(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"\x1b[1;32mok\x1b[0m\n", "ok\n"},
+		{"line\r\nnext\r\n", "line\nnext\n"},
+		{"10%\r50%\r100%\ndone\n", "100%\ndone\n"},
+		{"\x1b]0;title\x07\x1b[2Kbuilding\r\x1b[2Kbuilt\n", "built\n"},
+	}
+	for _, tt := range tests {
+		if got := clean(tt.in); got != tt.want {
+			t.Errorf("clean(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	if got, want := Quote([]string{"go", "test", "./..."}), "go test ./..."; got != want {
+		t.Errorf("Quote() = %q, want %q", got, want)
+	}
+	args := []string{"printf", "%s|", "a b", "it's", "$HOME", "", "x;y", "A=1"}
+	if got, want := Quote(args), `printf '%s|' 'a b' 'it'\''s' '$HOME' '' 'x;y' 'A=1'`; got != want {
+		t.Errorf("Quote() = %q, want %q", got, want)
+	}
+	result, err := RunCommand(Quote(args), RunOptions{})
+	if err != nil {
+		t.Fatalf("RunCommand() error = %v", err)
+	}
+	if want := "a b|it's|$HOME||x;y|A=1|"; result.Stdout != want {
+		t.Errorf("RunCommand(Quote()) stdout = %q, want %q", result.Stdout, want)
+	}
+}
@@ -0,0 +1,31 @@
+//go:build unix
+
+package augury
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+var interruptSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// setProcessGroup puts the command in its own process group, so signals
+// reach everything it starts and the terminal's Ctrl+C is left to us.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func signalGroup(p *os.Process, sig os.Signal) {
+	if s, ok := sig.(syscall.Signal); ok {
+		syscall.Kill(-p.Pid, s)
+	}
+}
+
+func terminateGroup(p *os.Process) {
+	syscall.Kill(-p.Pid, syscall.SIGTERM)
+}
+
+func killGroup(p *os.Process) {
+	syscall.Kill(-p.Pid, syscall.SIGKILL)
+}
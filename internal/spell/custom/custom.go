@@ -0,0 +1,365 @@
+// Package custom implements spells defined by teams in YAML files under
+// .grimorio/spells, without changes to grimorio itself.
+package custom
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/emiliopalmerini/grimorio/internal/cache"
+	"github.com/emiliopalmerini/grimorio/internal/claude"
+	"github.com/emiliopalmerini/grimorio/internal/diff"
+	"github.com/emiliopalmerini/grimorio/internal/git"
+	"github.com/emiliopalmerini/grimorio/internal/prompt"
+	"github.com/emiliopalmerini/grimorio/internal/spell/augury"
+	"github.com/emiliopalmerini/grimorio/internal/spell/identify"
+	"gopkg.in/yaml.v3"
+)
+
+// Dir holds spell definitions, relative to the repository root.
+const Dir = ".grimorio/spells"
+
+// Input is a source of data for the prompt.
+type Input string
+
+const (
+	StagedDiff    Input = "staged-diff" // {{.Diff}}; --all includes unstaged changes
+	BranchDiff    Input = "branch-diff" // {{.Diff}} and {{.Commits}} against --base
+	File          Input = "file"        // {{.Code}} and {{.Path}} of the file argument
+	CommandOutput Input = "command"     // {{.Command}}, {{.ExitCode}}, {{.Stdout}}, {{.Stderr}} of the arguments run as given
+	Stdin         Input = "stdin"       // {{.Stdin}}
+)
+
+// Output is what happens to the response.
+type Output string
+
+const (
+	Print     Output = "print"
+	Clipboard Output = "clipboard"
+	Editor    Output = "editor" // open in $EDITOR before the other outputs
+	WriteFile Output = "file"   // write to the definition's file
+)
+
+var (
+	inputs  = []Input{StagedDiff, BranchDiff, File, CommandOutput, Stdin}
+	outputs = []Output{Print, Clipboard, Editor, WriteFile}
+	nameRe  = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+)
+
+// Definition is a user-defined spell.
+type Definition struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Model       string   `yaml:"model"`
+	Prompt      string   `yaml:"prompt"`
+	Inputs      []Input  `yaml:"inputs"`
+	Output      []Output `yaml:"output"`
+	File        string   `yaml:"file"`
+	Cache       bool     `yaml:"cache"` // Serve repeated prompts from the response cache
+
+	// Path is the definition file.
+	Path string `yaml:"-"`
+
+	template *prompt.Template
+}
+
+// Load reads every definition in the repository's spell directory. Invalid
+// files are reported in the error, joined, while the valid ones are still
+// returned.
+func Load() ([]*Definition, error) {
+	root, err := git.GetRootDir()
+	if err != nil {
+		return nil, nil
+	}
+	return LoadDir(filepath.Join(root, Dir))
+}
+
+// LoadDir reads the *.yaml and *.yml definitions in dir, sorted by name.
+// A missing directory yields no definitions.
+func LoadDir(dir string) ([]*Definition, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var defs []*Definition
+	var errs []error
+	seen := make(map[string]string)
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		path := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		def, err := Parse(path, data)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if other, ok := seen[def.Name]; ok {
+			errs = append(errs, fmt.Errorf("%s: spell %q is already defined in %s", path, def.Name, other))
+			continue
+		}
+		seen[def.Name] = path
+		defs = append(defs, def)
+	}
+
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs, errors.Join(errs...)
+}
+
+// Parse decodes and validates a definition.
+func Parse(path string, data []byte) (*Definition, error) {
+	def := &Definition{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(def); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	def.Path = path
+
+	if err := def.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	tmpl, err := prompt.Parse(def.Name, path, def.Prompt)
+	if err != nil {
+		return nil, err
+	}
+	def.template = tmpl
+	return def, nil
+}
+
+func (d *Definition) validate() error {
+	if !nameRe.MatchString(d.Name) {
+		return fmt.Errorf("name %q must be lowercase letters, digits and dashes", d.Name)
+	}
+	if strings.TrimSpace(d.Prompt) == "" {
+		return fmt.Errorf("prompt is required")
+	}
+	if d.Model == "" {
+		d.Model = string(claude.Sonnet)
+	}
+
+	for _, in := range d.Inputs {
+		if !contains(inputs, in) {
+			return fmt.Errorf("unknown input %q (expected one of %s)", in, join(inputs))
+		}
+	}
+	if d.Has(File) && d.Has(CommandOutput) {
+		return fmt.Errorf("inputs %q and %q both take the arguments, use one", File, CommandOutput)
+	}
+	if d.Has(StagedDiff) && d.Has(BranchDiff) {
+		return fmt.Errorf("inputs %q and %q both fill {{.Diff}}, use one", StagedDiff, BranchDiff)
+	}
+
+	if len(d.Output) == 0 {
+		d.Output = []Output{Print}
+	}
+	for _, out := range d.Output {
+		if !contains(outputs, out) {
+			return fmt.Errorf("unknown output %q (expected one of %s)", out, join(outputs))
+		}
+	}
+	if d.Writes(WriteFile) && d.File == "" {
+		return fmt.Errorf("output %q needs a file", WriteFile)
+	}
+	return nil
+}
+
+// OutputPath returns where the file output is written. Relative paths
+// are resolved against the repository root.
+func (d *Definition) OutputPath() (string, error) {
+	if filepath.IsAbs(d.File) {
+		return d.File, nil
+	}
+	root, err := git.GetRootDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, d.File), nil
+}
+
+// Has reports whether the spell reads input.
+func (d *Definition) Has(input Input) bool {
+	return contains(d.Inputs, input)
+}
+
+// Writes reports whether the spell sends its response to output.
+func (d *Definition) Writes(output Output) bool {
+	return contains(d.Output, output)
+}
+
+// Options are the per-run settings of a user spell.
+type Options struct {
+	Model       claude.Model
+	All         bool      // staged-diff: include unstaged changes
+	Base        string    // branch-diff: base branch, auto-detected when empty
+	Description string    // {{.Description}}
+	Args        []string  // file path, or the command to run
+	Stdin       io.Reader // stdin input
+}
+
+// Gather collects the inputs of the spell.
+func (d *Definition) Gather(opts Options) (prompt.Data, error) {
+	data := prompt.Data{Description: opts.Description}
+
+	if d.Has(StagedDiff) {
+		raw, err := git.GetDiff(git.DiffOptions{All: opts.All})
+		if err != nil {
+			return data, err
+		}
+		newRev := diff.IndexRev
+		if opts.All {
+			newRev = ""
+		}
+		if data.Diff, err = d.prioritize(raw, "", newRev, opts.Model); err != nil {
+			return data, err
+		}
+	}
+
+	if d.Has(BranchDiff) {
+		base := opts.Base
+		if base == "" {
+			var err error
+			if base, err = git.GetBaseBranch(); err != nil {
+				return data, err
+			}
+		}
+		raw, err := git.GetBranchDiff(base, 0)
+		if err != nil {
+			return data, err
+		}
+		fork, err := git.MergeBase(base, "HEAD")
+		if err != nil {
+			return data, err
+		}
+		if data.Diff, err = d.prioritize(raw, fork, "HEAD", opts.Model); err != nil {
+			return data, err
+		}
+		data.Commits, _ = git.GetBranchCommits(base)
+	}
+
+	if d.Has(File) {
+		if len(opts.Args) != 1 {
+			return data, fmt.Errorf("%s takes one file argument", d.Name)
+		}
+		content, err := identify.ReadFile(opts.Args[0])
+		if err != nil {
+			return data, err
+		}
+		data.Path, data.Code = opts.Args[0], content
+	}
+
+	if d.Has(CommandOutput) {
+		if len(opts.Args) == 0 {
+			return data, fmt.Errorf("%s takes a command to run", d.Name)
+		}
+		result, err := augury.RunCommand(augury.Quote(opts.Args), augury.RunOptions{})
+		if err != nil {
+			return data, err
+		}
+		data.Command, data.ExitCode = result.Command, result.ExitCode
+		data.Stdout, data.Stderr = result.Stdout, result.Stderr
+	}
+
+	if d.Has(Stdin) && opts.Stdin != nil {
+		content, err := io.ReadAll(opts.Stdin)
+		if err != nil {
+			return data, fmt.Errorf("read stdin: %w", err)
+		}
+		data.Stdin = string(content)
+	}
+
+	return data, nil
+}
+
+// prioritize fits a diff between the base revision and newRev to the model's diff
+// budget like the built-in spells do, honoring the repository's scoring
+// profile. Only an invalid budget is an error; the raw diff is used when
+// prioritizing fails.
+func (d *Definition) prioritize(raw, base, newRev string, model claude.Model) (string, error) {
+	budget, err := prompt.DiffBudget(model)
+	if err != nil {
+		return "", err
+	}
+	profile, err := diff.LoadProfile()
+	if err != nil {
+		return raw, nil
+	}
+
+	opts := diff.DefaultOptions()
+	opts.Profile = profile
+	opts.MaxHighPriorityLines = profile.Budget(d.Name, 0)
+	opts.MaxHighPriorityTokens = budget
+	opts.BaseRev = base
+	opts.NewRev = newRev
+
+	prioritized, err := diff.Prioritize(raw, opts)
+	if err != nil {
+		// Fall back to raw diff on error, the template renderer truncates it
+		return raw, nil
+	}
+	return diff.FormatForPrompt(prioritized), nil
+}
+
+// Cast gathers the inputs, renders the prompt and sends it to Claude.
+func (d *Definition) Cast(opts Options) (string, error) {
+	if opts.Model == "" {
+		opts.Model = claude.Model(d.Model)
+	}
+
+	data, err := d.Gather(opts)
+	if err != nil {
+		return "", err
+	}
+	text, err := d.template.Render(opts.Model, data)
+	if err != nil {
+		return "", err
+	}
+
+	if d.Cache {
+		cache.Allow(d.Name)
+	}
+	response, err := claude.Run(opts.Model, d.Name, text)
+	if err != nil {
+		return "", err
+	}
+	if response == "" {
+		return "", fmt.Errorf("claude returned empty response")
+	}
+	return response, nil
+}
+
+func contains[T comparable](list []T, v T) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+func join[T ~string](list []T) string {
+	parts := make([]string, len(list))
+	for i, item := range list {
+		parts[i] = string(item)
+	}
+	return strings.Join(parts, ", ")
+}
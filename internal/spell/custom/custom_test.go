@@ -0,0 +1,140 @@
+package custom
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	def, err := Parse("changelog.yaml", []byte(`
+name: changelog
+description: Draft a changelog entry
+prompt: |
+  Write a changelog entry for:
+  {{.Diff}}
+inputs: [staged-diff, stdin]
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if def.Name != "changelog" || def.Path != "changelog.yaml" {
+		t.Errorf("Parse() = %+v", def)
+	}
+	if def.Model != "sonnet" {
+		t.Errorf("Model = %q, want sonnet default", def.Model)
+	}
+	if len(def.Output) != 1 || !def.Writes(Print) {
+		t.Errorf("Output = %v, want [print] default", def.Output)
+	}
+	if !def.Has(StagedDiff) || !def.Has(Stdin) || def.Has(File) {
+		t.Errorf("Inputs = %v", def.Inputs)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := map[string]string{
+		"bad name":        "name: Changelog\nprompt: x\n",
+		"missing prompt":  "name: changelog\n",
+		"unknown key":     "name: changelog\nprompt: x\nmodle: opus\n",
+		"unknown input":   "name: changelog\nprompt: x\ninputs: [clipboard]\n",
+		"unknown output":  "name: changelog\nprompt: x\noutput: [slack]\n",
+		"file and cmd":    "name: changelog\nprompt: x\ninputs: [file, command]\n",
+		"two diffs":       "name: changelog\nprompt: x\ninputs: [staged-diff, branch-diff]\n",
+		"file output":     "name: changelog\nprompt: x\noutput: [file]\n",
+		"bad template":    "name: changelog\nprompt: '{{.Diffs}}'\n",
+		"unclosed action": "name: changelog\nprompt: '{{if .Diff}}'\n",
+	}
+	for name, data := range tests {
+		if _, err := Parse("spell.yaml", []byte(data)); err == nil {
+			t.Errorf("%s: Parse() expected error", name)
+		} else if !strings.Contains(err.Error(), "spell.yaml") {
+			t.Errorf("%s: error %q does not name the file", name, err)
+		}
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("zeta.yaml", "name: zeta\nprompt: z\n")
+	write("alpha.yml", "name: alpha\nprompt: a\n")
+	write("again.yaml", "name: zeta\nprompt: z\n")
+	write("broken.yaml", "name: broken\n")
+	write("notes.md", "not a spell")
+
+	defs, err := LoadDir(dir)
+	if err == nil {
+		t.Fatal("LoadDir() expected error for broken and duplicate spells")
+	}
+	for _, want := range []string{"broken.yaml", `spell "zeta" is already defined`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadDir() error %q missing %q", err, want)
+		}
+	}
+
+	var names []string
+	for _, def := range defs {
+		names = append(names, def.Name)
+	}
+	if got := strings.Join(names, ","); got != "alpha,zeta" {
+		t.Errorf("LoadDir() names = %s, want alpha,zeta", got)
+	}
+
+	defs, err = LoadDir(filepath.Join(dir, "missing"))
+	if err != nil || defs != nil {
+		t.Errorf("LoadDir(missing) = %v, %v, want nothing", defs, err)
+	}
+}
+
+func TestGatherStdin(t *testing.T) {
+	def, err := Parse("summarize.yaml", []byte("name: summarize\nprompt: '{{.Stdin}}'\ninputs: [stdin]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := def.Gather(Options{Description: "why", Stdin: strings.NewReader("piped text")})
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	if data.Stdin != "piped text" || data.Description != "why" {
+		t.Errorf("Gather() = %+v", data)
+	}
+
+	data, err = def.Gather(Options{})
+	if err != nil || data.Stdin != "" {
+		t.Errorf("Gather() without stdin = %+v, %v", data, err)
+	}
+}
+
+func TestGatherArgs(t *testing.T) {
+	def, err := Parse("explain.yaml", []byte("name: explain\nprompt: '{{.Path}} {{.Code}}'\ninputs: [file]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := def.Gather(Options{}); err == nil {
+		t.Error("Gather() without a file argument expected error")
+	}
+
+	def, err = Parse("explain.yaml", []byte("name: explain\nprompt: '{{.Stdout}}'\ninputs: [command]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := def.Gather(Options{}); err == nil {
+		t.Error("Gather() without a command expected error")
+	}
+	data, err := def.Gather(Options{Args: []string{"printf", "%s\n", "a b", "$HOME;"}})
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	if data.Stdout != "a b\n$HOME;\n" {
+		t.Errorf("Gather() stdout = %q, want the arguments passed as given", data.Stdout)
+	}
+}
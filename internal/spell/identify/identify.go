@@ -8,10 +8,24 @@ import (
 	"strings"
 
 	"github.com/emiliopalmerini/grimorio/internal/claude"
+	"github.com/emiliopalmerini/grimorio/internal/git"
 	"github.com/emiliopalmerini/grimorio/internal/lsp"
+	"github.com/emiliopalmerini/grimorio/internal/prompt"
+	"github.com/emiliopalmerini/grimorio/internal/redact"
 )
 
+// DefaultModel explains code unless configured otherwise.
+const DefaultModel = claude.Sonnet
+
 func ReadFile(path string) (string, error) {
+	redactor, err := redact.Load()
+	if err != nil {
+		return "", err
+	}
+	if redactor.Excluded(repoPath(path)) {
+		return "", fmt.Errorf("%s is excluded by redaction rules and will not be sent", path)
+	}
+
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return "", fmt.Errorf("failed to read file: %w", err)
@@ -19,36 +33,48 @@ func ReadFile(path string) (string, error) {
 	return string(content), nil
 }
 
-func GetLSPContext(path string, content string) string {
-	lang := lsp.DetectLanguage(path)
-	if lang == nil || !lang.Available() {
-		return ""
-	}
-
-	client, err := lsp.NewClient(lang)
+// ReadFileAt is ReadFile for the version of path at rev, "" meaning the
+// index.
+func ReadFileAt(rev, path string) (string, error) {
+	redactor, err := redact.Load()
 	if err != nil {
-		return ""
+		return "", err
 	}
-	defer client.Close()
-
-	ctx := context.Background()
-	rootPath := filepath.Dir(path)
-	if err := client.Initialize(ctx, rootPath); err != nil {
-		return ""
+	rel := repoPath(path)
+	if redactor.Excluded(rel) {
+		return "", fmt.Errorf("%s is excluded by redaction rules and will not be sent", path)
 	}
+	return git.ShowFile(rev, rel)
+}
 
-	absPath, err := filepath.Abs(path)
+// repoPath returns path relative to the repository root with forward
+// slashes, as redaction patterns are written, or path itself outside a
+// repository.
+func repoPath(path string) string {
+	root, err := git.GetRootDir()
 	if err != nil {
-		return ""
+		return filepath.ToSlash(filepath.Clean(path))
 	}
-	uri := "file://" + absPath
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.ToSlash(filepath.Clean(path))
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	if rel, err := filepath.Rel(root, abs); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
 
-	if err := client.OpenDocument(uri, lang.Name, content); err != nil {
+func GetLSPContext(path string, content string) string {
+	lang := lsp.DetectLanguage(path)
+	if lang == nil || !lang.Available() {
 		return ""
 	}
-	defer client.CloseDocument(uri)
 
-	symbols, err := client.DocumentSymbols(uri)
+	symbols, err := lsp.FileSymbols(context.Background(), path, content, lang)
 	if err != nil || len(symbols) == 0 {
 		return ""
 	}
@@ -62,23 +88,20 @@ func GetLSPContext(path string, content string) string {
 	return sb.String()
 }
 
-func Explain(content string, symbol string, lspContext string) (string, error) {
-	prompt := `Explain this code in plain language. Be concise but thorough.
-Focus on:
-- What the code does
-- Key functions/types and their purpose
-- Important patterns or techniques used
-`
-
-	if symbol != "" {
-		prompt += fmt.Sprintf("\nFocus specifically on: %s\n", symbol)
-	}
-
-	if lspContext != "" {
-		prompt += "\n" + lspContext + "\n"
+func Explain(model claude.Model, content string, symbol string, lspContext string) (string, error) {
+	text, err := Prompt(model, content, symbol, lspContext)
+	if err != nil {
+		return "", err
 	}
 
-	prompt += "\nCode:\n" + content
+	return claude.Run(model, "identify", text)
+}
 
-	return claude.Run(claude.Sonnet, "identify", prompt)
+// Prompt renders the identify prompt without sending it.
+func Prompt(model claude.Model, content string, symbol string, lspContext string) (string, error) {
+	tmpl, err := prompt.Load("identify")
+	if err != nil {
+		return "", err
+	}
+	return tmpl.Render(model, prompt.Data{Code: content, Symbol: symbol, LSPContext: lspContext})
 }
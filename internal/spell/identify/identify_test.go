@@ -0,0 +1,31 @@
+package identify
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/emiliopalmerini/grimorio/internal/config"
+)
+
+func TestReadFile_ExcludedFromSubdirectory(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if out, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	os.WriteFile(config.FileName, []byte("[redaction]\nexclude = [\"deploy/secrets/*\"]\n"), 0o644)
+	os.MkdirAll(filepath.Join("deploy", "secrets"), 0o755)
+	os.WriteFile(filepath.Join("deploy", "secrets", "prod.yaml"), []byte("token: x\n"), 0o644)
+	os.WriteFile(filepath.Join("deploy", "app.yaml"), []byte("name: app\n"), 0o644)
+
+	t.Chdir("deploy")
+	if _, err := ReadFile(filepath.Join("secrets", "prod.yaml")); err == nil || !strings.Contains(err.Error(), "excluded by redaction rules") {
+		t.Errorf("ReadFile(secrets/prod.yaml) error = %v, want excluded", err)
+	}
+	if got, err := ReadFile("app.yaml"); err != nil || got != "name: app\n" {
+		t.Errorf("ReadFile(app.yaml) = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,443 @@
+package identify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/emiliopalmerini/grimorio/internal/claude"
+	"github.com/emiliopalmerini/grimorio/internal/git"
+	"github.com/emiliopalmerini/grimorio/internal/lsp"
+	"github.com/emiliopalmerini/grimorio/internal/prompt"
+	"github.com/emiliopalmerini/grimorio/internal/redact"
+)
+
+// maxPackageFiles bounds how many files of a directory are read.
+const maxPackageFiles = 60
+
+// maxKeyTypes bounds the types whose references are looked up.
+const maxKeyTypes = 8
+
+// maxDefinitions bounds the definitions followed from the key types.
+const maxDefinitions = 24
+
+// maxUsers bounds the outside users listed for each key type.
+const maxUsers = 5
+
+var readmeNames = map[string]bool{"README.md": true, "README": true, "README.txt": true}
+
+// apiKinds are the symbol kinds that make up a package's API.
+var apiKinds = map[string]bool{
+	"Class": true, "Interface": true, "Struct": true, "Enum": true,
+	"Function": true, "Method": true, "Constructor": true, "Constant": true,
+}
+
+// typeKinds are the API kinds followed as key types.
+var typeKinds = map[string]bool{"Class": true, "Interface": true, "Struct": true, "Enum": true}
+
+// typeNameRe finds the type names a declaration refers to.
+var typeNameRe = regexp.MustCompile(`\b[A-Z][A-Za-z0-9_]*\b`)
+
+// Package is a directory read for identify, with what the language server
+// knows about it.
+type Package struct {
+	Dir          string
+	Docs         string         // README and package doc comments
+	Files        []*PackageFile // Most important first
+	KeyTypes     []KeyType
+	Dependencies []string // Definitions outside the package used by the key types
+
+	abs  string
+	root string
+}
+
+// PackageFile is one source file of a Package.
+type PackageFile struct {
+	Path    string // Relative to the repository root
+	Content string
+	Test    bool
+	API     []lsp.DocumentSymbol // Exported symbols
+	Refs    int                  // Files using its key types
+
+	abs string
+}
+
+// KeyType is an exported type of a Package and the files using it.
+type KeyType struct {
+	Name     string
+	File     string
+	Internal []string // Other files of the package
+	External []string // Files outside the package
+}
+
+// ReadPackage reads the source files and docs of dir, leaving out files
+// excluded by the redaction rules or that cannot be read, and ranks the
+// files by importance.
+func ReadPackage(dir string) (*Package, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	redactor, err := redact.Load()
+	if err != nil {
+		return nil, err
+	}
+	root, err := git.GetRootDir()
+	if err != nil {
+		root = abs
+	}
+
+	pkg := &Package{Dir: dir, abs: abs, root: root}
+	var readme string
+	for _, e := range entries {
+		name := e.Name()
+		if !e.Type().IsRegular() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		path := filepath.Join(abs, name)
+		if redactor.Excluded(pkg.rel(path)) {
+			continue
+		}
+		if !readmeNames[name] && lsp.DetectLanguage(name) == nil {
+			continue
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if readmeNames[name] {
+			readme = string(content)
+			continue
+		}
+		if len(pkg.Files) < maxPackageFiles {
+			pkg.Files = append(pkg.Files, &PackageFile{Path: pkg.rel(path), Content: string(content), Test: IsTestFile(name), abs: path})
+		}
+	}
+	if len(pkg.Files) == 0 {
+		return nil, fmt.Errorf("no source files in %s", dir)
+	}
+
+	var docs []string
+	for _, f := range pkg.Files {
+		if c := packageComment(f.Path, f.Content); c != "" {
+			docs = append(docs, c)
+		}
+	}
+	if readme != "" {
+		docs = append(docs, readme)
+	}
+	pkg.Docs = strings.Join(docs, "\n\n")
+
+	pkg.analyze()
+	pkg.rank()
+	return pkg, nil
+}
+
+// analyze asks the language server of the package's main language for the
+// exported API of each file, where the key types are used and what they
+// depend on elsewhere in the repository. Without a server the files are
+// sent as they are.
+func (p *Package) analyze() {
+	lang := mainLanguage(p.Files)
+	if lang == nil || !lang.Available() {
+		return
+	}
+
+	client, err := lsp.NewClient(lang)
+	if err != nil {
+		return
+	}
+	defer client.Close()
+
+	if err := client.Initialize(context.Background(), p.root); err != nil {
+		return
+	}
+
+	type candidate struct {
+		file *PackageFile
+		sym  lsp.DocumentSymbol
+	}
+	var keyTypes []candidate
+	for _, f := range p.Files {
+		if lsp.DetectLanguage(f.Path) != lang {
+			continue
+		}
+		uri := "file://" + f.abs
+		if err := client.OpenDocument(uri, lang.Name, f.Content); err != nil {
+			continue
+		}
+		symbols, err := client.DocumentSymbols(uri)
+		client.CloseDocument(uri)
+		if err != nil {
+			continue
+		}
+		for _, sym := range symbols {
+			if !apiKinds[sym.Kind] || !exported(lang, sym.Name) {
+				continue
+			}
+			f.API = append(f.API, sym)
+			if typeKinds[sym.Kind] && !f.Test && len(keyTypes) < maxKeyTypes {
+				keyTypes = append(keyTypes, candidate{f, sym})
+			}
+		}
+	}
+
+	followed := make(map[string]bool)
+	for _, c := range keyTypes {
+		uri := "file://" + c.file.abs
+		kt := KeyType{Name: c.sym.Name, File: c.file.Path}
+
+		locations, _ := client.References(uri, c.sym.Selection, false)
+		seen := make(map[string]bool)
+		for _, loc := range locations {
+			path := loc.Path()
+			if path == c.file.abs || seen[path] {
+				continue
+			}
+			seen[path] = true
+			c.file.Refs++
+			if filepath.Dir(path) == p.abs {
+				kt.Internal = append(kt.Internal, filepath.Base(path))
+			} else {
+				kt.External = append(kt.External, p.rel(path))
+			}
+		}
+		p.KeyTypes = append(p.KeyTypes, kt)
+
+		lines := strings.Split(c.file.Content, "\n")
+		for line := c.sym.Line; line <= c.sym.EndLine && line < len(lines); line++ {
+			for _, m := range typeNameRe.FindAllStringIndex(lines[line], -1) {
+				name := lines[line][m[0]:m[1]]
+				if name == c.sym.Name || followed[name] || len(followed) == maxDefinitions {
+					continue
+				}
+				followed[name] = true
+
+				definitions, err := client.Definition(uri, lsp.Position{Line: line, Character: m[0]})
+				if err != nil || len(definitions) == 0 {
+					continue
+				}
+				def := definitions[0]
+				path := def.Path()
+				if filepath.Dir(path) == p.abs || !strings.HasPrefix(path, p.root+string(filepath.Separator)) {
+					continue
+				}
+				p.Dependencies = append(p.Dependencies, fmt.Sprintf("%s uses %s, defined in %s:%d", c.sym.Name, name, p.rel(path), def.Range.Start.Line+1))
+			}
+		}
+	}
+}
+
+// rank orders the files by importance: how much exported API they hold and
+// how widely their key types are used. The file named after the package and
+// doc files come first among equals, tests last.
+func (p *Package) rank() {
+	base := filepath.Base(p.abs)
+	score := func(f *PackageFile) int {
+		s := 2*len(f.API) + f.Refs
+		name := strings.TrimSuffix(filepath.Base(f.Path), filepath.Ext(f.Path))
+		if name == base || name == "doc" {
+			s += 3
+		}
+		return s
+	}
+	sort.SliceStable(p.Files, func(i, j int) bool {
+		if p.Files[i].Test != p.Files[j].Test {
+			return !p.Files[i].Test
+		}
+		return score(p.Files[i]) > score(p.Files[j])
+	})
+}
+
+// code concatenates whole files, most important first, as long as they fit
+// in budget tokens. It returns which files were included.
+func (p *Package) code(budget int) (string, map[string]bool) {
+	var sb strings.Builder
+	shown := make(map[string]bool)
+	for _, f := range p.Files {
+		block := fmt.Sprintf("=== %s ===\n%s\n\n", f.Path, f.Content)
+		cost := prompt.EstimateTokens(block)
+		if cost > budget {
+			continue
+		}
+		budget -= cost
+		sb.WriteString(block)
+		shown[f.Path] = true
+	}
+	return sb.String(), shown
+}
+
+// context describes the package's layout for the prompt: its files and
+// their API, where the key types are used and what they depend on.
+func (p *Package) context(shown map[string]bool) string {
+	var sb strings.Builder
+	sb.WriteString("Files, most important first:\n")
+	for _, f := range p.Files {
+		var names []string
+		for _, sym := range f.API {
+			names = append(names, sym.Name)
+		}
+		fmt.Fprintf(&sb, "- %s", f.Path)
+		if len(names) > 0 {
+			fmt.Fprintf(&sb, ": %s", strings.Join(names, ", "))
+		}
+		if !shown[f.Path] {
+			sb.WriteString(" (code not shown)")
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(p.KeyTypes) > 0 {
+		sb.WriteString("\nKey types and where they are used:\n")
+		for _, kt := range p.KeyTypes {
+			fmt.Fprintf(&sb, "- %s (%s)", kt.Name, filepath.Base(kt.File))
+			if len(kt.Internal) > 0 {
+				fmt.Fprintf(&sb, ", in %s", strings.Join(kt.Internal, ", "))
+			}
+			if len(kt.External) > 0 {
+				users := kt.External
+				if len(users) > maxUsers {
+					users = append(users[:maxUsers:maxUsers], fmt.Sprintf("%d more", len(kt.External)-maxUsers))
+				}
+				fmt.Fprintf(&sb, ", outside the package in %s", strings.Join(users, ", "))
+			}
+			if len(kt.Internal) == 0 && len(kt.External) == 0 {
+				sb.WriteString(", not referenced")
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	if len(p.Dependencies) > 0 {
+		sb.WriteString("\nDependencies elsewhere in the repository:\n")
+		for _, d := range p.Dependencies {
+			sb.WriteString("- " + d + "\n")
+		}
+	}
+	return sb.String()
+}
+
+func (p *Package) rel(path string) string {
+	if rel, err := filepath.Rel(p.root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// ExplainPackage explains a whole package or directory.
+func ExplainPackage(model claude.Model, pkg *Package, symbol string) (string, error) {
+	text, err := PackagePrompt(model, pkg, symbol)
+	if err != nil {
+		return "", err
+	}
+
+	return claude.Run(model, "identify", text)
+}
+
+// PackagePrompt renders the identify-package prompt without sending it.
+// Half of the model's budget goes to whole files, by importance; the
+// others are described by their API only.
+func PackagePrompt(model claude.Model, pkg *Package, symbol string) (string, error) {
+	tmpl, err := prompt.Load("identify-package")
+	if err != nil {
+		return "", err
+	}
+
+	budget, err := prompt.ContextBudget(model)
+	if err != nil {
+		return "", err
+	}
+	code, shown := pkg.code(budget / 2)
+	return tmpl.Render(model, prompt.Data{
+		Path:       pkg.Dir,
+		Symbol:     symbol,
+		Docs:       pkg.Docs,
+		Code:       code,
+		LSPContext: pkg.context(shown),
+	})
+}
+
+// mainLanguage returns the language most non-test files are written in.
+func mainLanguage(files []*PackageFile) *lsp.Language {
+	counts := make(map[*lsp.Language]int)
+	var main *lsp.Language
+	for _, f := range files {
+		lang := lsp.DetectLanguage(f.Path)
+		if lang == nil || f.Test {
+			continue
+		}
+		counts[lang]++
+		if main == nil || counts[lang] > counts[main] {
+			main = lang
+		}
+	}
+	return main
+}
+
+// exported reports whether name is part of the public API, by the
+// conventions of lang. Languages with explicit visibility count every
+// top-level symbol.
+func exported(lang *lsp.Language, name string) bool {
+	switch lang.Name {
+	case "go":
+		// Methods may come as "(*T).Name" or "T.Name"
+		name = name[strings.LastIndex(name, ".")+1:]
+		r, _ := utf8.DecodeRuneInString(name)
+		return unicode.IsUpper(r)
+	case "python":
+		return !strings.HasPrefix(name, "_")
+	}
+	return true
+}
+
+// IsTestFile reports whether a file name follows the test file conventions
+// of Go, Python or JavaScript.
+func IsTestFile(name string) bool {
+	return strings.HasSuffix(name, "_test.go") ||
+		strings.HasSuffix(name, "_test.py") ||
+		(strings.HasPrefix(name, "test_") && strings.HasSuffix(name, ".py")) ||
+		strings.Contains(name, ".test.") ||
+		strings.Contains(name, ".spec.")
+}
+
+// packageComment returns the "// Package x ..." comment of a Go file.
+func packageComment(path, content string) string {
+	if filepath.Ext(path) != ".go" {
+		return ""
+	}
+
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "package ") {
+			continue
+		}
+		start := i
+		for start > 0 && strings.HasPrefix(lines[start-1], "//") {
+			start--
+		}
+		if start == i || !strings.HasPrefix(lines[start], "// Package ") {
+			return ""
+		}
+		var comment []string
+		for _, l := range lines[start:i] {
+			comment = append(comment, strings.TrimPrefix(strings.TrimPrefix(l, "//"), " "))
+		}
+		return strings.Join(comment, "\n")
+	}
+	return ""
+}
@@ -0,0 +1,133 @@
+package identify
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/emiliopalmerini/grimorio/internal/lsp"
+)
+
+func TestReadPackage(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if out, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+
+	pkgDir := filepath.Join("internal", "diff")
+	os.MkdirAll(pkgDir, 0o755)
+	files := map[string]string{
+		"hunk.go":      "package diff\n\ntype Hunk struct{}\n",
+		"diff.go":      "// Package diff parses unified diffs.\n// It is used by every spell.\npackage diff\n",
+		"diff_test.go": "package diff\n",
+		"README.md":    "# diff\n",
+		"notes.bin":    "binary",
+		".hidden.go":   "package diff\n",
+	}
+	for name, content := range files {
+		os.WriteFile(filepath.Join(pkgDir, name), []byte(content), 0o644)
+	}
+
+	pkg, err := ReadPackage(pkgDir)
+	if err != nil {
+		t.Fatalf("ReadPackage() error = %v", err)
+	}
+
+	var paths []string
+	for _, f := range pkg.Files {
+		paths = append(paths, f.Path)
+	}
+	if got, want := strings.Join(paths, " "), "internal/diff/diff.go internal/diff/hunk.go internal/diff/diff_test.go"; got != want {
+		t.Errorf("files = %s, want %s", got, want)
+	}
+	if !pkg.Files[2].Test {
+		t.Error("diff_test.go is not marked as a test")
+	}
+	if want := "Package diff parses unified diffs.\nIt is used by every spell.\n\n# diff\n"; pkg.Docs != want {
+		t.Errorf("Docs = %q, want %q", pkg.Docs, want)
+	}
+
+	if _, err := ReadPackage(t.TempDir()); err == nil {
+		t.Error("ReadPackage() of an empty directory expected error")
+	}
+}
+
+func TestRank(t *testing.T) {
+	pkg := &Package{abs: "/src/internal/diff", Files: []*PackageFile{
+		{Path: "internal/diff/a_test.go", Test: true, API: make([]lsp.DocumentSymbol, 9)},
+		{Path: "internal/diff/render.go", API: make([]lsp.DocumentSymbol, 1)},
+		{Path: "internal/diff/hunk.go", API: make([]lsp.DocumentSymbol, 2), Refs: 3},
+		{Path: "internal/diff/diff.go", API: make([]lsp.DocumentSymbol, 1)},
+	}}
+	pkg.rank()
+
+	var got []string
+	for _, f := range pkg.Files {
+		got = append(got, filepath.Base(f.Path))
+	}
+	if want := "hunk.go diff.go render.go a_test.go"; strings.Join(got, " ") != want {
+		t.Errorf("rank() = %s, want %s", strings.Join(got, " "), want)
+	}
+}
+
+func TestPackageCodeAndContext(t *testing.T) {
+	pkg := &Package{
+		Files: []*PackageFile{
+			{Path: "diff/diff.go", Content: "package diff", API: []lsp.DocumentSymbol{{Name: "Parse"}, {Name: "Hunk"}}},
+			{Path: "diff/big.go", Content: strings.Repeat("x ", 2000)},
+			{Path: "diff/small.go", Content: "package diff"},
+		},
+		KeyTypes: []KeyType{
+			{Name: "Hunk", File: "diff/diff.go", Internal: []string{"big.go"}, External: []string{"a.go", "b.go", "c.go", "d.go", "e.go", "f.go", "g.go"}},
+			{Name: "Unused", File: "diff/small.go"},
+		},
+		Dependencies: []string{"Hunk uses Range, defined in lsp/types.go:9"},
+	}
+
+	code, shown := pkg.code(100)
+	if !shown["diff/diff.go"] || shown["diff/big.go"] || !shown["diff/small.go"] {
+		t.Errorf("code() shown = %v, want the files that fit", shown)
+	}
+	if !strings.HasPrefix(code, "=== diff/diff.go ===\npackage diff\n") || strings.Contains(code, "big.go") {
+		t.Errorf("code() = %q", code)
+	}
+
+	got := pkg.context(shown)
+	for _, want := range []string{
+		"- diff/diff.go: Parse, Hunk\n",
+		"- diff/big.go (code not shown)\n",
+		"- Hunk (diff.go), in big.go, outside the package in a.go, b.go, c.go, d.go, e.go, 2 more\n",
+		"- Unused (small.go), not referenced\n",
+		"- Hunk uses Range, defined in lsp/types.go:9\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("context() missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestExported(t *testing.T) {
+	goLang := lsp.DetectLanguage("a.go")
+	pyLang := lsp.DetectLanguage("a.py")
+	tests := []struct {
+		lang *lsp.Language
+		name string
+		want bool
+	}{
+		{goLang, "Parse", true},
+		{goLang, "parse", false},
+		{goLang, "(*Client).Close", true},
+		{goLang, "Client.close", false},
+		{pyLang, "parse", true},
+		{pyLang, "_parse", false},
+		{lsp.DetectLanguage("a.rs"), "parse", true},
+	}
+	for _, tt := range tests {
+		if got := exported(tt.lang, tt.name); got != tt.want {
+			t.Errorf("exported(%s, %q) = %v, want %v", tt.lang.Name, tt.name, got, tt.want)
+		}
+	}
+}
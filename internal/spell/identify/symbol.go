@@ -0,0 +1,407 @@
+package identify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/emiliopalmerini/grimorio/internal/git"
+	"github.com/emiliopalmerini/grimorio/internal/lsp"
+	"github.com/emiliopalmerini/grimorio/internal/redact"
+)
+
+// maxTypeLookups bounds the definition requests made for one symbol.
+const maxTypeLookups = 40
+
+// maxTypeDefinitions bounds the type definitions sent with a symbol.
+const maxTypeDefinitions = 6
+
+// maxDefinitionLines bounds the lines shown of each type definition.
+const maxDefinitionLines = 40
+
+// maxCallSites bounds the call sites sent with a symbol.
+const maxCallSites = 8
+
+// callSiteContext is the number of lines shown around a call site.
+const callSiteContext = 2
+
+// ErrSymbolNotFound is returned when neither the file nor the workspace has
+// the requested symbol.
+var ErrSymbolNotFound = errors.New("symbol not found")
+
+// Symbol is a symbol resolved through the language server, with the
+// context needed to explain it without the rest of its file.
+type Symbol struct {
+	Name        string
+	Kind        string
+	Path        string // Relative to the repository root
+	Line        int    // 1-based, including its leading comment
+	EndLine     int
+	Source      string
+	Hover       string
+	Definitions []Snippet // Types it uses
+	CallSites   []Snippet
+	References  int // Call sites found, of which CallSites is a sample
+}
+
+// Snippet is a piece of a file.
+type Snippet struct {
+	Title string
+	Path  string
+	Line  int // 1-based
+	Code  string
+}
+
+// workspace reads files for the language server, within the repository
+// and the redaction rules.
+type workspace struct {
+	client   *lsp.Client
+	lang     *lsp.Language
+	root     string
+	redactor *redact.Redactor
+	files    map[string][]string
+	opened   map[string]bool
+}
+
+// ResolveSymbol finds name in the file at path, or elsewhere in the
+// workspace, and gathers its source, type information, the types it uses
+// and a sample of its call sites. It fails when no language server is
+// available or the symbol cannot be found.
+func ResolveSymbol(path, content, name string) (*Symbol, error) {
+	lang := lsp.DetectLanguage(path)
+	if lang == nil {
+		return nil, fmt.Errorf("no language server for %s files", filepath.Ext(path))
+	}
+	if !lang.Available() {
+		return nil, fmt.Errorf("LSP server not found: %s", lang.Command)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	redactor, err := redact.Load()
+	if err != nil {
+		return nil, err
+	}
+	root, err := git.GetRootDir()
+	if err != nil {
+		root = filepath.Dir(abs)
+	}
+
+	client, err := lsp.NewClient(lang)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	if err := client.Initialize(context.Background(), root); err != nil {
+		return nil, err
+	}
+
+	ws := &workspace{client: client, lang: lang, root: root, redactor: redactor, files: make(map[string][]string), opened: map[string]bool{abs: true}}
+	ws.files[abs] = strings.Split(content, "\n")
+	uri := "file://" + abs
+	if err := client.OpenDocument(uri, lang.Name, content); err != nil {
+		return nil, err
+	}
+	defer client.CloseDocument(uri)
+
+	sym, file, err := ws.find(abs, name)
+	if err != nil {
+		return nil, err
+	}
+	return ws.describe(sym, file), nil
+}
+
+// find looks for name among the symbols of the file, then in the
+// workspace.
+func (w *workspace) find(abs, name string) (lsp.DocumentSymbol, string, error) {
+	symbols, err := w.client.DocumentSymbols("file://" + abs)
+	if err == nil {
+		if sym, ok := matchSymbol(symbols, name); ok {
+			return sym, abs, nil
+		}
+	}
+
+	found, err := w.client.WorkspaceSymbols(name)
+	if err != nil {
+		return lsp.DocumentSymbol{}, "", fmt.Errorf("%w: %s", ErrSymbolNotFound, name)
+	}
+	for _, s := range found {
+		path := s.Location.Path()
+		if !symbolMatches(s.Name, name) || !w.readable(path) {
+			continue
+		}
+		symbols, err := w.symbols(path)
+		if err != nil {
+			continue
+		}
+		if sym, ok := enclosing(symbols, s.Location.Range.Start.Line, nil); ok {
+			return sym, path, nil
+		}
+	}
+	return lsp.DocumentSymbol{}, "", fmt.Errorf("%w: %s", ErrSymbolNotFound, name)
+}
+
+// describe gathers what is sent about sym, defined in file.
+func (w *workspace) describe(sym lsp.DocumentSymbol, file string) *Symbol {
+	lines := w.files[file]
+	end := min(sym.EndLine, len(lines)-1)
+	sym.Line = min(sym.Line, end)
+	start := leadingComment(lines, sym.Line)
+
+	s := &Symbol{
+		Name:    sym.Name,
+		Kind:    sym.Kind,
+		Path:    w.rel(file),
+		Line:    start + 1,
+		EndLine: end + 1,
+		Source:  strings.Join(lines[start:end+1], "\n"),
+	}
+
+	uri := "file://" + file
+	s.Hover, _ = w.client.Hover(uri, sym.Selection)
+
+	lookups := make(map[string]bool)
+	for line := sym.Line; line <= end && len(s.Definitions) < maxTypeDefinitions; line++ {
+		for _, m := range typeNameRe.FindAllStringIndex(lines[line], -1) {
+			ident := lines[line][m[0]:m[1]]
+			if ident == sym.Name || lookups[ident] || len(lookups) == maxTypeLookups || len(s.Definitions) == maxTypeDefinitions {
+				continue
+			}
+			lookups[ident] = true
+			if def, ok := w.typeDefinition(uri, lsp.Position{Line: line, Character: m[0]}, file, sym); ok {
+				s.Definitions = append(s.Definitions, def)
+			}
+		}
+	}
+
+	references, _ := w.client.References(uri, sym.Selection, false)
+	var sites []lsp.Location
+	for _, ref := range references {
+		if ref.Path() == file && ref.Range.Start.Line >= sym.Line && ref.Range.Start.Line <= end {
+			continue
+		}
+		sites = append(sites, ref)
+	}
+	s.References = len(sites)
+	for _, ref := range sampleSites(sites, maxCallSites) {
+		path := ref.Path()
+		if !w.readable(path) {
+			continue
+		}
+		lines := w.files[path]
+		line := ref.Range.Start.Line
+		if line >= len(lines) {
+			continue
+		}
+		from, to := max(0, line-callSiteContext), min(len(lines)-1, line+callSiteContext)
+
+		var sb strings.Builder
+		for i := from; i <= to; i++ {
+			marker := " "
+			if i == line {
+				marker = ">"
+			}
+			fmt.Fprintf(&sb, "%s %4d | %s\n", marker, i+1, lines[i])
+		}
+		s.CallSites = append(s.CallSites, Snippet{Path: w.rel(path), Line: line + 1, Code: sb.String()})
+	}
+
+	return s
+}
+
+// typeDefinition follows the identifier at pos to the type it names and
+// returns its declaration.
+func (w *workspace) typeDefinition(uri string, pos lsp.Position, file string, self lsp.DocumentSymbol) (Snippet, bool) {
+	definitions, err := w.client.Definition(uri, pos)
+	if err != nil || len(definitions) == 0 {
+		return Snippet{}, false
+	}
+	def := definitions[0]
+	path := def.Path()
+	if !w.readable(path) {
+		return Snippet{}, false
+	}
+
+	symbols, err := w.symbols(path)
+	if err != nil {
+		return Snippet{}, false
+	}
+	decl, ok := enclosing(symbols, def.Range.Start.Line, typeKinds)
+	if !ok || (path == file && decl.Line == self.Line) {
+		return Snippet{}, false
+	}
+
+	lines := w.files[path]
+	if decl.Line >= len(lines) {
+		return Snippet{}, false
+	}
+	start := leadingComment(lines, decl.Line)
+	end := min(decl.EndLine, len(lines)-1, start+maxDefinitionLines-1)
+	code := strings.Join(lines[start:end+1], "\n")
+	if end < decl.EndLine {
+		code += "\n..."
+	}
+	return Snippet{Title: decl.Name, Path: w.rel(path), Line: start + 1, Code: code}, true
+}
+
+// symbols returns the document symbols of a file, opening it in the
+// language server if it is not the one being explained.
+func (w *workspace) symbols(path string) ([]lsp.DocumentSymbol, error) {
+	uri := "file://" + path
+	if lsp.DetectLanguage(path) != w.lang {
+		return nil, fmt.Errorf("%s is not a %s file", path, w.lang.Name)
+	}
+	if !w.opened[path] {
+		if err := w.client.OpenDocument(uri, w.lang.Name, strings.Join(w.files[path], "\n")); err != nil {
+			return nil, err
+		}
+		defer w.client.CloseDocument(uri)
+	}
+	return w.client.DocumentSymbols(uri)
+}
+
+// readable loads path unless it is outside the repository or excluded by
+// the redaction rules.
+func (w *workspace) readable(path string) bool {
+	if _, ok := w.files[path]; ok {
+		return w.files[path] != nil
+	}
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil || strings.HasPrefix(rel, "..") || w.redactor.Excluded(filepath.ToSlash(rel)) {
+		w.files[path] = nil
+		return false
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		w.files[path] = nil
+		return false
+	}
+	w.files[path] = strings.Split(string(content), "\n")
+	return true
+}
+
+func (w *workspace) rel(path string) string {
+	if rel, err := filepath.Rel(w.root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// Context renders the type information, type definitions and call sites
+// for the prompt.
+func (s *Symbol) Context() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s is a %s at %s:%d-%d.\n", s.Name, strings.ToLower(s.Kind), s.Path, s.Line, s.EndLine)
+	if s.Hover != "" {
+		fmt.Fprintf(&sb, "\nType information:\n%s\n", s.Hover)
+	}
+	if len(s.Definitions) > 0 {
+		sb.WriteString("\nTypes it uses:\n")
+		for _, d := range s.Definitions {
+			fmt.Fprintf(&sb, "=== %s (%s:%d) ===\n%s\n", d.Title, d.Path, d.Line, d.Code)
+		}
+	}
+	if len(s.CallSites) > 0 {
+		fmt.Fprintf(&sb, "\nCall sites (%d of %d):\n", len(s.CallSites), s.References)
+		for _, c := range s.CallSites {
+			fmt.Fprintf(&sb, "=== %s:%d ===\n%s", c.Path, c.Line, c.Code)
+		}
+	} else {
+		sb.WriteString("\nNo call sites found.\n")
+	}
+	return sb.String()
+}
+
+// matchSymbol picks the symbol called name, preferring an exact match over
+// a method or member with that name.
+func matchSymbol(symbols []lsp.DocumentSymbol, name string) (lsp.DocumentSymbol, bool) {
+	for _, sym := range symbols {
+		if sym.Name == name {
+			return sym, true
+		}
+	}
+	for _, sym := range symbols {
+		if symbolMatches(sym.Name, name) {
+			return sym, true
+		}
+	}
+	return lsp.DocumentSymbol{}, false
+}
+
+// symbolMatches reports whether a symbol name as reported by a server, like
+// "(*Client).Close", is what the user asked for: "(*Client).Close",
+// "Client.Close" or "Close".
+func symbolMatches(symbol, name string) bool {
+	normalize := strings.NewReplacer("(", "", ")", "", "*", "").Replace
+	symbol, name = normalize(symbol), normalize(name)
+	return symbol == name || strings.HasSuffix(symbol, "."+name)
+}
+
+// enclosing returns the innermost symbol spanning line, of one of kinds if
+// given.
+func enclosing(symbols []lsp.DocumentSymbol, line int, kinds map[string]bool) (lsp.DocumentSymbol, bool) {
+	var best lsp.DocumentSymbol
+	found := false
+	for _, sym := range symbols {
+		if line < sym.Line || line > sym.EndLine || (kinds != nil && !kinds[sym.Kind]) {
+			continue
+		}
+		if !found || sym.EndLine-sym.Line < best.EndLine-best.Line {
+			best, found = sym, true
+		}
+	}
+	return best, found
+}
+
+// leadingComment returns the first line of the comment or attributes right
+// above line, or line itself if there are none.
+func leadingComment(lines []string, line int) int {
+	for line > 0 {
+		prev := strings.TrimSpace(lines[line-1])
+		if prev == "" || !(strings.HasPrefix(prev, "//") || strings.HasPrefix(prev, "#") ||
+			strings.HasPrefix(prev, "/*") || strings.HasPrefix(prev, "*") ||
+			strings.HasPrefix(prev, "--") || strings.HasPrefix(prev, "@") ||
+			strings.HasPrefix(prev, "[")) {
+			break
+		}
+		line--
+	}
+	return line
+}
+
+// sampleSites picks up to n call sites, spreading them across files: one
+// from each file first, in order, then the rest.
+func sampleSites(sites []lsp.Location, n int) []lsp.Location {
+	sorted := append([]lsp.Location(nil), sites...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].URI != sorted[j].URI {
+			return sorted[i].URI < sorted[j].URI
+		}
+		return sorted[i].Range.Start.Line < sorted[j].Range.Start.Line
+	})
+
+	var first, rest []lsp.Location
+	seen := make(map[string]bool)
+	for _, s := range sorted {
+		if seen[s.URI] {
+			rest = append(rest, s)
+			continue
+		}
+		seen[s.URI] = true
+		first = append(first, s)
+	}
+	sample := append(first, rest...)
+	if len(sample) > n {
+		sample = sample[:n]
+	}
+	return sample
+}
@@ -0,0 +1,112 @@
+package identify
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/emiliopalmerini/grimorio/internal/lsp"
+)
+
+func TestMatchSymbol(t *testing.T) {
+	symbols := []lsp.DocumentSymbol{
+		{Name: "Client", Kind: "Struct"},
+		{Name: "(*Client).Close", Kind: "Method"},
+		{Name: "Close", Kind: "Function"},
+		{Name: "(*Server).Start", Kind: "Method"},
+	}
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"Close", "Close", true},
+		{"Client.Close", "(*Client).Close", true},
+		{"(*Client).Close", "(*Client).Close", true},
+		{"Start", "(*Server).Start", true},
+		{"lose", "", false},
+		{"Stop", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := matchSymbol(symbols, tt.name)
+		if ok != tt.ok || got.Name != tt.want {
+			t.Errorf("matchSymbol(%q) = %q, %v, want %q, %v", tt.name, got.Name, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestEnclosing(t *testing.T) {
+	symbols := []lsp.DocumentSymbol{
+		{Name: "Client", Kind: "Class", Line: 0, EndLine: 50},
+		{Name: "connect", Kind: "Method", Line: 10, EndLine: 20},
+		{Name: "Options", Kind: "Struct", Line: 60, EndLine: 70},
+	}
+	if got, _ := enclosing(symbols, 15, nil); got.Name != "connect" {
+		t.Errorf("enclosing(15) = %q, want connect", got.Name)
+	}
+	if got, _ := enclosing(symbols, 15, typeKinds); got.Name != "Client" {
+		t.Errorf("enclosing(15, types) = %q, want Client", got.Name)
+	}
+	if _, ok := enclosing(symbols, 55, nil); ok {
+		t.Error("enclosing(55) found a symbol between declarations")
+	}
+}
+
+func TestLeadingComment(t *testing.T) {
+	lines := strings.Split(`x := 1
+
+// Parse reads a diff.
+// It never fails.
+func Parse() {}
+
+@decorator
+def run():`, "\n")
+	if got := leadingComment(lines, 4); got != 2 {
+		t.Errorf("leadingComment(4) = %d, want 2", got)
+	}
+	if got := leadingComment(lines, 7); got != 6 {
+		t.Errorf("leadingComment(7) = %d, want 6", got)
+	}
+	if got := leadingComment(lines, 0); got != 0 {
+		t.Errorf("leadingComment(0) = %d, want 0", got)
+	}
+}
+
+func TestSampleSites(t *testing.T) {
+	at := func(uri string, line int) lsp.Location {
+		return lsp.Location{URI: uri, Range: lsp.Range{Start: lsp.Position{Line: line}}}
+	}
+	sites := []lsp.Location{at("file:///b.go", 9), at("file:///a.go", 3), at("file:///a.go", 1), at("file:///c.go", 5), at("file:///b.go", 2)}
+
+	var got []string
+	for _, s := range sampleSites(sites, 4) {
+		got = append(got, s.Path()+":"+string(rune('0'+s.Range.Start.Line)))
+	}
+	if want := "/a.go:1 /b.go:2 /c.go:5 /a.go:3"; strings.Join(got, " ") != want {
+		t.Errorf("sampleSites() = %s, want %s", strings.Join(got, " "), want)
+	}
+}
+
+func TestSymbolContext(t *testing.T) {
+	s := &Symbol{
+		Name: "Parse", Kind: "Function", Path: "internal/diff/parser.go", Line: 10, EndLine: 40,
+		Hover:       "func Parse(raw string) []File",
+		Definitions: []Snippet{{Title: "File", Path: "internal/diff/types.go", Line: 3, Code: "type File struct{}"}},
+		CallSites:   []Snippet{{Path: "cmd/diff.go", Line: 12, Code: ">   12 | diff.Parse(raw)\n"}},
+		References:  5,
+	}
+	got := s.Context()
+	for _, want := range []string{
+		"Parse is a function at internal/diff/parser.go:10-40.\n",
+		"\nType information:\nfunc Parse(raw string) []File\n",
+		"\nTypes it uses:\n=== File (internal/diff/types.go:3) ===\ntype File struct{}\n",
+		"\nCall sites (1 of 5):\n=== cmd/diff.go:12 ===\n>   12 | diff.Parse(raw)\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Context() missing %q in:\n%s", want, got)
+		}
+	}
+
+	if got := (&Symbol{Name: "x", Kind: "Variable"}).Context(); !strings.Contains(got, "No call sites found.") {
+		t.Errorf("Context() = %q, want a note that there are no call sites", got)
+	}
+}
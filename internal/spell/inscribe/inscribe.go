@@ -0,0 +1,304 @@
+package inscribe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/emiliopalmerini/grimorio/internal/cantrip/mending"
+	"github.com/emiliopalmerini/grimorio/internal/claude"
+	"github.com/emiliopalmerini/grimorio/internal/goast"
+	"github.com/emiliopalmerini/grimorio/internal/lsp"
+	"github.com/emiliopalmerini/grimorio/internal/prompt"
+	"github.com/emiliopalmerini/grimorio/internal/spell/identify"
+	"github.com/emiliopalmerini/grimorio/internal/textutil"
+)
+
+// DefaultModel writes doc comments unless configured otherwise.
+const DefaultModel = claude.Sonnet
+
+// ErrUnsupported is returned for files in languages inscribe cannot
+// document.
+var ErrUnsupported = errors.New("unsupported language")
+
+// docKinds are the symbol kinds that get doc comments, besides Go's, which
+// come from go/ast.
+var docKinds = map[string]bool{
+	"Class": true, "Interface": true, "Struct": true, "Enum": true,
+	"Function": true, "Method": true, "Constructor": true,
+}
+
+// Target is an exported symbol without a doc comment.
+type Target struct {
+	Name   string
+	Kind   string
+	Line   int    // 0-based line the comment is inserted at
+	Indent string // Indentation of the comment
+}
+
+// File is a source file and the symbols it lacks docs for.
+type File struct {
+	Path     string
+	Language string
+	Content  string
+	Targets  []Target
+}
+
+// Supported reports whether inscribe can document the file at path. Test
+// files are left out.
+func Supported(path string) bool {
+	lang := lsp.DetectLanguage(path)
+	if lang == nil || identify.IsTestFile(filepath.Base(path)) {
+		return false
+	}
+	switch lang.Name {
+	case "go", "python", "rust", "csharp", "typescript":
+		return true
+	}
+	return false
+}
+
+// Find reads the file at path and lists its exported symbols without doc
+// comments. Go files are parsed with go/ast; other languages need their
+// language server.
+func Find(path string) (*File, error) {
+	if !Supported(path) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, path)
+	}
+	content, err := identify.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lang := lsp.DetectLanguage(path)
+	file := &File{Path: path, Language: lang.Name, Content: content}
+	lines := strings.Split(content, "\n")
+
+	if lang.Name == "go" {
+		symbols, err := goast.Undocumented(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		for _, s := range symbols {
+			file.Targets = append(file.Targets, Target{Name: s.Name, Kind: s.Kind, Line: s.Line, Indent: textutil.Indentation(lines[s.Line])})
+		}
+		return file, nil
+	}
+
+	symbols, err := lsp.FileSymbols(context.Background(), path, content, lang)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range symbols {
+		if !docKinds[s.Kind] || s.Line >= len(lines) || !exported(lang.Name, s.Name, lines[s.Line]) {
+			continue
+		}
+		if t, ok := target(lang.Name, lines, s); ok {
+			file.Targets = append(file.Targets, t)
+		}
+	}
+	return file, nil
+}
+
+// Inscribe asks Claude for the doc comments of file's targets and returns
+// the file with them inserted, along with the number of comments.
+func Inscribe(model claude.Model, file *File) (string, int, error) {
+	text, err := Prompt(model, file)
+	if err != nil {
+		return "", 0, err
+	}
+	response, err := claude.Run(model, "inscribe", text)
+	if err != nil {
+		return "", 0, err
+	}
+
+	edits := Edits(file, ParseComments(response))
+	if len(edits) == 0 {
+		return "", 0, fmt.Errorf("no doc comments in the response for %s", file.Path)
+	}
+	updated := mending.ApplyEdits(file.Content, edits)
+
+	if file.Language == "go" {
+		if _, err := goast.Undocumented(updated); err != nil {
+			return "", 0, fmt.Errorf("doc comments break %s: %w", file.Path, err)
+		}
+	}
+	return updated, len(edits), nil
+}
+
+// Prompt renders the inscribe prompt for file without sending it.
+func Prompt(model claude.Model, file *File) (string, error) {
+	tmpl, err := prompt.Load("inscribe")
+	if err != nil {
+		return "", err
+	}
+
+	var symbols strings.Builder
+	for _, t := range file.Targets {
+		fmt.Fprintf(&symbols, "- %s (%s, line %d)\n", t.Name, strings.ToLower(t.Kind), t.Line+1)
+	}
+	return tmpl.Render(model, prompt.Data{
+		Path:     file.Path,
+		Language: file.Language,
+		Symbols:  symbols.String(),
+		Code:     file.Content,
+	})
+}
+
+// ParseComments reads the "=== Name ===" blocks of a response into comment
+// text by symbol name.
+func ParseComments(response string) map[string]string {
+	response = textutil.StripCodeBlock(strings.TrimSpace(response))
+
+	comments := make(map[string]string)
+	var name string
+	var body []string
+	flush := func() {
+		if text := strings.TrimSpace(strings.Join(body, "\n")); name != "" && text != "" {
+			comments[name] = text
+		}
+	}
+	for _, line := range strings.Split(response, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "=== ") && strings.HasSuffix(trimmed, " ===") && len(trimmed) > 8 {
+			flush()
+			name, body = strings.TrimSpace(trimmed[4:len(trimmed)-4]), nil
+			continue
+		}
+		body = append(body, strings.TrimRight(line, " \t"))
+	}
+	flush()
+	return comments
+}
+
+// Edits turns comments into insertions above their symbols, or below the
+// signature for Python docstrings, formatted for the file's language.
+func Edits(file *File, comments map[string]string) []lsp.TextEdit {
+	var edits []lsp.TextEdit
+	for _, t := range file.Targets {
+		text, ok := comments[t.Name]
+		if !ok {
+			continue
+		}
+		at := lsp.Position{Line: t.Line, Character: 0}
+		edits = append(edits, lsp.TextEdit{
+			Range:   lsp.Range{Start: at, End: at},
+			NewText: format(file.Language, t, text),
+		})
+	}
+	return edits
+}
+
+// format renders comment text with the markers and indentation of lang.
+func format(lang string, t Target, text string) string {
+	lines := strings.Split(text, "\n")
+	var sb strings.Builder
+	prefixed := func(prefix string) {
+		// Markers in the text itself would be doubled
+		marker := strings.TrimSpace(prefix)
+		for _, line := range lines {
+			if strings.HasPrefix(line, marker) {
+				line = strings.TrimPrefix(line[len(marker):], " ")
+			}
+			sb.WriteString(strings.TrimRight(t.Indent+prefix+line, " ") + "\n")
+		}
+	}
+
+	switch lang {
+	case "go":
+		prefixed("// ")
+	case "rust":
+		prefixed("/// ")
+	case "csharp":
+		sb.WriteString(t.Indent + "/// <summary>\n")
+		prefixed("/// ")
+		sb.WriteString(t.Indent + "/// </summary>\n")
+	case "python":
+		if len(lines) == 1 {
+			sb.WriteString(t.Indent + `"""` + lines[0] + `"""` + "\n")
+			break
+		}
+		sb.WriteString(t.Indent + `"""` + lines[0] + "\n")
+		for _, line := range lines[1:] {
+			sb.WriteString(strings.TrimRight(t.Indent+line, " ") + "\n")
+		}
+		sb.WriteString(t.Indent + `"""` + "\n")
+	default:
+		sb.WriteString(t.Indent + "/**\n")
+		prefixed(" * ")
+		sb.WriteString(t.Indent + " */\n")
+	}
+	return sb.String()
+}
+
+// target decides where the comment of sym goes, and whether it already has
+// one: a comment above it, past any attributes or decorators, or a Python
+// docstring as the first statement of its body.
+func target(lang string, lines []string, sym lsp.DocumentSymbol) (Target, bool) {
+	t := Target{Name: sym.Name, Kind: sym.Kind}
+
+	if lang == "python" {
+		end := sym.Line
+		for end < min(sym.EndLine, len(lines)-1) && !strings.HasSuffix(strings.TrimSpace(textutil.StripHashComment(lines[end])), ":") {
+			end++
+		}
+		if !strings.HasSuffix(strings.TrimSpace(textutil.StripHashComment(lines[end])), ":") {
+			// One-line bodies have nowhere to put a docstring
+			return t, false
+		}
+		body := end + 1
+		for body < len(lines) && strings.TrimSpace(lines[body]) == "" {
+			body++
+		}
+		if body < len(lines) {
+			first := strings.TrimLeft(strings.TrimSpace(lines[body]), "rRuUbB")
+			if strings.HasPrefix(first, `"""`) || strings.HasPrefix(first, `'''`) {
+				return t, false
+			}
+			t.Indent = textutil.Indentation(lines[body])
+		}
+		if len(t.Indent) <= len(textutil.Indentation(lines[sym.Line])) {
+			t.Indent = textutil.Indentation(lines[sym.Line]) + "    "
+		}
+		t.Line = end + 1
+		return t, true
+	}
+
+	line := sym.Line
+	for line > 0 && isAttribute(lines[line-1]) {
+		line--
+	}
+	if line > 0 {
+		prev := strings.TrimSpace(lines[line-1])
+		if strings.HasPrefix(prev, "//") || strings.HasPrefix(prev, "*") || strings.HasSuffix(prev, "*/") {
+			return t, false
+		}
+	}
+	t.Line = line
+	t.Indent = textutil.Indentation(lines[line])
+	return t, true
+}
+
+// exported reports whether a symbol is public, from the conventions of lang
+// and the line it is declared on.
+func exported(lang, name, decl string) bool {
+	decl = strings.TrimSpace(decl)
+	switch lang {
+	case "python":
+		return !strings.HasPrefix(name, "_")
+	case "rust":
+		return strings.HasPrefix(decl, "pub ") || strings.HasPrefix(decl, "pub(")
+	case "csharp":
+		return strings.Contains(decl, "public ") || strings.Contains(decl, "protected ")
+	case "typescript":
+		return strings.HasPrefix(decl, "export ")
+	}
+	return false
+}
+
+func isAttribute(line string) bool {
+	line = strings.TrimSpace(line)
+	return strings.HasPrefix(line, "#[") || strings.HasPrefix(line, "@") || (strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"))
+}
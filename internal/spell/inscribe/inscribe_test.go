@@ -0,0 +1,170 @@
+package inscribe
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/emiliopalmerini/grimorio/internal/cantrip/mending"
+	"github.com/emiliopalmerini/grimorio/internal/claude"
+	"github.com/emiliopalmerini/grimorio/internal/lsp"
+)
+
+func TestFindGo(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if out, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	src := `package sample
+
+// Documented is fine.
+type Documented struct{}
+
+type Client struct {
+	Name string
+}
+
+func (c *Client) Close() error {
+	return nil
+}
+
+func helper() {}
+`
+	os.WriteFile("sample.go", []byte(src), 0o644)
+
+	file, err := Find("sample.go")
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	if len(file.Targets) != 2 || file.Targets[0].Name != "Client" || file.Targets[1].Name != "(*Client).Close" {
+		t.Fatalf("Find() targets = %+v", file.Targets)
+	}
+
+	text, err := Prompt(claude.Sonnet, file)
+	if err != nil {
+		t.Fatalf("Prompt() error = %v", err)
+	}
+	if !strings.Contains(text, "- (*Client).Close (method, line 10)") {
+		t.Errorf("Prompt() does not list the symbols:\n%s", text)
+	}
+
+	response := "=== Client ===\nClient talks to the server.\n\n=== (*Client).Close ===\n// Close releases the connection.\n"
+	updated := mending.ApplyEdits(file.Content, Edits(file, ParseComments(response)))
+
+	want := strings.Replace(src, "type Client struct", "// Client talks to the server.\ntype Client struct", 1)
+	want = strings.Replace(want, "func (c *Client) Close", "// Close releases the connection.\nfunc (c *Client) Close", 1)
+	if updated != want {
+		t.Errorf("ApplyEdits() =\n%s\nwant\n%s", updated, want)
+	}
+}
+
+func TestParseComments(t *testing.T) {
+	response := "```\n=== Parse ===\nParse reads a diff.\nIt never fails.\n\n=== Empty ===\n\n=== Hunk ===\nHunk is a change.\n```"
+	got := ParseComments(response)
+	if len(got) != 2 || got["Parse"] != "Parse reads a diff.\nIt never fails." || got["Hunk"] != "Hunk is a change." {
+		t.Errorf("ParseComments() = %q", got)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		lang   string
+		indent string
+		text   string
+		want   string
+	}{
+		{"go", "\t", "Mode is a mode.\n\nIt has values.", "\t// Mode is a mode.\n\t//\n\t// It has values.\n"},
+		{"rust", "", "Parses input.", "/// Parses input.\n"},
+		{"csharp", "    ", "Opens the file.", "    /// <summary>\n    /// Opens the file.\n    /// </summary>\n"},
+		{"typescript", "", "Renders the page.", "/**\n * Renders the page.\n */\n"},
+		{"python", "    ", "Return the sum.", "    \"\"\"Return the sum.\"\"\"\n"},
+		{"python", "    ", "Return the sum.\n\nOverflows wrap.", "    \"\"\"Return the sum.\n\n    Overflows wrap.\n    \"\"\"\n"},
+	}
+	for _, tt := range tests {
+		if got := format(tt.lang, Target{Indent: tt.indent}, tt.text); got != tt.want {
+			t.Errorf("format(%s, %q) = %q, want %q", tt.lang, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTarget(t *testing.T) {
+	rust := strings.Split(`/// Documented.
+pub fn documented() {}
+
+#[derive(Debug)]
+pub struct Config {}
+
+    pub fn method(&self) {}`, "\n")
+	tests := []struct {
+		name  string
+		lang  string
+		lines []string
+		sym   lsp.DocumentSymbol
+		want  Target
+		ok    bool
+	}{
+		{"rust documented", "rust", rust, lsp.DocumentSymbol{Name: "documented", Line: 1}, Target{}, false},
+		{"rust attribute", "rust", rust, lsp.DocumentSymbol{Name: "Config", Line: 4}, Target{Name: "Config", Line: 3}, true},
+		{"rust indented", "rust", rust, lsp.DocumentSymbol{Name: "method", Line: 6}, Target{Name: "method", Line: 6, Indent: "    "}, true},
+		{
+			"python", "python",
+			strings.Split("def add(a,\n        b):  # sum\n    return a + b", "\n"),
+			lsp.DocumentSymbol{Name: "add", Line: 0, EndLine: 2},
+			Target{Name: "add", Line: 2, Indent: "    "}, true,
+		},
+		{
+			"python docstring", "python",
+			strings.Split("def add(a, b):\n    '''Add.'''\n    return a + b", "\n"),
+			lsp.DocumentSymbol{Name: "add", Line: 0, EndLine: 2},
+			Target{}, false,
+		},
+		{
+			"python one line", "python",
+			strings.Split("def add(a, b): return a + b", "\n"),
+			lsp.DocumentSymbol{Name: "add", Line: 0, EndLine: 0},
+			Target{}, false,
+		},
+	}
+	for _, tt := range tests {
+		got, ok := target(tt.lang, tt.lines, tt.sym)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("%s: target() = %+v, %v, want %+v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestExported(t *testing.T) {
+	tests := []struct {
+		lang, name, decl string
+		want             bool
+	}{
+		{"python", "run", "def run():", true},
+		{"python", "_run", "def _run():", false},
+		{"rust", "run", "pub fn run() {", true},
+		{"rust", "run", "pub(crate) fn run() {", true},
+		{"rust", "run", "fn run() {", false},
+		{"csharp", "Run", "public void Run()", true},
+		{"csharp", "Run", "private void Run()", false},
+		{"typescript", "run", "export function run() {", true},
+		{"typescript", "run", "function run() {", false},
+	}
+	for _, tt := range tests {
+		if got := exported(tt.lang, tt.name, tt.decl); got != tt.want {
+			t.Errorf("exported(%s, %q) = %v, want %v", tt.lang, tt.decl, got, tt.want)
+		}
+	}
+}
+
+func TestSupported(t *testing.T) {
+	for path, want := range map[string]bool{
+		"main.go": true, "main_test.go": false, "app.py": true, "test_app.py": false,
+		"lib.rs": true, "App.cs": true, "index.ts": true, "app.spec.ts": false,
+		"flake.nix": false, "README.md": false,
+	} {
+		if got := Supported(path); got != want {
+			t.Errorf("Supported(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
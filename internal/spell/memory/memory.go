@@ -10,20 +10,37 @@ import (
 	"github.com/emiliopalmerini/grimorio/internal/diff"
 	"github.com/emiliopalmerini/grimorio/internal/editor"
 	"github.com/emiliopalmerini/grimorio/internal/git"
+	"github.com/emiliopalmerini/grimorio/internal/prompt"
 	"github.com/emiliopalmerini/grimorio/internal/textutil"
 )
 
-func GetDiff(all bool) (string, error) {
+// DefaultModel writes commit messages unless configured otherwise.
+const DefaultModel = claude.Haiku
+
+func GetDiff(all bool, model claude.Model) (string, error) {
 	rawDiff, err := git.GetDiff(git.DiffOptions{All: all})
 	if err != nil {
 		return "", err
 	}
 
+	profile, err := diff.LoadProfile()
+	if err != nil {
+		return "", err
+	}
+
 	opts := diff.DefaultOptions()
+	opts.Profile = profile
+	opts.MaxHighPriorityLines = profile.Budget("modify-memory", 0)
+	if opts.MaxHighPriorityTokens, err = prompt.DiffBudget(model); err != nil {
+		return "", err
+	}
+	if !all {
+		opts.NewRev = diff.IndexRev
+	}
 	prioritized, err := diff.Prioritize(rawDiff, opts)
 	if err != nil {
-		// Fall back to raw diff on error
-		return git.TruncateDiff(rawDiff, git.DefaultMaxDiffLines), nil
+		// Fall back to raw diff on error, the prompt builder truncates it
+		return rawDiff, nil
 	}
 
 	return diff.FormatForPrompt(prioritized), nil
@@ -33,39 +50,17 @@ func GetRecentCommits(n int) (string, error) {
 	return git.GetRecentCommits(n, "%s")
 }
 
-func GenerateMessage(diff string, history string, description string) (string, error) {
-	prompt := `Analyze this git diff and generate a conventional commit message with title and body.
-
-Rules:
-- Use conventional commits format for the title: type(scope): description
-- Types: feat, fix, docs, style, refactor, test, chore
-- Keep the title under 50 characters
-- Add a blank line after the title
-- Write a concise body explaining what changed and why
-- Focus on the "why" not the "what"
-- Do not use emojis
-- Output ONLY the commit message (title + body), nothing else
-`
-
-	if history != "" {
-		prompt += `
-Recent commits (match this style):
-` + history + `
-`
+func GenerateMessage(model claude.Model, diff string, history string, description string) (string, error) {
+	tmpl, err := prompt.Load("modify-memory")
+	if err != nil {
+		return "", err
 	}
-
-	if description != "" {
-		prompt += `
-User motivation:
-` + description + `
-`
+	text, err := tmpl.Render(model, prompt.Data{Diff: diff, History: history, Description: description})
+	if err != nil {
+		return "", err
 	}
 
-	prompt += `
-Diff:
-` + diff
-
-	msg, err := claude.Run(claude.Haiku, "modify-memory", prompt)
+	msg, err := claude.Run(model, "modify-memory", text)
 	if err != nil {
 		return "", err
 	}
@@ -4,43 +4,55 @@ import (
 	"github.com/emiliopalmerini/grimorio/internal/claude"
 	"github.com/emiliopalmerini/grimorio/internal/diff"
 	"github.com/emiliopalmerini/grimorio/internal/git"
+	"github.com/emiliopalmerini/grimorio/internal/prompt"
 )
 
-// maxHighPriorityLines is the line budget for scrying.
-// Lower than modify-memory since scrying uses Opus which is more expensive.
-const maxHighPriorityLines = 300
+// DefaultModel reviews diffs unless configured otherwise.
+const DefaultModel = claude.Opus
 
-func GetDiff(all bool) (string, error) {
+func GetDiff(all bool, model claude.Model) (string, error) {
 	rawDiff, err := git.GetDiff(git.DiffOptions{All: all})
 	if err != nil {
 		return "", err
 	}
 
+	profile, err := diff.LoadProfile()
+	if err != nil {
+		return "", err
+	}
+
 	opts := diff.DefaultOptions()
-	opts.MaxHighPriorityLines = maxHighPriorityLines
+	opts.Profile = profile
+	opts.MaxHighPriorityLines = profile.Budget("scrying", 0)
+	if opts.MaxHighPriorityTokens, err = prompt.DiffBudget(model); err != nil {
+		return "", err
+	}
+	if !all {
+		opts.NewRev = diff.IndexRev
+	}
 	prioritized, err := diff.Prioritize(rawDiff, opts)
 	if err != nil {
-		// Fall back to truncated diff on error
-		return git.TruncateDiff(rawDiff, maxHighPriorityLines), nil
+		// Fall back to raw diff on error, the prompt builder truncates it
+		return rawDiff, nil
 	}
 
 	return diff.FormatForPrompt(prioritized), nil
 }
 
-func Review(diff string) (string, error) {
-	prompt := `Review this git diff for potential issues. Look for:
-- Bugs or logic errors
-- Security vulnerabilities
-- Performance issues
-- Code style problems
-- Missing error handling
-- Edge cases not handled
-
-Be concise. If the code looks good, say so briefly.
-If there are issues, list them with file and context.
+func Review(model claude.Model, diff string) (string, error) {
+	text, err := Prompt(model, diff)
+	if err != nil {
+		return "", err
+	}
 
-Diff:
-` + diff
+	return claude.Run(model, "scrying", text)
+}
 
-	return claude.Run(claude.Opus, "scrying", prompt)
+// Prompt renders the scrying prompt without sending it.
+func Prompt(model claude.Model, diff string) (string, error) {
+	tmpl, err := prompt.Load("scrying")
+	if err != nil {
+		return "", err
+	}
+	return tmpl.Render(model, prompt.Data{Diff: diff})
 }
@@ -2,13 +2,21 @@ package sending
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/emiliopalmerini/grimorio/internal/cantrip/breaking"
 	"github.com/emiliopalmerini/grimorio/internal/claude"
+	"github.com/emiliopalmerini/grimorio/internal/diff"
 	"github.com/emiliopalmerini/grimorio/internal/git"
+	"github.com/emiliopalmerini/grimorio/internal/prompt"
 	"github.com/emiliopalmerini/grimorio/internal/textutil"
 )
 
+// DefaultModel writes PR descriptions unless configured otherwise.
+const DefaultModel = claude.Haiku
+
 func GetBranchInfo() (current, base string, err error) {
 	current, err = git.GetCurrentBranch()
 	if err != nil {
@@ -27,46 +35,78 @@ func GetBranchInfo() (current, base string, err error) {
 	return current, base, nil
 }
 
-func GetBranchDiff(base string) (string, error) {
-	return git.GetBranchDiff(base, git.DefaultMaxDiffLines)
-}
-
-func GetBranchCommits(base string) (string, error) {
-	return git.GetBranchCommits(base)
+// GetAPIChanges detects exported API changes between base and HEAD in
+// the Go packages the branch changes. It returns nil for non-Go
+// repositories.
+func GetAPIChanges(base string) (*breaking.Report, error) {
+	root, err := git.GetRootDir()
+	if err != nil {
+		return nil, nil
+	}
+	if _, err := os.Stat(filepath.Join(root, "go.mod")); err != nil {
+		return nil, nil
+	}
+	return breaking.Detect(breaking.Options{Base: base, Head: "HEAD"})
 }
 
-func GeneratePRDescription(diff, commits, description string) (string, error) {
-	prompt := `Analyze this git branch and generate a pull request title and description.
+// GetBranchDiff returns the prioritized branch diff. Hunks touching
+// breaking API changes in report are always kept.
+func GetBranchDiff(base string, report *breaking.Report, model claude.Model) (string, error) {
+	rawDiff, err := git.GetBranchDiff(base, 0)
+	if err != nil {
+		return "", err
+	}
+	fork, err := git.MergeBase(base, "HEAD")
+	if err != nil {
+		return "", err
+	}
 
-Rules:
-- First line is the PR title: concise, under 72 characters
-- Add a blank line after the title
-- Write a "## Summary" section with 2-4 bullet points explaining the key changes
-- Write a "## Changes" section briefly describing what was modified
-- If applicable, add a "## Testing" section with suggested test steps
-- Do not use emojis
-- Output ONLY the PR title and description, nothing else
-`
+	profile, err := diff.LoadProfile()
+	if err != nil {
+		return "", err
+	}
 
-	if description != "" {
-		prompt += `
-User context:
-` + description + `
-`
+	opts := diff.DefaultOptions()
+	opts.Profile = profile
+	opts.MaxHighPriorityLines = profile.Budget("sending", 0)
+	if opts.MaxHighPriorityTokens, err = prompt.DiffBudget(model); err != nil {
+		return "", err
+	}
+	opts.BaseRev = fork
+	opts.NewRev = "HEAD"
+	if report != nil {
+		opts.Breaking = report.Locations()
 	}
 
-	if commits != "" {
-		prompt += `
-Branch commits:
-` + commits + `
-`
+	prioritized, err := diff.Prioritize(rawDiff, opts)
+	if err != nil {
+		// Fall back to raw diff on error, the prompt builder truncates it
+		return rawDiff, nil
 	}
 
-	prompt += `
-Diff:
-` + diff
+	return diff.FormatForPrompt(prioritized), nil
+}
+
+func GetBranchCommits(base string) (string, error) {
+	return git.GetBranchCommits(base)
+}
+
+func GeneratePRDescription(model claude.Model, diff, commits, description, apiChanges string) (string, error) {
+	tmpl, err := prompt.Load("sending")
+	if err != nil {
+		return "", err
+	}
+	text, err := tmpl.Render(model, prompt.Data{
+		Diff:        diff,
+		Commits:     commits,
+		Description: description,
+		APIChanges:  apiChanges,
+	})
+	if err != nil {
+		return "", err
+	}
 
-	msg, err := claude.Run(claude.Haiku, "sending", prompt)
+	msg, err := claude.Run(model, "sending", text)
 	if err != nil {
 		return "", err
 	}
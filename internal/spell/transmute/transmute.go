@@ -0,0 +1,478 @@
+package transmute
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/emiliopalmerini/grimorio/internal/claude"
+	"github.com/emiliopalmerini/grimorio/internal/diff"
+	"github.com/emiliopalmerini/grimorio/internal/goast"
+	"github.com/emiliopalmerini/grimorio/internal/lsp"
+	"github.com/emiliopalmerini/grimorio/internal/prompt"
+	"github.com/emiliopalmerini/grimorio/internal/spell/augury"
+	"github.com/emiliopalmerini/grimorio/internal/spell/identify"
+	"github.com/emiliopalmerini/grimorio/internal/textutil"
+)
+
+// DefaultModel writes tests unless configured otherwise.
+const DefaultModel = claude.Sonnet
+
+// DefaultAttempts bounds how many times failing tests are sent back.
+const DefaultAttempts = 3
+
+// ErrUnsupported is returned for files in languages transmute cannot write
+// tests for.
+var ErrUnsupported = errors.New("unsupported language")
+
+// ErrNoFunctions is returned for files whose changes touch no function.
+var ErrNoFunctions = errors.New("no changed functions")
+
+// functionKinds are the symbol kinds that get tests.
+var functionKinds = map[string]bool{"Function": true, "Method": true, "Constructor": true}
+
+var (
+	pyDefRe  = regexp.MustCompile(`^(\s*)(?:async\s+)?def\s+(\w+)\s*\(`)
+	pyTestRe = regexp.MustCompile(`(?m)^\s*(?:async\s+)?def\s+(test\w*)\s*\(`)
+	tsTestRe = regexp.MustCompile("\\b(?:it|test)\\(\\s*['\"`]([^'\"`]+)")
+)
+
+// Function is a function or method touched by the diff. Lines are 0-based.
+type Function struct {
+	Name    string
+	Kind    string
+	Line    int
+	EndLine int
+}
+
+// Target is a changed source file and the test file its tests go in.
+type Target struct {
+	Path      string // Relative to the working directory
+	Language  string
+	Content   string
+	Diff      string // The file's hunks
+	Functions []Function
+	TestPath  string
+	Tests     string // Test file contents before transmute, empty if new
+	Command   string // Runs the test file
+}
+
+// Supported reports whether transmute can write tests for the source file
+// at path. Test and generated files are left out.
+func Supported(path string) bool {
+	lang := lsp.DetectLanguage(path)
+	if lang == nil || identify.IsTestFile(filepath.Base(path)) || diff.CategorizeFile(path) != diff.CategorySource {
+		return false
+	}
+	switch lang.Name {
+	case "go", "python", "typescript":
+		return true
+	}
+	return false
+}
+
+// Find reads the new side of fd, found under root, and maps its hunks to
+// the functions they change. newRev is where the new side lives, as in
+// diff.Options: diff.IndexRev for staged diffs, "HEAD" for branch diffs and
+// empty for the working tree. Go files are parsed with go/ast; other
+// languages need their language server.
+func Find(fd diff.FileDiff, root, newRev string) (*Target, error) {
+	if fd.IsDelete || fd.IsBinary || !Supported(fd.NewPath) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, fd.NewPath)
+	}
+	path, err := relative(filepath.Join(root, fd.NewPath))
+	if err != nil {
+		return nil, err
+	}
+	content, err := readNew(path, newRev)
+	if err != nil {
+		return nil, err
+	}
+
+	lang := lsp.DetectLanguage(path)
+	var symbols []lsp.DocumentSymbol
+	if lang.Name == "go" {
+		if symbols, err = goast.Symbols(content); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	} else if symbols, err = lsp.FileSymbols(context.Background(), path, content, lang); err != nil {
+		return nil, err
+	}
+
+	functions := ChangedFunctions(fd.Hunks, symbols)
+	if lang.Name == "go" {
+		functions = dropEntryPoints(functions)
+	}
+	if len(functions) == 0 {
+		return nil, fmt.Errorf("%w in %s", ErrNoFunctions, path)
+	}
+
+	t := &Target{
+		Path:      path,
+		Language:  lang.Name,
+		Content:   content,
+		Diff:      fileDiff(fd),
+		Functions: functions,
+		TestPath:  TestPath(path, lang.Name),
+	}
+	if tests, err := os.ReadFile(t.TestPath); err == nil {
+		t.Tests = string(tests)
+	}
+	t.Command = TestCommand(lang.Name, t.TestPath, root)
+	return t, nil
+}
+
+// readNew reads path at newRev, so the hunk lines match the content even
+// when the working tree has moved on from the diff.
+func readNew(path, newRev string) (string, error) {
+	switch newRev {
+	case "":
+		return identify.ReadFile(path)
+	case diff.IndexRev:
+		// git show :path reads the index
+		return identify.ReadFileAt("", path)
+	}
+	return identify.ReadFileAt(newRev, path)
+}
+
+// ChangedFunctions returns the functions and methods with lines added or
+// removed by hunks. Functions nested in another changed one are left to it.
+func ChangedFunctions(hunks []diff.Hunk, symbols []lsp.DocumentSymbol) []Function {
+	var touched []lsp.DocumentSymbol
+	for _, s := range symbols {
+		if !functionKinds[s.Kind] {
+			continue
+		}
+		for _, h := range hunks {
+			if touches(ChangedLines(h), s) {
+				touched = append(touched, s)
+				break
+			}
+		}
+	}
+
+	var functions []Function
+	for _, s := range touched {
+		nested := false
+		for _, outer := range touched {
+			if outer != s && outer.Line <= s.Line && s.EndLine <= outer.EndLine {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			functions = append(functions, Function{Name: s.Name, Kind: s.Kind, Line: s.Line, EndLine: s.EndLine})
+		}
+	}
+	return functions
+}
+
+// ChangedLines returns the 0-based lines of the new file that h adds, and
+// the lines its removals happened before.
+func ChangedLines(h diff.Hunk) []int {
+	var lines []int
+	line := h.NewStart - 1
+	for i, text := range strings.Split(strings.TrimSuffix(h.Content, "\n"), "\n") {
+		if i == 0 || text == "" {
+			// The @@ header, and the end of the content
+			continue
+		}
+		switch text[0] {
+		case '+':
+			lines = append(lines, line)
+			line++
+		case '-':
+			lines = append(lines, line)
+		case ' ':
+			line++
+		}
+	}
+	return lines
+}
+
+// TestPath returns where the tests of the source file at path belong: the
+// existing test file if there is one, otherwise the conventional one for
+// lang next to it.
+func TestPath(path, lang string) string {
+	dir, base := filepath.Dir(path), filepath.Base(path)
+	ext := filepath.Ext(base)
+	name := strings.TrimSuffix(base, ext)
+
+	var candidates []string
+	switch lang {
+	case "go":
+		candidates = []string{filepath.Join(dir, name+"_test.go")}
+	case "python":
+		candidates = []string{
+			filepath.Join(dir, "test_"+base),
+			filepath.Join(dir, name+"_test.py"),
+			filepath.Join(dir, "tests", "test_"+base),
+		}
+	default:
+		candidates = []string{
+			filepath.Join(dir, name+".test"+ext),
+			filepath.Join(dir, name+".spec"+ext),
+			filepath.Join(dir, "__tests__", name+".test"+ext),
+		}
+	}
+	for _, c := range candidates {
+		if _, err := os.Stat(c); err == nil {
+			return c
+		}
+	}
+	return candidates[0]
+}
+
+// TestCommand returns the command that runs the tests in testPath. Go tests
+// run for the whole package, which must build; TypeScript tests run with
+// vitest if the project at root depends on it, jest otherwise.
+func TestCommand(lang, testPath, root string) string {
+	switch lang {
+	case "go":
+		dir := filepath.ToSlash(filepath.Dir(testPath))
+		if dir != "." && !strings.HasPrefix(dir, "/") && !strings.HasPrefix(dir, "../") {
+			dir = "./" + dir
+		}
+		return "go test " + dir
+	case "python":
+		return "python -m pytest -q " + testPath
+	default:
+		if pkg, err := os.ReadFile(filepath.Join(root, "package.json")); err == nil && strings.Contains(string(pkg), `"vitest"`) {
+			return "npx vitest run " + testPath
+		}
+		return "npx jest " + testPath
+	}
+}
+
+// Generate asks Claude for the test file of t. current is the test file as
+// it is now, failure the run of the previous attempt and feedback what was
+// wrong with it, both empty on the first attempt. A response that drops
+// tests t already had is returned along with the error, so it can be fed
+// back.
+func Generate(model claude.Model, t *Target, current string, failure *augury.Result, feedback string) (string, error) {
+	text, err := Prompt(model, t, current, failure, feedback)
+	if err != nil {
+		return "", err
+	}
+	response, err := claude.Run(model, "transmute", text)
+	if err != nil {
+		return "", err
+	}
+
+	tests := strings.TrimSpace(textutil.StripCodeBlock(strings.TrimSpace(response)))
+	if tests == "" {
+		return "", fmt.Errorf("no tests in the response for %s", t.Path)
+	}
+	tests += "\n"
+	if missing := missingTests(t.Language, t.Tests, tests); len(missing) > 0 {
+		return tests, fmt.Errorf("existing tests were dropped: %s", strings.Join(missing, ", "))
+	}
+	return tests, nil
+}
+
+// Prompt renders the transmute prompt for t without sending it.
+func Prompt(model claude.Model, t *Target, current string, failure *augury.Result, feedback string) (string, error) {
+	tmpl, err := prompt.Load("transmute")
+	if err != nil {
+		return "", err
+	}
+
+	var functions strings.Builder
+	for _, f := range t.Functions {
+		fmt.Fprintf(&functions, "- %s (%s, lines %d-%d)\n", f.Name, strings.ToLower(f.Kind), f.Line+1, f.EndLine+1)
+	}
+	data := prompt.Data{
+		Path:        t.Path,
+		Language:    t.Language,
+		Symbols:     functions.String(),
+		Diff:        t.Diff,
+		Code:        t.Content,
+		TestPath:    t.TestPath,
+		Tests:       current,
+		Command:     t.Command,
+		Description: feedback,
+	}
+	if failure != nil {
+		data.ExitCode = failure.ExitCode
+		data.Stdout = failure.Stdout
+		data.Stderr = failure.Stderr
+		data.Errors = augury.FormatDiagnostics(augury.ParseDiagnostics(failure.Stderr + failure.Stdout))
+	}
+	return tmpl.Render(model, data)
+}
+
+// TestNames lists the tests defined in the contents of a test file.
+func TestNames(lang, tests string) []string {
+	var names []string
+	switch lang {
+	case "go":
+		symbols, _ := goast.Symbols(tests)
+		for _, s := range symbols {
+			if s.Kind == "Function" && strings.HasPrefix(s.Name, "Test") {
+				names = append(names, s.Name)
+			}
+		}
+	case "python":
+		for _, m := range pyTestRe.FindAllStringSubmatch(tests, -1) {
+			names = append(names, m[1])
+		}
+	default:
+		for _, m := range tsTestRe.FindAllStringSubmatch(tests, -1) {
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// FailedTests returns the tests that failed in result, by the name they are
+// defined with. It reports false when the failures cannot be pinned on
+// single tests, like when the tests do not compile or cannot be collected.
+func FailedTests(lang string, result *augury.Result) ([]string, bool) {
+	output := result.Stderr + result.Stdout
+	switch lang {
+	case "go":
+		if strings.Contains(output, "[build failed]") || strings.Contains(output, "[setup failed]") {
+			return nil, false
+		}
+	case "python":
+		if strings.Contains(output, "during collection") || strings.Contains(output, "ERROR collecting") {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, d := range augury.ParseDiagnostics(output) {
+		if d.Test == "" {
+			continue
+		}
+		name := d.Test
+		if lang == "go" {
+			// Subtests fail along with their parent
+			name, _, _ = strings.Cut(name, "/")
+		} else {
+			// pytest names tests path::Class::test[params]
+			if i := strings.LastIndex(name, "::"); i >= 0 {
+				name = name[i+2:]
+			}
+			name, _, _ = strings.Cut(name, "[")
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names, len(names) > 0
+}
+
+// Prune removes the named tests from the contents of a test file.
+func Prune(lang, tests string, names []string) (string, error) {
+	switch lang {
+	case "go":
+		return goast.RemoveFuncs(tests, names)
+	case "python":
+		return removePythonFuncs(tests, names), nil
+	}
+	return "", fmt.Errorf("%w: cannot remove single %s tests", ErrUnsupported, lang)
+}
+
+// removePythonFuncs deletes the named functions, with their decorators,
+// and the blank lines after them.
+func removePythonFuncs(src string, names []string) string {
+	remove := make(map[string]bool, len(names))
+	for _, name := range names {
+		remove[name] = true
+	}
+
+	lines := strings.Split(src, "\n")
+	var kept []string
+	for i := 0; i < len(lines); i++ {
+		m := pyDefRe.FindStringSubmatch(lines[i])
+		if m == nil || !remove[m[2]] {
+			kept = append(kept, lines[i])
+			continue
+		}
+		indent := m[1]
+		for len(kept) > 0 && strings.HasPrefix(kept[len(kept)-1], indent+"@") {
+			kept = kept[:len(kept)-1]
+		}
+		// Skip the rest of the signature, then the body
+		for i < len(lines)-1 && !strings.HasSuffix(strings.TrimSpace(textutil.StripHashComment(lines[i])), ":") {
+			i++
+		}
+		for i+1 < len(lines) && (strings.TrimSpace(lines[i+1]) == "" || len(textutil.Indentation(lines[i+1])) > len(indent)) {
+			i++
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// missingTests returns the tests of before that after no longer has.
+func missingTests(lang, before, after string) []string {
+	have := make(map[string]bool)
+	for _, name := range TestNames(lang, after) {
+		have[name] = true
+	}
+	var missing []string
+	for _, name := range TestNames(lang, before) {
+		if !have[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// touches reports whether any of lines falls within the symbol.
+func touches(lines []int, s lsp.DocumentSymbol) bool {
+	for _, line := range lines {
+		if s.Line <= line && line <= s.EndLine {
+			return true
+		}
+	}
+	return false
+}
+
+// dropEntryPoints leaves out main and init, which tests cannot call.
+func dropEntryPoints(functions []Function) []Function {
+	var kept []Function
+	for _, f := range functions {
+		if f.Name != "main" && f.Name != "init" {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// fileDiff renders the hunks of fd as a diff of that file alone.
+func fileDiff(fd diff.FileDiff) string {
+	var sb strings.Builder
+	old := "a/" + fd.OldPath
+	if fd.IsNew {
+		old = "/dev/null"
+	}
+	fmt.Fprintf(&sb, "--- %s\n+++ b/%s\n", old, fd.NewPath)
+	for _, h := range fd.Hunks {
+		sb.WriteString(h.Content)
+	}
+	return sb.String()
+}
+
+// relative returns path relative to the working directory, where commands
+// run, if it is inside it.
+func relative(path string) (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(wd, path)
+	if err != nil {
+		return path, nil
+	}
+	return rel, nil
+}
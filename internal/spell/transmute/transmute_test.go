@@ -0,0 +1,325 @@
+package transmute
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/emiliopalmerini/grimorio/internal/claude"
+	"github.com/emiliopalmerini/grimorio/internal/diff"
+	"github.com/emiliopalmerini/grimorio/internal/lsp"
+	"github.com/emiliopalmerini/grimorio/internal/spell/augury"
+)
+
+func TestChangedLines(t *testing.T) {
+	h := diff.Hunk{
+		NewStart: 10,
+		Content:  "@@ -10,5 +10,5 @@ func a() {\n ctx\n-old\n+new\n+added\n ctx\n-gone\n",
+	}
+	want := []int{10, 10, 11, 13}
+	if got := ChangedLines(h); !reflect.DeepEqual(got, want) {
+		t.Errorf("ChangedLines() = %v, want %v", got, want)
+	}
+}
+
+func TestChangedFunctions(t *testing.T) {
+	symbols := []lsp.DocumentSymbol{
+		{Name: "Parser", Kind: "Class", Line: 0, EndLine: 30},
+		{Name: "parse", Kind: "Method", Line: 2, EndLine: 10},
+		{Name: "inner", Kind: "Function", Line: 4, EndLine: 6},
+		{Name: "helper", Kind: "Method", Line: 12, EndLine: 20},
+		{Name: "render", Kind: "Method", Line: 22, EndLine: 28},
+	}
+	hunks := []diff.Hunk{
+		{NewStart: 6, Content: "@@ -6,1 +6,1 @@\n-a\n+b\n"},
+		{NewStart: 24, Content: "@@ -24,2 +24,1 @@\n x\n-y\n"},
+	}
+	want := []Function{
+		{Name: "parse", Kind: "Method", Line: 2, EndLine: 10},
+		{Name: "render", Kind: "Method", Line: 22, EndLine: 28},
+	}
+	if got := ChangedFunctions(hunks, symbols); !reflect.DeepEqual(got, want) {
+		t.Errorf("ChangedFunctions() = %+v, want %+v", got, want)
+	}
+}
+
+func TestTestPath(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.MkdirAll("pkg/tests", 0o755)
+	os.WriteFile("pkg/tests/test_util.py", nil, 0o644)
+
+	tests := []struct {
+		path, lang, want string
+	}{
+		{"internal/diff/parser.go", "go", "internal/diff/parser_test.go"},
+		{"pkg/math.py", "python", "pkg/test_math.py"},
+		{"pkg/util.py", "python", "pkg/tests/test_util.py"},
+		{"src/app.ts", "typescript", "src/app.test.ts"},
+		{"src/View.tsx", "typescript", "src/View.test.tsx"},
+	}
+	for _, tt := range tests {
+		if got := TestPath(tt.path, tt.lang); got != filepath.FromSlash(tt.want) {
+			t.Errorf("TestPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestTestCommand(t *testing.T) {
+	root := t.TempDir()
+	tests := []struct {
+		lang, testPath, want string
+	}{
+		{"go", "internal/diff/parser_test.go", "go test ./internal/diff"},
+		{"go", "main_test.go", "go test ."},
+		{"go", "../other/x_test.go", "go test ../other"},
+		{"python", "pkg/test_math.py", "python -m pytest -q pkg/test_math.py"},
+		{"typescript", "src/app.test.ts", "npx jest src/app.test.ts"},
+	}
+	for _, tt := range tests {
+		if got := TestCommand(tt.lang, tt.testPath, root); got != tt.want {
+			t.Errorf("TestCommand(%s, %q) = %q, want %q", tt.lang, tt.testPath, got, tt.want)
+		}
+	}
+
+	os.WriteFile(filepath.Join(root, "package.json"), []byte(`{"devDependencies": {"vitest": "^2.0.0"}}`), 0o644)
+	if got, want := TestCommand("typescript", "src/app.test.ts", root), "npx vitest run src/app.test.ts"; got != want {
+		t.Errorf("TestCommand() with vitest = %q, want %q", got, want)
+	}
+}
+
+func TestFailedTests(t *testing.T) {
+	tests := []struct {
+		name   string
+		lang   string
+		output string
+		want   []string
+		ok     bool
+	}{
+		{
+			name: "go",
+			lang: "go",
+			output: `--- FAIL: TestParse (0.00s)
+    --- FAIL: TestParse/empty (0.00s)
+        parser_test.go:12: got 1, want 0
+--- FAIL: TestRender (0.00s)
+    render_test.go:30: mismatch
+FAIL
+FAIL	example.com/pkg	0.01s`,
+			want: []string{"TestParse", "TestRender"},
+			ok:   true,
+		},
+		{
+			name:   "go build",
+			lang:   "go",
+			output: "# example.com/pkg\n./parser_test.go:5:2: undefined: x\nFAIL\texample.com/pkg [build failed]",
+		},
+		{
+			name: "pytest",
+			lang: "python",
+			output: `FAILED tests/test_math.py::test_add[1-2] - assert 4 == 3
+FAILED tests/test_math.py::TestDiv::test_zero - ZeroDivisionError`,
+			want: []string{"test_add", "test_zero"},
+			ok:   true,
+		},
+		{
+			name:   "pytest collection",
+			lang:   "python",
+			output: "ERROR collecting tests/test_math.py\n1 error during collection",
+		},
+		{
+			name:   "typescript",
+			lang:   "typescript",
+			output: "FAIL src/app.test.ts",
+		},
+	}
+	for _, tt := range tests {
+		got, ok := FailedTests(tt.lang, &augury.Result{Stdout: tt.output, ExitCode: 1})
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: FailedTests() = %v, %v, want %v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPrunePython(t *testing.T) {
+	src := `import pytest
+
+from math_utils import add
+
+
+@pytest.mark.parametrize("a,b,want", [(1, 2, 3)])
+def test_add(a, b, want):
+    assert add(a, b) == want
+
+
+def test_sub(
+    capsys,
+):
+    assert True
+
+
+class TestDiv:
+    def test_zero(self):
+        with pytest.raises(ZeroDivisionError):
+            div(1, 0)
+
+    def test_one(self):
+        assert div(1, 1) == 1
+`
+	want := `import pytest
+
+from math_utils import add
+
+
+@pytest.mark.parametrize("a,b,want", [(1, 2, 3)])
+def test_add(a, b, want):
+    assert add(a, b) == want
+
+
+class TestDiv:
+    def test_one(self):
+        assert div(1, 1) == 1
+`
+	got, err := Prune("python", src, []string{"test_sub", "test_zero"})
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if got != want {
+		t.Errorf("Prune() =\n%s\nwant\n%s", got, want)
+	}
+
+	if _, err := Prune("typescript", "", []string{"adds"}); err == nil {
+		t.Error("Prune(typescript) succeeded, want an error")
+	}
+}
+
+func TestTestNames(t *testing.T) {
+	tests := []struct {
+		lang, src string
+		want      []string
+	}{
+		{"go", "package x\n\nfunc TestA(t *testing.T) {}\n\nfunc helper() {}\n\nfunc TestB(t *testing.T) {}\n", []string{"TestA", "TestB"}},
+		{"python", "def test_a():\n    pass\n\nclass TestX:\n    async def test_b(self):\n        pass\n\ndef helper():\n    pass\n", []string{"test_a", "test_b"}},
+		{"typescript", "describe('add', () => {\n  it('adds', () => {})\n  test(\"subtracts\", () => {})\n})\n", []string{"adds", "subtracts"}},
+	}
+	for _, tt := range tests {
+		if got := TestNames(tt.lang, tt.src); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("TestNames(%s) = %v, want %v", tt.lang, got, tt.want)
+		}
+	}
+
+	if got := missingTests("python", "def test_a():\n    pass\ndef test_b():\n    pass\n", "def test_b():\n    pass\n"); !reflect.DeepEqual(got, []string{"test_a"}) {
+		t.Errorf("missingTests() = %v, want [test_a]", got)
+	}
+}
+
+func TestFindGo(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if out, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	os.MkdirAll("calc", 0o755)
+	src := `package calc
+
+func Add(a, b int) int {
+	return a + b
+}
+
+func Sub(a, b int) int {
+	return a - b
+}
+
+func main() {
+	println(Add(1, 2))
+}
+`
+	os.WriteFile("calc/calc.go", []byte(src), 0o644)
+	os.WriteFile("calc/calc_test.go", []byte("package calc\n\nfunc TestAdd(t *testing.T) {}\n"), 0o644)
+
+	fd := diff.Parse(`diff --git a/calc/calc.go b/calc/calc.go
+--- a/calc/calc.go
++++ b/calc/calc.go
+@@ -6,6 +6,6 @@ func Add(a, b int) int {
+
+ func Sub(a, b int) int {
+-	return a + b
++	return a - b
+ }
+
+ func main() {
+@@ -11,3 +11,3 @@ func main() {
+-	println(Add(1, 1))
++	println(Add(1, 2))
+ }
+`)[0]
+
+	target, err := Find(fd, dir, "")
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	want := []Function{{Name: "Sub", Kind: "Function", Line: 6, EndLine: 8}}
+	if !reflect.DeepEqual(target.Functions, want) {
+		t.Errorf("Find() functions = %+v, want %+v", target.Functions, want)
+	}
+	if target.TestPath != filepath.Join("calc", "calc_test.go") || target.Command != "go test ./calc" {
+		t.Errorf("Find() test path = %q, command = %q", target.TestPath, target.Command)
+	}
+	if !strings.Contains(target.Tests, "TestAdd") {
+		t.Errorf("Find() did not read the existing tests: %q", target.Tests)
+	}
+
+	text, err := Prompt(claude.Sonnet, target, target.Tests, &augury.Result{ExitCode: 1, Stdout: "--- FAIL: TestSub (0.00s)"}, "They fail when run.")
+	if err != nil {
+		t.Fatalf("Prompt() error = %v", err)
+	}
+	for _, part := range []string{"- Sub (function, lines 7-9)", "go test ./calc", "They fail when run.", "TestSub", "Current calc/calc_test.go:"} {
+		if !strings.Contains(text, part) {
+			t.Errorf("Prompt() is missing %q", part)
+		}
+	}
+
+	if _, err := Find(diff.FileDiff{NewPath: "calc/calc_test.go"}, dir, ""); err == nil {
+		t.Error("Find() accepted a test file")
+	}
+}
+
+func TestFindStaged(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	run := func(args ...string) string {
+		t.Helper()
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return string(out)
+	}
+	run("init", "-q")
+	run("config", "user.email", "dev@example.com")
+	run("config", "user.name", "dev")
+	os.WriteFile("calc.go", []byte("package calc\n\nfunc Add(a, b int) int {\n\treturn a + b\n}\n\nfunc Sub(a, b int) int {\n\treturn a + b\n}\n"), 0o644)
+	run("add", "-A")
+	run("commit", "-q", "-m", "base")
+
+	// Staged: Sub is fixed. Unstaged: a function is added above Add,
+	// shifting everything down in the working tree.
+	os.WriteFile("calc.go", []byte("package calc\n\nfunc Add(a, b int) int {\n\treturn a + b\n}\n\nfunc Sub(a, b int) int {\n\treturn a - b\n}\n"), 0o644)
+	run("add", "-A")
+	os.WriteFile("calc.go", []byte("package calc\n\nfunc Neg(a int) int {\n\treturn -a\n}\n\nfunc Add(a, b int) int {\n\treturn a + b\n}\n\nfunc Sub(a, b int) int {\n\treturn a - b\n}\n"), 0o644)
+
+	fd := diff.Parse(run("diff", "--cached"))[0]
+	target, err := Find(fd, dir, diff.IndexRev)
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	want := []Function{{Name: "Sub", Kind: "Function", Line: 6, EndLine: 8}}
+	if !reflect.DeepEqual(target.Functions, want) {
+		t.Errorf("Find() functions = %+v, want %+v", target.Functions, want)
+	}
+	if strings.Contains(target.Content, "Neg") {
+		t.Error("Find() read the working tree instead of the index")
+	}
+}
@@ -11,3 +11,16 @@ func StripCodeBlock(s string) string {
 	}
 	return s
 }
+
+// Indentation returns the leading spaces and tabs of line.
+func Indentation(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// StripHashComment removes a trailing # comment from line.
+func StripHashComment(line string) string {
+	if i := strings.Index(line, "#"); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
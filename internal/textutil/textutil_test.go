@@ -54,3 +54,71 @@ func TestStripCodeBlock(t *testing.T) {
 		})
 	}
 }
+
+func TestIndentation(t *testing.T) {
+	for line, want := range map[string]string{
+		"":               "",
+		"def f():":       "",
+		"    return 1":   "    ",
+		"\t\t// comment": "\t\t",
+		"  \t x = 1  ":   "  \t ",
+	} {
+		if got := Indentation(line); got != want {
+			t.Errorf("Indentation(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
+
+func TestStripHashComment(t *testing.T) {
+	for line, want := range map[string]string{
+		"def f():  # entry point": "def f():  ",
+		"x = 1":                   "x = 1",
+		"# only a comment":        "",
+	} {
+		if got := StripHashComment(line); got != want {
+			t.Errorf("StripHashComment(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	before := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	after := "a\nx\nb\nc\nd\ne\nf\ng\nh\ni\nJ\n"
+
+	want := `--- a/f.go
++++ b/f.go
+@@ -1,4 +1,5 @@
+ a
++x
+ b
+ c
+ d
+@@ -7,4 +8,4 @@
+ g
+ h
+ i
+-j
++J
+`
+	if got := UnifiedDiff("f.go", before, after, 3); got != want {
+		t.Errorf("UnifiedDiff() =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := UnifiedDiff("f.go", before, before, 3); got != "" {
+		t.Errorf("UnifiedDiff() of equal files = %q, want empty", got)
+	}
+
+	if got, want := UnifiedDiff("f.go", "", "new\n", 3), "--- a/f.go\n+++ b/f.go\n@@ -0,0 +1 @@\n+new\n"; got != want {
+		t.Errorf("UnifiedDiff() of a new file = %q, want %q", got, want)
+	}
+}
+
+func TestUnifiedDiffMergesCloseChanges(t *testing.T) {
+	before := "1\n2\n3\n4\n5\n"
+	after := "1\nx\n2\n3\n4\ny\n5\n"
+
+	want := "--- a/f\n+++ b/f\n@@ -1,5 +1,7 @@\n 1\n+x\n 2\n 3\n 4\n+y\n 5\n"
+	if got := UnifiedDiff("f", before, after, 3); got != want {
+		t.Errorf("UnifiedDiff() =\n%s\nwant\n%s", got, want)
+	}
+}